@@ -1,42 +1,75 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/http"
-	"time"
+	"os"
 
+	"github.com/tassyosilva/GestGAS/internal/auth"
+	"github.com/tassyosilva/GestGAS/internal/bina"
+	"github.com/tassyosilva/GestGAS/internal/config"
 	"github.com/tassyosilva/GestGAS/internal/database"
 	"github.com/tassyosilva/GestGAS/internal/routes"
+	"github.com/tassyosilva/GestGAS/internal/webhook"
 )
 
 func main() {
+	// Carregar configuração (arquivo YAML e variáveis de ambiente)
+	cfg, err := config.Carregar()
+	if err != nil {
+		log.Fatalf("Erro ao carregar configuração: %v", err)
+	}
+	auth.Configurar(cfg.Auth)
+
 	// Conectar ao banco de dados
-	db, err := database.Conectar()
+	db, err := database.Conectar(cfg.Banco)
 	if err != nil {
 		log.Fatalf("Erro na conexão com o banco de dados: %v", err)
 	}
 	defer db.Close()
 	
-	// Inicializar o banco de dados (criar tabelas se não existirem)
+	// Subcomando "migrate": gerencia as migrações e encerra sem iniciar o servidor
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := executarMigrate(db, os.Args[2:]); err != nil {
+			log.Fatalf("Erro ao executar migrações: %v", err)
+		}
+		return
+	}
+	
+	// Inicializar o banco de dados (aplicar migrações pendentes e dados iniciais)
 	if err := database.InicializarBancoDados(db); err != nil {
 		log.Fatalf("Erro ao inicializar o banco de dados: %v", err)
 	}
 	
+	// Central de chamadas do bina e, se configurado, o receptor TCP dos dispositivos
+	central := bina.NovaCentral(db)
+	if cfg.Bina.EnderecoTCP != "" {
+		go func() {
+			if err := bina.Escutar(cfg.Bina.EnderecoTCP, cfg.Bina.OrigensTCP, central); err != nil {
+				log.Printf("Receptor de chamadas do bina encerrado: %v", err)
+			}
+		}()
+	}
+	
+	// Despachante dos webhooks: distribui os eventos da outbox e faz as entregas pendentes
+	go webhook.NovoDespachante(db).Executar(context.Background())
+	
 	// Configurar rotas
-	handler := routes.ConfigurarRotas(db)
+	handler := routes.ConfigurarRotas(db, cfg, central)
 	
 	// Configurar o servidor
 	server := &http.Server{
-		Addr:         ":8080",
+		Addr:         cfg.Servidor.Endereco,
 		Handler:      handler,
-		ReadTimeout:  10 * time.Second,
-		WriteTimeout: 10 * time.Second,
-		IdleTimeout:  120 * time.Second,
+		ReadTimeout:  cfg.Servidor.ReadTimeout,
+		WriteTimeout: cfg.Servidor.WriteTimeout,
+		IdleTimeout:  cfg.Servidor.IdleTimeout,
 	}
 	
 	// Iniciar o servidor
-	fmt.Printf("Servidor rodando na porta %s\n", server.Addr)
+	fmt.Printf("Servidor rodando em %s\n", server.Addr)
 	if err := server.ListenAndServe(); err != nil {
 		log.Fatalf("Falha ao iniciar o servidor: %v", err)
 	}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/tassyosilva/GestGAS/internal/database"
+)
+
+const usoMigrate = "uso: gestgas migrate up|down [N]|status"
+
+// executarMigrate trata o subcomando "migrate", aplicando ou revertendo migrações sem iniciar o servidor
+func executarMigrate(db *sql.DB, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf(usoMigrate)
+	}
+
+	switch args[0] {
+	case "up":
+		aplicadas, err := database.MigrarParaCima(db)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%d migração(ões) aplicada(s)\n", aplicadas)
+	case "down":
+		passos := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n <= 0 {
+				return fmt.Errorf("número de migrações inválido: %s", args[1])
+			}
+			passos = n
+		}
+		revertidas, err := database.MigrarParaBaixo(db, passos)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%d migração(ões) revertida(s)\n", revertidas)
+	case "status":
+		estados, semVersao, err := database.StatusMigracoes(db)
+		if err != nil {
+			return err
+		}
+		if semVersao {
+			fmt.Println("Instalação anterior ao controle de versões detectada: será marcada na versão do esquema inicial no próximo migrate up")
+		}
+		for _, e := range estados {
+			situacao := "pendente"
+			if e.Aplicada {
+				situacao = "aplicada em " + e.AplicadaEm.Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(os.Stdout, "%04d  %-40s %s\n", e.Versao, e.Nome, situacao)
+		}
+	default:
+		return fmt.Errorf(usoMigrate)
+	}
+	return nil
+}
@@ -7,4 +7,8 @@ require (
 	golang.org/x/crypto v0.19.0
 )
 
-require github.com/golang-jwt/jwt v3.2.2+incompatible // indirect
+require github.com/golang-jwt/jwt v3.2.2+incompatible
+
+require gopkg.in/yaml.v3 v3.0.1
+
+require github.com/skip2/go-qrcode v0.0.0-20200617195104-da1b6568686e
@@ -2,30 +2,63 @@ package auth
 
 import (
 	"fmt"
+	"sync"
 	"time"
-	"os"
 
 	"github.com/golang-jwt/jwt"
+	"github.com/tassyosilva/GestGAS/internal/config"
 	"golang.org/x/crypto/bcrypt"
 )
 
-// Chave secreta para assinar os JWT
-// Em produção, isso deve ser carregado a partir de uma variável de ambiente
-var jwtKey = []byte(getEnv("JWT_SECRET", "sua_chave_secreta_super_segura"))
+// Chave secreta para assinar os JWT e validade dos tokens, definidas por Configurar na inicialização
+var (
+	jwtKey          []byte
+	validadeToken   = 15 * time.Minute
+	validadeRefresh = 30 * 24 * time.Hour
+)
+
+// Tokens de stream só servem para abrir as conexões de eventos (Server-Sent Events), que o
+// navegador abre sem poder enviar o cabeçalho Authorization. Valem pouco tempo: a conexão
+// aberta continua sendo conferida contra a sessão a cada ping.
+const (
+	audienciaStream     = "gestgas-stream"
+	validadeTokenStream = time.Minute
+)
 
 // Claims é a estrutura que vai dentro do token JWT
 type Claims struct {
-	UserID int    `json:"user_id"`
-	Perfil string `json:"perfil"`
+	UserID   int    `json:"user_id"`
+	Perfil   string `json:"perfil"`
+	SessaoID int    `json:"sid"`
 	jwt.StandardClaims
 }
 
-// Função auxiliar para obter variáveis de ambiente com valor padrão
-func getEnv(key, fallback string) string {
-	if value, exists := os.LookupEnv(key); exists {
-		return value
-	}
-	return fallback
+// hashFicticio é comparado com a senha quando o login não existe, para que a resposta demore o
+// mesmo que a de uma senha incorreta e não revele quais logins existem
+var (
+	hashFicticio     []byte
+	hashFicticioOnce sync.Once
+)
+
+// Configurar define a chave de assinatura, a validade dos tokens emitidos e a política de senhas
+func Configurar(cfg config.Auth) {
+	jwtKey = []byte(cfg.JWTSecret)
+	validadeToken = cfg.TokenTTL
+	validadeRefresh = cfg.RefreshTTL
+	tamanhoMinimoSenha = cfg.SenhaTamanhoMinimo
+
+	// Gerar o hash fictício já na inicialização, e não no primeiro login inexistente
+	gerarHashFicticio()
+}
+
+// ValidadeToken retorna por quanto tempo um token de acesso recém-emitido é válido
+func ValidadeToken() time.Duration {
+	return validadeToken
+}
+
+// ValidadeRefresh retorna por quanto tempo uma sessão pode ser renovada sem novo login
+func ValidadeRefresh() time.Duration {
+	return validadeRefresh
 }
 
 // HashSenha gera um hash bcrypt da senha fornecida
@@ -43,19 +76,52 @@ func VerificarSenha(senha, hashArmazenado string) bool {
 	return err == nil
 }
 
-// GerarToken gera um token JWT para autenticação
-func GerarToken(userID int, perfil string) (string, error) {
-	// Define a expiração do token (24 horas)
-	expirationTime := time.Now().Add(24 * time.Hour)
+// SimularVerificacaoSenha faz a mesma comparação bcrypt de VerificarSenha contra um hash fictício.
+// Deve ser chamada quando o login não existe; o resultado é sempre falso.
+func SimularVerificacaoSenha(senha string) {
+	gerarHashFicticio()
+	bcrypt.CompareHashAndPassword(hashFicticio, []byte(senha))
+}
+
+func gerarHashFicticio() {
+	hashFicticioOnce.Do(func() {
+		// Mesmo custo de HashSenha, para que a comparação leve o mesmo tempo. Com custo e
+		// senha fixos e válidos, a geração não falha.
+		hashFicticio, _ = bcrypt.GenerateFromPassword([]byte("gestgas-login-inexistente"), bcrypt.DefaultCost)
+	})
+}
+
+// GerarToken gera um token JWT de acesso vinculado a uma sessão
+func GerarToken(userID int, perfil string, sessaoID int) (string, error) {
+	return assinarToken(userID, perfil, sessaoID, validadeToken, "")
+}
+
+// GerarTokenStream gera um token de stream vinculado à sessão, para ser enviado na URL das
+// conexões de eventos. Ele não é aceito como token de acesso nas demais rotas.
+func GerarTokenStream(userID int, perfil string, sessaoID int) (string, time.Time, error) {
+	expira := time.Now().Add(validadeTokenStream)
+	token, err := assinarToken(userID, perfil, sessaoID, validadeTokenStream, audienciaStream)
+	return token, expira, err
+}
+
+func assinarToken(userID int, perfil string, sessaoID int, validade time.Duration, audiencia string) (string, error) {
+	if len(jwtKey) == 0 {
+		return "", fmt.Errorf("chave de assinatura JWT não configurada")
+	}
+
+	// Define a expiração do token conforme a validade pedida
+	expirationTime := time.Now().Add(validade)
 	
 	// Cria o payload do token (claims)
 	claims := &Claims{
-		UserID: userID,
-		Perfil: perfil,
+		UserID:   userID,
+		Perfil:   perfil,
+		SessaoID: sessaoID,
 		StandardClaims: jwt.StandardClaims{
 			ExpiresAt: expirationTime.Unix(),
 			IssuedAt:  time.Now().Unix(),
 			Issuer:    "gestgas-api",
+			Audience:  audiencia,
 		},
 	}
 	
@@ -71,8 +137,35 @@ func GerarToken(userID int, perfil string) (string, error) {
 	return tokenString, nil
 }
 
-// ValidarToken valida um token JWT e retorna os claims se válido
+// ValidarToken valida um token JWT de acesso e retorna os claims se válido
 func ValidarToken(tokenString string) (*Claims, error) {
+	claims, err := lerToken(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Audience != "" {
+		return nil, fmt.Errorf("token não é de acesso")
+	}
+	return claims, nil
+}
+
+// ValidarTokenStream valida um token de stream e retorna os claims se válido
+func ValidarTokenStream(tokenString string) (*Claims, error) {
+	claims, err := lerToken(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Audience != audienciaStream {
+		return nil, fmt.Errorf("token não é de stream")
+	}
+	return claims, nil
+}
+
+func lerToken(tokenString string) (*Claims, error) {
+	if len(jwtKey) == 0 {
+		return nil, fmt.Errorf("chave de assinatura JWT não configurada")
+	}
+
 	// Parseia o token
 	claims := &Claims{}
 	
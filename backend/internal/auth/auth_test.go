@@ -0,0 +1,54 @@
+package auth
+
+import (
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestTokenStreamNaoValeComoAcesso(t *testing.T) {
+	jwtKey = []byte("chave-de-teste-com-16+")
+
+	acesso, err := GerarToken(7, "atendente", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream, _, err := GerarTokenStream(7, "atendente", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ValidarToken(stream); err == nil {
+		t.Error("o token de stream não pode ser aceito como token de acesso")
+	}
+	if _, err := ValidarTokenStream(acesso); err == nil {
+		t.Error("o token de acesso não pode ser aceito na URL do stream")
+	}
+
+	claims, err := ValidarTokenStream(stream)
+	if err != nil {
+		t.Fatalf("token de stream recusado: %v", err)
+	}
+	if claims.UserID != 7 || claims.SessaoID != 3 || claims.Perfil != "atendente" {
+		t.Errorf("claims inesperados: %+v", claims)
+	}
+	if _, err := ValidarToken(acesso); err != nil {
+		t.Errorf("token de acesso recusado: %v", err)
+	}
+}
+
+func TestSimularVerificacaoSenhaUsaMesmoCusto(t *testing.T) {
+	SimularVerificacaoSenha("qualquer")
+
+	custoFicticio, err := bcrypt.Cost(hashFicticio)
+	if err != nil {
+		t.Fatalf("hash fictício inválido: %v", err)
+	}
+	hash, err := HashSenha("outra senha")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if custo, _ := bcrypt.Cost([]byte(hash)); custo != custoFicticio {
+		t.Fatalf("custo do hash fictício = %d, esperado %d (o mesmo das senhas reais)", custoFicticio, custo)
+	}
+}
@@ -0,0 +1,359 @@
+package auth
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Tipos de chave controlados pelo limitador de tentativas de login
+const (
+	ChaveLogin = "login"
+	ChaveIP    = "ip"
+)
+
+// registrosMaximos limita a memória usada pelo limitador. Ao atingi-lo, os registros vencidos são
+// descartados e, se não bastar, os mais antigos, deixando os bloqueios por último.
+const registrosMaximos = 10000
+
+// registrosAposDescarte é até onde o descarte reduz o mapa, para não repeti-lo a cada nova falha
+const registrosAposDescarte = registrosMaximos * 9 / 10
+
+// Politica define quando as tentativas de login passam a ser atrasadas e bloqueadas
+type Politica struct {
+	FalhasParaAtraso    int           // Falhas seguidas a partir das quais cada nova tentativa precisa esperar
+	AtrasoInicial       time.Duration // Espera após a primeira falha com atraso; dobra a cada falha seguinte
+	AtrasoMaximo        time.Duration
+	FalhasBloqueioLogin int // Falhas seguidas do mesmo login que bloqueiam o login
+	FalhasBloqueioIP    int // Falhas seguidas do mesmo IP (em qualquer login) que bloqueiam o IP
+	DuracaoBloqueio     time.Duration
+	Janela              time.Duration // Sem falhas por esse tempo, a contagem recomeça
+}
+
+// PoliticaPadrao atrasa a partir da 3ª falha seguida, bloqueia o login na 10ª e o IP na 30ª, por 15 minutos
+func PoliticaPadrao() Politica {
+	return Politica{
+		FalhasParaAtraso:    3,
+		AtrasoInicial:       time.Second,
+		AtrasoMaximo:        30 * time.Second,
+		FalhasBloqueioLogin: 10,
+		FalhasBloqueioIP:    30,
+		DuracaoBloqueio:     15 * time.Minute,
+		Janela:              15 * time.Minute,
+	}
+}
+
+// Restricao descreve um login ou IP que está bloqueado ou precisa esperar antes de tentar de novo
+type Restricao struct {
+	Tipo        string    `json:"tipo"` // login ou ip
+	Valor       string    `json:"valor"`
+	Falhas      int       `json:"falhas"`
+	UltimaFalha time.Time `json:"ultima_falha"`
+	Bloqueado   bool      `json:"bloqueado"` // false quando há apenas atraso progressivo
+	LiberadoEm  time.Time `json:"liberado_em"`
+}
+
+type registroFalhas struct {
+	falhas       int
+	ultimaFalha  time.Time
+	bloqueadoAte time.Time
+	pendentes    int       // Tentativas reservadas cuja senha ainda está sendo conferida
+	reservadoEm  time.Time // Momento da última reserva
+}
+
+// Limitador conta as falhas de login seguidas por login e por IP de origem, em memória.
+// O IP é o da conexão: atrás de um proxy reverso, todos os clientes dividem a mesma contagem por IP.
+type Limitador struct {
+	politica Politica
+	agora    func() time.Time
+
+	mu        sync.Mutex
+	registros map[string]*registroFalhas
+}
+
+// NovoLimitador cria um limitador com a política informada. agora permite substituir o relógio
+// nos testes; se nil, usa time.Now.
+func NovoLimitador(p Politica, agora func() time.Time) *Limitador {
+	if agora == nil {
+		agora = time.Now
+	}
+	return &Limitador{politica: p, agora: agora, registros: map[string]*registroFalhas{}}
+}
+
+// Tentativa é uma tentativa de login reservada por Reservar. Ela deve ser concluída com Falhou ou
+// Sucesso depois de conferir a senha; Cancelar libera a reserva sem contar nada e pode ser
+// chamado com defer, pois não tem efeito depois da conclusão.
+type Tentativa struct {
+	l         *Limitador
+	login, ip string
+	concluida bool
+}
+
+// Reservar verifica se o login, a partir desse IP, pode ser tentado e, se puder, reserva a tentativa
+// sob o mesmo bloqueio. As tentativas reservadas contam como falhas até serem concluídas, de modo que
+// requisições paralelas não passem do limite antes de qualquer uma delas registrar a falha.
+// Retorna a espera restante (e nenhuma tentativa) quando a tentativa não é permitida.
+func (l *Limitador) Reservar(login, ip string) (*Tentativa, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	agora := l.agora()
+	if espera := l.espera(login, ip, agora); espera > 0 {
+		return nil, espera
+	}
+
+	l.abrirEspaco(agora)
+	for _, c := range []string{chave(ChaveLogin, login), chave(ChaveIP, ip)} {
+		r := l.registroVigente(c, agora)
+		if r == nil {
+			r = &registroFalhas{}
+			l.registros[c] = r
+		}
+		r.pendentes++
+		r.reservadoEm = agora
+	}
+	return &Tentativa{l: l, login: login, ip: ip}, 0
+}
+
+// Falhou conta uma senha incorreta (ou login inexistente) para o login e para o IP
+func (t *Tentativa) Falhou() {
+	t.concluir(func(agora time.Time) { t.l.contarFalha(t.login, t.ip, agora) })
+}
+
+// Sucesso zera as falhas do login. As do IP não são zeradas, para que um login válido
+// não sirva para liberar tentativas contra outros logins a partir do mesmo IP.
+func (t *Tentativa) Sucesso() {
+	t.concluir(func(time.Time) { t.l.zerarLogin(t.login) })
+}
+
+// Cancelar libera a reserva sem contar falha nem sucesso, por exemplo após um erro interno
+func (t *Tentativa) Cancelar() {
+	t.concluir(nil)
+}
+
+// concluir libera a reserva e aplica o resultado sob o mesmo bloqueio, para que nenhuma outra
+// reserva veja a tentativa já liberada e a falha ainda não contada
+func (t *Tentativa) concluir(resultado func(agora time.Time)) {
+	if t == nil || t.concluida {
+		return
+	}
+	t.concluida = true
+
+	l := t.l
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, c := range []string{chave(ChaveLogin, t.login), chave(ChaveIP, t.ip)} {
+		// O registro pode ter sido liberado pelo administrador enquanto a senha era conferida
+		if r, ok := l.registros[c]; ok && r.pendentes > 0 {
+			r.pendentes--
+		}
+	}
+	if resultado != nil {
+		resultado(l.agora())
+	}
+}
+
+// Verificar retorna quanto tempo ainda falta para o login, a partir desse IP, poder ser tentado
+// de novo; zero quando a tentativa é permitida. Não reserva a tentativa: para isso use Reservar.
+func (l *Limitador) Verificar(login, ip string) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.espera(login, ip, l.agora())
+}
+
+// espera calcula a espera do login e do IP contando as tentativas pendentes como falhas
+func (l *Limitador) espera(login, ip string, agora time.Time) time.Duration {
+	limites := map[string]int{
+		chave(ChaveLogin, login): l.politica.FalhasBloqueioLogin,
+		chave(ChaveIP, ip):       l.politica.FalhasBloqueioIP,
+	}
+	var espera time.Duration
+	for c, limite := range limites {
+		if r := l.registroVigente(c, agora); r != nil {
+			if e := l.liberadoEm(l.projetado(r, limite)).Sub(agora); e > espera {
+				espera = e
+			}
+		}
+	}
+	return espera
+}
+
+// projetado é o registro como ficaria se todas as tentativas pendentes falhassem no momento da reserva
+func (l *Limitador) projetado(r *registroFalhas, limite int) *registroFalhas {
+	if r.pendentes == 0 {
+		return r
+	}
+	p := *r
+	p.falhas += p.pendentes
+	if p.reservadoEm.After(p.ultimaFalha) {
+		p.ultimaFalha = p.reservadoEm
+	}
+	if limite > 0 && p.falhas >= limite && !p.bloqueadoAte.After(p.ultimaFalha) {
+		p.bloqueadoAte = p.ultimaFalha.Add(l.politica.DuracaoBloqueio)
+	}
+	return &p
+}
+
+// contarFalha conta uma falha para o login e para o IP; deve ser chamado com l.mu bloqueado
+func (l *Limitador) contarFalha(login, ip string, agora time.Time) {
+	l.abrirEspaco(agora)
+
+	limites := map[string]int{
+		chave(ChaveLogin, login): l.politica.FalhasBloqueioLogin,
+		chave(ChaveIP, ip):       l.politica.FalhasBloqueioIP,
+	}
+	for c, limite := range limites {
+		r := l.registroVigente(c, agora)
+		if r == nil {
+			r = &registroFalhas{}
+			l.registros[c] = r
+		}
+		r.falhas++
+		r.ultimaFalha = agora
+		if limite > 0 && r.falhas >= limite {
+			r.bloqueadoAte = agora.Add(l.politica.DuracaoBloqueio)
+		}
+	}
+}
+
+// zerarLogin apaga as falhas do login, mantendo as outras tentativas ainda pendentes;
+// deve ser chamado com l.mu bloqueado
+func (l *Limitador) zerarLogin(login string) {
+	c := chave(ChaveLogin, login)
+	r, ok := l.registros[c]
+	if !ok {
+		return
+	}
+	if r.pendentes == 0 {
+		delete(l.registros, c)
+		return
+	}
+	*r = registroFalhas{pendentes: r.pendentes, reservadoEm: r.reservadoEm}
+}
+
+// Restricoes lista os logins e IPs bloqueados ou em atraso progressivo, do que libera mais tarde ao mais cedo
+func (l *Limitador) Restricoes() []Restricao {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	agora := l.agora()
+	l.descartarVencidos(agora)
+
+	restricoes := []Restricao{}
+	for c, r := range l.registros {
+		liberadoEm := l.liberadoEm(r)
+		if !liberadoEm.After(agora) {
+			continue
+		}
+		tipo, valor, _ := strings.Cut(c, ":")
+		restricoes = append(restricoes, Restricao{
+			Tipo:        tipo,
+			Valor:       valor,
+			Falhas:      r.falhas,
+			UltimaFalha: r.ultimaFalha,
+			Bloqueado:   r.bloqueadoAte.After(agora),
+			LiberadoEm:  liberadoEm,
+		})
+	}
+	sort.Slice(restricoes, func(i, j int) bool {
+		return restricoes[i].LiberadoEm.After(restricoes[j].LiberadoEm)
+	})
+	return restricoes
+}
+
+// Liberar apaga as falhas de um login ou IP, encerrando bloqueio e atraso. Retorna false se não havia registro.
+func (l *Limitador) Liberar(tipo, valor string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	c := chave(tipo, valor)
+	if _, ok := l.registros[c]; !ok {
+		return false
+	}
+	delete(l.registros, c)
+	return true
+}
+
+// registroVigente retorna o registro da chave, descartando-o se o bloqueio acabou e a janela passou
+func (l *Limitador) registroVigente(c string, agora time.Time) *registroFalhas {
+	r, ok := l.registros[c]
+	if !ok {
+		return nil
+	}
+	if l.vencido(r, agora) {
+		delete(l.registros, c)
+		return nil
+	}
+	return r
+}
+
+func (l *Limitador) vencido(r *registroFalhas, agora time.Time) bool {
+	return r.pendentes == 0 && !r.bloqueadoAte.After(agora) && !r.ultimaFalha.Add(l.politica.Janela).After(agora)
+}
+
+func (l *Limitador) descartarVencidos(agora time.Time) {
+	for c, r := range l.registros {
+		if l.vencido(r, agora) {
+			delete(l.registros, c)
+		}
+	}
+}
+
+// abrirEspaco mantém o mapa abaixo de registrosMaximos antes de um novo registro: descarta os
+// vencidos e, se não bastar, os de atividade mais antiga, preservando os bloqueios enquanto possível
+func (l *Limitador) abrirEspaco(agora time.Time) {
+	if len(l.registros) < registrosMaximos {
+		return
+	}
+	l.descartarVencidos(agora)
+	if len(l.registros) < registrosMaximos {
+		return
+	}
+
+	chaves := make([]string, 0, len(l.registros))
+	for c := range l.registros {
+		chaves = append(chaves, c)
+	}
+	sort.Slice(chaves, func(i, j int) bool {
+		a, b := l.registros[chaves[i]], l.registros[chaves[j]]
+		if bloqueadoA, bloqueadoB := a.bloqueadoAte.After(agora), b.bloqueadoAte.After(agora); bloqueadoA != bloqueadoB {
+			return bloqueadoB
+		}
+		return ultimaAtividade(a).Before(ultimaAtividade(b))
+	})
+	for _, c := range chaves[:len(chaves)-registrosAposDescarte] {
+		delete(l.registros, c)
+	}
+}
+
+// ultimaAtividade é a falha ou a reserva mais recente do registro
+func ultimaAtividade(r *registroFalhas) time.Time {
+	if r.reservadoEm.After(r.ultimaFalha) {
+		return r.reservadoEm
+	}
+	return r.ultimaFalha
+}
+
+// liberadoEm é quando a próxima tentativa passa a ser aceita: o fim do bloqueio ou do atraso progressivo
+func (l *Limitador) liberadoEm(r *registroFalhas) time.Time {
+	if !r.bloqueadoAte.IsZero() && r.bloqueadoAte.After(r.ultimaFalha) {
+		return r.bloqueadoAte
+	}
+	if l.politica.FalhasParaAtraso <= 0 || r.falhas < l.politica.FalhasParaAtraso {
+		return r.ultimaFalha
+	}
+	atraso := l.politica.AtrasoInicial
+	for i := l.politica.FalhasParaAtraso; i < r.falhas && atraso < l.politica.AtrasoMaximo; i++ {
+		atraso *= 2
+	}
+	if atraso > l.politica.AtrasoMaximo {
+		atraso = l.politica.AtrasoMaximo
+	}
+	return r.ultimaFalha.Add(atraso)
+}
+
+// chave normaliza o login (sem diferenciar maiúsculas) e identifica o tipo
+func chave(tipo, valor string) string {
+	return tipo + ":" + strings.ToLower(strings.TrimSpace(valor))
+}
@@ -0,0 +1,298 @@
+package auth
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// relogio é um relógio manual para os testes do limitador
+type relogio struct{ t time.Time }
+
+func (r *relogio) agora() time.Time        { return r.t }
+func (r *relogio) avancar(d time.Duration) { r.t = r.t.Add(d) }
+
+// registrarFalha e registrarSucesso aplicam o resultado de uma tentativa sem passar pela reserva,
+// para montar os cenários sem esperar os atrasos
+func (l *Limitador) registrarFalha(login, ip string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.contarFalha(login, ip, l.agora())
+}
+
+func (l *Limitador) registrarSucesso(login, ip string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.zerarLogin(login)
+}
+
+func novoLimitadorTeste() (*Limitador, *relogio) {
+	r := &relogio{t: time.Date(2024, 3, 1, 8, 0, 0, 0, time.UTC)}
+	return NovoLimitador(PoliticaPadrao(), r.agora), r
+}
+
+func TestLimitadorAtrasoProgressivo(t *testing.T) {
+	l, _ := novoLimitadorTeste()
+
+	for i := 0; i < 2; i++ {
+		l.registrarFalha("maria", "10.0.0.1")
+	}
+	if espera := l.Verificar("maria", "10.0.0.1"); espera != 0 {
+		t.Fatalf("antes da 3ª falha não deveria haver espera, veio %v", espera)
+	}
+
+	esperados := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second}
+	for _, esperado := range esperados {
+		l.registrarFalha("maria", "10.0.0.1")
+		if espera := l.Verificar("maria", "10.0.0.1"); espera != esperado {
+			t.Fatalf("espera = %v, esperado %v", espera, esperado)
+		}
+	}
+}
+
+func TestLimitadorAtrasoMaximo(t *testing.T) {
+	l, _ := novoLimitadorTeste()
+	l.politica.FalhasBloqueioLogin = 0 // só atraso, sem bloqueio
+
+	for i := 0; i < 20; i++ {
+		l.registrarFalha("maria", "10.0.0.1")
+	}
+	if espera := l.Verificar("maria", "10.0.0.1"); espera != 30*time.Second {
+		t.Fatalf("espera = %v, esperado o máximo de 30s", espera)
+	}
+}
+
+func TestLimitadorBloqueioELiberacaoPorTempo(t *testing.T) {
+	l, relogio := novoLimitadorTeste()
+
+	for i := 0; i < 10; i++ {
+		l.registrarFalha("admin", "10.0.0.1")
+	}
+	if espera := l.Verificar("admin", "10.0.0.1"); espera != 15*time.Minute {
+		t.Fatalf("espera após o bloqueio = %v, esperado 15m", espera)
+	}
+
+	// Outro login do mesmo IP não é bloqueado (o IP tem limite maior), só sofre o atraso do IP
+	if espera := l.Verificar("joao", "10.0.0.1"); espera != 30*time.Second {
+		t.Fatalf("outro login do mesmo IP: espera = %v, esperado o atraso máximo de 30s", espera)
+	}
+	if espera := l.Verificar("joao", "10.0.0.2"); espera != 0 {
+		t.Fatalf("outro login de outro IP não deveria esperar, veio %v", espera)
+	}
+
+	relogio.avancar(14*time.Minute + 59*time.Second)
+	if espera := l.Verificar("admin", "10.0.0.2"); espera != time.Second {
+		t.Fatalf("um segundo antes do fim, espera = %v, esperado 1s", espera)
+	}
+
+	relogio.avancar(time.Second)
+	if espera := l.Verificar("admin", "10.0.0.1"); espera != 0 {
+		t.Fatalf("após o bloqueio não deveria haver espera, veio %v", espera)
+	}
+
+	// A contagem recomeça depois do bloqueio
+	l.registrarFalha("admin", "10.0.0.1")
+	if espera := l.Verificar("admin", "10.0.0.1"); espera != 0 {
+		t.Fatalf("primeira falha após o bloqueio não deveria gerar espera, veio %v", espera)
+	}
+}
+
+func TestLimitadorBloqueioPorIP(t *testing.T) {
+	l, relogio := novoLimitadorTeste()
+
+	// Uma senha por login, em logins diferentes: só a contagem do IP cresce
+	for i := 0; i < 30; i++ {
+		l.registrarFalha("usuario"+string(rune('a'+i%26))+string(rune('a'+i/26)), "10.0.0.9")
+		relogio.avancar(time.Second)
+	}
+
+	if espera := l.Verificar("novo", "10.0.0.9"); espera <= 14*time.Minute {
+		t.Fatalf("IP deveria estar bloqueado, espera = %v", espera)
+	}
+	if espera := l.Verificar("novo", "10.0.0.10"); espera != 0 {
+		t.Fatalf("outro IP não deveria esperar, veio %v", espera)
+	}
+
+	// Um login correto a partir do IP não libera o IP
+	l.registrarSucesso("novo", "10.0.0.9")
+	if espera := l.Verificar("novo", "10.0.0.9"); espera == 0 {
+		t.Fatal("sucesso não deveria liberar o IP bloqueado")
+	}
+}
+
+func TestLimitadorSucessoZeraLogin(t *testing.T) {
+	l, _ := novoLimitadorTeste()
+
+	for i := 0; i < 5; i++ {
+		l.registrarFalha("Maria", "10.0.0.1")
+	}
+	l.registrarSucesso("maria", "10.0.0.2")
+
+	if espera := l.Verificar("MARIA", "10.0.0.2"); espera != 0 {
+		t.Fatalf("sucesso deveria zerar as falhas do login, espera = %v", espera)
+	}
+}
+
+func TestLimitadorJanelaSemFalhas(t *testing.T) {
+	l, relogio := novoLimitadorTeste()
+
+	for i := 0; i < 9; i++ {
+		l.registrarFalha("admin", "10.0.0.1")
+	}
+	relogio.avancar(15 * time.Minute)
+
+	// Passada a janela, a 10ª falha conta como a primeira e não bloqueia
+	l.registrarFalha("admin", "10.0.0.1")
+	if espera := l.Verificar("admin", "10.0.0.1"); espera != 0 {
+		t.Fatalf("falhas antigas não deveriam contar, espera = %v", espera)
+	}
+}
+
+func TestLimitadorRestricoesELiberar(t *testing.T) {
+	l, relogio := novoLimitadorTeste()
+
+	for i := 0; i < 10; i++ {
+		l.registrarFalha("admin", "10.0.0.1")
+	}
+
+	restricoes := l.Restricoes()
+	if len(restricoes) != 2 {
+		t.Fatalf("esperadas 2 restrições (login bloqueado e IP em atraso), vieram %d: %+v", len(restricoes), restricoes)
+	}
+	r := restricoes[0]
+	if r.Tipo != ChaveLogin || r.Valor != "admin" || !r.Bloqueado || r.Falhas != 10 ||
+		!r.LiberadoEm.Equal(relogio.agora().Add(15*time.Minute)) {
+		t.Fatalf("restrição do login inesperada: %+v", r)
+	}
+	if restricoes[1].Tipo != ChaveIP || restricoes[1].Bloqueado {
+		t.Fatalf("restrição do IP inesperada: %+v", restricoes[1])
+	}
+
+	if !l.Liberar(ChaveLogin, "ADMIN") {
+		t.Fatal("Liberar deveria encontrar o login bloqueado")
+	}
+	if l.Liberar(ChaveLogin, "admin") {
+		t.Fatal("Liberar de novo não deveria encontrar registro")
+	}
+	if !l.Liberar(ChaveIP, "10.0.0.1") {
+		t.Fatal("Liberar deveria encontrar o IP")
+	}
+	if espera := l.Verificar("admin", "10.0.0.1"); espera != 0 {
+		t.Fatalf("após liberar não deveria haver espera, veio %v", espera)
+	}
+	if len(l.Restricoes()) != 0 {
+		t.Fatal("não deveriam restar restrições")
+	}
+
+	// Atrasos vencidos não aparecem na lista
+	for i := 0; i < 3; i++ {
+		l.registrarFalha("joao", "10.0.0.3")
+	}
+	relogio.avancar(time.Second)
+	if restricoes := l.Restricoes(); len(restricoes) != 0 {
+		t.Fatalf("atraso já cumprido não deveria ser listado: %+v", restricoes)
+	}
+}
+
+func TestLimitadorReservaContaTentativasPendentes(t *testing.T) {
+	l, _ := novoLimitadorTeste()
+
+	// Em sequência, a 4ª tentativa espera o atraso; em paralelo, sem concluir as anteriores, também
+	var tentativas []*Tentativa
+	for {
+		tentativa, espera := l.Reservar("maria", "10.0.0.1")
+		if tentativa == nil {
+			if espera != time.Second {
+				t.Fatalf("espera = %v, esperado 1s", espera)
+			}
+			break
+		}
+		tentativas = append(tentativas, tentativa)
+	}
+	if len(tentativas) != 3 {
+		t.Fatalf("esperadas 3 reservas simultâneas antes do atraso, vieram %d", len(tentativas))
+	}
+
+	// Cancelar (ou concluir de novo) não conta falha e libera a reserva
+	tentativas[0].Cancelar()
+	tentativas[0].Falhou()
+	tentativas[1].Falhou()
+	tentativas[2].Falhou()
+	if espera := l.Verificar("maria", "10.0.0.1"); espera != 0 {
+		t.Fatalf("duas falhas não deveriam gerar espera, veio %v", espera)
+	}
+}
+
+func TestLimitadorReservasParalelasRespeitamBloqueio(t *testing.T) {
+	l, _ := novoLimitadorTeste()
+	l.politica.FalhasParaAtraso = 0 // só bloqueio, sem atraso
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	permitidas := 0
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			tentativa, _ := l.Reservar("admin", "10.0.0.1")
+			if tentativa == nil {
+				return
+			}
+			mu.Lock()
+			permitidas++
+			mu.Unlock()
+			defer tentativa.Falhou()
+		}()
+	}
+	wg.Wait()
+
+	if permitidas > 10 {
+		t.Fatalf("%d tentativas paralelas passaram do limite de 10 falhas", permitidas)
+	}
+	if espera := l.Verificar("admin", "10.0.0.1"); espera == 0 {
+		t.Fatal("o login deveria estar bloqueado")
+	}
+}
+
+func TestLimitadorSucessoComTentativaPendente(t *testing.T) {
+	l, _ := novoLimitadorTeste()
+
+	outra, _ := l.Reservar("maria", "10.0.0.1")
+	certa, _ := l.Reservar("maria", "10.0.0.1")
+	certa.Sucesso()
+
+	// A tentativa que continua pendente ainda conta
+	if r := l.registros[chave(ChaveLogin, "maria")]; r == nil || r.pendentes != 1 || r.falhas != 0 {
+		t.Fatalf("registro do login inesperado após o sucesso: %+v", r)
+	}
+	outra.Falhou()
+	if r := l.registros[chave(ChaveLogin, "maria")]; r == nil || r.falhas != 1 || r.pendentes != 0 {
+		t.Fatalf("registro do login inesperado após a falha: %+v", r)
+	}
+}
+
+func TestLimitadorLimiteDeRegistros(t *testing.T) {
+	l, relogio := novoLimitadorTeste()
+
+	for i := 0; i < 10; i++ {
+		l.registrarFalha("admin", "10.0.0.1")
+	}
+	for i := 0; i < registrosMaximos; i++ {
+		relogio.avancar(time.Millisecond)
+		l.registrarFalha("usuario"+strconv.Itoa(i), "192.168.0.1")
+	}
+
+	if len(l.registros) > registrosMaximos {
+		t.Fatalf("o limitador guardou %d registros, acima do máximo de %d", len(l.registros), registrosMaximos)
+	}
+	if espera := l.Verificar("admin", "10.0.0.2"); espera == 0 {
+		t.Fatal("o bloqueio do login não deveria ser descartado")
+	}
+	if _, ok := l.registros[chave(ChaveLogin, "usuario0")]; ok {
+		t.Fatal("os registros mais antigos deveriam ser descartados")
+	}
+	if _, ok := l.registros[chave(ChaveLogin, "usuario"+strconv.Itoa(registrosMaximos-1))]; !ok {
+		t.Fatal("o registro mais recente deveria ser mantido")
+	}
+}
@@ -0,0 +1,78 @@
+package auth
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"unicode/utf8"
+)
+
+// ErrSenhaFraca indica senha recusada pela política de senhas
+var ErrSenhaFraca = errors.New("senha não atende à política de senhas")
+
+// tamanhoMinimoSenha é o tamanho mínimo exigido, definido por Configurar
+var tamanhoMinimoSenha = 8
+
+// tamanhoMaximoSenha é o limite do bcrypt, que recusa senhas com mais de 72 bytes
+const tamanhoMaximoSenha = 72
+
+// senhasComuns são senhas fáceis de adivinhar, recusadas independentemente do tamanho mínimo
+var senhasComuns = map[string]bool{
+	"123456": true, "1234567": true, "12345678": true, "123456789": true, "1234567890": true,
+	"12345678910": true, "000000": true, "00000000": true, "111111": true, "11111111": true,
+	"654321": true, "87654321": true, "123123": true, "123321": true, "102030": true,
+	"10203040": true, "abc123": true, "abcd1234": true, "qwerty": true, "qwerty123": true,
+	"asdfgh": true, "password": true, "password1": true, "senha": true, "senha123": true,
+	"senha1234": true, "minhasenha": true, "mudar123": true, "mudarsenha": true, "trocar123": true,
+	"admin": true, "admin123": true, "admin1234": true, "administrador": true, "gestgas": true,
+	"gestgas123": true, "botijao": true, "botijao13": true, "iloveyou": true, "brasil": true,
+	"brasil123": true,
+}
+
+// alfabetoTemporario evita caracteres fáceis de confundir ao ditar ou digitar (0/O, 1/l/I)
+const alfabetoTemporario = "ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz23456789"
+
+// ValidarSenha aplica a política de senhas: tamanho mínimo, diferente do login e fora da lista de senhas comuns
+func ValidarSenha(senha, login string) error {
+	if utf8.RuneCountInString(senha) < tamanhoMinimoSenha {
+		return fmt.Errorf("%w: a senha deve ter pelo menos %d caracteres", ErrSenhaFraca, tamanhoMinimoSenha)
+	}
+	if len(senha) > tamanhoMaximoSenha {
+		return fmt.Errorf("%w: a senha deve ter no máximo %d bytes", ErrSenhaFraca, tamanhoMaximoSenha)
+	}
+	normalizada := strings.ToLower(strings.TrimSpace(senha))
+	if login != "" && normalizada == strings.ToLower(strings.TrimSpace(login)) {
+		return fmt.Errorf("%w: a senha não pode ser igual ao login", ErrSenhaFraca)
+	}
+	// Só espaços ou um único caractere repetido também contam como senha comum
+	if normalizada == "" || senhasComuns[normalizada] || caractereRepetido(normalizada) {
+		return fmt.Errorf("%w: a senha é muito comum, escolha outra", ErrSenhaFraca)
+	}
+	return nil
+}
+
+// GerarSenhaTemporaria gera uma senha aleatória para redefinições feitas pelo administrador.
+// Ela só serve para entrar e trocar a senha, pois o usuário fica obrigado a trocá-la.
+func GerarSenhaTemporaria() (string, error) {
+	tamanho := tamanhoMinimoSenha
+	if tamanho < 12 {
+		tamanho = 12
+	}
+	limite := big.NewInt(int64(len(alfabetoTemporario)))
+	b := make([]byte, tamanho)
+	for i := range b {
+		n, err := rand.Int(rand.Reader, limite)
+		if err != nil {
+			return "", fmt.Errorf("erro ao gerar senha temporária: %w", err)
+		}
+		b[i] = alfabetoTemporario[n.Int64()]
+	}
+	return string(b), nil
+}
+
+func caractereRepetido(s string) bool {
+	primeiro, _ := utf8.DecodeRuneInString(s)
+	return strings.Trim(s, string(primeiro)) == ""
+}
@@ -0,0 +1,228 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+	"unicode/utf8"
+)
+
+// Motivos de revogação gravados na sessão
+const (
+	MotivoLogout        = "logout"
+	MotivoRevogadaAdmin = "revogada_admin"
+	MotivoReusoRefresh  = "reuso_refresh"
+
+	MotivoUsuarioDesativado = "usuario_desativado"
+	MotivoSenhaAlterada     = "senha_alterada"
+	MotivoSenhaRedefinida   = "senha_redefinida"
+)
+
+// Motivos gravados no registro de tentativas de login
+const (
+	TentativaSucesso            = "sucesso"
+	TentativaSenhaIncorreta     = "senha_incorreta"
+	TentativaUsuarioInexistente = "usuario_inexistente"
+	TentativaUsuarioDesativado  = "usuario_desativado"
+	TentativaBloqueada          = "bloqueado"
+)
+
+// toleranciaReuso é o intervalo após uma renovação em que o refresh token anterior ainda é
+// recusado sem revogar a sessão, para o caso de duas abas renovarem ao mesmo tempo
+const toleranciaReuso = 30 * time.Second
+
+// ErrRefreshInvalido indica refresh token desconhecido, expirado ou de sessão revogada
+var ErrRefreshInvalido = errors.New("refresh token inválido ou expirado")
+
+// Tokens são as credenciais entregues no login e em cada renovação
+type Tokens struct {
+	SessaoID      int       `json:"-"`
+	Token         string    `json:"token"`
+	Expira        time.Time `json:"expira"`
+	RefreshToken  string    `json:"refresh_token"`
+	RefreshExpira time.Time `json:"refresh_expira"`
+}
+
+// IniciarSessao cria uma sessão para o usuário e emite o primeiro par de tokens
+func IniciarSessao(db *sql.DB, userID int, perfil, ip, userAgent string) (Tokens, error) {
+	refresh, hash, err := gerarRefreshToken()
+	if err != nil {
+		return Tokens{}, err
+	}
+
+	t := Tokens{RefreshToken: refresh, RefreshExpira: time.Now().Add(validadeRefresh)}
+	err = db.QueryRow(`
+		INSERT INTO sessoes (usuario_id, refresh_hash, ip, user_agent, expira_em)
+		VALUES ($1, $2, NULLIF($3, ''), NULLIF($4, ''), $5)
+		RETURNING id
+	`, userID, hash, ip, limitarTexto(userAgent, 255), t.RefreshExpira).Scan(&t.SessaoID)
+	if err != nil {
+		return Tokens{}, fmt.Errorf("erro ao criar sessão: %w", err)
+	}
+
+	return emitirToken(t, userID, perfil)
+}
+
+// RenovarSessao troca um refresh token válido por um novo par de tokens. O refresh token usado
+// deixa de valer (rotação); apresentá-lo de novo depois da tolerância revoga a sessão inteira,
+// pois indica que ele foi copiado. O perfil é relido do cadastro a cada renovação.
+func RenovarSessao(db *sql.DB, refreshToken string) (Tokens, error) {
+	hash := hashRefreshToken(refreshToken)
+
+	tx, err := db.Begin()
+	if err != nil {
+		return Tokens{}, err
+	}
+	defer tx.Rollback() // sem efeito após o commit
+
+	var userID int
+	var perfil string
+	var ativo bool
+	var t Tokens
+	var expiraEm time.Time
+	var revogada sql.NullTime
+	err = tx.QueryRow(`
+		SELECT s.id, s.usuario_id, u.perfil, u.ativo, s.expira_em, s.revogada_em
+		FROM sessoes s
+		JOIN usuarios u ON u.id = s.usuario_id
+		WHERE s.refresh_hash = $1
+		FOR UPDATE OF s
+	`, hash).Scan(&t.SessaoID, &userID, &perfil, &ativo, &expiraEm, &revogada)
+	if err == sql.ErrNoRows {
+		_, err = db.Exec(`
+			UPDATE sessoes SET revogada_em = NOW(), motivo_revogacao = $2
+			WHERE refresh_hash_anterior = $1 AND revogada_em IS NULL
+			AND ultimo_uso_em < NOW() - make_interval(secs => $3)
+		`, hash, MotivoReusoRefresh, toleranciaReuso.Seconds())
+		if err != nil {
+			return Tokens{}, err
+		}
+		return Tokens{}, ErrRefreshInvalido
+	}
+	if err != nil {
+		return Tokens{}, err
+	}
+	if revogada.Valid || !ativo || time.Now().After(expiraEm) {
+		return Tokens{}, ErrRefreshInvalido
+	}
+
+	refresh, novoHash, err := gerarRefreshToken()
+	if err != nil {
+		return Tokens{}, err
+	}
+	t.RefreshToken = refresh
+	t.RefreshExpira = time.Now().Add(validadeRefresh)
+	_, err = tx.Exec(`
+		UPDATE sessoes
+		SET refresh_hash_anterior = refresh_hash, refresh_hash = $1, expira_em = $2, ultimo_uso_em = NOW()
+		WHERE id = $3
+	`, novoHash, t.RefreshExpira, t.SessaoID)
+	if err != nil {
+		return Tokens{}, fmt.Errorf("erro ao renovar sessão: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return Tokens{}, err
+	}
+	return emitirToken(t, userID, perfil)
+}
+
+// EncerrarSessao revoga uma sessão; os tokens de acesso dela deixam de ser aceitos
+func EncerrarSessao(db *sql.DB, sessaoID int, motivo string) error {
+	_, err := db.Exec(`
+		UPDATE sessoes SET revogada_em = NOW(), motivo_revogacao = $2
+		WHERE id = $1 AND revogada_em IS NULL
+	`, sessaoID, motivo)
+	return err
+}
+
+// RevogarSessoesUsuario revoga todas as sessões ativas do usuário e retorna quantas foram revogadas
+func RevogarSessoesUsuario(db *sql.DB, userID int, motivo string) (int64, error) {
+	res, err := db.Exec(`
+		UPDATE sessoes SET revogada_em = NOW(), motivo_revogacao = $2
+		WHERE usuario_id = $1 AND revogada_em IS NULL
+	`, userID, motivo)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// RevogarOutrasSessoes revoga as sessões ativas do usuário, exceto a informada (a da requisição atual)
+func RevogarOutrasSessoes(db *sql.DB, userID, sessaoID int, motivo string) (int64, error) {
+	res, err := db.Exec(`
+		UPDATE sessoes SET revogada_em = NOW(), motivo_revogacao = $3
+		WHERE usuario_id = $1 AND id <> $2 AND revogada_em IS NULL
+	`, userID, sessaoID, motivo)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// SessaoAtiva indica se a sessão do token pertence ao usuário ativo, não foi revogada e não expirou,
+// e se o usuário ainda precisa trocar a senha provisória
+func SessaoAtiva(db *sql.DB, sessaoID, userID int) (ativa, deveTrocarSenha bool, err error) {
+	err = db.QueryRow(`
+		SELECT u.deve_trocar_senha FROM sessoes s
+		JOIN usuarios u ON u.id = s.usuario_id
+		WHERE s.id = $1 AND s.usuario_id = $2 AND s.revogada_em IS NULL AND s.expira_em > NOW() AND u.ativo
+	`, sessaoID, userID).Scan(&deveTrocarSenha)
+	if err == sql.ErrNoRows {
+		return false, false, nil
+	}
+	if err != nil {
+		return false, false, err
+	}
+	return true, deveTrocarSenha, nil
+}
+
+// RegistrarTentativa grava uma tentativa de login, com sucesso ou não, na auditoria
+func RegistrarTentativa(db *sql.DB, login string, usuarioID *int, ip, userAgent string, sucesso bool, motivo string) error {
+	_, err := db.Exec(`
+		INSERT INTO tentativas_login (login, usuario_id, ip, user_agent, sucesso, motivo)
+		VALUES ($1, $2, NULLIF($3, ''), NULLIF($4, ''), $5, $6)
+	`, limitarTexto(login, 50), usuarioID, ip, limitarTexto(userAgent, 255), sucesso, motivo)
+	return err
+}
+
+func emitirToken(t Tokens, userID int, perfil string) (Tokens, error) {
+	token, err := GerarToken(userID, perfil, t.SessaoID)
+	if err != nil {
+		return Tokens{}, err
+	}
+	t.Token = token
+	t.Expira = time.Now().Add(validadeToken)
+	return t, nil
+}
+
+// gerarRefreshToken retorna um token aleatório e o hash gravado no banco
+func gerarRefreshToken() (string, string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", fmt.Errorf("erro ao gerar refresh token: %w", err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+	return token, hashRefreshToken(token), nil
+}
+
+func hashRefreshToken(token string) string {
+	soma := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(soma[:])
+}
+
+func limitarTexto(s string, limite int) string {
+	if len(s) <= limite {
+		return s
+	}
+	// Não corta um caractere UTF-8 ao meio
+	for limite > 0 && !utf8.RuneStart(s[limite]) {
+		limite--
+	}
+	return s[:limite]
+}
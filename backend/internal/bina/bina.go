@@ -0,0 +1,61 @@
+// Package bina recebe as ligações identificadas pelo bina (identificador de chamadas) do PABX,
+// localiza o cliente pelo telefone e avisa as telas dos atendentes.
+package bina
+
+import (
+	"strings"
+	"time"
+)
+
+// Chamada é uma ligação recebida do dispositivo, antes da identificação do cliente
+type Chamada struct {
+	Numero     string
+	Ramal      string
+	RecebidaEm time.Time
+}
+
+// InterpretarLinha lê uma linha enviada pelo dispositivo. São aceitos o formato simples
+// "numero;ramal" (ramal opcional) e o padrão de modems com caller ID ("NMBR = numero").
+// Linhas de controle (RING, DATE, TIME, NAME) e números privativos são ignorados.
+func InterpretarLinha(linha string) (Chamada, bool) {
+	linha = strings.TrimSpace(linha)
+	if linha == "" {
+		return Chamada{}, false
+	}
+
+	var numero, ramal string
+	maiuscula := strings.ToUpper(linha)
+	switch {
+	case strings.HasPrefix(maiuscula, "NMBR"):
+		partes := strings.SplitN(linha, "=", 2)
+		if len(partes) != 2 {
+			return Chamada{}, false
+		}
+		numero = partes[1]
+	case strings.HasPrefix(maiuscula, "RING"), strings.HasPrefix(maiuscula, "DATE"),
+		strings.HasPrefix(maiuscula, "TIME"), strings.HasPrefix(maiuscula, "NAME"):
+		return Chamada{}, false
+	default:
+		partes := strings.SplitN(linha, ";", 2)
+		numero = partes[0]
+		if len(partes) == 2 {
+			ramal = strings.TrimSpace(partes[1])
+		}
+	}
+
+	numero = strings.TrimSpace(numero)
+	if !possuiDigitos(numero) {
+		// "P" (privativo) e "O" (indisponível) no padrão NMBR
+		return Chamada{}, false
+	}
+	return Chamada{Numero: numero, Ramal: ramal, RecebidaEm: time.Now()}, true
+}
+
+func possuiDigitos(s string) bool {
+	for _, r := range s {
+		if r >= '0' && r <= '9' {
+			return true
+		}
+	}
+	return false
+}
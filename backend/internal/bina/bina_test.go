@@ -0,0 +1,46 @@
+package bina
+
+import "testing"
+
+func TestInterpretarLinha(t *testing.T) {
+	casos := []struct {
+		linha  string
+		ok     bool
+		numero string
+		ramal  string
+	}{
+		{"86999887766;201", true, "86999887766", "201"},
+		{"  (86) 3222-1100 ; 12 \r\n", true, "(86) 3222-1100", "12"},
+		{"86999887766", true, "86999887766", ""},
+		{"86999887766;", true, "86999887766", ""},
+		{"NMBR = 8632221100", true, "8632221100", ""},
+		{"nmbr=86999887766", true, "86999887766", ""},
+		{"NMBR = P", false, "", ""},
+		{"NMBR = O", false, "", ""},
+		{"NMBR 8632221100", false, "", ""},
+		{"RING", false, "", ""},
+		{"DATE = 0105", false, "", ""},
+		{"TIME = 1430", false, "", ""},
+		{"NAME = FULANO", false, "", ""},
+		{"", false, "", ""},
+		{"   ", false, "", ""},
+		{";201", false, "", ""},
+		{"privado;201", false, "", ""},
+	}
+	for _, c := range casos {
+		chamada, ok := InterpretarLinha(c.linha)
+		if ok != c.ok {
+			t.Errorf("InterpretarLinha(%q) ok = %v, esperado %v", c.linha, ok, c.ok)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if chamada.Numero != c.numero || chamada.Ramal != c.ramal {
+			t.Errorf("InterpretarLinha(%q) = %q;%q, esperado %q;%q", c.linha, chamada.Numero, chamada.Ramal, c.numero, c.ramal)
+		}
+		if chamada.RecebidaEm.IsZero() {
+			t.Errorf("InterpretarLinha(%q) sem horário de recebimento", c.linha)
+		}
+	}
+}
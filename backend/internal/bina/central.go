@@ -0,0 +1,183 @@
+package bina
+
+import (
+	"database/sql"
+	"sync"
+	"time"
+
+	"github.com/tassyosilva/GestGAS/internal/models"
+	"github.com/tassyosilva/GestGAS/internal/telefone"
+)
+
+// tamanhoFilaAssinante é quantas chamadas podem aguardar uma tela lenta antes de serem descartadas para ela
+const tamanhoFilaAssinante = 16
+
+// Central identifica os clientes das chamadas recebidas e as repassa às telas conectadas
+type Central struct {
+	db         *sql.DB
+	mu         sync.Mutex
+	assinantes map[chan models.ChamadaRecebida]string // Canal da tela -> ramal filtrado ("" recebe todas)
+}
+
+// NovaCentral cria a central de chamadas
+func NovaCentral(db *sql.DB) *Central {
+	return &Central{db: db, assinantes: map[chan models.ChamadaRecebida]string{}}
+}
+
+// Assinar registra uma tela interessada nas chamadas do ramal informado (vazio para todas).
+// A função retornada cancela a assinatura.
+func (c *Central) Assinar(ramal string) (<-chan models.ChamadaRecebida, func()) {
+	ch := make(chan models.ChamadaRecebida, tamanhoFilaAssinante)
+	c.mu.Lock()
+	c.assinantes[ch] = ramal
+	c.mu.Unlock()
+
+	return ch, func() {
+		c.mu.Lock()
+		delete(c.assinantes, ch)
+		c.mu.Unlock()
+	}
+}
+
+// Receber identifica os clientes da chamada, registra a ligação e a envia às telas conectadas
+func (c *Central) Receber(chamada Chamada) (models.ChamadaRecebida, error) {
+	if chamada.RecebidaEm.IsZero() {
+		chamada.RecebidaEm = time.Now()
+	}
+	recebida := models.ChamadaRecebida{
+		Numero:     chamada.Numero,
+		Ramal:      chamada.Ramal,
+		Clientes:   []models.ClienteChamada{},
+		RecebidaEm: chamada.RecebidaEm,
+	}
+
+	if e164, ok := telefone.NormalizarE164(chamada.Numero); ok {
+		recebida.TelefoneE164 = e164
+		clientes, err := buscarClientes(c.db, e164)
+		if err != nil {
+			return recebida, err
+		}
+		recebida.Clientes = clientes
+	}
+
+	// Cliente da chamada só é gravado quando a identificação não é ambígua
+	var clienteID *int
+	if len(recebida.Clientes) == 1 {
+		clienteID = &recebida.Clientes[0].ID
+	}
+	err := c.db.QueryRow(`
+		INSERT INTO chamadas (numero, telefone_e164, ramal, cliente_id, clientes_encontrados, recebida_em)
+		VALUES ($1, NULLIF($2, ''), NULLIF($3, ''), $4, $5, $6)
+		RETURNING id
+	`, recebida.Numero, recebida.TelefoneE164, recebida.Ramal, clienteID, len(recebida.Clientes), recebida.RecebidaEm).Scan(&recebida.ID)
+	if err != nil {
+		return recebida, err
+	}
+
+	c.publicar(recebida)
+	return recebida, nil
+}
+
+// publicar entrega a chamada às telas do ramal sem bloquear; telas com a fila cheia perdem o aviso
+func (c *Central) publicar(recebida models.ChamadaRecebida) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for ch, ramal := range c.assinantes {
+		if ramal != "" && recebida.Ramal != "" && ramal != recebida.Ramal {
+			continue
+		}
+		select {
+		case ch <- recebida:
+		default:
+		}
+	}
+}
+
+// buscarClientes retorna os clientes com o telefone informado, cada um com o último pedido não cancelado
+func buscarClientes(db *sql.DB, e164 string) ([]models.ClienteChamada, error) {
+	rows, err := db.Query(`
+		SELECT id, nome, telefone, COALESCE(endereco, ''), COALESCE(complemento, ''),
+			COALESCE(bairro, ''), COALESCE(cidade, ''), COALESCE(observacoes, '')
+		FROM clientes
+		WHERE telefone_e164 = $1
+		ORDER BY atualizado_em DESC, id
+	`, e164)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	clientes := []models.ClienteChamada{}
+	for rows.Next() {
+		var cliente models.ClienteChamada
+		err := rows.Scan(&cliente.ID, &cliente.Nome, &cliente.Telefone, &cliente.Endereco,
+			&cliente.Complemento, &cliente.Bairro, &cliente.Cidade, &cliente.Observacoes)
+		if err != nil {
+			return nil, err
+		}
+		clientes = append(clientes, cliente)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range clientes {
+		if err := carregarUltimoPedido(db, &clientes[i]); err != nil {
+			return nil, err
+		}
+	}
+	return clientes, nil
+}
+
+// carregarUltimoPedido preenche o último pedido do cliente e a sugestão para repeti-lo
+func carregarUltimoPedido(db *sql.DB, cliente *models.ClienteChamada) error {
+	var pedido models.PedidoResumido
+	var enderecoEntrega string
+	err := db.QueryRow(`
+		SELECT id, status, forma_pagamento, valor_total, criado_em, endereco_entrega
+		FROM pedidos
+		WHERE cliente_id = $1 AND status <> $2
+		ORDER BY criado_em DESC
+		LIMIT 1
+	`, cliente.ID, models.StatusCancelado).Scan(
+		&pedido.ID, &pedido.Status, &pedido.FormaPagamento, &pedido.ValorTotal, &pedido.DataPedido, &enderecoEntrega,
+	)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	cliente.UltimoPedido = &pedido
+
+	rows, err := db.Query(`
+		SELECT produto_id, quantidade, COALESCE(retorna_botija, false)
+		FROM itens_pedido
+		WHERE pedido_id = $1
+		ORDER BY id
+	`, pedido.ID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	sugerido := &models.NovoPedidoRequest{
+		ClienteID:       cliente.ID,
+		FormaPagamento:  pedido.FormaPagamento,
+		EnderecoEntrega: enderecoEntrega,
+		CanalOrigem:     models.CanalTelefone,
+		Itens:           []models.ItemPedidoRequest{},
+	}
+	for rows.Next() {
+		var item models.ItemPedidoRequest
+		if err := rows.Scan(&item.ProdutoID, &item.Quantidade, &item.RetornaBotija); err != nil {
+			return err
+		}
+		sugerido.Itens = append(sugerido.Itens, item)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	cliente.PedidoSugerido = sugerido
+	return nil
+}
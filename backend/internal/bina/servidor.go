@@ -0,0 +1,106 @@
+package bina
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+)
+
+// Escutar aceita conexões TCP de dispositivos de bina no endereço informado. Cada linha recebida
+// é interpretada por InterpretarLinha e repassada à central. O protocolo não tem autenticação,
+// então só são atendidas conexões da própria máquina e das origens informadas (IPs ou faixas
+// CIDR); as demais são encerradas sem leitura. Só retorna em caso de erro ao escutar.
+func Escutar(endereco string, origens []string, central *Central) error {
+	permitidas, err := LerOrigens(origens)
+	if err != nil {
+		return err
+	}
+
+	listener, err := net.Listen("tcp", endereco)
+	if err != nil {
+		return err
+	}
+	defer listener.Close()
+	log.Printf("Receptor de chamadas do bina escutando em %s", endereco)
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if ne, ok := err.(net.Error); ok && ne.Timeout() {
+				continue
+			}
+			return err
+		}
+		if !origemPermitida(conn.RemoteAddr(), permitidas) {
+			log.Printf("Conexão do bina recusada: origem %s não autorizada (bina.origens_tcp)", conn.RemoteAddr())
+			conn.Close()
+			continue
+		}
+		go atenderConexao(conn, central)
+	}
+}
+
+// LerOrigens converte as origens autorizadas, IPs ou faixas CIDR, em redes
+func LerOrigens(origens []string) ([]*net.IPNet, error) {
+	var redes []*net.IPNet
+	for _, origem := range origens {
+		origem = strings.TrimSpace(origem)
+		if !strings.Contains(origem, "/") {
+			ip := net.ParseIP(origem)
+			if ip == nil {
+				return nil, fmt.Errorf("origem do bina inválida: %q (use um IP ou uma faixa CIDR)", origem)
+			}
+			bits := 128
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 32
+			}
+			redes = append(redes, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, rede, err := net.ParseCIDR(origem)
+		if err != nil {
+			return nil, fmt.Errorf("origem do bina inválida: %q (use um IP ou uma faixa CIDR)", origem)
+		}
+		redes = append(redes, rede)
+	}
+	return redes, nil
+}
+
+// origemPermitida indica se a conexão vem da própria máquina ou de uma das redes autorizadas
+func origemPermitida(endereco net.Addr, permitidas []*net.IPNet) bool {
+	tcp, ok := endereco.(*net.TCPAddr)
+	if !ok {
+		return false
+	}
+	if tcp.IP.IsLoopback() {
+		return true
+	}
+	for _, rede := range permitidas {
+		if rede.Contains(tcp.IP) {
+			return true
+		}
+	}
+	return false
+}
+
+// atenderConexao lê as chamadas de um dispositivo até ele desconectar
+func atenderConexao(conn net.Conn, central *Central) {
+	defer conn.Close()
+	origem := conn.RemoteAddr().String()
+
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		chamada, ok := InterpretarLinha(scanner.Text())
+		if !ok {
+			continue
+		}
+		if _, err := central.Receber(chamada); err != nil {
+			log.Printf("Erro ao processar chamada de %s recebida de %s: %v", chamada.Numero, origem, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		log.Printf("Conexão do bina %s encerrada com erro: %v", origem, err)
+	}
+}
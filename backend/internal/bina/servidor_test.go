@@ -0,0 +1,47 @@
+package bina
+
+import (
+	"net"
+	"testing"
+)
+
+func TestOrigemPermitida(t *testing.T) {
+	permitidas, err := LerOrigens([]string{"192.168.0.20", " 10.1.0.0/16 ", "fd00::5"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	casos := []struct {
+		ip       string
+		esperado bool
+	}{
+		{"127.0.0.1", true},
+		{"::1", true},
+		{"192.168.0.20", true},
+		{"192.168.0.21", false},
+		{"10.1.200.3", true},
+		{"10.2.0.1", false},
+		{"fd00::5", true},
+		{"fd00::6", false},
+		{"203.0.113.9", false},
+	}
+	for _, c := range casos {
+		endereco := &net.TCPAddr{IP: net.ParseIP(c.ip), Port: 40000}
+		if permitida := origemPermitida(endereco, permitidas); permitida != c.esperado {
+			t.Errorf("origemPermitida(%s) = %v, esperado %v", c.ip, permitida, c.esperado)
+		}
+	}
+
+	// Sem origens configuradas só a própria máquina é atendida
+	if origemPermitida(&net.TCPAddr{IP: net.ParseIP("192.168.0.20")}, nil) {
+		t.Error("sem origens configuradas a rede local não deveria ser aceita")
+	}
+}
+
+func TestLerOrigensInvalidas(t *testing.T) {
+	for _, origem := range []string{"", "pabx.local", "192.168.0.300", "10.0.0.0/33"} {
+		if _, err := LerOrigens([]string{origem}); err == nil {
+			t.Errorf("origem %q deveria ser recusada", origem)
+		}
+	}
+}
@@ -0,0 +1,329 @@
+package conciliacao
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/tassyosilva/GestGAS/internal/models"
+	"github.com/tassyosilva/GestGAS/internal/pix"
+)
+
+// Erros da conciliação manual
+var (
+	ErrTransacaoNaoEncontrada = errors.New("transação não encontrada")
+	ErrPedidoNaoEncontrado    = errors.New("pedido não encontrado")
+	ErrJaConciliado           = errors.New("já conciliado")
+	ErrDadosInvalidos         = errors.New("dados inválidos")
+)
+
+// StatusPedidoSQL calcula o status de conciliação de um pedido com alias "p" na consulta
+const StatusPedidoSQL = `CASE
+WHEN EXISTS (SELECT 1 FROM transacoes_bancarias tb WHERE tb.pedido_id = p.id) THEN 'conciliado'
+WHEN p.forma_pagamento IN ('pix', 'cartao_debito', 'cartao_credito') AND p.status IN ('entregue', 'finalizado') THEN 'pendente'
+ELSE 'nao_aplicavel' END`
+
+// janelaRecebimento é quantos dias depois da entrega o crédito pode cair no extrato, por forma de
+// pagamento. O Pix cai na hora; o débito em até dois dias úteis; o crédito à vista em até 30 dias.
+// Todas aceitam um dia antes, para entregas registradas depois da meia-noite.
+var janelaRecebimento = map[models.FormaPagamento]int{
+	models.PagamentoPix:           1,
+	models.PagamentoCartaoDebito:  3,
+	models.PagamentoCartaoCredito: 35,
+}
+
+// Importar grava os créditos do extrato, ignorando débitos e lançamentos já importados, e em
+// seguida tenta conciliar automaticamente todos os créditos pendentes
+func Importar(tx *sql.Tx, arquivo, formato string, transacoes []Transacao, usuarioID int) (models.ImportacaoExtratoResponse, error) {
+	resp := models.ImportacaoExtratoResponse{Formato: formato, TransacoesLidas: len(transacoes)}
+
+	err := tx.QueryRow(`
+		INSERT INTO extratos_importacoes (arquivo, formato, usuario_id)
+		VALUES ($1, $2, $3)
+		RETURNING id
+	`, arquivo, formato, usuarioID).Scan(&resp.ImportacaoID)
+	if err != nil {
+		return resp, fmt.Errorf("erro ao registrar importação: %w", err)
+	}
+
+	creditos, debitos := separarCreditos(transacoes)
+	resp.DebitosIgnorados = debitos
+	for _, t := range creditos {
+		res, err := tx.Exec(`
+			INSERT INTO transacoes_bancarias (importacao_id, identificador, data, valor, descricao, txid)
+			VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''))
+			ON CONFLICT (identificador) DO NOTHING
+		`, resp.ImportacaoID, t.Identificador, t.Data, t.Valor, t.Descricao, t.TxID)
+		if err != nil {
+			return resp, fmt.Errorf("erro ao gravar transação %s: %w", t.Identificador, err)
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			resp.TransacoesRepetidas++
+		} else {
+			resp.TransacoesImportadas++
+		}
+	}
+
+	_, err = tx.Exec(`
+		UPDATE extratos_importacoes SET transacoes_importadas = $1, transacoes_repetidas = $2
+		WHERE id = $3
+	`, resp.TransacoesImportadas, resp.TransacoesRepetidas, resp.ImportacaoID)
+	if err != nil {
+		return resp, fmt.Errorf("erro ao atualizar importação: %w", err)
+	}
+
+	resp.ConciliadasAutomatico, err = ConciliarAutomaticamente(tx, usuarioID)
+	return resp, err
+}
+
+type transacaoPendente struct {
+	id    int
+	data  time.Time
+	valor float64
+	txid  string
+}
+
+type pedidoPendente struct {
+	id       int
+	forma    models.FormaPagamento
+	valor    float64
+	entregue time.Time
+}
+
+// ConciliarAutomaticamente vincula os créditos pendentes aos pedidos pagos por Pix ou cartão.
+// Primeiro pelo txid da cobrança Pix, exigindo o mesmo valor; depois pelo valor exato dentro da
+// janela de recebimento da forma de pagamento, mas só quando o crédito e o pedido são o único
+// candidato um do outro. Casos ambíguos ficam para a conciliação manual.
+func ConciliarAutomaticamente(tx *sql.Tx, usuarioID int) (int, error) {
+	transacoes, err := transacoesPendentes(tx)
+	if err != nil || len(transacoes) == 0 {
+		return 0, err
+	}
+	pedidos, err := pedidosPendentes(tx, transacoes)
+	if err != nil {
+		return 0, err
+	}
+
+	conciliadas := 0
+	for _, par := range parear(transacoes, pedidos) {
+		if err := vincularTransacao(tx, par.transacao.id, par.pedido.id, models.ConciliacaoAutomatica, usuarioID); err != nil {
+			return conciliadas, err
+		}
+		conciliadas++
+	}
+	return conciliadas, nil
+}
+
+// separarCreditos devolve os créditos do extrato e quantos lançamentos foram ignorados por
+// serem débitos (ou zerados)
+func separarCreditos(transacoes []Transacao) ([]Transacao, int) {
+	var creditos []Transacao
+	debitos := 0
+	for _, t := range transacoes {
+		if t.Valor <= 0 {
+			debitos++
+			continue
+		}
+		creditos = append(creditos, t)
+	}
+	return creditos, debitos
+}
+
+// parAutomatico é um crédito e o pedido ao qual a conciliação automática o vincula
+type parAutomatico struct {
+	transacao transacaoPendente
+	pedido    pedidoPendente
+}
+
+// parear escolhe os vínculos da conciliação automática. Os pedidos vinculados são removidos
+// do mapa, para não serem oferecidos a outro crédito.
+func parear(transacoes []transacaoPendente, pedidos map[int]pedidoPendente) []parAutomatico {
+	var pares []parAutomatico
+	vincular := func(t transacaoPendente, p pedidoPendente) {
+		pares = append(pares, parAutomatico{transacao: t, pedido: p})
+		delete(pedidos, p.id)
+	}
+
+	// Primeira passagem: txid da cobrança Pix
+	var restantes []transacaoPendente
+	for _, t := range transacoes {
+		pedidoID, ok := pix.PedidoDoTxID(t.txid)
+		p, encontrado := pedidos[pedidoID]
+		if !ok || !encontrado || !mesmoValor(p.valor, t.valor) {
+			restantes = append(restantes, t)
+			continue
+		}
+		vincular(t, p)
+	}
+
+	// Segunda passagem: valor e data, só com correspondência única nos dois sentidos
+	candidatos := map[int][]pedidoPendente{}
+	transacoesPorPedido := map[int]int{}
+	for _, t := range restantes {
+		for _, p := range pedidos {
+			if mesmoValor(p.valor, t.valor) && dentroDaJanela(p, t.data) {
+				candidatos[t.id] = append(candidatos[t.id], p)
+				transacoesPorPedido[p.id]++
+			}
+		}
+	}
+	for _, t := range restantes {
+		if len(candidatos[t.id]) != 1 {
+			continue
+		}
+		p := candidatos[t.id][0]
+		if transacoesPorPedido[p.id] != 1 {
+			continue
+		}
+		vincular(t, p)
+	}
+
+	return pares
+}
+
+// Conciliar vincula manualmente uma transação a um pedido não cancelado, de qualquer forma de pagamento
+func Conciliar(tx *sql.Tx, transacaoID, pedidoID, usuarioID int) error {
+	var vinculado sql.NullInt64
+	err := tx.QueryRow("SELECT pedido_id FROM transacoes_bancarias WHERE id = $1 FOR UPDATE", transacaoID).Scan(&vinculado)
+	if err == sql.ErrNoRows {
+		return ErrTransacaoNaoEncontrada
+	}
+	if err != nil {
+		return err
+	}
+	if vinculado.Valid {
+		return fmt.Errorf("%w: transação vinculada ao pedido #%d", ErrJaConciliado, vinculado.Int64)
+	}
+
+	var status models.StatusPedido
+	err = tx.QueryRow("SELECT status FROM pedidos WHERE id = $1 FOR UPDATE", pedidoID).Scan(&status)
+	if err == sql.ErrNoRows {
+		return ErrPedidoNaoEncontrado
+	}
+	if err != nil {
+		return err
+	}
+	if status == models.StatusCancelado {
+		return fmt.Errorf("%w: pedido cancelado não pode ser conciliado", ErrDadosInvalidos)
+	}
+
+	var outra int
+	err = tx.QueryRow("SELECT id FROM transacoes_bancarias WHERE pedido_id = $1", pedidoID).Scan(&outra)
+	if err == nil {
+		return fmt.Errorf("%w: pedido vinculado à transação #%d", ErrJaConciliado, outra)
+	}
+	if err != sql.ErrNoRows {
+		return err
+	}
+
+	return vincularTransacao(tx, transacaoID, pedidoID, models.ConciliacaoManual, usuarioID)
+}
+
+// Desfazer remove o vínculo da transação com o pedido, que volta a ficar pendente
+func Desfazer(db *sql.DB, transacaoID int) error {
+	res, err := db.Exec(`
+		UPDATE transacoes_bancarias
+		SET pedido_id = NULL, conciliacao = NULL, conciliado_por = NULL, conciliado_em = NULL
+		WHERE id = $1
+	`, transacaoID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrTransacaoNaoEncontrada
+	}
+	return nil
+}
+
+func vincularTransacao(tx *sql.Tx, transacaoID, pedidoID int, tipo models.TipoConciliacao, usuarioID int) error {
+	_, err := tx.Exec(`
+		UPDATE transacoes_bancarias
+		SET pedido_id = $1, conciliacao = $2, conciliado_por = $3, conciliado_em = NOW()
+		WHERE id = $4 AND pedido_id IS NULL
+	`, pedidoID, tipo, usuarioID, transacaoID)
+	if err != nil {
+		return fmt.Errorf("erro ao conciliar transação #%d com o pedido #%d: %w", transacaoID, pedidoID, err)
+	}
+	return nil
+}
+
+func transacoesPendentes(tx *sql.Tx) ([]transacaoPendente, error) {
+	rows, err := tx.Query(`
+		SELECT id, data, valor, COALESCE(txid, '')
+		FROM transacoes_bancarias
+		WHERE pedido_id IS NULL
+		ORDER BY data, id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao buscar transações pendentes: %w", err)
+	}
+	defer rows.Close()
+
+	var transacoes []transacaoPendente
+	for rows.Next() {
+		var t transacaoPendente
+		if err := rows.Scan(&t.id, &t.data, &t.valor, &t.txid); err != nil {
+			return nil, err
+		}
+		transacoes = append(transacoes, t)
+	}
+	return transacoes, rows.Err()
+}
+
+// pedidosPendentes busca os pedidos entregues, pagos por Pix ou cartão e ainda sem crédito
+// vinculado, cuja janela de recebimento alcança o período das transações pendentes
+func pedidosPendentes(tx *sql.Tx, transacoes []transacaoPendente) (map[int]pedidoPendente, error) {
+	inicio, fim := transacoes[0].data, transacoes[0].data
+	for _, t := range transacoes {
+		if t.data.Before(inicio) {
+			inicio = t.data
+		}
+		if t.data.After(fim) {
+			fim = t.data
+		}
+	}
+	maiorJanela := 0
+	for _, dias := range janelaRecebimento {
+		if dias > maiorJanela {
+			maiorJanela = dias
+		}
+	}
+
+	rows, err := tx.Query(`
+		SELECT p.id, p.forma_pagamento, p.valor_total, COALESCE(p.data_entrega, p.criado_em)
+		FROM pedidos p
+		WHERE p.forma_pagamento IN ('pix', 'cartao_debito', 'cartao_credito')
+		AND p.status IN ('entregue', 'finalizado')
+		AND COALESCE(p.data_entrega, p.criado_em) >= $1
+		AND COALESCE(p.data_entrega, p.criado_em) < $2
+		AND NOT EXISTS (SELECT 1 FROM transacoes_bancarias tb WHERE tb.pedido_id = p.id)
+	`, inicio.AddDate(0, 0, -maiorJanela), fim.AddDate(0, 0, 2))
+	if err != nil {
+		return nil, fmt.Errorf("erro ao buscar pedidos pendentes: %w", err)
+	}
+	defer rows.Close()
+
+	pedidos := map[int]pedidoPendente{}
+	for rows.Next() {
+		var p pedidoPendente
+		if err := rows.Scan(&p.id, &p.forma, &p.valor, &p.entregue); err != nil {
+			return nil, err
+		}
+		pedidos[p.id] = p
+	}
+	return pedidos, rows.Err()
+}
+
+// dentroDaJanela compara só as datas: o extrato não traz o horário do crédito
+func dentroDaJanela(p pedidoPendente, dataCredito time.Time) bool {
+	entregue := time.Date(p.entregue.Year(), p.entregue.Month(), p.entregue.Day(), 0, 0, 0, 0, time.UTC)
+	credito := time.Date(dataCredito.Year(), dataCredito.Month(), dataCredito.Day(), 0, 0, 0, 0, time.UTC)
+	dias := int(credito.Sub(entregue).Hours() / 24)
+	return dias >= -1 && dias <= janelaRecebimento[p.forma]
+}
+
+func mesmoValor(a, b float64) bool {
+	return math.Abs(a-b) < 0.005
+}
@@ -0,0 +1,110 @@
+package conciliacao
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tassyosilva/GestGAS/internal/models"
+	"github.com/tassyosilva/GestGAS/internal/pix"
+)
+
+func TestParear(t *testing.T) {
+	dia := time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC)
+	entregue := time.Date(2024, 1, 10, 14, 30, 0, 0, time.UTC)
+
+	casos := []struct {
+		nome       string
+		transacoes []transacaoPendente
+		pedidos    []pedidoPendente
+		pares      map[int]int // transação -> pedido
+	}{
+		{
+			nome:       "txid com o mesmo valor",
+			transacoes: []transacaoPendente{{id: 1, data: dia, valor: 120, txid: pix.TxIDPedido(7)}},
+			pedidos: []pedidoPendente{
+				{id: 7, forma: models.PagamentoPix, valor: 120, entregue: entregue},
+				{id: 8, forma: models.PagamentoPix, valor: 120, entregue: entregue},
+			},
+			pares: map[int]int{1: 7},
+		},
+		{
+			nome:       "txid com valor diferente cai no pareamento por valor",
+			transacoes: []transacaoPendente{{id: 1, data: dia, valor: 100, txid: pix.TxIDPedido(7)}},
+			pedidos: []pedidoPendente{
+				{id: 7, forma: models.PagamentoPix, valor: 120, entregue: entregue},
+				{id: 8, forma: models.PagamentoPix, valor: 100, entregue: entregue},
+			},
+			pares: map[int]int{1: 8},
+		},
+		{
+			nome:       "txid com valor diferente e sem outro candidato",
+			transacoes: []transacaoPendente{{id: 1, data: dia, valor: 100, txid: pix.TxIDPedido(7)}},
+			pedidos:    []pedidoPendente{{id: 7, forma: models.PagamentoPix, valor: 120, entregue: entregue}},
+			pares:      map[int]int{},
+		},
+		{
+			nome:       "valor único dentro da janela",
+			transacoes: []transacaoPendente{{id: 1, data: dia.AddDate(0, 0, 2), valor: 95.5}},
+			pedidos:    []pedidoPendente{{id: 3, forma: models.PagamentoCartaoDebito, valor: 95.5, entregue: entregue}},
+			pares:      map[int]int{1: 3},
+		},
+		{
+			nome:       "valor fora da janela do Pix",
+			transacoes: []transacaoPendente{{id: 1, data: dia.AddDate(0, 0, 2), valor: 95.5}},
+			pedidos:    []pedidoPendente{{id: 3, forma: models.PagamentoPix, valor: 95.5, entregue: entregue}},
+			pares:      map[int]int{},
+		},
+		{
+			nome:       "dois pedidos de mesmo valor na janela",
+			transacoes: []transacaoPendente{{id: 1, data: dia, valor: 120}},
+			pedidos: []pedidoPendente{
+				{id: 7, forma: models.PagamentoPix, valor: 120, entregue: entregue},
+				{id: 8, forma: models.PagamentoCartaoDebito, valor: 120, entregue: entregue},
+			},
+			pares: map[int]int{},
+		},
+		{
+			nome: "dois créditos de mesmo valor para um pedido",
+			transacoes: []transacaoPendente{
+				{id: 1, data: dia, valor: 120},
+				{id: 2, data: dia, valor: 120},
+			},
+			pedidos: []pedidoPendente{{id: 7, forma: models.PagamentoPix, valor: 120, entregue: entregue}},
+			pares:   map[int]int{},
+		},
+		{
+			nome: "pedido vinculado pelo txid sai da disputa por valor",
+			transacoes: []transacaoPendente{
+				{id: 1, data: dia, valor: 120, txid: pix.TxIDPedido(7)},
+				{id: 2, data: dia, valor: 120},
+			},
+			pedidos: []pedidoPendente{
+				{id: 7, forma: models.PagamentoPix, valor: 120, entregue: entregue},
+				{id: 8, forma: models.PagamentoPix, valor: 120, entregue: entregue},
+			},
+			pares: map[int]int{1: 7, 2: 8},
+		},
+	}
+
+	for _, c := range casos {
+		t.Run(c.nome, func(t *testing.T) {
+			pedidos := map[int]pedidoPendente{}
+			for _, p := range c.pedidos {
+				pedidos[p.id] = p
+			}
+
+			pares := parear(c.transacoes, pedidos)
+			if len(pares) != len(c.pares) {
+				t.Fatalf("%d vínculos, esperados %d: %+v", len(pares), len(c.pares), pares)
+			}
+			for _, par := range pares {
+				if esperado, ok := c.pares[par.transacao.id]; !ok || esperado != par.pedido.id {
+					t.Errorf("transação #%d vinculada ao pedido #%d, esperado %v", par.transacao.id, par.pedido.id, c.pares[par.transacao.id])
+				}
+				if _, ok := pedidos[par.pedido.id]; ok {
+					t.Errorf("pedido #%d vinculado continua entre os pendentes", par.pedido.id)
+				}
+			}
+		})
+	}
+}
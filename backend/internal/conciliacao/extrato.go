@@ -0,0 +1,327 @@
+// Package conciliacao importa extratos bancários (OFX ou CSV) e vincula os créditos recebidos
+// aos pedidos pagos por Pix ou cartão, pelo txid do Pix ou pelo valor e pela data.
+package conciliacao
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/csv"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/tassyosilva/GestGAS/internal/pix"
+)
+
+// Formatos de extrato aceitos
+const (
+	FormatoOFX = "ofx"
+	FormatoCSV = "csv"
+)
+
+// Transacao é um lançamento lido do extrato. Créditos têm valor positivo.
+type Transacao struct {
+	Identificador string // Único entre importações: o mesmo lançamento importado de novo é ignorado
+	Data          time.Time
+	Valor         float64
+	Descricao     string
+	TxID          string // txid de cobrança de pedido encontrado na descrição, se houver
+}
+
+// padraoTxID encontra na descrição do lançamento o txid gerado por pix.TxIDPedido
+var padraoTxID = regexp.MustCompile(`PEDIDO\d+`)
+
+// LerExtrato identifica o formato pelo conteúdo e lê os lançamentos
+func LerExtrato(conteudo []byte) (string, []Transacao, error) {
+	texto := paraUTF8(conteudo)
+	inicio := strings.ToUpper(strings.TrimSpace(texto))
+	if len(inicio) > 500 {
+		inicio = inicio[:500]
+	}
+	if strings.HasPrefix(inicio, "OFXHEADER") || strings.Contains(inicio, "<OFX>") {
+		transacoes, err := lerOFX(texto)
+		return FormatoOFX, transacoes, err
+	}
+	transacoes, err := lerCSV(texto)
+	return FormatoCSV, transacoes, err
+}
+
+// lerOFX lê os lançamentos (STMTTRN) de um OFX 1.x (SGML, sem fechamento dos campos) ou 2.x (XML)
+func lerOFX(texto string) ([]Transacao, error) {
+	var transacoes []Transacao
+	var conta string
+	var atual map[string]string
+
+	for _, trecho := range strings.Split(texto, "<")[1:] {
+		fim := strings.IndexByte(trecho, '>')
+		if fim < 0 {
+			continue
+		}
+		tag := strings.ToUpper(strings.TrimSpace(trecho[:fim]))
+		valor := strings.TrimSpace(html.UnescapeString(trecho[fim+1:]))
+
+		switch {
+		case tag == "STMTTRN":
+			atual = map[string]string{}
+		case tag == "/STMTTRN":
+			if atual == nil {
+				continue
+			}
+			t, err := transacaoOFX(atual, conta)
+			if err != nil {
+				return nil, fmt.Errorf("lançamento %d: %w", len(transacoes)+1, err)
+			}
+			transacoes = append(transacoes, t)
+			atual = nil
+		case tag == "ACCTID":
+			conta = valor
+		case atual != nil && !strings.HasPrefix(tag, "/"):
+			atual[tag] = valor
+		}
+	}
+
+	if len(transacoes) == 0 {
+		return nil, errors.New("nenhum lançamento encontrado no OFX")
+	}
+	return transacoes, nil
+}
+
+func transacaoOFX(campos map[string]string, conta string) (Transacao, error) {
+	data := campos["DTPOSTED"]
+	if len(data) < 8 {
+		return Transacao{}, fmt.Errorf("data inválida: %q", data)
+	}
+	dia, err := time.Parse("20060102", data[:8])
+	if err != nil {
+		return Transacao{}, fmt.Errorf("data inválida: %q", data)
+	}
+	valor, err := lerValor(campos["TRNAMT"])
+	if err != nil {
+		return Transacao{}, err
+	}
+	if campos["FITID"] == "" {
+		return Transacao{}, errors.New("lançamento sem FITID")
+	}
+
+	descricao := strings.TrimSpace(campos["NAME"] + " " + campos["MEMO"])
+	return Transacao{
+		Identificador: "ofx:" + conta + ":" + campos["FITID"],
+		Data:          dia,
+		Valor:         valor,
+		Descricao:     descricao,
+		TxID:          encontrarTxID(descricao),
+	}, nil
+}
+
+// colunasExtrato mapeia os nomes de coluna usados pelos bancos (sem acentos, em minúsculas)
+var colunasExtrato = map[string]string{
+	"data":                "data",
+	"data lancamento":     "data",
+	"data do lancamento":  "data",
+	"data movimento":      "data",
+	"data da transacao":   "data",
+	"valor":               "valor",
+	"valor (r$)":          "valor",
+	"valor r$":            "valor",
+	"credito":             "credito",
+	"credito (r$)":        "credito",
+	"debito":              "debito",
+	"debito (r$)":         "debito",
+	"descricao":           "descricao",
+	"historico":           "descricao",
+	"lancamento":          "descricao",
+	"detalhes":            "descricao",
+	"identificador":       "identificador",
+	"id":                  "identificador",
+	"documento":           "identificador",
+	"numero do documento": "identificador",
+	"nr documento":        "identificador",
+}
+
+// lerCSV lê um extrato em CSV com cabeçalho. O separador (ponto e vírgula, vírgula ou tabulação)
+// é detectado pelo cabeçalho. O valor pode vir numa coluna com sinal ou em colunas de crédito e débito.
+func lerCSV(texto string) ([]Transacao, error) {
+	texto = strings.TrimPrefix(texto, "\ufeff")
+	primeiraLinha := texto
+	if i := strings.IndexByte(texto, '\n'); i >= 0 {
+		primeiraLinha = texto[:i]
+	}
+	separador := ';'
+	if strings.Count(primeiraLinha, "\t") > strings.Count(primeiraLinha, string(separador)) {
+		separador = '\t'
+	}
+	if strings.Count(primeiraLinha, ",") > strings.Count(primeiraLinha, string(separador)) {
+		separador = ','
+	}
+
+	leitor := csv.NewReader(strings.NewReader(texto))
+	leitor.Comma = separador
+	leitor.FieldsPerRecord = -1
+	leitor.LazyQuotes = true
+
+	cabecalho, err := leitor.Read()
+	if err != nil {
+		return nil, fmt.Errorf("erro ao ler cabeçalho do CSV: %w", err)
+	}
+	indice := map[string]int{}
+	for i, nome := range cabecalho {
+		if coluna, ok := colunasExtrato[normalizarColuna(nome)]; ok {
+			if _, repetida := indice[coluna]; !repetida {
+				indice[coluna] = i
+			}
+		}
+	}
+	if _, ok := indice["data"]; !ok {
+		return nil, errors.New("coluna de data não encontrada no CSV")
+	}
+	_, temValor := indice["valor"]
+	_, temCredito := indice["credito"]
+	if !temValor && !temCredito {
+		return nil, errors.New("coluna de valor (ou de crédito) não encontrada no CSV")
+	}
+
+	var transacoes []Transacao
+	ocorrencias := map[string]int{}
+	for linha := 2; ; linha++ {
+		registro, err := leitor.Read()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, fmt.Errorf("linha %d: %w", linha, err)
+		}
+		campo := func(coluna string) string {
+			i, ok := indice[coluna]
+			if !ok || i >= len(registro) {
+				return ""
+			}
+			return strings.TrimSpace(registro[i])
+		}
+		if strings.Join(registro, "") == "" {
+			continue
+		}
+
+		data, err := lerData(campo("data"))
+		if err != nil {
+			// Linhas de saldo e rodapés dos bancos não têm data
+			if campo("data") == "" || strings.Contains(strings.ToLower(campo("descricao")), "saldo") {
+				continue
+			}
+			return nil, fmt.Errorf("linha %d: %w", linha, err)
+		}
+
+		var valor float64
+		switch {
+		case temValor && campo("valor") != "":
+			valor, err = lerValor(campo("valor"))
+		case campo("credito") != "":
+			valor, err = lerValor(campo("credito"))
+			valor = math.Abs(valor)
+		case campo("debito") != "":
+			valor, err = lerValor(campo("debito"))
+			valor = -math.Abs(valor)
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("linha %d: %w", linha, err)
+		}
+
+		descricao := campo("descricao")
+		identificador := campo("identificador")
+		if identificador == "" {
+			// Sem identificador do banco, o lançamento é reconhecido pelo conteúdo e pela ordem em
+			// que aparece entre lançamentos iguais, para que reimportar o arquivo não o duplique
+			chave := fmt.Sprintf("%s|%.2f|%s", data.Format("2006-01-02"), valor, descricao)
+			ocorrencias[chave]++
+			soma := sha1.Sum([]byte(fmt.Sprintf("%s|%d", chave, ocorrencias[chave])))
+			identificador = hex.EncodeToString(soma[:])
+		}
+
+		transacoes = append(transacoes, Transacao{
+			Identificador: "csv:" + identificador,
+			Data:          data,
+			Valor:         valor,
+			Descricao:     descricao,
+			TxID:          encontrarTxID(descricao),
+		})
+	}
+
+	if len(transacoes) == 0 {
+		return nil, errors.New("nenhum lançamento encontrado no CSV")
+	}
+	return transacoes, nil
+}
+
+// lerValor interpreta valores como "1.234,56", "-50,00", "1234.56" ou "R$ 10,00"
+func lerValor(s string) (float64, error) {
+	original := s
+	s = strings.ReplaceAll(strings.TrimSpace(s), "R$", "")
+	s = strings.ReplaceAll(s, " ", "")
+	ultimaVirgula, ultimoPonto := strings.LastIndexByte(s, ','), strings.LastIndexByte(s, '.')
+	switch {
+	case ultimaVirgula > ultimoPonto:
+		s = strings.ReplaceAll(s, ".", "")
+		s = strings.Replace(s, ",", ".", 1)
+	case ultimoPonto > ultimaVirgula && ultimaVirgula >= 0:
+		s = strings.ReplaceAll(s, ",", "")
+	case strings.Count(s, ".") > 1:
+		s = strings.ReplaceAll(s, ".", "")
+	}
+	valor, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsNaN(valor) || math.IsInf(valor, 0) {
+		return 0, fmt.Errorf("valor inválido: %q", original)
+	}
+	return math.Round(valor*100) / 100, nil
+}
+
+// lerData aceita DD/MM/AAAA, DD/MM/AA, DD-MM-AAAA e AAAA-MM-DD, com ou sem horário
+func lerData(s string) (time.Time, error) {
+	if campos := strings.Fields(s); len(campos) > 0 {
+		s = campos[0]
+	}
+	for _, layout := range []string{"02/01/2006", "2006-01-02", "02-01-2006", "02/01/06"} {
+		if data, err := time.Parse(layout, s); err == nil {
+			return data, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("data inválida: %q", s)
+}
+
+// encontrarTxID procura na descrição o txid de cobrança de um pedido
+func encontrarTxID(descricao string) string {
+	for _, candidato := range padraoTxID.FindAllString(strings.ToUpper(descricao), -1) {
+		if _, ok := pix.PedidoDoTxID(candidato); ok {
+			return candidato
+		}
+	}
+	return ""
+}
+
+// normalizarColuna remove acentos, espaços extras e maiúsculas do nome da coluna
+func normalizarColuna(nome string) string {
+	nome = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(nome, "\ufeff")))
+	nome = strings.NewReplacer("á", "a", "â", "a", "ã", "a", "à", "a", "é", "e", "ê", "e",
+		"í", "i", "ó", "o", "ô", "o", "õ", "o", "ú", "u", "ç", "c").Replace(nome)
+	return strings.Join(strings.Fields(nome), " ")
+}
+
+// paraUTF8 converte extratos em Latin-1/Windows-1252, comuns nos bancos, para UTF-8
+func paraUTF8(conteudo []byte) string {
+	if utf8.Valid(conteudo) {
+		return string(conteudo)
+	}
+	var b bytes.Buffer
+	for _, c := range conteudo {
+		b.WriteRune(rune(c))
+	}
+	return b.String()
+}
@@ -0,0 +1,165 @@
+package conciliacao
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLerValor(t *testing.T) {
+	casos := []struct {
+		entrada string
+		valor   float64
+	}{
+		{"120,00", 120},
+		{"1.234,56", 1234.56},
+		{"-50,5", -50.5},
+		{"R$ 10,00", 10},
+		{"1234.56", 1234.56},
+		{"1,234.56", 1234.56},
+		{"1.234.567", 1234567},
+		{"99,999", 100},
+	}
+	for _, c := range casos {
+		valor, err := lerValor(c.entrada)
+		if err != nil {
+			t.Errorf("lerValor(%q) falhou: %v", c.entrada, err)
+			continue
+		}
+		if valor != c.valor {
+			t.Errorf("lerValor(%q) = %v, esperado %v", c.entrada, valor, c.valor)
+		}
+	}
+
+	for _, entrada := range []string{"", "abc", "NaN", "Inf"} {
+		if _, err := lerValor(entrada); err == nil {
+			t.Errorf("lerValor(%q) deveria falhar", entrada)
+		}
+	}
+}
+
+func TestLerExtrato(t *testing.T) {
+	casos := []struct {
+		nome      string
+		conteudo  string
+		formato   string
+		valores   []float64
+		debitos   int
+		repetidos int // lançamentos com o mesmo identificador de outro
+		txids     []string
+	}{
+		{
+			nome: "OFX com débito ignorado",
+			conteudo: `OFXHEADER:100
+<OFX><BANKACCTFROM><ACCTID>12345</BANKACCTFROM>
+<STMTTRN><TRNTYPE>CREDIT<DTPOSTED>20240105120000<TRNAMT>120.00<FITID>A1<MEMO>PIX RECEBIDO PEDIDO42</STMTTRN>
+<STMTTRN><TRNTYPE>DEBIT<DTPOSTED>20240105<TRNAMT>-35.90<FITID>A2<MEMO>TARIFA</STMTTRN>
+</OFX>`,
+			formato: FormatoOFX,
+			valores: []float64{120, -35.90},
+			debitos: 1,
+			txids:   []string{"PEDIDO42", ""},
+		},
+		{
+			nome: "OFX com FITID repetido",
+			conteudo: `<OFX><ACCTID>12345
+<STMTTRN><DTPOSTED>20240105<TRNAMT>80,00<FITID>DUP</STMTTRN>
+<STMTTRN><DTPOSTED>20240105<TRNAMT>80,00<FITID>DUP</STMTTRN>
+</OFX>`,
+			formato:   FormatoOFX,
+			valores:   []float64{80, 80},
+			repetidos: 1,
+			txids:     []string{"", ""},
+		},
+		{
+			nome: "CSV com vírgula decimal e coluna de débito",
+			conteudo: "Data;Histórico;Crédito (R$);Débito (R$)\n" +
+				"05/01/2024;Pix recebido pedido7;1.234,56;\n" +
+				"05/01/2024;Pagamento fornecedor;;350,00\n" +
+				";Saldo do dia;;\n",
+			formato: FormatoCSV,
+			valores: []float64{1234.56, -350},
+			debitos: 1,
+			txids:   []string{"PEDIDO7", ""},
+		},
+		{
+			nome: "CSV com lançamentos iguais sem identificador",
+			conteudo: "data,valor,descricao\n" +
+				"2024-01-05,\"10,00\",Pix\n" +
+				"2024-01-05,\"10,00\",Pix\n",
+			formato: FormatoCSV,
+			valores: []float64{10, 10},
+			txids:   []string{"", ""},
+		},
+	}
+
+	for _, c := range casos {
+		t.Run(c.nome, func(t *testing.T) {
+			formato, transacoes, err := LerExtrato([]byte(c.conteudo))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if formato != c.formato {
+				t.Errorf("formato = %s, esperado %s", formato, c.formato)
+			}
+			if len(transacoes) != len(c.valores) {
+				t.Fatalf("lidos %d lançamentos, esperados %d: %+v", len(transacoes), len(c.valores), transacoes)
+			}
+			identificadores := map[string]bool{}
+			repetidos := 0
+			for i, tr := range transacoes {
+				if tr.Valor != c.valores[i] {
+					t.Errorf("lançamento %d: valor %v, esperado %v", i+1, tr.Valor, c.valores[i])
+				}
+				if tr.TxID != c.txids[i] {
+					t.Errorf("lançamento %d: txid %q, esperado %q", i+1, tr.TxID, c.txids[i])
+				}
+				if identificadores[tr.Identificador] {
+					repetidos++
+				}
+				identificadores[tr.Identificador] = true
+			}
+			if repetidos != c.repetidos {
+				t.Errorf("%d identificadores repetidos, esperados %d", repetidos, c.repetidos)
+			}
+
+			creditos, debitos := separarCreditos(transacoes)
+			if debitos != c.debitos {
+				t.Errorf("%d débitos ignorados, esperados %d", debitos, c.debitos)
+			}
+			for _, tr := range creditos {
+				if tr.Valor <= 0 {
+					t.Errorf("débito %v entre os créditos", tr.Valor)
+				}
+			}
+		})
+	}
+}
+
+func TestLerExtratoReimportadoMantemIdentificadores(t *testing.T) {
+	conteudo := "data;valor;descricao\n05/01/2024;10,00;Pix\n05/01/2024;10,00;Pix\n"
+	_, primeira, err := LerExtrato([]byte(conteudo))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, segunda, err := LerExtrato([]byte(conteudo))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range primeira {
+		if primeira[i].Identificador != segunda[i].Identificador {
+			t.Errorf("lançamento %d mudou de identificador ao ser reimportado", i+1)
+		}
+	}
+}
+
+func TestLerExtratoSemLancamentos(t *testing.T) {
+	for _, conteudo := range []string{
+		"<OFX><ACCTID>1</OFX>",
+		"data;valor;descricao\n",
+		strings.Repeat("x", 10),
+	} {
+		if _, _, err := LerExtrato([]byte(conteudo)); err == nil {
+			t.Errorf("extrato %q deveria ser recusado", conteudo)
+		}
+	}
+}
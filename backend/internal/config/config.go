@@ -0,0 +1,394 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tassyosilva/GestGAS/internal/bina"
+	"github.com/tassyosilva/GestGAS/internal/pix"
+	"gopkg.in/yaml.v3"
+)
+
+// ArquivoPadrao é o arquivo de configuração lido quando GESTGAS_CONFIG não é informado
+const ArquivoPadrao = "gestgas.yaml"
+
+// Config reúne todas as configurações da aplicação
+type Config struct {
+	Banco    Banco    `yaml:"banco"`
+	Servidor Servidor `yaml:"servidor"`
+	CORS     CORS     `yaml:"cors"`
+	Auth     Auth     `yaml:"auth"`
+	Entregas Entregas `yaml:"entregas"`
+	Bina     Bina     `yaml:"bina"`
+	Pix      Pix      `yaml:"pix"`
+}
+
+// Banco contém os dados de conexão e o tamanho do pool do PostgreSQL
+type Banco struct {
+	DSN                string        `yaml:"dsn"` // Se informado, tem precedência sobre os campos individuais
+	Host               string        `yaml:"host"`
+	Porta              int           `yaml:"porta"`
+	Usuario            string        `yaml:"usuario"`
+	Senha              string        `yaml:"senha"`
+	Nome               string        `yaml:"nome"`
+	SSLMode            string        `yaml:"sslmode"`
+	MaxConexoesAbertas int           `yaml:"max_conexoes_abertas"`
+	MaxConexoesOciosas int           `yaml:"max_conexoes_ociosas"`
+	TempoVidaConexao   time.Duration `yaml:"tempo_vida_conexao"`
+}
+
+// Servidor contém o endereço de escuta e os timeouts do servidor HTTP
+type Servidor struct {
+	Endereco     string        `yaml:"endereco"`
+	ReadTimeout  time.Duration `yaml:"read_timeout"`
+	WriteTimeout time.Duration `yaml:"write_timeout"`
+	IdleTimeout  time.Duration `yaml:"idle_timeout"`
+}
+
+// CORS contém as origens autorizadas a chamar a API pelo navegador
+type CORS struct {
+	OrigensPermitidas []string `yaml:"origens_permitidas"`
+}
+
+// Auth contém a chave de assinatura e a validade dos tokens JWT e a política de senhas
+type Auth struct {
+	JWTSecret  string        `yaml:"jwt_secret"`
+	TokenTTL   time.Duration `yaml:"token_ttl"`   // Validade dos tokens de acesso
+	RefreshTTL time.Duration `yaml:"refresh_ttl"` // Sessão sem uso por mais tempo que isso exige novo login
+
+	SenhaTamanhoMinimo int `yaml:"senha_tamanho_minimo"` // Exigido ao cadastrar ou trocar senhas
+}
+
+// Entregas contém o ponto de partida usado no roteiro dos entregadores
+type Entregas struct {
+	DepositoLatitude  *float64 `yaml:"deposito_latitude"` // Se vazio, o roteiro começa pela primeira parada
+	DepositoLongitude *float64 `yaml:"deposito_longitude"`
+}
+
+// Bina contém a integração com o identificador de chamadas (bina) do PABX
+type Bina struct {
+	EnderecoTCP string   `yaml:"endereco_tcp"` // Se vazio, o receptor TCP não é iniciado
+	OrigensTCP  []string `yaml:"origens_tcp"`  // IPs ou faixas CIDR aceitos no receptor TCP, além da própria máquina
+	Token       string   `yaml:"token"`        // Token enviado pelo dispositivo no cabeçalho X-Bina-Token
+}
+
+// Pix contém o recebedor usado nos QR Codes de cobrança dos pedidos
+type Pix struct {
+	Chave         string `yaml:"chave"`          // Chave Pix da revenda; se vazia, a cobrança por Pix fica desativada
+	NomeRecebedor string `yaml:"nome_recebedor"` // Até 25 caracteres, exibido pelo banco do cliente
+	Cidade        string `yaml:"cidade"`         // Até 15 caracteres
+}
+
+// tamanhoMinimoJWTSecret evita chaves triviais na assinatura dos tokens
+const tamanhoMinimoJWTSecret = 16
+
+// Valores de exemplo do gestgas.example.yaml: são públicos e não podem ser usados em produção
+const (
+	exemploJWTSecret = "troque-esta-chave-por-uma-longa-e-aleatoria"
+	exemploBinaToken = "troque-este-token-do-bina"
+)
+
+// Limites aceitos para o tamanho mínimo das senhas; o bcrypt considera só os primeiros 72 bytes
+const (
+	tamanhoMinimoSenha = 6
+	tamanhoMaximoSenha = 72
+)
+
+// Padrao retorna a configuração usada quando nada é informado em arquivo ou ambiente
+func Padrao() Config {
+	return Config{
+		Banco: Banco{
+			Host:               "localhost",
+			Porta:              5432,
+			Usuario:            "postgres",
+			Nome:               "gestgas",
+			SSLMode:            "disable",
+			MaxConexoesAbertas: 25,
+			MaxConexoesOciosas: 5,
+			TempoVidaConexao:   30 * time.Minute,
+		},
+		Servidor: Servidor{
+			Endereco:     ":8080",
+			ReadTimeout:  10 * time.Second,
+			WriteTimeout: 10 * time.Second,
+			IdleTimeout:  120 * time.Second,
+		},
+		CORS: CORS{
+			OrigensPermitidas: []string{"http://localhost:3000"},
+		},
+		Auth: Auth{
+			TokenTTL:   15 * time.Minute,
+			RefreshTTL: 30 * 24 * time.Hour,
+
+			SenhaTamanhoMinimo: 8,
+		},
+	}
+}
+
+// Carregar monta a configuração a partir dos valores padrão, do arquivo YAML
+// (GESTGAS_CONFIG ou gestgas.yaml, se existir) e das variáveis de ambiente, nesta ordem
+func Carregar() (Config, error) {
+	cfg := Padrao()
+
+	arquivo, explicito := os.LookupEnv("GESTGAS_CONFIG")
+	if !explicito {
+		arquivo = ArquivoPadrao
+	}
+	if err := carregarArquivo(&cfg, arquivo, explicito); err != nil {
+		return cfg, err
+	}
+
+	if err := aplicarAmbiente(&cfg); err != nil {
+		return cfg, err
+	}
+
+	if err := cfg.Validar(); err != nil {
+		return cfg, err
+	}
+	return cfg, nil
+}
+
+// carregarArquivo lê o arquivo YAML sobre cfg; a ausência do arquivo padrão não é erro
+func carregarArquivo(cfg *Config, arquivo string, obrigatorio bool) error {
+	conteudo, err := os.ReadFile(arquivo)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) && !obrigatorio {
+			return nil
+		}
+		return fmt.Errorf("erro ao ler arquivo de configuração %s: %w", arquivo, err)
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(conteudo))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("erro ao interpretar arquivo de configuração %s: %w", arquivo, err)
+	}
+	return nil
+}
+
+// aplicarAmbiente sobrescreve cfg com as variáveis de ambiente GESTGAS_*
+func aplicarAmbiente(cfg *Config) error {
+	var erros []string
+
+	texto := func(chave string, destino *string) {
+		if valor, ok := os.LookupEnv(chave); ok {
+			*destino = valor
+		}
+	}
+	inteiro := func(chave string, destino *int) {
+		if valor, ok := os.LookupEnv(chave); ok {
+			n, err := strconv.Atoi(strings.TrimSpace(valor))
+			if err != nil {
+				erros = append(erros, fmt.Sprintf("%s: número inteiro inválido %q", chave, valor))
+				return
+			}
+			*destino = n
+		}
+	}
+	decimal := func(chave string, destino **float64) {
+		if valor, ok := os.LookupEnv(chave); ok {
+			f, err := strconv.ParseFloat(strings.TrimSpace(valor), 64)
+			if err != nil {
+				erros = append(erros, fmt.Sprintf("%s: número decimal inválido %q", chave, valor))
+				return
+			}
+			*destino = &f
+		}
+	}
+	duracao := func(chave string, destino *time.Duration) {
+		if valor, ok := os.LookupEnv(chave); ok {
+			d, err := time.ParseDuration(strings.TrimSpace(valor))
+			if err != nil {
+				erros = append(erros, fmt.Sprintf("%s: duração inválida %q (use por exemplo 10s, 5m, 24h)", chave, valor))
+				return
+			}
+			*destino = d
+		}
+	}
+
+	texto("GESTGAS_DB_DSN", &cfg.Banco.DSN)
+	texto("GESTGAS_DB_HOST", &cfg.Banco.Host)
+	inteiro("GESTGAS_DB_PORTA", &cfg.Banco.Porta)
+	texto("GESTGAS_DB_USUARIO", &cfg.Banco.Usuario)
+	texto("GESTGAS_DB_SENHA", &cfg.Banco.Senha)
+	texto("GESTGAS_DB_NOME", &cfg.Banco.Nome)
+	texto("GESTGAS_DB_SSLMODE", &cfg.Banco.SSLMode)
+	inteiro("GESTGAS_DB_MAX_CONEXOES_ABERTAS", &cfg.Banco.MaxConexoesAbertas)
+	inteiro("GESTGAS_DB_MAX_CONEXOES_OCIOSAS", &cfg.Banco.MaxConexoesOciosas)
+	duracao("GESTGAS_DB_TEMPO_VIDA_CONEXAO", &cfg.Banco.TempoVidaConexao)
+
+	texto("GESTGAS_ENDERECO", &cfg.Servidor.Endereco)
+	duracao("GESTGAS_READ_TIMEOUT", &cfg.Servidor.ReadTimeout)
+	duracao("GESTGAS_WRITE_TIMEOUT", &cfg.Servidor.WriteTimeout)
+	duracao("GESTGAS_IDLE_TIMEOUT", &cfg.Servidor.IdleTimeout)
+
+	if valor, ok := os.LookupEnv("GESTGAS_CORS_ORIGENS"); ok {
+		cfg.CORS.OrigensPermitidas = nil
+		for _, origem := range strings.Split(valor, ",") {
+			if origem = strings.TrimSpace(origem); origem != "" {
+				cfg.CORS.OrigensPermitidas = append(cfg.CORS.OrigensPermitidas, origem)
+			}
+		}
+	}
+
+	// JWT_SECRET é mantida por compatibilidade com instalações anteriores
+	texto("JWT_SECRET", &cfg.Auth.JWTSecret)
+	texto("GESTGAS_JWT_SECRET", &cfg.Auth.JWTSecret)
+	duracao("GESTGAS_TOKEN_TTL", &cfg.Auth.TokenTTL)
+	duracao("GESTGAS_REFRESH_TTL", &cfg.Auth.RefreshTTL)
+	inteiro("GESTGAS_SENHA_TAMANHO_MINIMO", &cfg.Auth.SenhaTamanhoMinimo)
+
+	decimal("GESTGAS_DEPOSITO_LATITUDE", &cfg.Entregas.DepositoLatitude)
+	decimal("GESTGAS_DEPOSITO_LONGITUDE", &cfg.Entregas.DepositoLongitude)
+
+	texto("GESTGAS_BINA_ENDERECO_TCP", &cfg.Bina.EnderecoTCP)
+	if valor, ok := os.LookupEnv("GESTGAS_BINA_ORIGENS_TCP"); ok {
+		cfg.Bina.OrigensTCP = nil
+		for _, origem := range strings.Split(valor, ",") {
+			if origem = strings.TrimSpace(origem); origem != "" {
+				cfg.Bina.OrigensTCP = append(cfg.Bina.OrigensTCP, origem)
+			}
+		}
+	}
+	texto("GESTGAS_BINA_TOKEN", &cfg.Bina.Token)
+
+	texto("GESTGAS_PIX_CHAVE", &cfg.Pix.Chave)
+	texto("GESTGAS_PIX_NOME_RECEBEDOR", &cfg.Pix.NomeRecebedor)
+	texto("GESTGAS_PIX_CIDADE", &cfg.Pix.Cidade)
+
+	if len(erros) > 0 {
+		return fmt.Errorf("configuração inválida:\n  - %s", strings.Join(erros, "\n  - "))
+	}
+	return nil
+}
+
+// Validar verifica se a configuração está completa e coerente
+func (c Config) Validar() error {
+	var erros []string
+
+	if c.Banco.DSN == "" {
+		if c.Banco.Host == "" {
+			erros = append(erros, "banco.host é obrigatório (GESTGAS_DB_HOST) quando banco.dsn não é informado")
+		}
+		if c.Banco.Porta <= 0 || c.Banco.Porta > 65535 {
+			erros = append(erros, fmt.Sprintf("banco.porta inválida: %d (GESTGAS_DB_PORTA)", c.Banco.Porta))
+		}
+		if c.Banco.Usuario == "" {
+			erros = append(erros, "banco.usuario é obrigatório (GESTGAS_DB_USUARIO) quando banco.dsn não é informado")
+		}
+		if c.Banco.Nome == "" {
+			erros = append(erros, "banco.nome é obrigatório (GESTGAS_DB_NOME) quando banco.dsn não é informado")
+		}
+	}
+	if c.Banco.MaxConexoesAbertas < 0 {
+		erros = append(erros, "banco.max_conexoes_abertas não pode ser negativo")
+	}
+	if c.Banco.MaxConexoesOciosas < 0 {
+		erros = append(erros, "banco.max_conexoes_ociosas não pode ser negativo")
+	}
+	if c.Banco.MaxConexoesAbertas > 0 && c.Banco.MaxConexoesOciosas > c.Banco.MaxConexoesAbertas {
+		erros = append(erros, "banco.max_conexoes_ociosas não pode ser maior que banco.max_conexoes_abertas")
+	}
+	if c.Banco.TempoVidaConexao < 0 {
+		erros = append(erros, "banco.tempo_vida_conexao não pode ser negativo")
+	}
+
+	if c.Servidor.Endereco == "" {
+		erros = append(erros, "servidor.endereco é obrigatório (GESTGAS_ENDERECO), por exemplo :8080")
+	}
+	if c.Servidor.ReadTimeout <= 0 || c.Servidor.WriteTimeout <= 0 || c.Servidor.IdleTimeout <= 0 {
+		erros = append(erros, "os timeouts do servidor devem ser maiores que zero")
+	}
+
+	for _, origem := range c.CORS.OrigensPermitidas {
+		if origem == "*" {
+			continue
+		}
+		u, err := url.Parse(origem)
+		if err != nil || u.Scheme == "" || u.Host == "" || (u.Path != "" && u.Path != "/") {
+			erros = append(erros, fmt.Sprintf("origem CORS inválida: %q (use esquema://host[:porta])", origem))
+		}
+	}
+
+	if c.Auth.JWTSecret == "" {
+		erros = append(erros, "auth.jwt_secret é obrigatório (GESTGAS_JWT_SECRET)")
+	} else if len(c.Auth.JWTSecret) < tamanhoMinimoJWTSecret {
+		erros = append(erros, fmt.Sprintf("auth.jwt_secret deve ter pelo menos %d caracteres", tamanhoMinimoJWTSecret))
+	} else if c.Auth.JWTSecret == exemploJWTSecret {
+		erros = append(erros, "auth.jwt_secret ainda é o valor do arquivo de exemplo; gere uma chave aleatória (GESTGAS_JWT_SECRET)")
+	}
+	if c.Auth.TokenTTL <= 0 {
+		erros = append(erros, "auth.token_ttl deve ser maior que zero (GESTGAS_TOKEN_TTL)")
+	}
+	if c.Auth.RefreshTTL < c.Auth.TokenTTL {
+		erros = append(erros, "auth.refresh_ttl deve ser maior ou igual a auth.token_ttl (GESTGAS_REFRESH_TTL)")
+	}
+	if c.Auth.SenhaTamanhoMinimo < tamanhoMinimoSenha || c.Auth.SenhaTamanhoMinimo > tamanhoMaximoSenha {
+		erros = append(erros, fmt.Sprintf("auth.senha_tamanho_minimo deve estar entre %d e %d (GESTGAS_SENHA_TAMANHO_MINIMO)",
+			tamanhoMinimoSenha, tamanhoMaximoSenha))
+	}
+
+	lat, lon := c.Entregas.DepositoLatitude, c.Entregas.DepositoLongitude
+	if (lat == nil) != (lon == nil) {
+		erros = append(erros, "entregas.deposito_latitude e entregas.deposito_longitude devem ser informadas juntas")
+	} else if lat != nil && (*lat < -90 || *lat > 90 || *lon < -180 || *lon > 180) {
+		erros = append(erros, "coordenadas do depósito fora dos limites válidos")
+	}
+
+	if c.Bina.Token != "" && len(c.Bina.Token) < tamanhoMinimoJWTSecret {
+		erros = append(erros, fmt.Sprintf("bina.token deve ter pelo menos %d caracteres", tamanhoMinimoJWTSecret))
+	} else if c.Bina.Token == exemploBinaToken {
+		erros = append(erros, "bina.token ainda é o valor do arquivo de exemplo; gere um token aleatório (GESTGAS_BINA_TOKEN)")
+	}
+	if _, err := bina.LerOrigens(c.Bina.OrigensTCP); err != nil {
+		erros = append(erros, err.Error()+" (GESTGAS_BINA_ORIGENS_TCP)")
+	}
+
+	if c.Pix.Chave != "" {
+		if err := pix.ValidarRecebedor(c.Pix.Chave, c.Pix.NomeRecebedor, c.Pix.Cidade); err != nil {
+			erros = append(erros, "pix: "+err.Error()+" (GESTGAS_PIX_CHAVE, GESTGAS_PIX_NOME_RECEBEDOR, GESTGAS_PIX_CIDADE)")
+		}
+	}
+
+	if len(erros) > 0 {
+		return fmt.Errorf("configuração inválida:\n  - %s", strings.Join(erros, "\n  - "))
+	}
+	return nil
+}
+
+// StringConexao retorna a string de conexão do PostgreSQL
+func (b Banco) StringConexao() string {
+	if b.DSN != "" {
+		return b.DSN
+	}
+	partes := []string{
+		"host=" + valorConexao(b.Host),
+		"port=" + strconv.Itoa(b.Porta),
+		"user=" + valorConexao(b.Usuario),
+		"dbname=" + valorConexao(b.Nome),
+	}
+	if b.Senha != "" {
+		partes = append(partes, "password="+valorConexao(b.Senha))
+	}
+	if b.SSLMode != "" {
+		partes = append(partes, "sslmode="+valorConexao(b.SSLMode))
+	}
+	return strings.Join(partes, " ")
+}
+
+// valorConexao escapa um valor no formato chave=valor aceito pelo lib/pq
+func valorConexao(valor string) string {
+	if valor != "" && !strings.ContainsAny(valor, ` '\`) {
+		return valor
+	}
+	valor = strings.ReplaceAll(valor, `\`, `\\`)
+	valor = strings.ReplaceAll(valor, `'`, `\'`)
+	return "'" + valor + "'"
+}
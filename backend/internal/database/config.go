@@ -6,27 +6,23 @@ import (
 	"log"
 
 	_ "github.com/lib/pq"
+	"github.com/tassyosilva/GestGAS/internal/config"
+	"github.com/tassyosilva/GestGAS/internal/permissao"
 	"golang.org/x/crypto/bcrypt"
 )
 
-// Configuração do banco de dados
-const (
-	host     = "192.168.1.106"
-	port     = 5432
-	user     = "postgres"
-	password = "123456"
-	dbname   = "gestgas"
-)
-
 // Conectar estabelece a conexão com o banco de dados PostgreSQL
-func Conectar() (*sql.DB, error) {
-	psqlInfo := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=disable",
-		host, port, user, password, dbname)
-	db, err := sql.Open("postgres", psqlInfo)
+func Conectar(cfg config.Banco) (*sql.DB, error) {
+	db, err := sql.Open("postgres", cfg.StringConexao())
 	if err != nil {
 		return nil, fmt.Errorf("não foi possível conectar ao banco de dados: %w", err)
 	}
 
+	// Configurar o pool de conexões
+	db.SetMaxOpenConns(cfg.MaxConexoesAbertas)
+	db.SetMaxIdleConns(cfg.MaxConexoesOciosas)
+	db.SetConnMaxLifetime(cfg.TempoVidaConexao)
+
 	// Verificar conexão com o banco de dados
 	err = db.Ping()
 	if err != nil {
@@ -37,172 +33,37 @@ func Conectar() (*sql.DB, error) {
 	return db, nil
 }
 
-// InicializarBancoDados cria as tabelas necessárias se não existirem
-func InicializarBancoDados(db *sql.DB) error {
-	// Criar tabela de usuários
-	_, err := db.Exec(`
-CREATE TABLE IF NOT EXISTS usuarios (
-id SERIAL PRIMARY KEY,
-nome VARCHAR(100) NOT NULL,
-login VARCHAR(50) UNIQUE NOT NULL,
-senha VARCHAR(255) NOT NULL,
-cpf VARCHAR(14) UNIQUE,
-email VARCHAR(100) UNIQUE,
-perfil VARCHAR(20) NOT NULL,
-criado_em TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
-atualizado_em TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
-)
-`)
-	if err != nil {
-		return fmt.Errorf("erro ao criar tabela de usuários: %w", err)
-	}
-	// Criar tabela de produtos
-	_, err = db.Exec(`
-CREATE TABLE IF NOT EXISTS produtos (
-id SERIAL PRIMARY KEY,
-nome VARCHAR(100) NOT NULL,
-descricao TEXT,
-categoria VARCHAR(50) NOT NULL,
-preco DECIMAL(10, 2) NOT NULL,
-criado_em TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
-atualizado_em TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
-)
-`)
-	if err != nil {
-		return fmt.Errorf("erro ao criar tabela de produtos: %w", err)
-	}
-	// Criar tabela de clientes
-	_, err = db.Exec(`
-CREATE TABLE IF NOT EXISTS clientes (
-id SERIAL PRIMARY KEY,
-nome VARCHAR(100) NOT NULL,
-telefone VARCHAR(20) NOT NULL,
-cpf VARCHAR(14) UNIQUE,
-email VARCHAR(100) UNIQUE,
-endereco VARCHAR(255),
-complemento VARCHAR(100),
-bairro VARCHAR(100),
-cidade VARCHAR(100),
-estado VARCHAR(2),
-cep VARCHAR(10),
-observacoes TEXT,
-canal_origem VARCHAR(20),
-criado_em TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
-atualizado_em TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
-)
-`)
-	if err != nil {
-		return fmt.Errorf("erro ao criar tabela de clientes: %w", err)
+// exigirTrocaSenhaAdminPadrao marca para troca a senha do admin de instalações anteriores que
+// ainda usam a senha inicial
+func exigirTrocaSenhaAdminPadrao(db *sql.DB) error {
+	var id int
+	var senhaHash string
+	err := db.QueryRow("SELECT id, senha FROM usuarios WHERE login = 'admin' AND NOT deve_trocar_senha").Scan(&id, &senhaHash)
+	if err == sql.ErrNoRows {
+		return nil
 	}
-	// Criar tabela de pedidos
-	_, err = db.Exec(`
-CREATE TABLE IF NOT EXISTS pedidos (
-id SERIAL PRIMARY KEY,
-cliente_id INTEGER NOT NULL REFERENCES clientes(id),
-atendente_id INTEGER NOT NULL REFERENCES usuarios(id),
-entregador_id INTEGER REFERENCES usuarios(id),
-status VARCHAR(20) NOT NULL,
-forma_pagamento VARCHAR(20) NOT NULL,
-valor_total DECIMAL(10, 2) NOT NULL,
-observacoes TEXT,
-endereco_entrega VARCHAR(255) NOT NULL,
-canal_origem VARCHAR(20),
-data_entrega TIMESTAMP WITH TIME ZONE,
-criado_em TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
-atualizado_em TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
-)
-`)
 	if err != nil {
-		return fmt.Errorf("erro ao criar tabela de pedidos: %w", err)
+		return fmt.Errorf("erro ao verificar senha do usuário admin: %w", err)
 	}
-	// Criar tabela de itens de pedido
-	_, err = db.Exec(`
-CREATE TABLE IF NOT EXISTS itens_pedido (
-id SERIAL PRIMARY KEY,
-pedido_id INTEGER NOT NULL REFERENCES pedidos(id),
-produto_id INTEGER NOT NULL REFERENCES produtos(id),
-quantidade INTEGER NOT NULL,
-preco_unitario DECIMAL(10, 2) NOT NULL,
-subtotal DECIMAL(10, 2) NOT NULL,
-retorna_botija BOOLEAN DEFAULT FALSE
-)
-`)
-	if err != nil {
-		return fmt.Errorf("erro ao criar tabela de itens de pedido: %w", err)
+	if bcrypt.CompareHashAndPassword([]byte(senhaHash), []byte("admin")) != nil {
+		return nil
 	}
-	// Criar tabela de estoque
-	_, err = db.Exec(`
-CREATE TABLE IF NOT EXISTS estoque (
-id SERIAL PRIMARY KEY,
-produto_id INTEGER NOT NULL REFERENCES produtos(id),
-quantidade INTEGER NOT NULL DEFAULT 0,
-botijas_vazias INTEGER DEFAULT 0,
-botijas_emprestadas INTEGER DEFAULT 0,
-alerta_minimo INTEGER,
-criado_em TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
-atualizado_em TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
-)
-`)
-	if err != nil {
-		return fmt.Errorf("erro ao criar tabela de estoque: %w", err)
+	if _, err := db.Exec("UPDATE usuarios SET deve_trocar_senha = TRUE WHERE id = $1", id); err != nil {
+		return fmt.Errorf("erro ao marcar troca de senha do usuário admin: %w", err)
 	}
-	// Criar tabela de movimentações de estoque
-	_, err = db.Exec(`
-CREATE TABLE IF NOT EXISTS movimentacoes_estoque (
-id SERIAL PRIMARY KEY,
-produto_id INTEGER NOT NULL REFERENCES produtos(id),
-tipo VARCHAR(20) NOT NULL,
-quantidade INTEGER NOT NULL,
-observacoes TEXT,
-usuario_id INTEGER NOT NULL REFERENCES usuarios(id),
-pedido_id INTEGER REFERENCES pedidos(id),
-criado_em TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
-)
-`)
-	if err != nil {
-		return fmt.Errorf("erro ao criar tabela de movimentações de estoque: %w", err)
-	}
-	// Criar tabela de vendas fiadas
-	_, err = db.Exec(`
-CREATE TABLE IF NOT EXISTS vendas_fiadas (
-id SERIAL PRIMARY KEY,
-pedido_id INTEGER NOT NULL REFERENCES pedidos(id),
-cliente_id INTEGER NOT NULL REFERENCES clientes(id),
-valor_total DECIMAL(10, 2) NOT NULL,
-data_vencimento TIMESTAMP WITH TIME ZONE NOT NULL,
-status VARCHAR(20) NOT NULL,
-data_pagamento TIMESTAMP WITH TIME ZONE,
-criado_em TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
-atualizado_em TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
-)
-`)
-	if err != nil {
-		return fmt.Errorf("erro ao criar tabela de vendas fiadas: %w", err)
-	}
-	// Criar tabela de vendas antecipadas
-	_, err = db.Exec(`
-CREATE TABLE IF NOT EXISTS vendas_antecipadas (
-id SERIAL PRIMARY KEY,
-cliente_id INTEGER NOT NULL REFERENCES clientes(id),
-produto_id INTEGER NOT NULL REFERENCES produtos(id),
-quantidade INTEGER NOT NULL,
-valor_total DECIMAL(10, 2) NOT NULL,
-forma_pagamento VARCHAR(20) NOT NULL,
-data_pagamento TIMESTAMP WITH TIME ZONE NOT NULL,
-data_entrega_prevista TIMESTAMP WITH TIME ZONE NOT NULL,
-status VARCHAR(20) NOT NULL,
-pedido_id INTEGER REFERENCES pedidos(id),
-criado_em TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
-atualizado_em TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
-)
-`)
-	if err != nil {
-		return fmt.Errorf("erro ao criar tabela de vendas antecipadas: %w", err)
+	log.Println("O usuário admin ainda usa a senha inicial e deverá trocá-la no próximo acesso")
+	return nil
+}
+
+// InicializarBancoDados aplica as migrações pendentes e cadastra os dados iniciais
+func InicializarBancoDados(db *sql.DB) error {
+	if _, err := MigrarParaCima(db); err != nil {
+		return fmt.Errorf("erro ao aplicar migrações: %w", err)
 	}
 
 	// Verificar se já existe um usuário administrador
 	var count int
-	err = db.QueryRow("SELECT COUNT(*) FROM usuarios WHERE login = 'admin'").Scan(&count)
+	err := db.QueryRow("SELECT COUNT(*) FROM usuarios WHERE login = 'admin'").Scan(&count)
 	if err != nil {
 		return fmt.Errorf("erro ao verificar usuário admin: %w", err)
 	}
@@ -213,14 +74,17 @@ atualizado_em TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
 		if err != nil {
 			return fmt.Errorf("erro ao gerar hash de senha: %w", err)
 		}
+		// A senha inicial é conhecida, então precisa ser trocada no primeiro acesso
 		_, err = db.Exec(`
-INSERT INTO usuarios (nome, login, senha, cpf, email, perfil)
-VALUES ('Administrador', 'admin', $1, '000.000.000-00', 'admin@gestgas.com', 'admin')
+INSERT INTO usuarios (nome, login, senha, cpf, email, perfil, deve_trocar_senha)
+VALUES ('Administrador', 'admin', $1, '000.000.000-00', 'admin@gestgas.com', 'admin', TRUE)
 `, string(senhaHash))
 		if err != nil {
 			return fmt.Errorf("erro ao criar usuário admin: %w", err)
 		}
-		fmt.Println("Usuário administrador criado com sucesso!")
+		fmt.Println("Usuário administrador criado com sucesso! Troque a senha inicial no primeiro acesso.")
+	} else if err := exigirTrocaSenhaAdminPadrao(db); err != nil {
+		return err
 	}
 	// Inserir alguns produtos iniciais se ainda não existirem
 	err = db.QueryRow("SELECT COUNT(*) FROM produtos").Scan(&count)
@@ -256,46 +120,9 @@ SELECT id, 20, 5 FROM produtos
 		fmt.Println("Produtos e estoque inicial configurados com sucesso!")
 	}
 
-	// Verificar se a coluna canal_origem existe na tabela pedidos
-	var columnExists bool
-	err = db.QueryRow(`
-SELECT EXISTS (
-SELECT 1
-FROM information_schema.columns
-WHERE table_name = 'pedidos' AND column_name = 'canal_origem'
-)
-`).Scan(&columnExists)
-	if err != nil {
-		log.Printf("Erro ao verificar coluna canal_origem: %v", err)
-	} else if !columnExists {
-		// Adicionar a coluna canal_origem se não existir
-		_, err = db.Exec(`ALTER TABLE pedidos ADD COLUMN canal_origem VARCHAR(20)`)
-		if err != nil {
-			log.Printf("Erro ao adicionar coluna canal_origem: %v", err)
-		} else {
-			log.Println("Coluna canal_origem adicionada com sucesso à tabela pedidos")
-		}
-	}
-
-	// Adicionar o campo motivo_cancelamento à verificação de colunas
-	var motivo_cancelamento_exists bool
-	err = db.QueryRow(`
-    SELECT EXISTS (
-        SELECT 1 
-        FROM information_schema.columns 
-        WHERE table_name = 'pedidos' AND column_name = 'motivo_cancelamento'
-    )
-`).Scan(&motivo_cancelamento_exists)
-	if err != nil {
-		log.Printf("Erro ao verificar coluna motivo_cancelamento: %v", err)
-	} else if !motivo_cancelamento_exists {
-		// Adicionar a coluna motivo_cancelamento se não existir
-		_, err = db.Exec(`ALTER TABLE pedidos ADD COLUMN motivo_cancelamento TEXT`)
-		if err != nil {
-			log.Printf("Erro ao adicionar coluna motivo_cancelamento: %v", err)
-		} else {
-			log.Println("Coluna motivo_cancelamento adicionada com sucesso à tabela pedidos")
-		}
+	// Gravar as permissões novas do catálogo e carregar a matriz de permissões
+	if err := permissao.Sincronizar(db); err != nil {
+		return fmt.Errorf("erro ao sincronizar permissões: %w", err)
 	}
 
 	log.Println("Banco de dados inicializado com sucesso!")
@@ -0,0 +1,353 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"log"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// arquivosMigracoes contém os arquivos NNNN_nome.up.sql e NNNN_nome.down.sql embutidos no binário
+//
+//go:embed migrations/*.sql
+var arquivosMigracoes embed.FS
+
+// chaveBloqueioMigracoes identifica o advisory lock que impede duas instâncias de migrar ao mesmo tempo
+const chaveBloqueioMigracoes int64 = 4743534741
+
+// versaoEsquemaInicial é a migração que corresponde às tabelas criadas antes do controle de versões
+const versaoEsquemaInicial = 1
+
+// Migracao representa uma alteração versionada do esquema do banco de dados
+type Migracao struct {
+	Versao int
+	Nome   string
+	Up     string
+	Down   string
+}
+
+// EstadoMigracao indica se uma migração já foi aplicada ao banco de dados
+type EstadoMigracao struct {
+	Versao     int
+	Nome       string
+	Aplicada   bool
+	AplicadaEm *time.Time
+}
+
+// carregarMigracoes lê as migrações embutidas, ordenadas por versão
+func carregarMigracoes() ([]Migracao, error) {
+	entradas, err := arquivosMigracoes.ReadDir("migrations")
+	if err != nil {
+		return nil, fmt.Errorf("erro ao ler migrações: %w", err)
+	}
+
+	porVersao := map[int]*Migracao{}
+	for _, entrada := range entradas {
+		arquivo := entrada.Name()
+		var direcao string
+		switch {
+		case strings.HasSuffix(arquivo, ".up.sql"):
+			direcao = "up"
+		case strings.HasSuffix(arquivo, ".down.sql"):
+			direcao = "down"
+		default:
+			return nil, fmt.Errorf("arquivo de migração com nome inválido: %s", arquivo)
+		}
+
+		base := strings.TrimSuffix(arquivo, "."+direcao+".sql")
+		separador := strings.Index(base, "_")
+		if separador <= 0 {
+			return nil, fmt.Errorf("arquivo de migração com nome inválido: %s", arquivo)
+		}
+		versao, err := strconv.Atoi(base[:separador])
+		if err != nil || versao <= 0 {
+			return nil, fmt.Errorf("versão inválida no arquivo de migração: %s", arquivo)
+		}
+
+		conteudo, err := arquivosMigracoes.ReadFile(path.Join("migrations", arquivo))
+		if err != nil {
+			return nil, fmt.Errorf("erro ao ler migração %s: %w", arquivo, err)
+		}
+
+		m, existe := porVersao[versao]
+		if !existe {
+			m = &Migracao{Versao: versao, Nome: base[separador+1:]}
+			porVersao[versao] = m
+		} else if m.Nome != base[separador+1:] {
+			return nil, fmt.Errorf("versão %04d usada por mais de uma migração", versao)
+		}
+		if direcao == "up" {
+			m.Up = string(conteudo)
+		} else {
+			m.Down = string(conteudo)
+		}
+	}
+
+	migracoes := make([]Migracao, 0, len(porVersao))
+	for _, m := range porVersao {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migração %04d_%s deve ter arquivos up e down", m.Versao, m.Nome)
+		}
+		migracoes = append(migracoes, *m)
+	}
+	sort.Slice(migracoes, func(i, j int) bool { return migracoes[i].Versao < migracoes[j].Versao })
+	return migracoes, nil
+}
+
+// MigrarParaCima aplica todas as migrações pendentes, em ordem, e retorna quantas foram aplicadas
+func MigrarParaCima(db *sql.DB) (int, error) {
+	migracoes, err := carregarMigracoes()
+	if err != nil {
+		return 0, err
+	}
+
+	aplicadas := 0
+	err = comBloqueioMigracoes(db, func(conn *sql.Conn) error {
+		versoes, err := versoesAplicadas(conn)
+		if err != nil {
+			return err
+		}
+		for _, m := range migracoes {
+			if _, ok := versoes[m.Versao]; ok {
+				continue
+			}
+			if err := executarMigracao(conn, m, m.Up, true); err != nil {
+				return err
+			}
+			log.Printf("Migração %04d_%s aplicada", m.Versao, m.Nome)
+			aplicadas++
+		}
+		return nil
+	})
+	return aplicadas, err
+}
+
+// MigrarParaBaixo reverte as últimas migrações aplicadas e retorna quantas foram revertidas
+func MigrarParaBaixo(db *sql.DB, passos int) (int, error) {
+	if passos <= 0 {
+		return 0, fmt.Errorf("número de migrações a reverter deve ser maior que zero")
+	}
+	migracoes, err := carregarMigracoes()
+	if err != nil {
+		return 0, err
+	}
+
+	revertidas := 0
+	err = comBloqueioMigracoes(db, func(conn *sql.Conn) error {
+		versoes, err := versoesAplicadas(conn)
+		if err != nil {
+			return err
+		}
+		for i := len(migracoes) - 1; i >= 0 && revertidas < passos; i-- {
+			m := migracoes[i]
+			if _, ok := versoes[m.Versao]; !ok {
+				continue
+			}
+			if err := executarMigracao(conn, m, m.Down, false); err != nil {
+				return err
+			}
+			log.Printf("Migração %04d_%s revertida", m.Versao, m.Nome)
+			revertidas++
+		}
+		return nil
+	})
+	return revertidas, err
+}
+
+// StatusMigracoes lista as migrações conhecidas e se cada uma já foi aplicada. Só lê o banco:
+// não cria schema_migrations nem marca instalações antigas, o que fica para up e down.
+// semVersao indica um banco criado antes do controle de versões, que será marcado na versão
+// do esquema inicial na próxima execução de up ou down.
+func StatusMigracoes(db *sql.DB) (estados []EstadoMigracao, semVersao bool, err error) {
+	migracoes, err := carregarMigracoes()
+	if err != nil {
+		return nil, false, err
+	}
+
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, false, fmt.Errorf("erro ao obter conexão para migrações: %w", err)
+	}
+	defer conn.Close()
+
+	var controleExiste bool
+	err = conn.QueryRowContext(ctx, "SELECT to_regclass('schema_migrations') IS NOT NULL").Scan(&controleExiste)
+	if err != nil {
+		return nil, false, fmt.Errorf("erro ao consultar schema_migrations: %w", err)
+	}
+
+	versoes := map[int]time.Time{}
+	if controleExiste {
+		versoes, err = versoesAplicadas(conn)
+		if err != nil {
+			return nil, false, err
+		}
+	}
+	if len(versoes) == 0 {
+		semVersao, err = instalacaoExistente(conn)
+		if err != nil {
+			return nil, false, err
+		}
+	}
+
+	for _, m := range migracoes {
+		estado := EstadoMigracao{Versao: m.Versao, Nome: m.Nome}
+		if aplicadaEm, ok := versoes[m.Versao]; ok {
+			estado.Aplicada = true
+			estado.AplicadaEm = &aplicadaEm
+		}
+		estados = append(estados, estado)
+	}
+	return estados, semVersao, nil
+}
+
+// comBloqueioMigracoes executa fn numa conexão dedicada, protegida pelo advisory lock de migrações.
+// Também garante que a tabela schema_migrations exista e que instalações antigas sejam marcadas.
+func comBloqueioMigracoes(db *sql.DB, fn func(conn *sql.Conn) error) error {
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("erro ao obter conexão para migrações: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", chaveBloqueioMigracoes); err != nil {
+		return fmt.Errorf("erro ao obter bloqueio de migrações: %w", err)
+	}
+	defer conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", chaveBloqueioMigracoes)
+
+	_, err = conn.ExecContext(ctx, `
+CREATE TABLE IF NOT EXISTS schema_migrations (
+versao INTEGER PRIMARY KEY,
+nome VARCHAR(255) NOT NULL,
+aplicada_em TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+)
+`)
+	if err != nil {
+		return fmt.Errorf("erro ao criar tabela schema_migrations: %w", err)
+	}
+
+	if err := marcarInstalacaoExistente(conn); err != nil {
+		return err
+	}
+	return fn(conn)
+}
+
+// marcarInstalacaoExistente detecta bancos criados antes do controle de versões
+// (tabelas existentes, schema_migrations vazia) e os registra na versão do esquema inicial
+func marcarInstalacaoExistente(conn *sql.Conn) error {
+	ctx := context.Background()
+
+	var totalVersoes int
+	err := conn.QueryRowContext(ctx, "SELECT COUNT(*) FROM schema_migrations").Scan(&totalVersoes)
+	if err != nil {
+		return fmt.Errorf("erro ao consultar schema_migrations: %w", err)
+	}
+	if totalVersoes > 0 {
+		return nil
+	}
+
+	existente, err := instalacaoExistente(conn)
+	if err != nil || !existente {
+		return err
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("erro ao iniciar transação: %w", err)
+	}
+	defer tx.Rollback() // sem efeito após o commit
+
+	// Instalações antigas podem não ter recebido as colunas adicionadas manualmente
+	_, err = tx.Exec(`
+ALTER TABLE pedidos ADD COLUMN IF NOT EXISTS canal_origem VARCHAR(20);
+ALTER TABLE pedidos ADD COLUMN IF NOT EXISTS motivo_cancelamento TEXT;
+`)
+	if err != nil {
+		return fmt.Errorf("erro ao ajustar colunas da instalação existente: %w", err)
+	}
+
+	migracoes, err := carregarMigracoes()
+	if err != nil {
+		return err
+	}
+	for _, m := range migracoes {
+		if m.Versao != versaoEsquemaInicial {
+			continue
+		}
+		_, err = tx.Exec("INSERT INTO schema_migrations (versao, nome) VALUES ($1, $2)", m.Versao, m.Nome)
+		if err != nil {
+			return fmt.Errorf("erro ao marcar versão do esquema inicial: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("erro ao finalizar transação: %w", err)
+	}
+	log.Printf("Banco de dados existente detectado e marcado na versão %04d", versaoEsquemaInicial)
+	return nil
+}
+
+// instalacaoExistente indica se o banco já tem as tabelas do sistema
+func instalacaoExistente(conn *sql.Conn) (bool, error) {
+	var existente bool
+	err := conn.QueryRowContext(context.Background(), "SELECT to_regclass('usuarios') IS NOT NULL").Scan(&existente)
+	if err != nil {
+		return false, fmt.Errorf("erro ao detectar instalação existente: %w", err)
+	}
+	return existente, nil
+}
+
+// versoesAplicadas retorna as versões registradas em schema_migrations e quando foram aplicadas
+func versoesAplicadas(conn *sql.Conn) (map[int]time.Time, error) {
+	rows, err := conn.QueryContext(context.Background(), "SELECT versao, aplicada_em FROM schema_migrations")
+	if err != nil {
+		return nil, fmt.Errorf("erro ao consultar migrações aplicadas: %w", err)
+	}
+	defer rows.Close()
+
+	versoes := map[int]time.Time{}
+	for rows.Next() {
+		var versao int
+		var aplicadaEm time.Time
+		if err := rows.Scan(&versao, &aplicadaEm); err != nil {
+			return nil, fmt.Errorf("erro ao ler migrações aplicadas: %w", err)
+		}
+		versoes[versao] = aplicadaEm
+	}
+	return versoes, rows.Err()
+}
+
+// executarMigracao roda o SQL de uma migração e atualiza schema_migrations na mesma transação
+func executarMigracao(conn *sql.Conn, m Migracao, script string, subir bool) error {
+	tx, err := conn.BeginTx(context.Background(), nil)
+	if err != nil {
+		return fmt.Errorf("erro ao iniciar transação da migração %04d: %w", m.Versao, err)
+	}
+	defer tx.Rollback() // sem efeito após o commit
+
+	if _, err := tx.Exec(script); err != nil {
+		return fmt.Errorf("erro ao executar migração %04d_%s: %w", m.Versao, m.Nome, err)
+	}
+
+	if subir {
+		_, err = tx.Exec("INSERT INTO schema_migrations (versao, nome) VALUES ($1, $2)", m.Versao, m.Nome)
+	} else {
+		_, err = tx.Exec("DELETE FROM schema_migrations WHERE versao = $1", m.Versao)
+	}
+	if err != nil {
+		return fmt.Errorf("erro ao registrar migração %04d: %w", m.Versao, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("erro ao finalizar migração %04d: %w", m.Versao, err)
+	}
+	return nil
+}
@@ -0,0 +1,9 @@
+DROP TABLE IF EXISTS vendas_antecipadas;
+DROP TABLE IF EXISTS vendas_fiadas;
+DROP TABLE IF EXISTS movimentacoes_estoque;
+DROP TABLE IF EXISTS estoque;
+DROP TABLE IF EXISTS itens_pedido;
+DROP TABLE IF EXISTS pedidos;
+DROP TABLE IF EXISTS clientes;
+DROP TABLE IF EXISTS produtos;
+DROP TABLE IF EXISTS usuarios;
@@ -0,0 +1,116 @@
+-- Esquema inicial do GestGAS
+CREATE TABLE usuarios (
+id SERIAL PRIMARY KEY,
+nome VARCHAR(100) NOT NULL,
+login VARCHAR(50) UNIQUE NOT NULL,
+senha VARCHAR(255) NOT NULL,
+cpf VARCHAR(14) UNIQUE,
+email VARCHAR(100) UNIQUE,
+perfil VARCHAR(20) NOT NULL,
+criado_em TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+atualizado_em TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE produtos (
+id SERIAL PRIMARY KEY,
+nome VARCHAR(100) NOT NULL,
+descricao TEXT,
+categoria VARCHAR(50) NOT NULL,
+preco DECIMAL(10, 2) NOT NULL,
+criado_em TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+atualizado_em TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE clientes (
+id SERIAL PRIMARY KEY,
+nome VARCHAR(100) NOT NULL,
+telefone VARCHAR(20) NOT NULL,
+cpf VARCHAR(14) UNIQUE,
+email VARCHAR(100) UNIQUE,
+endereco VARCHAR(255),
+complemento VARCHAR(100),
+bairro VARCHAR(100),
+cidade VARCHAR(100),
+estado VARCHAR(2),
+cep VARCHAR(10),
+observacoes TEXT,
+canal_origem VARCHAR(20),
+criado_em TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+atualizado_em TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE pedidos (
+id SERIAL PRIMARY KEY,
+cliente_id INTEGER NOT NULL REFERENCES clientes(id),
+atendente_id INTEGER NOT NULL REFERENCES usuarios(id),
+entregador_id INTEGER REFERENCES usuarios(id),
+status VARCHAR(20) NOT NULL,
+forma_pagamento VARCHAR(20) NOT NULL,
+valor_total DECIMAL(10, 2) NOT NULL,
+observacoes TEXT,
+endereco_entrega VARCHAR(255) NOT NULL,
+canal_origem VARCHAR(20),
+motivo_cancelamento TEXT,
+data_entrega TIMESTAMP WITH TIME ZONE,
+criado_em TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+atualizado_em TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE itens_pedido (
+id SERIAL PRIMARY KEY,
+pedido_id INTEGER NOT NULL REFERENCES pedidos(id),
+produto_id INTEGER NOT NULL REFERENCES produtos(id),
+quantidade INTEGER NOT NULL,
+preco_unitario DECIMAL(10, 2) NOT NULL,
+subtotal DECIMAL(10, 2) NOT NULL,
+retorna_botija BOOLEAN DEFAULT FALSE
+);
+
+CREATE TABLE estoque (
+id SERIAL PRIMARY KEY,
+produto_id INTEGER NOT NULL REFERENCES produtos(id),
+quantidade INTEGER NOT NULL DEFAULT 0,
+botijas_vazias INTEGER DEFAULT 0,
+botijas_emprestadas INTEGER DEFAULT 0,
+alerta_minimo INTEGER,
+criado_em TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+atualizado_em TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE movimentacoes_estoque (
+id SERIAL PRIMARY KEY,
+produto_id INTEGER NOT NULL REFERENCES produtos(id),
+tipo VARCHAR(20) NOT NULL,
+quantidade INTEGER NOT NULL,
+observacoes TEXT,
+usuario_id INTEGER NOT NULL REFERENCES usuarios(id),
+pedido_id INTEGER REFERENCES pedidos(id),
+criado_em TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE vendas_fiadas (
+id SERIAL PRIMARY KEY,
+pedido_id INTEGER NOT NULL REFERENCES pedidos(id),
+cliente_id INTEGER NOT NULL REFERENCES clientes(id),
+valor_total DECIMAL(10, 2) NOT NULL,
+data_vencimento TIMESTAMP WITH TIME ZONE NOT NULL,
+status VARCHAR(20) NOT NULL,
+data_pagamento TIMESTAMP WITH TIME ZONE,
+criado_em TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+atualizado_em TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE vendas_antecipadas (
+id SERIAL PRIMARY KEY,
+cliente_id INTEGER NOT NULL REFERENCES clientes(id),
+produto_id INTEGER NOT NULL REFERENCES produtos(id),
+quantidade INTEGER NOT NULL,
+valor_total DECIMAL(10, 2) NOT NULL,
+forma_pagamento VARCHAR(20) NOT NULL,
+data_pagamento TIMESTAMP WITH TIME ZONE NOT NULL,
+data_entrega_prevista TIMESTAMP WITH TIME ZONE NOT NULL,
+status VARCHAR(20) NOT NULL,
+pedido_id INTEGER REFERENCES pedidos(id),
+criado_em TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+atualizado_em TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,11 @@
+-- Pagamentos totais ou parciais de vendas fiadas.
+-- IF NOT EXISTS: instalações anteriores ao controle de versões já podem ter a tabela.
+CREATE TABLE IF NOT EXISTS pagamentos_fiado (
+id SERIAL PRIMARY KEY,
+venda_fiada_id INTEGER NOT NULL REFERENCES vendas_fiadas(id),
+valor DECIMAL(10, 2) NOT NULL,
+forma_pagamento VARCHAR(20) NOT NULL,
+observacoes TEXT,
+usuario_id INTEGER NOT NULL REFERENCES usuarios(id),
+criado_em TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
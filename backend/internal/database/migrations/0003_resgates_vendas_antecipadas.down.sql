@@ -0,0 +1,4 @@
+ALTER TABLE vendas_antecipadas DROP COLUMN IF EXISTS motivo_cancelamento;
+ALTER TABLE vendas_antecipadas DROP COLUMN IF EXISTS valor_reembolsado;
+ALTER TABLE vendas_antecipadas DROP COLUMN IF EXISTS quantidade_resgatada;
+DROP TABLE IF EXISTS resgates_vendas_antecipadas;
@@ -0,0 +1,13 @@
+-- Resgates de vale-gás e controle de cancelamento das vendas antecipadas.
+-- IF NOT EXISTS: instalações anteriores ao controle de versões já podem ter estes objetos.
+CREATE TABLE IF NOT EXISTS resgates_vendas_antecipadas (
+id SERIAL PRIMARY KEY,
+venda_antecipada_id INTEGER NOT NULL REFERENCES vendas_antecipadas(id),
+pedido_id INTEGER NOT NULL REFERENCES pedidos(id),
+usuario_id INTEGER NOT NULL REFERENCES usuarios(id),
+criado_em TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+ALTER TABLE vendas_antecipadas ADD COLUMN IF NOT EXISTS quantidade_resgatada INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE vendas_antecipadas ADD COLUMN IF NOT EXISTS valor_reembolsado DECIMAL(10, 2);
+ALTER TABLE vendas_antecipadas ADD COLUMN IF NOT EXISTS motivo_cancelamento TEXT;
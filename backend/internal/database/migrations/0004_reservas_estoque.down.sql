@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS reservas_estoque;
+ALTER TABLE estoque DROP CONSTRAINT IF EXISTS estoque_reservado_valido;
+ALTER TABLE estoque DROP COLUMN IF EXISTS reservado;
@@ -0,0 +1,31 @@
+-- Reserva de estoque para pedidos em aberto.
+-- estoque.quantidade continua sendo o estoque físico; estoque.reservado é a parte já comprometida com pedidos.
+ALTER TABLE estoque ADD COLUMN reservado INTEGER NOT NULL DEFAULT 0;
+
+-- Antes desta versão a baixa de estoque não bloqueava a linha e podia deixar a quantidade negativa.
+-- A restrição abaixo é validada contra as linhas existentes e impediria a migração (e a subida do
+-- servidor, que migra ao iniciar), então essas quantidades são zeradas antes, como um ajuste
+-- registrado em nome do primeiro administrador, com a quantidade anterior nas observações.
+INSERT INTO movimentacoes_estoque (produto_id, tipo, quantidade, observacoes, usuario_id)
+SELECT e.produto_id, 'ajuste', 0,
+'Migração 0004: estoque negativo (' || e.quantidade || ') zerado para a reserva de estoque',
+(SELECT id FROM usuarios WHERE perfil = 'admin' ORDER BY id LIMIT 1)
+FROM estoque e
+WHERE e.quantidade < 0
+AND EXISTS (SELECT 1 FROM usuarios WHERE perfil = 'admin');
+
+UPDATE estoque SET quantidade = 0, atualizado_em = NOW() WHERE quantidade < 0;
+
+ALTER TABLE estoque ADD CONSTRAINT estoque_reservado_valido CHECK (reservado >= 0 AND reservado <= quantidade);
+
+CREATE TABLE reservas_estoque (
+id SERIAL PRIMARY KEY,
+pedido_id INTEGER NOT NULL REFERENCES pedidos(id),
+produto_id INTEGER NOT NULL REFERENCES produtos(id),
+quantidade INTEGER NOT NULL CHECK (quantidade > 0),
+status VARCHAR(20) NOT NULL,
+criado_em TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+atualizado_em TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_reservas_estoque_pedido ON reservas_estoque (pedido_id);
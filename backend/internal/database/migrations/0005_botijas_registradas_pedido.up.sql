@@ -0,0 +1,9 @@
+-- Marca os pedidos cujas botijas vazias devolvidas já entraram no estoque,
+-- para que o retorno seja registrado uma única vez, qualquer que seja o endpoint usado.
+ALTER TABLE pedidos ADD COLUMN botijas_registradas BOOLEAN NOT NULL DEFAULT FALSE;
+
+UPDATE pedidos SET botijas_registradas = TRUE
+WHERE EXISTS (
+SELECT 1 FROM movimentacoes_estoque m
+WHERE m.pedido_id = pedidos.id AND m.tipo = 'botijas_vazias'
+);
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS pedidos_historico;
@@ -0,0 +1,24 @@
+-- Histórico de status dos pedidos: uma linha por transição, incluindo a criação (status_anterior nulo).
+CREATE TABLE pedidos_historico (
+id SERIAL PRIMARY KEY,
+pedido_id INTEGER NOT NULL REFERENCES pedidos(id),
+status_anterior VARCHAR(20),
+status_novo VARCHAR(20) NOT NULL,
+usuario_id INTEGER REFERENCES usuarios(id),
+entregador_id INTEGER REFERENCES usuarios(id),
+motivo TEXT,
+criado_em TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_pedidos_historico_pedido ON pedidos_historico (pedido_id, criado_em);
+
+-- Pedidos existentes: registrar a criação e, se já mudaram de status, o status atual como uma
+-- transição a partir de 'novo', para que só a linha da criação tenha status_anterior nulo.
+-- As transições intermediárias desses pedidos não são conhecidas.
+INSERT INTO pedidos_historico (pedido_id, status_anterior, status_novo, usuario_id, criado_em)
+SELECT id, NULL, 'novo', atendente_id, criado_em FROM pedidos;
+
+INSERT INTO pedidos_historico (pedido_id, status_anterior, status_novo, entregador_id, motivo, criado_em)
+SELECT id, 'novo', status, entregador_id, NULLIF(motivo_cancelamento, ''), atualizado_em
+FROM pedidos
+WHERE status <> 'novo';
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_pedidos_entregador_status;
+ALTER TABLE clientes DROP CONSTRAINT IF EXISTS clientes_coordenadas_validas;
+ALTER TABLE clientes DROP COLUMN IF EXISTS longitude;
+ALTER TABLE clientes DROP COLUMN IF EXISTS latitude;
@@ -0,0 +1,6 @@
+-- Coordenadas do endereço do cliente, usadas para ordenar as paradas do roteiro de entregas.
+ALTER TABLE clientes ADD COLUMN latitude DOUBLE PRECISION;
+ALTER TABLE clientes ADD COLUMN longitude DOUBLE PRECISION;
+ALTER TABLE clientes ADD CONSTRAINT clientes_coordenadas_validas CHECK ((latitude IS NULL AND longitude IS NULL) OR (latitude BETWEEN -90 AND 90 AND longitude BETWEEN -180 AND 180));
+
+CREATE INDEX idx_pedidos_entregador_status ON pedidos (entregador_id, status);
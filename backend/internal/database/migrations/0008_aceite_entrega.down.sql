@@ -0,0 +1 @@
+ALTER TABLE pedidos DROP COLUMN IF EXISTS aceito_em;
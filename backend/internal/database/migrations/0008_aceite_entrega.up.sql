@@ -0,0 +1,2 @@
+-- Momento em que o entregador aceitou o pedido atribuído a ele (nulo enquanto não aceito).
+ALTER TABLE pedidos ADD COLUMN aceito_em TIMESTAMP WITH TIME ZONE;
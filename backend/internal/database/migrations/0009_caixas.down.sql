@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_pedidos_caixa;
+ALTER TABLE pedidos DROP COLUMN IF EXISTS caixa_id;
+DROP TABLE IF EXISTS fechamentos_caixa;
+DROP TABLE IF EXISTS movimentacoes_caixa;
+DROP TABLE IF EXISTS caixas;
@@ -0,0 +1,43 @@
+-- Sessões de caixa: abertura com troco inicial, sangrias/suprimentos e conferência no fechamento.
+CREATE TABLE caixas (
+id SERIAL PRIMARY KEY,
+usuario_id INTEGER NOT NULL REFERENCES usuarios(id),
+status VARCHAR(20) NOT NULL,
+valor_abertura DECIMAL(10, 2) NOT NULL CHECK (valor_abertura >= 0),
+observacoes_abertura TEXT,
+aberto_em TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+fechado_em TIMESTAMP WITH TIME ZONE,
+fechado_por INTEGER REFERENCES usuarios(id),
+observacoes_fechamento TEXT
+);
+
+-- Cada atendente tem no máximo um caixa aberto
+CREATE UNIQUE INDEX idx_caixas_aberto_usuario ON caixas (usuario_id) WHERE status = 'aberto';
+
+CREATE TABLE movimentacoes_caixa (
+id SERIAL PRIMARY KEY,
+caixa_id INTEGER NOT NULL REFERENCES caixas(id),
+tipo VARCHAR(20) NOT NULL,
+valor DECIMAL(10, 2) NOT NULL CHECK (valor > 0),
+motivo TEXT,
+usuario_id INTEGER NOT NULL REFERENCES usuarios(id),
+criado_em TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_movimentacoes_caixa_caixa ON movimentacoes_caixa (caixa_id);
+
+-- Conferência gravada no fechamento: valor esperado x valor declarado por forma de pagamento
+CREATE TABLE fechamentos_caixa (
+id SERIAL PRIMARY KEY,
+caixa_id INTEGER NOT NULL REFERENCES caixas(id),
+forma_pagamento VARCHAR(20) NOT NULL,
+quantidade_pedidos INTEGER NOT NULL DEFAULT 0,
+valor_vendas DECIMAL(10, 2) NOT NULL DEFAULT 0,
+valor_esperado DECIMAL(10, 2) NOT NULL,
+valor_declarado DECIMAL(10, 2),
+UNIQUE (caixa_id, forma_pagamento)
+);
+
+-- Caixa em que o pedido foi finalizado
+ALTER TABLE pedidos ADD COLUMN caixa_id INTEGER REFERENCES caixas(id);
+CREATE INDEX idx_pedidos_caixa ON pedidos (caixa_id);
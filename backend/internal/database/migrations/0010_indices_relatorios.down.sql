@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_itens_pedido_pedido;
+DROP INDEX IF EXISTS idx_pedidos_criado_em;
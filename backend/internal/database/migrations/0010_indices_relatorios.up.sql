@@ -0,0 +1,3 @@
+-- Índices usados pelas agregações do dashboard por período.
+CREATE INDEX idx_pedidos_criado_em ON pedidos (criado_em);
+CREATE INDEX idx_itens_pedido_pedido ON itens_pedido (pedido_id);
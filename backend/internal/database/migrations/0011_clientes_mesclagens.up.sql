@@ -0,0 +1,15 @@
+-- Auditoria das mesclagens de clientes duplicados: uma linha por cliente removido,
+-- com a cópia do cadastro apagado e a quantidade de registros transferidos.
+CREATE TABLE clientes_mesclagens (
+id SERIAL PRIMARY KEY,
+cliente_id INTEGER NOT NULL REFERENCES clientes(id),
+cliente_removido_id INTEGER NOT NULL,
+dados_removido JSONB NOT NULL,
+pedidos_movidos INTEGER NOT NULL DEFAULT 0,
+fiados_movidos INTEGER NOT NULL DEFAULT 0,
+vales_movidos INTEGER NOT NULL DEFAULT 0,
+usuario_id INTEGER NOT NULL REFERENCES usuarios(id),
+criado_em TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_clientes_mesclagens_cliente ON clientes_mesclagens (cliente_id);
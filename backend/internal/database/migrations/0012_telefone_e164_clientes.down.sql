@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_clientes_telefone_e164;
+ALTER TABLE clientes DROP COLUMN IF EXISTS telefone_e164;
@@ -0,0 +1,35 @@
+-- Telefone normalizado em E.164 (+55 DDD número) para busca exata e indexada do cliente que está ligando.
+-- A coluna telefone continua guardando o número como foi digitado, para exibição.
+ALTER TABLE clientes ADD COLUMN telefone_e164 VARCHAR(16);
+
+-- Backfill com a mesma regra de internal/telefone: sem formatação, zeros de discagem e código 55,
+-- celulares de oito dígitos recebem o nono dígito. Números que não se encaixam ficam nulos.
+-- A regra de referência é telefone.NormalizarE164 (casos em telefone_test.go); este CASE é uma
+-- cópia para o backfill, e mudanças na regra devem vir em nova migração que recalcule a coluna.
+UPDATE clientes c SET telefone_e164 = n.e164
+FROM (
+SELECT id,
+CASE
+WHEN internacional AND digitos NOT LIKE '55%' AND length(digitos) BETWEEN 8 AND 15 THEN '+' || digitos
+WHEN internacional AND digitos NOT LIKE '55%' THEN NULL
+WHEN nacional !~ '^[1-9][1-9]' THEN NULL
+WHEN length(nacional) = 11 AND substr(nacional, 3, 1) = '9' THEN '+55' || nacional
+WHEN length(nacional) = 10 AND substr(nacional, 3, 1) IN ('6', '7', '8', '9') THEN '+55' || substr(nacional, 1, 2) || '9' || substr(nacional, 3)
+WHEN length(nacional) = 10 AND substr(nacional, 3, 1) IN ('2', '3', '4', '5') THEN '+55' || nacional
+END AS e164
+FROM (
+SELECT id, internacional, digitos,
+CASE
+WHEN internacional THEN substr(digitos, 3)
+WHEN length(ltrim(digitos, '0')) IN (12, 13) AND ltrim(digitos, '0') LIKE '55%' THEN substr(ltrim(digitos, '0'), 3)
+ELSE ltrim(digitos, '0')
+END AS nacional
+FROM (
+SELECT id, btrim(telefone) LIKE '+%' AS internacional, regexp_replace(telefone, '\D', '', 'g') AS digitos
+FROM clientes
+) t
+) t2
+) n
+WHERE c.id = n.id;
+
+CREATE INDEX idx_clientes_telefone_e164 ON clientes (telefone_e164);
@@ -0,0 +1,12 @@
+-- Registro das ligações identificadas pelo bina, para consulta das chamadas recentes pelo atendente.
+CREATE TABLE chamadas (
+id SERIAL PRIMARY KEY,
+numero VARCHAR(30) NOT NULL,
+telefone_e164 VARCHAR(16),
+ramal VARCHAR(20),
+cliente_id INTEGER REFERENCES clientes(id) ON DELETE SET NULL,
+clientes_encontrados INTEGER NOT NULL DEFAULT 0,
+recebida_em TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_chamadas_recebida_em ON chamadas (recebida_em);
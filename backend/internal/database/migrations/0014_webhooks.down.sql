@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS webhooks_tentativas;
+DROP TABLE IF EXISTS webhooks_entregas;
+DROP TABLE IF EXISTS webhooks_outbox;
+DROP TABLE IF EXISTS webhooks;
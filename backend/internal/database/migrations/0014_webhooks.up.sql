@@ -0,0 +1,51 @@
+-- Webhooks de saída: assinaturas cadastradas pelo administrador, a fila de eventos gravada na
+-- mesma transação da alteração (outbox) e o registro de cada entrega e de suas tentativas.
+CREATE TABLE webhooks (
+id SERIAL PRIMARY KEY,
+url VARCHAR(500) NOT NULL,
+segredo VARCHAR(200) NOT NULL,
+eventos TEXT[] NOT NULL,
+descricao VARCHAR(200),
+ativo BOOLEAN NOT NULL DEFAULT TRUE,
+criado_em TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+atualizado_em TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE webhooks_outbox (
+id BIGSERIAL PRIMARY KEY,
+tipo VARCHAR(50) NOT NULL,
+dados JSONB NOT NULL,
+criado_em TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+distribuido_em TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX idx_webhooks_outbox_pendentes ON webhooks_outbox (id) WHERE distribuido_em IS NULL;
+
+CREATE TABLE webhooks_entregas (
+id BIGSERIAL PRIMARY KEY,
+webhook_id INTEGER NOT NULL REFERENCES webhooks(id) ON DELETE CASCADE,
+evento_id BIGINT NOT NULL REFERENCES webhooks_outbox(id),
+status VARCHAR(20) NOT NULL DEFAULT 'pendente',
+tentativas INTEGER NOT NULL DEFAULT 0,
+proxima_tentativa TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+ultimo_status_http INTEGER,
+ultimo_erro TEXT,
+entregue_em TIMESTAMP WITH TIME ZONE,
+criado_em TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+atualizado_em TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+UNIQUE (webhook_id, evento_id)
+);
+
+CREATE INDEX idx_webhooks_entregas_pendentes ON webhooks_entregas (proxima_tentativa) WHERE status = 'pendente';
+CREATE INDEX idx_webhooks_entregas_webhook ON webhooks_entregas (webhook_id, criado_em);
+
+CREATE TABLE webhooks_tentativas (
+id BIGSERIAL PRIMARY KEY,
+entrega_id BIGINT NOT NULL REFERENCES webhooks_entregas(id) ON DELETE CASCADE,
+status_http INTEGER,
+erro TEXT,
+duracao_ms INTEGER NOT NULL,
+criado_em TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_webhooks_tentativas_entrega ON webhooks_tentativas (entrega_id);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS transacoes_bancarias;
+DROP TABLE IF EXISTS extratos_importacoes;
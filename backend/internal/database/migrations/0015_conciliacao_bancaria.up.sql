@@ -0,0 +1,29 @@
+-- Conciliação bancária: extratos importados (OFX ou CSV) e os créditos recebidos, cada um
+-- vinculado no máximo a um pedido pago por Pix ou cartão.
+CREATE TABLE extratos_importacoes (
+id SERIAL PRIMARY KEY,
+arquivo VARCHAR(255),
+formato VARCHAR(10) NOT NULL,
+transacoes_importadas INTEGER NOT NULL DEFAULT 0,
+transacoes_repetidas INTEGER NOT NULL DEFAULT 0,
+usuario_id INTEGER NOT NULL REFERENCES usuarios(id),
+criado_em TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE transacoes_bancarias (
+id SERIAL PRIMARY KEY,
+importacao_id INTEGER NOT NULL REFERENCES extratos_importacoes(id),
+identificador VARCHAR(255) NOT NULL UNIQUE,
+data DATE NOT NULL,
+valor DECIMAL(10, 2) NOT NULL,
+descricao TEXT,
+txid VARCHAR(35),
+pedido_id INTEGER UNIQUE REFERENCES pedidos(id),
+conciliacao VARCHAR(20),
+conciliado_por INTEGER REFERENCES usuarios(id),
+conciliado_em TIMESTAMP WITH TIME ZONE,
+criado_em TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_transacoes_bancarias_data ON transacoes_bancarias (data);
+CREATE INDEX idx_transacoes_bancarias_pendentes ON transacoes_bancarias (valor) WHERE pedido_id IS NULL;
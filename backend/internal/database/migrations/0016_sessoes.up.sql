@@ -0,0 +1,18 @@
+-- Sessões de login: cada uma guarda o hash do refresh token atual (o token em si nunca é gravado)
+-- e pode ser revogada, o que invalida também os tokens de acesso emitidos para ela.
+CREATE TABLE sessoes (
+id SERIAL PRIMARY KEY,
+usuario_id INTEGER NOT NULL REFERENCES usuarios(id) ON DELETE CASCADE,
+refresh_hash VARCHAR(64) NOT NULL UNIQUE,
+refresh_hash_anterior VARCHAR(64),
+ip VARCHAR(45),
+user_agent VARCHAR(255),
+expira_em TIMESTAMP WITH TIME ZONE NOT NULL,
+ultimo_uso_em TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+revogada_em TIMESTAMP WITH TIME ZONE,
+motivo_revogacao VARCHAR(50),
+criado_em TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_sessoes_usuario ON sessoes (usuario_id) WHERE revogada_em IS NULL;
+CREATE INDEX idx_sessoes_refresh_hash_anterior ON sessoes (refresh_hash_anterior);
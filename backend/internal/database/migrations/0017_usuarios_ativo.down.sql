@@ -0,0 +1,2 @@
+ALTER TABLE usuarios DROP COLUMN IF EXISTS desativado_em;
+ALTER TABLE usuarios DROP COLUMN IF EXISTS ativo;
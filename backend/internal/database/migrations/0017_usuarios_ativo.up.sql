@@ -0,0 +1,3 @@
+-- Usuários desativados não fazem login nem recebem pedidos, mas continuam nos pedidos antigos
+ALTER TABLE usuarios ADD COLUMN ativo BOOLEAN NOT NULL DEFAULT TRUE;
+ALTER TABLE usuarios ADD COLUMN desativado_em TIMESTAMP WITH TIME ZONE;
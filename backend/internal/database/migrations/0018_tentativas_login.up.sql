@@ -0,0 +1,15 @@
+-- Registro de auditoria das tentativas de login, com sucesso ou não
+CREATE TABLE tentativas_login (
+id BIGSERIAL PRIMARY KEY,
+login VARCHAR(50) NOT NULL,
+usuario_id INTEGER REFERENCES usuarios(id) ON DELETE SET NULL,
+ip VARCHAR(45),
+user_agent VARCHAR(255),
+sucesso BOOLEAN NOT NULL,
+motivo VARCHAR(30) NOT NULL,
+criado_em TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_tentativas_login_criado_em ON tentativas_login (criado_em);
+CREATE INDEX idx_tentativas_login_login ON tentativas_login (login, criado_em);
+CREATE INDEX idx_tentativas_login_ip ON tentativas_login (ip, criado_em);
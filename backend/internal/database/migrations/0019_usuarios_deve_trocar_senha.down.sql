@@ -0,0 +1,2 @@
+ALTER TABLE usuarios DROP COLUMN IF EXISTS senha_alterada_em;
+ALTER TABLE usuarios DROP COLUMN IF EXISTS deve_trocar_senha;
@@ -0,0 +1,4 @@
+-- Usuários com senha provisória (admin inicial ou senha redefinida por um administrador)
+-- só podem trocar a senha até defini-la
+ALTER TABLE usuarios ADD COLUMN deve_trocar_senha BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE usuarios ADD COLUMN senha_alterada_em TIMESTAMP WITH TIME ZONE;
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS perfil_permissoes;
+DROP TABLE IF EXISTS permissoes;
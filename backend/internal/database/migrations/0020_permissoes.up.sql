@@ -0,0 +1,13 @@
+-- Ações nomeadas do sistema (estoque.ajustar, produto.excluir...) e os perfis que podem executá-las.
+-- As permissões são gravadas na inicialização a partir do catálogo do backend; admin tem todas.
+CREATE TABLE permissoes (
+codigo VARCHAR(50) PRIMARY KEY,
+descricao VARCHAR(150) NOT NULL
+);
+
+CREATE TABLE perfil_permissoes (
+perfil VARCHAR(20) NOT NULL,
+permissao VARCHAR(50) NOT NULL REFERENCES permissoes(codigo) ON DELETE CASCADE,
+criado_em TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+PRIMARY KEY (perfil, permissao)
+);
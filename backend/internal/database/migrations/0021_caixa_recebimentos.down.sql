@@ -0,0 +1,6 @@
+ALTER TABLE fechamentos_caixa DROP COLUMN IF EXISTS valor_recebimentos_fiado;
+ALTER TABLE fechamentos_caixa DROP COLUMN IF EXISTS valor_vendas_antecipadas;
+DROP INDEX IF EXISTS idx_pagamentos_fiado_caixa;
+ALTER TABLE pagamentos_fiado DROP COLUMN IF EXISTS caixa_id;
+DROP INDEX IF EXISTS idx_vendas_antecipadas_caixa;
+ALTER TABLE vendas_antecipadas DROP COLUMN IF EXISTS caixa_id;
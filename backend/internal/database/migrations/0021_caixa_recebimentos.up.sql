@@ -0,0 +1,11 @@
+-- Caixa que recebeu o pagamento de cada vale-gás e de cada pagamento de venda fiada
+-- (nulo quando quem registrou não tinha caixa aberto).
+ALTER TABLE vendas_antecipadas ADD COLUMN caixa_id INTEGER REFERENCES caixas(id);
+CREATE INDEX idx_vendas_antecipadas_caixa ON vendas_antecipadas (caixa_id);
+
+ALTER TABLE pagamentos_fiado ADD COLUMN caixa_id INTEGER REFERENCES caixas(id);
+CREATE INDEX idx_pagamentos_fiado_caixa ON pagamentos_fiado (caixa_id);
+
+-- Recebimentos fora dos pedidos, somados ao esperado de cada forma de pagamento no fechamento
+ALTER TABLE fechamentos_caixa ADD COLUMN valor_vendas_antecipadas DECIMAL(10, 2) NOT NULL DEFAULT 0;
+ALTER TABLE fechamentos_caixa ADD COLUMN valor_recebimentos_fiado DECIMAL(10, 2) NOT NULL DEFAULT 0;
@@ -0,0 +1,177 @@
+package entrega
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/tassyosilva/GestGAS/internal/models"
+	"github.com/tassyosilva/GestGAS/internal/pedido"
+)
+
+// ErrEntregadorNaoEncontrado indica que o ID informado não pertence a um entregador
+var ErrEntregadorNaoEncontrado = errors.New("entregador não encontrado")
+
+// semRegiao agrupa os pedidos de clientes sem bairro nem CEP
+const semRegiao = "Sem bairro/CEP"
+
+// Regiao identifica a região de uma parada: o bairro do cliente ou, na falta dele, o prefixo do CEP
+func Regiao(parada models.ParadaEntrega) string {
+	if bairro := strings.TrimSpace(parada.Bairro); bairro != "" {
+		return bairro
+	}
+	var digitos strings.Builder
+	for _, r := range parada.CEP {
+		if unicode.IsDigit(r) {
+			digitos.WriteRune(r)
+		}
+	}
+	if digitos.Len() >= 5 {
+		return "CEP " + digitos.String()[:5]
+	}
+	return semRegiao
+}
+
+// consultaParadas seleciona os pedidos com os dados de endereço do cliente
+const consultaParadas = `
+	SELECT
+		p.id, p.status, p.cliente_id, c.nome, c.telefone,
+		p.endereco_entrega, c.bairro, c.cep, c.latitude, c.longitude,
+		p.valor_total, p.forma_pagamento
+	FROM pedidos p
+	JOIN clientes c ON p.cliente_id = c.id
+`
+
+func buscarParadas(db *sql.DB, filtro string, args ...interface{}) ([]models.ParadaEntrega, error) {
+	rows, err := db.Query(consultaParadas+filtro+" ORDER BY p.criado_em, p.id", args...)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao buscar pedidos para entrega: %w", err)
+	}
+	defer rows.Close()
+
+	paradas := []models.ParadaEntrega{}
+	for rows.Next() {
+		var parada models.ParadaEntrega
+		var bairro, cep sql.NullString
+		var latitude, longitude sql.NullFloat64
+
+		err := rows.Scan(
+			&parada.PedidoID, &parada.Status, &parada.Cliente.ID, &parada.Cliente.Nome, &parada.Cliente.Telefone,
+			&parada.EnderecoEntrega, &bairro, &cep, &latitude, &longitude,
+			&parada.ValorTotal, &parada.FormaPagamento,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("erro ao ler pedido para entrega: %w", err)
+		}
+
+		parada.Bairro = bairro.String
+		parada.CEP = cep.String
+		if latitude.Valid && longitude.Valid {
+			lat, lon := latitude.Float64, longitude.Float64
+			parada.Latitude, parada.Longitude = &lat, &lon
+		}
+		paradas = append(paradas, parada)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("erro ao ler pedidos para entrega: %w", err)
+	}
+	return paradas, nil
+}
+
+// LotesPendentes agrupa por região os pedidos em preparo que ainda não têm entregador,
+// com as paradas de cada lote já ordenadas
+func (p *Planejador) LotesPendentes(db *sql.DB) ([]models.LoteEntrega, error) {
+	paradas, err := buscarParadas(db, "WHERE p.status = $1 AND p.entregador_id IS NULL", models.StatusEmPreparo)
+	if err != nil {
+		return nil, err
+	}
+
+	// Agrupar sem diferenciar maiúsculas/minúsculas, mantendo o nome como foi cadastrado
+	indice := map[string]int{}
+	lotes := []models.LoteEntrega{}
+	for _, parada := range paradas {
+		regiao := Regiao(parada)
+		chave := strings.ToLower(regiao)
+		i, ok := indice[chave]
+		if !ok {
+			i = len(lotes)
+			indice[chave] = i
+			lotes = append(lotes, models.LoteEntrega{Regiao: regiao})
+		}
+		lotes[i].Pedidos = append(lotes[i].Pedidos, parada)
+	}
+
+	for i := range lotes {
+		if _, _, err := p.Ordenar(lotes[i].Pedidos); err != nil {
+			return nil, err
+		}
+	}
+
+	// Lotes maiores primeiro; empate pela região
+	sort.SliceStable(lotes, func(i, j int) bool {
+		if len(lotes[i].Pedidos) != len(lotes[j].Pedidos) {
+			return len(lotes[i].Pedidos) > len(lotes[j].Pedidos)
+		}
+		return lotes[i].Regiao < lotes[j].Regiao
+	})
+	return lotes, nil
+}
+
+// Rota monta o roteiro do entregador com os pedidos atribuídos a ele que ainda não foram entregues
+func (p *Planejador) Rota(db *sql.DB, entregadorID int) (models.RotaEntregador, error) {
+	var rota models.RotaEntregador
+	err := db.QueryRow("SELECT id, nome, perfil FROM usuarios WHERE id = $1 AND perfil = $2", entregadorID, models.PerfilEntregador).
+		Scan(&rota.Entregador.ID, &rota.Entregador.Nome, &rota.Entregador.Perfil)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return rota, ErrEntregadorNaoEncontrado
+		}
+		return rota, fmt.Errorf("erro ao buscar entregador: %w", err)
+	}
+
+	rota.Paradas, err = buscarParadas(db, "WHERE p.entregador_id = $1 AND p.status IN ($2, $3)",
+		entregadorID, models.StatusEmPreparo, models.StatusEmEntrega)
+	if err != nil {
+		return rota, err
+	}
+
+	rota.DistanciaTotalKm, rota.SemCoordenadas, err = p.Ordenar(rota.Paradas)
+	return rota, err
+}
+
+// AtribuirLote atribui ao entregador todos os pedidos do lote numa única transação
+func AtribuirLote(db *sql.DB, req models.AtribuirLoteRequest, usuarioID int, perfil string) error {
+	if req.EntregadorID <= 0 {
+		return &pedido.Erro{Tipo: pedido.ErrDadosInvalidos, Mensagem: "Entregador é obrigatório"}
+	}
+	if len(req.PedidoIDs) == 0 {
+		return &pedido.Erro{Tipo: pedido.ErrDadosInvalidos, Mensagem: "O lote deve ter pelo menos um pedido"}
+	}
+
+	// Bloquear os pedidos sempre na mesma ordem para evitar deadlocks entre atribuições simultâneas
+	ids := append([]int(nil), req.PedidoIDs...)
+	sort.Ints(ids)
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("erro ao iniciar transação: %w", err)
+	}
+	defer tx.Rollback() // sem efeito após o commit
+
+	for i, id := range ids {
+		if i > 0 && id == ids[i-1] {
+			continue
+		}
+		if err := pedido.AtribuirEntregadorTx(tx, id, req.EntregadorID, usuarioID, perfil); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("erro ao finalizar transação: %w", err)
+	}
+	return nil
+}
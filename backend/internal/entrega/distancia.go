@@ -0,0 +1,33 @@
+package entrega
+
+import "math"
+
+// Coordenada é um ponto geográfico em graus decimais
+type Coordenada struct {
+	Latitude  float64
+	Longitude float64
+}
+
+// ProvedorDistancia calcula a distância, em quilômetros, entre dois pontos.
+// A implementação padrão é a linha reta; um provedor de rotas viárias pode
+// ser plugado no Planejador sem alterar o restante do módulo.
+type ProvedorDistancia interface {
+	Distancia(origem, destino Coordenada) (float64, error)
+}
+
+// raioTerraKm é o raio médio da Terra usado na fórmula de haversine
+const raioTerraKm = 6371.0
+
+// LinhaReta calcula a distância em linha reta (grande círculo) entre dois pontos
+type LinhaReta struct{}
+
+// Distancia implementa ProvedorDistancia pela fórmula de haversine
+func (LinhaReta) Distancia(origem, destino Coordenada) (float64, error) {
+	lat1 := origem.Latitude * math.Pi / 180
+	lat2 := destino.Latitude * math.Pi / 180
+	dLat := lat2 - lat1
+	dLon := (destino.Longitude - origem.Longitude) * math.Pi / 180
+
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * raioTerraKm * math.Asin(math.Min(1, math.Sqrt(a))), nil
+}
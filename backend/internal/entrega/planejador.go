@@ -0,0 +1,98 @@
+// Package entrega organiza o despacho dos pedidos: agrupa os pedidos em preparo por região,
+// atribui lotes aos entregadores e ordena as paradas do roteiro de cada um.
+package entrega
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/tassyosilva/GestGAS/internal/config"
+	"github.com/tassyosilva/GestGAS/internal/models"
+)
+
+// Planejador ordena as paradas de um roteiro usando a heurística do vizinho mais próximo
+type Planejador struct {
+	provedor ProvedorDistancia
+	origem   *Coordenada
+}
+
+// NovoPlanejador cria um planejador com o provedor de distância informado (linha reta se nil)
+// e o depósito configurado como ponto de partida
+func NovoPlanejador(cfg config.Entregas, provedor ProvedorDistancia) *Planejador {
+	if provedor == nil {
+		provedor = LinhaReta{}
+	}
+	p := &Planejador{provedor: provedor}
+	if cfg.DepositoLatitude != nil && cfg.DepositoLongitude != nil {
+		p.origem = &Coordenada{Latitude: *cfg.DepositoLatitude, Longitude: *cfg.DepositoLongitude}
+	}
+	return p
+}
+
+// Ordenar define a ordem das paradas e a distância de cada trecho.
+// Partindo do depósito (ou da primeira parada, se não houver depósito), visita sempre a parada
+// mais próxima ainda não visitada. Paradas sem coordenadas vão para o fim, agrupadas por região.
+// Retorna a distância total percorrida e quantas paradas ficaram sem coordenadas.
+func (p *Planejador) Ordenar(paradas []models.ParadaEntrega) (float64, int, error) {
+	var comCoordenadas, semCoordenadas []models.ParadaEntrega
+	for _, parada := range paradas {
+		if parada.Latitude != nil && parada.Longitude != nil {
+			comCoordenadas = append(comCoordenadas, parada)
+		} else {
+			semCoordenadas = append(semCoordenadas, parada)
+		}
+	}
+
+	ordenadas := make([]models.ParadaEntrega, 0, len(paradas))
+	var total float64
+
+	atual := p.origem
+	for len(comCoordenadas) > 0 {
+		maisProxima := 0
+		menorDistancia := math.Inf(1)
+		if atual != nil {
+			for i, candidata := range comCoordenadas {
+				d, err := p.provedor.Distancia(*atual, coordenadaDe(candidata))
+				if err != nil {
+					return 0, 0, fmt.Errorf("erro ao calcular distância: %w", err)
+				}
+				if d < menorDistancia {
+					maisProxima, menorDistancia = i, d
+				}
+			}
+		}
+
+		escolhida := comCoordenadas[maisProxima]
+		if atual != nil {
+			distancia := arredondarMetros(menorDistancia)
+			escolhida.DistanciaKm = &distancia
+			total += menorDistancia
+		}
+		ordenadas = append(ordenadas, escolhida)
+
+		c := coordenadaDe(escolhida)
+		atual = &c
+		comCoordenadas = append(comCoordenadas[:maisProxima], comCoordenadas[maisProxima+1:]...)
+	}
+
+	sort.SliceStable(semCoordenadas, func(i, j int) bool {
+		return Regiao(semCoordenadas[i]) < Regiao(semCoordenadas[j])
+	})
+	ordenadas = append(ordenadas, semCoordenadas...)
+
+	for i := range ordenadas {
+		ordenadas[i].Ordem = i + 1
+	}
+	copy(paradas, ordenadas)
+	return arredondarMetros(total), len(semCoordenadas), nil
+}
+
+func coordenadaDe(parada models.ParadaEntrega) Coordenada {
+	return Coordenada{Latitude: *parada.Latitude, Longitude: *parada.Longitude}
+}
+
+// arredondarMetros arredonda uma distância em km para a precisão de metros
+func arredondarMetros(km float64) float64 {
+	return math.Round(km*1000) / 1000
+}
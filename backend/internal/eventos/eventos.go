@@ -0,0 +1,123 @@
+// Package eventos distribui, dentro do processo, os acontecimentos do sistema (pedidos criados,
+// mudanças de status, alertas de estoque) para as telas conectadas em tempo real.
+// Os eventos são publicados depois do commit da transação que os originou e os mais recentes
+// ficam guardados para que uma tela que reconectou receba o que perdeu.
+package eventos
+
+import (
+	"sync"
+	"time"
+)
+
+// Tipos de evento publicados
+const (
+	PedidoCriado         = "pedido.criado"
+	PedidoStatusAlterado = "pedido.status_alterado"
+	EntregaConfirmada    = "entrega.confirmada"
+	EstoqueAlerta        = "estoque.alerta"
+	BotijasRetornadas    = "botijas.retornadas"
+)
+
+// tamanhoHistorico é quantos eventos recentes ficam disponíveis para reenvio após uma reconexão
+const tamanhoHistorico = 1000
+
+// tamanhoFilaAssinante é quantos eventos podem aguardar uma tela lenta antes de ela ser desconectada
+const tamanhoFilaAssinante = 64
+
+// Evento é um acontecimento publicado no barramento
+type Evento struct {
+	ID       int64       `json:"id"`
+	Tipo     string      `json:"tipo"`
+	Dados    interface{} `json:"dados"`
+	CriadoEm time.Time   `json:"criado_em"`
+}
+
+// Barramento entrega cada evento publicado a todos os assinantes e guarda os mais recentes
+type Barramento struct {
+	mu         sync.Mutex
+	ultimoID   int64
+	historico  []Evento // Buffer circular com os últimos tamanhoHistorico eventos
+	inicio     int      // Posição do evento mais antigo em historico
+	assinantes map[chan Evento]struct{}
+}
+
+// NovoBarramento cria um barramento vazio. Os IDs partem do horário atual, para que continuem
+// crescendo depois de um reinício e uma tela reconectada perceba que não há como reenviar o que perdeu.
+func NovoBarramento() *Barramento {
+	return &Barramento{
+		ultimoID:   time.Now().UnixMilli() * 1000,
+		historico:  make([]Evento, 0, tamanhoHistorico),
+		assinantes: map[chan Evento]struct{}{},
+	}
+}
+
+// Publicar registra o evento e o envia aos assinantes sem bloquear. Um assinante com a fila
+// cheia é desconectado: ao reconectar, recebe o que perdeu a partir do último ID.
+func (b *Barramento) Publicar(tipo string, dados interface{}) Evento {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.ultimoID++
+	evento := Evento{ID: b.ultimoID, Tipo: tipo, Dados: dados, CriadoEm: time.Now()}
+	if len(b.historico) < tamanhoHistorico {
+		b.historico = append(b.historico, evento)
+	} else {
+		b.historico[b.inicio] = evento
+		b.inicio = (b.inicio + 1) % tamanhoHistorico
+	}
+
+	for ch := range b.assinantes {
+		select {
+		case ch <- evento:
+		default:
+			delete(b.assinantes, ch)
+			close(ch)
+		}
+	}
+	return evento
+}
+
+// Assinar registra um assinante. Com ultimoID > 0, retorna também os eventos publicados depois
+// dele; completo é falso quando parte deles já saiu do histórico (ou o servidor reiniciou) e a tela
+// precisa recarregar os dados. O canal é fechado se o assinante não acompanhar o ritmo dos eventos;
+// a função retornada cancela a assinatura.
+func (b *Barramento) Assinar(ultimoID int64) (perdidos []Evento, completo bool, eventos <-chan Evento, cancelar func()) {
+	ch := make(chan Evento, tamanhoFilaAssinante)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	completo = true
+	if ultimoID > 0 && ultimoID < b.ultimoID {
+		completo = len(b.historico) > 0 && b.historico[b.inicio].ID <= ultimoID+1
+		for i := 0; i < len(b.historico); i++ {
+			evento := b.historico[(b.inicio+i)%len(b.historico)]
+			if evento.ID > ultimoID {
+				perdidos = append(perdidos, evento)
+			}
+		}
+	}
+	b.assinantes[ch] = struct{}{}
+
+	return perdidos, completo, ch, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if _, ok := b.assinantes[ch]; ok {
+			delete(b.assinantes, ch)
+			close(ch)
+		}
+	}
+}
+
+// padrao é o barramento do processo, usado pelos handlers e pelos serviços
+var padrao = NovoBarramento()
+
+// Publicar publica um evento no barramento do processo
+func Publicar(tipo string, dados interface{}) Evento {
+	return padrao.Publicar(tipo, dados)
+}
+
+// Assinar registra um assinante no barramento do processo
+func Assinar(ultimoID int64) ([]Evento, bool, <-chan Evento, func()) {
+	return padrao.Assinar(ultimoID)
+}
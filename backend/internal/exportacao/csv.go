@@ -0,0 +1,60 @@
+package exportacao
+
+import (
+	"encoding/csv"
+	"io"
+)
+
+// bomUTF8 faz o Excel em português reconhecer o arquivo como UTF-8
+const bomUTF8 = "\ufeff"
+
+// linhasPorFlush controla a frequência com que o CSV é enviado ao destino
+const linhasPorFlush = 500
+
+type escritorCSV struct {
+	w       *csv.Writer
+	decimal byte
+	linhas  int
+}
+
+// NovoCSV cria um escritor CSV no padrão RFC 4180 (campos entre aspas quando necessário, fim de linha CRLF).
+// Com separador ';' os números usam vírgula decimal, como espera o Excel configurado em português.
+func NovoCSV(w io.Writer, separador rune, bom bool) (Escritor, error) {
+	if bom {
+		if _, err := io.WriteString(w, bomUTF8); err != nil {
+			return nil, err
+		}
+	}
+
+	cw := csv.NewWriter(w)
+	cw.Comma = separador
+	cw.UseCRLF = true
+
+	decimal := byte('.')
+	if separador == ';' {
+		decimal = ','
+	}
+	return &escritorCSV{w: cw, decimal: decimal}, nil
+}
+
+func (e *escritorCSV) Escrever(valores ...interface{}) error {
+	campos := make([]string, len(valores))
+	for i, v := range valores {
+		campos[i] = texto(v, e.decimal)
+	}
+	if err := e.w.Write(campos); err != nil {
+		return err
+	}
+
+	e.linhas++
+	if e.linhas%linhasPorFlush == 0 {
+		e.w.Flush()
+		return e.w.Error()
+	}
+	return nil
+}
+
+func (e *escritorCSV) Fechar() error {
+	e.w.Flush()
+	return e.w.Error()
+}
@@ -0,0 +1,83 @@
+// Package exportacao grava planilhas (CSV e XLSX) linha a linha diretamente no destino,
+// sem carregar o conjunto de dados em memória.
+package exportacao
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Formato identifica o tipo de arquivo gerado
+type Formato string
+
+const (
+	FormatoCSV  Formato = "csv"
+	FormatoXLSX Formato = "xlsx"
+)
+
+// formatoDataHora é o formato das datas gravadas nas planilhas
+const formatoDataHora = "2006-01-02 15:04:05"
+
+// Escritor grava as linhas de uma planilha. Os valores aceitos são string, int, int64,
+// float64, bool, time.Time, *time.Time e nil (célula vazia).
+type Escritor interface {
+	Escrever(valores ...interface{}) error
+	// Fechar grava o que estiver pendente; deve ser chamado ao final da exportação
+	Fechar() error
+}
+
+// ContentType retorna o tipo MIME do formato
+func (f Formato) ContentType() string {
+	if f == FormatoXLSX {
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	}
+	return "text/csv; charset=utf-8"
+}
+
+// inicioFormula são os caracteres que fazem o Excel e o LibreOffice interpretarem uma célula como fórmula
+const inicioFormula = "=+-@\t\r"
+
+// neutralizarFormula prefixa com apóstrofo o texto que seria interpretado como fórmula,
+// evitando que dados digitados por clientes (nome, endereço, observações) sejam executados na planilha
+func neutralizarFormula(s string) string {
+	if s != "" && strings.IndexByte(inicioFormula, s[0]) >= 0 {
+		return "'" + s
+	}
+	return s
+}
+
+// texto converte um valor em texto; decimal é o separador usado nos números com casas decimais
+func texto(valor interface{}, decimal byte) string {
+	switch v := valor.(type) {
+	case nil:
+		return ""
+	case string:
+		return neutralizarFormula(v)
+	case int:
+		return strconv.Itoa(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		s := strconv.FormatFloat(v, 'f', 2, 64)
+		if decimal != '.' {
+			s = strings.Replace(s, ".", string(decimal), 1)
+		}
+		return s
+	case bool:
+		if v {
+			return "sim"
+		}
+		return "não"
+	case time.Time:
+		return v.Format(formatoDataHora)
+	case *time.Time:
+		if v == nil {
+			return ""
+		}
+		return v.Format(formatoDataHora)
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,89 @@
+package exportacao
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestNeutralizarFormula(t *testing.T) {
+	casos := map[string]string{
+		"=HYPERLINK(\"http://x\")": "'=HYPERLINK(\"http://x\")",
+		"+55 95 3224-0000":         "'+55 95 3224-0000",
+		"-1":                       "'-1",
+		"@SUM(A1)":                 "'@SUM(A1)",
+		"\tcmd":                    "'\tcmd",
+		"\rcmd":                    "'\rcmd",
+		"Rua A, 10":                "Rua A, 10",
+		"a=1":                      "a=1",
+		"":                         "",
+	}
+	for entrada, esperado := range casos {
+		if saida := neutralizarFormula(entrada); saida != esperado {
+			t.Errorf("neutralizarFormula(%q) = %q, esperado %q", entrada, saida, esperado)
+		}
+	}
+}
+
+func TestCSVNeutralizaSoTexto(t *testing.T) {
+	var buf bytes.Buffer
+	e, err := NovoCSV(&buf, ';', false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Escrever("=1+1", -5.5, -3); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Fechar(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Números negativos continuam numéricos
+	if esperado := "'=1+1;-5,50;-3\r\n"; buf.String() != esperado {
+		t.Fatalf("CSV = %q, esperado %q", buf.String(), esperado)
+	}
+}
+
+func TestXLSXNeutralizaSoTexto(t *testing.T) {
+	var buf bytes.Buffer
+	e, err := NovoXLSX(&buf, "teste")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Escrever("=1+1", -2.5); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Fechar(); err != nil {
+		t.Fatal(err)
+	}
+
+	arquivo, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var planilha string
+	for _, f := range arquivo.File {
+		if f.Name != "xl/worksheets/sheet1.xml" {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		conteudo, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		planilha = string(conteudo)
+	}
+
+	if !strings.Contains(planilha, `<t xml:space="preserve">&#39;=1+1</t>`) {
+		t.Errorf("célula de texto iniciada por = deveria ser prefixada com apóstrofo: %s", planilha)
+	}
+	if !strings.Contains(planilha, `<v>-2.5</v>`) {
+		t.Errorf("números negativos devem continuar numéricos: %s", planilha)
+	}
+}
@@ -0,0 +1,131 @@
+package exportacao
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/xml"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Partes fixas de uma pasta de trabalho com uma única planilha
+const (
+	xlsxContentTypes = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types"><Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/><Default Extension="xml" ContentType="application/xml"/><Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/><Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/></Types>`
+
+	xlsxRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/></Relationships>`
+
+	xlsxWorkbookRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/></Relationships>`
+
+	xlsxWorkbookInicio = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets><sheet name="`
+	xlsxWorkbookFim = `" sheetId="1" r:id="rId1"/></sheets></workbook>`
+
+	xlsxPlanilhaInicio = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`
+	xlsxPlanilhaFim = `</sheetData></worksheet>`
+)
+
+// tamanhoMaximoNomePlanilha é o limite do Excel para o nome da aba
+const tamanhoMaximoNomePlanilha = 31
+
+type escritorXLSX struct {
+	zip    *zip.Writer
+	buf    *bufio.Writer
+	linhas int
+}
+
+// NovoXLSX cria um escritor XLSX. O arquivo é um ZIP gravado em sequência: as partes fixas
+// primeiro e a planilha por último, linha a linha, com células de texto embutidas (inlineStr).
+func NovoXLSX(w io.Writer, nomePlanilha string) (Escritor, error) {
+	zw := zip.NewWriter(w)
+
+	if len([]rune(nomePlanilha)) > tamanhoMaximoNomePlanilha {
+		nomePlanilha = string([]rune(nomePlanilha)[:tamanhoMaximoNomePlanilha])
+	}
+
+	partes := []struct{ nome, conteudo string }{
+		{"[Content_Types].xml", xlsxContentTypes},
+		{"_rels/.rels", xlsxRels},
+		{"xl/_rels/workbook.xml.rels", xlsxWorkbookRels},
+		{"xl/workbook.xml", xlsxWorkbookInicio + escaparXML(nomePlanilha) + xlsxWorkbookFim},
+	}
+	for _, parte := range partes {
+		f, err := zw.Create(parte.nome)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := io.WriteString(f, parte.conteudo); err != nil {
+			return nil, err
+		}
+	}
+
+	planilha, err := zw.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return nil, err
+	}
+	buf := bufio.NewWriter(planilha)
+	if _, err := buf.WriteString(xlsxPlanilhaInicio); err != nil {
+		return nil, err
+	}
+	return &escritorXLSX{zip: zw, buf: buf}, nil
+}
+
+func (e *escritorXLSX) Escrever(valores ...interface{}) error {
+	e.linhas++
+	linha := strconv.Itoa(e.linhas)
+
+	e.buf.WriteString(`<row r="` + linha + `">`)
+	for i, v := range valores {
+		if v == nil {
+			continue
+		}
+		ref := nomeColuna(i) + linha
+		switch n := v.(type) {
+		case int:
+			e.buf.WriteString(`<c r="` + ref + `"><v>` + strconv.Itoa(n) + `</v></c>`)
+		case int64:
+			e.buf.WriteString(`<c r="` + ref + `"><v>` + strconv.FormatInt(n, 10) + `</v></c>`)
+		case float64:
+			e.buf.WriteString(`<c r="` + ref + `"><v>` + strconv.FormatFloat(n, 'f', -1, 64) + `</v></c>`)
+		default:
+			t := texto(v, '.')
+			if t == "" {
+				continue
+			}
+			e.buf.WriteString(`<c r="` + ref + `" t="inlineStr"><is><t xml:space="preserve">` + escaparXML(t) + `</t></is></c>`)
+		}
+	}
+	_, err := e.buf.WriteString(`</row>`)
+	return err
+}
+
+func (e *escritorXLSX) Fechar() error {
+	if _, err := e.buf.WriteString(xlsxPlanilhaFim); err != nil {
+		return err
+	}
+	if err := e.buf.Flush(); err != nil {
+		return err
+	}
+	return e.zip.Close()
+}
+
+// nomeColuna converte o índice (0 = A) no nome da coluna do Excel
+func nomeColuna(indice int) string {
+	nome := ""
+	for indice >= 0 {
+		nome = string(rune('A'+indice%26)) + nome
+		indice = indice/26 - 1
+	}
+	return nome
+}
+
+// escaparXML escapa o texto e troca caracteres inválidos em XML
+func escaparXML(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
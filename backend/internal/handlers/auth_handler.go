@@ -3,14 +3,24 @@ package handlers
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net"
 	"net/http"
-	"time"
+	"strconv"
+	"strings"
 
 	"github.com/tassyosilva/GestGAS/internal/auth"
+	"github.com/tassyosilva/GestGAS/internal/middleware"
+	"github.com/tassyosilva/GestGAS/internal/models"
+	"github.com/tassyosilva/GestGAS/internal/permissao"
 )
 
-// LoginHandler processa requisições de login
-func LoginHandler(db *sql.DB) http.HandlerFunc {
+// LoginHandler processa requisições de login. Senhas incorretas seguidas atrasam e depois
+// bloqueiam temporariamente novas tentativas do mesmo login ou IP (ver auth.Limitador).
+func LoginHandler(db *sql.DB, limitador *auth.Limitador) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// Configurar cabeçalhos CORS para esta resposta específica
 		w.Header().Set("Content-Type", "application/json")
@@ -45,6 +55,19 @@ func LoginHandler(db *sql.DB) http.HandlerFunc {
 			return
 		}
 		
+		// Recusar enquanto o login ou o IP estiver em atraso ou bloqueado; a tentativa fica
+		// reservada até a senha ser conferida, para que requisições paralelas não passem do limite
+		ip := ipCliente(r)
+		tentativa, espera := limitador.Reservar(req.Login, ip)
+		if tentativa == nil {
+			registrarTentativaLogin(db, r, req.Login, nil, false, auth.TentativaBloqueada)
+			segundos := int(math.Ceil(espera.Seconds()))
+			w.Header().Set("Retry-After", strconv.Itoa(segundos))
+			http.Error(w, fmt.Sprintf("Muitas tentativas de login. Tente novamente em %d segundos", segundos), http.StatusTooManyRequests)
+			return
+		}
+		defer tentativa.Cancelar() // sem efeito após Falhou ou Sucesso
+		
 		// Buscar usuário pelo login
 		var usuario struct {
 			ID     int    `json:"id"`
@@ -52,15 +75,21 @@ func LoginHandler(db *sql.DB) http.HandlerFunc {
 			Login  string `json:"login"`
 			Senha  string `json:"senha"`
 			Perfil string `json:"perfil"`
+			Ativo  bool   `json:"ativo"`
+			DeveTrocarSenha bool `json:"deve_trocar_senha"`
 		}
 		
 		err := db.QueryRow(
-			"SELECT id, nome, login, senha, perfil FROM usuarios WHERE login = $1",
+			"SELECT id, nome, login, senha, perfil, ativo, deve_trocar_senha FROM usuarios WHERE login = $1",
 			req.Login,
-		).Scan(&usuario.ID, &usuario.Nome, &usuario.Login, &usuario.Senha, &usuario.Perfil)
+		).Scan(&usuario.ID, &usuario.Nome, &usuario.Login, &usuario.Senha, &usuario.Perfil, &usuario.Ativo, &usuario.DeveTrocarSenha)
 		
 		if err != nil {
 			if err == sql.ErrNoRows {
+				// Conferir a senha mesmo assim, para não revelar pelo tempo de resposta que o login não existe
+				auth.SimularVerificacaoSenha(req.Senha)
+				tentativa.Falhou()
+				registrarTentativaLogin(db, r, req.Login, nil, false, auth.TentativaUsuarioInexistente)
 				http.Error(w, "Credenciais inválidas", http.StatusUnauthorized)
 				return
 			}
@@ -70,36 +99,477 @@ func LoginHandler(db *sql.DB) http.HandlerFunc {
 		
 		// Verificar senha usando bcrypt
 		if !auth.VerificarSenha(req.Senha, usuario.Senha) {
+			tentativa.Falhou()
+			registrarTentativaLogin(db, r, req.Login, &usuario.ID, false, auth.TentativaSenhaIncorreta)
 			http.Error(w, "Credenciais inválidas", http.StatusUnauthorized)
 			return
 		}
 		
-		// Gerar token JWT incluindo o ID e o perfil do usuário
-		token, err := auth.GerarToken(usuario.ID, usuario.Perfil)
+		// Usuários desativados não podem entrar (a mensagem só aparece com a senha correta)
+		if !usuario.Ativo {
+			registrarTentativaLogin(db, r, req.Login, &usuario.ID, false, auth.TentativaUsuarioDesativado)
+			http.Error(w, "Usuário desativado", http.StatusForbidden)
+			return
+		}
+		
+		// Iniciar a sessão e gerar o token de acesso (com o ID e o perfil do usuário) e o refresh token
+		tokens, err := auth.IniciarSessao(db, usuario.ID, usuario.Perfil, ip, r.UserAgent())
 		if err != nil {
 			http.Error(w, "Erro ao gerar token", http.StatusInternalServerError)
 			return
 		}
+		tentativa.Sucesso()
+		registrarTentativaLogin(db, r, req.Login, &usuario.ID, true, auth.TentativaSucesso)
 		
 		// Criar resposta
 		resp := struct {
-			ID     int       `json:"id"`
-			Nome   string    `json:"nome"`
-			Login  string    `json:"login"`
-			Perfil string    `json:"perfil"`
-			Token  string    `json:"token"`
-			Expira time.Time `json:"expira"`
+			ID     int    `json:"id"`
+			Nome   string `json:"nome"`
+			Login  string `json:"login"`
+			Perfil string `json:"perfil"`
+			// Com senha provisória, o token só serve para POST /api/usuarios/me/senha
+			DeveTrocarSenha bool `json:"deve_trocar_senha"`
+			auth.Tokens
 		}{
 			ID:     usuario.ID,
 			Nome:   usuario.Nome,
 			Login:  usuario.Login,
 			Perfil: usuario.Perfil,
-			Token:  token,
-			Expira: time.Now().Add(24 * time.Hour), // Token expira em 24 horas
+			DeveTrocarSenha: usuario.DeveTrocarSenha,
+			Tokens: tokens,
 		}
 		
 		// Enviar resposta
 		w.WriteHeader(http.StatusOK)
 		json.NewEncoder(w).Encode(resp)
 	}
-}
\ No newline at end of file
+}
+
+// RenovarTokenHandler troca um refresh token válido por um novo token de acesso e um novo refresh token
+func RenovarTokenHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Configurar cabeçalhos
+		w.Header().Set("Content-Type", "application/json")
+
+		// Verificar se é uma requisição OPTIONS (preflight)
+		if r.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		// Verificar método
+		if r.Method != http.MethodPost {
+			http.Error(w, "Método não permitido", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var req struct {
+			RefreshToken string `json:"refresh_token"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Erro ao decodificar requisição", http.StatusBadRequest)
+			return
+		}
+		if req.RefreshToken == "" {
+			http.Error(w, "refresh_token é obrigatório", http.StatusBadRequest)
+			return
+		}
+
+		tokens, err := auth.RenovarSessao(db, req.RefreshToken)
+		if errors.Is(err, auth.ErrRefreshInvalido) {
+			http.Error(w, "Sessão expirada ou revogada, faça login novamente", http.StatusUnauthorized)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Erro ao renovar sessão: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		json.NewEncoder(w).Encode(tokens)
+	}
+}
+
+// LogoutHandler encerra a sessão do token usado na requisição
+func LogoutHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Verificar se o usuário está autenticado
+		sessaoID, ok := middleware.ObterSessaoID(r)
+		if !ok {
+			http.Error(w, "Usuário não autenticado", http.StatusUnauthorized)
+			return
+		}
+
+		// Configurar cabeçalhos
+		w.Header().Set("Content-Type", "application/json")
+
+		// Verificar método
+		if r.Method != http.MethodPost {
+			http.Error(w, "Método não permitido", http.StatusMethodNotAllowed)
+			return
+		}
+
+		if err := auth.EncerrarSessao(db, sessaoID, auth.MotivoLogout); err != nil {
+			http.Error(w, "Erro ao encerrar sessão: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		json.NewEncoder(w).Encode(map[string]string{"mensagem": "Sessão encerrada com sucesso"})
+	}
+}
+
+// TokenStreamHandler emite um token de stream de curta duração para abrir as conexões de eventos
+// (/api/eventos e /api/bina/eventos) com o EventSource do navegador, que não envia o cabeçalho
+// Authorization. O token vai no parâmetro token da URL e só é aceito nessas rotas.
+func TokenStreamHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Verificar se o usuário está autenticado
+		userID, ok := middleware.ObterUsuarioID(r)
+		if !ok {
+			http.Error(w, "Usuário não autenticado", http.StatusUnauthorized)
+			return
+		}
+		perfil, _ := middleware.ObterPerfilUsuario(r)
+		sessaoID, _ := middleware.ObterSessaoID(r)
+
+		// Configurar cabeçalhos
+		w.Header().Set("Content-Type", "application/json")
+
+		// Verificar método
+		if r.Method != http.MethodPost {
+			http.Error(w, "Método não permitido", http.StatusMethodNotAllowed)
+			return
+		}
+
+		token, expira, err := auth.GerarTokenStream(userID, perfil, sessaoID)
+		if err != nil {
+			http.Error(w, "Erro ao gerar token de stream: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"token":  token,
+			"expira": expira,
+		})
+	}
+}
+
+// RevogarSessoesUsuarioHandler revoga todas as sessões de um usuário (apenas admin). Os tokens de
+// acesso já emitidos deixam de ser aceitos imediatamente.
+func RevogarSessoesUsuarioHandler(db *sql.DB) http.HandlerFunc {
+	return middleware.RequerPermissao(permissao.UsuarioGerenciar)(func(w http.ResponseWriter, r *http.Request) {
+		// Configurar cabeçalhos
+		w.Header().Set("Content-Type", "application/json")
+
+		// Verificar método
+		if r.Method != http.MethodPost {
+			http.Error(w, "Método não permitido", http.StatusMethodNotAllowed)
+			return
+		}
+
+		// Extrair ID da URL (/api/usuarios/{id}/revogar-sessoes)
+		id, err := strconv.Atoi(strings.Split(r.URL.Path, "/")[3])
+		if err != nil {
+			http.Error(w, "ID inválido", http.StatusBadRequest)
+			return
+		}
+
+		if !protegerUsuarioAdmin(w, r, db, id) {
+			return
+		}
+
+		var existe bool
+		if err := db.QueryRow("SELECT EXISTS(SELECT 1 FROM usuarios WHERE id = $1)", id).Scan(&existe); err != nil {
+			http.Error(w, "Erro ao verificar usuário", http.StatusInternalServerError)
+			return
+		}
+		if !existe {
+			http.Error(w, "Usuário não encontrado", http.StatusNotFound)
+			return
+		}
+
+		revogadas, err := auth.RevogarSessoesUsuario(db, id, auth.MotivoRevogadaAdmin)
+		if err != nil {
+			http.Error(w, "Erro ao revogar sessões: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"mensagem":          "Sessões revogadas com sucesso",
+			"sessoes_revogadas": revogadas,
+		})
+	})
+}
+
+// TrocarSenhaHandler troca a senha do próprio usuário (POST /api/usuarios/me/senha). É a única rota
+// liberada enquanto a senha é provisória; as demais sessões do usuário são revogadas.
+func TrocarSenhaHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Verificar se o usuário está autenticado
+		userID, okID := middleware.ObterUsuarioID(r)
+		sessaoID, okSessao := middleware.ObterSessaoID(r)
+		if !okID || !okSessao {
+			http.Error(w, "Usuário não autenticado", http.StatusUnauthorized)
+			return
+		}
+
+		// Configurar cabeçalhos
+		w.Header().Set("Content-Type", "application/json")
+
+		// Verificar método
+		if r.Method != http.MethodPost {
+			http.Error(w, "Método não permitido", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var req struct {
+			SenhaAtual string `json:"senha_atual"`
+			NovaSenha  string `json:"nova_senha"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Erro ao decodificar requisição", http.StatusBadRequest)
+			return
+		}
+		if req.SenhaAtual == "" || req.NovaSenha == "" {
+			http.Error(w, "Senha atual e nova senha são obrigatórias", http.StatusBadRequest)
+			return
+		}
+
+		var login, senhaHash string
+		err := db.QueryRow("SELECT login, senha FROM usuarios WHERE id = $1", userID).Scan(&login, &senhaHash)
+		if err == sql.ErrNoRows {
+			http.Error(w, "Usuário não encontrado", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Erro ao buscar usuário", http.StatusInternalServerError)
+			return
+		}
+
+		// Senha atual incorreta não responde 401 para não encerrar a sessão no frontend
+		if !auth.VerificarSenha(req.SenhaAtual, senhaHash) {
+			http.Error(w, "Senha atual incorreta", http.StatusBadRequest)
+			return
+		}
+		if req.NovaSenha == req.SenhaAtual {
+			http.Error(w, "A nova senha deve ser diferente da atual", http.StatusBadRequest)
+			return
+		}
+		if err := auth.ValidarSenha(req.NovaSenha, login); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		novoHash, err := auth.HashSenha(req.NovaSenha)
+		if err != nil {
+			http.Error(w, "Erro ao processar senha", http.StatusInternalServerError)
+			return
+		}
+		_, err = db.Exec(`
+			UPDATE usuarios
+			SET senha = $1, deve_trocar_senha = FALSE, senha_alterada_em = NOW(), atualizado_em = NOW()
+			WHERE id = $2
+		`, novoHash, userID)
+		if err != nil {
+			http.Error(w, "Erro ao trocar senha: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if _, err := auth.RevogarOutrasSessoes(db, userID, sessaoID, auth.MotivoSenhaAlterada); err != nil {
+			log.Printf("Erro ao revogar as outras sessões do usuário %d: %v", userID, err)
+		}
+
+		json.NewEncoder(w).Encode(map[string]string{"mensagem": "Senha alterada com sucesso"})
+	}
+}
+
+// RedefinirSenhaUsuarioHandler gera uma senha temporária para outro usuário (apenas admin). A senha
+// só é exibida nesta resposta, as sessões do usuário são revogadas e ele terá de trocá-la ao entrar.
+func RedefinirSenhaUsuarioHandler(db *sql.DB) http.HandlerFunc {
+	return middleware.RequerPermissao(permissao.UsuarioGerenciar)(func(w http.ResponseWriter, r *http.Request) {
+		// Configurar cabeçalhos; a senha temporária não deve ficar em cache
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+
+		// Verificar método
+		if r.Method != http.MethodPost {
+			http.Error(w, "Método não permitido", http.StatusMethodNotAllowed)
+			return
+		}
+
+		// Extrair ID da URL (/api/usuarios/{id}/redefinir-senha)
+		id, err := strconv.Atoi(strings.Split(r.URL.Path, "/")[3])
+		if err != nil {
+			http.Error(w, "ID inválido", http.StatusBadRequest)
+			return
+		}
+
+		userID, _ := middleware.ObterUsuarioID(r)
+		if userID == id {
+			http.Error(w, "Para trocar a própria senha use "+middleware.RotaTrocarSenha, http.StatusBadRequest)
+			return
+		}
+
+		if !protegerUsuarioAdmin(w, r, db, id) {
+			return
+		}
+
+		senhaTemporaria, err := auth.GerarSenhaTemporaria()
+		if err != nil {
+			http.Error(w, "Erro ao gerar senha temporária", http.StatusInternalServerError)
+			return
+		}
+		senhaHash, err := auth.HashSenha(senhaTemporaria)
+		if err != nil {
+			http.Error(w, "Erro ao processar senha", http.StatusInternalServerError)
+			return
+		}
+
+		var login string
+		err = db.QueryRow(`
+			UPDATE usuarios
+			SET senha = $1, deve_trocar_senha = TRUE, senha_alterada_em = NOW(), atualizado_em = NOW()
+			WHERE id = $2
+			RETURNING login
+		`, senhaHash, id).Scan(&login)
+		if err == sql.ErrNoRows {
+			http.Error(w, "Usuário não encontrado", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Erro ao redefinir senha: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		revogadas, err := auth.RevogarSessoesUsuario(db, id, auth.MotivoSenhaRedefinida)
+		if err != nil {
+			http.Error(w, "Erro ao revogar sessões: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"mensagem":          "Senha redefinida; o usuário deverá trocá-la no próximo acesso",
+			"login":             login,
+			"senha_temporaria":  senhaTemporaria,
+			"sessoes_revogadas": revogadas,
+		})
+	})
+}
+
+// ListarTentativasLoginHandler lista o registro de tentativas de login (apenas admin), com filtros
+// opcionais por login, ip e sucesso
+func ListarTentativasLoginHandler(db *sql.DB) http.HandlerFunc {
+	return middleware.RequerPermissao(permissao.SegurancaGerenciar)(func(w http.ResponseWriter, r *http.Request) {
+		// Configurar cabeçalhos
+		w.Header().Set("Content-Type", "application/json")
+
+		// Verificar método
+		if r.Method != http.MethodGet {
+			http.Error(w, "Método não permitido", http.StatusMethodNotAllowed)
+			return
+		}
+
+		query := r.URL.Query()
+		var condicoes []string
+		var params []interface{}
+		if login := query.Get("login"); login != "" {
+			params = append(params, strings.ToLower(login))
+			condicoes = append(condicoes, "LOWER(login) = $"+strconv.Itoa(len(params)))
+		}
+		if ip := query.Get("ip"); ip != "" {
+			params = append(params, ip)
+			condicoes = append(condicoes, "ip = $"+strconv.Itoa(len(params)))
+		}
+		if sucesso := query.Get("sucesso"); sucesso != "" {
+			valor, err := strconv.ParseBool(sucesso)
+			if err != nil {
+				http.Error(w, "Parâmetro sucesso inválido (use true ou false)", http.StatusBadRequest)
+				return
+			}
+			params = append(params, valor)
+			condicoes = append(condicoes, "sucesso = $"+strconv.Itoa(len(params)))
+		}
+
+		limit, _ := strconv.Atoi(query.Get("limit"))
+		if limit < 1 || limit > 500 {
+			limit = 100
+		}
+
+		sqlQuery := `
+			SELECT id, login, usuario_id, COALESCE(ip, ''), COALESCE(user_agent, ''), sucesso, motivo, criado_em
+			FROM tentativas_login`
+		if len(condicoes) > 0 {
+			sqlQuery += " WHERE " + strings.Join(condicoes, " AND ")
+		}
+		params = append(params, limit)
+		sqlQuery += " ORDER BY id DESC LIMIT $" + strconv.Itoa(len(params))
+
+		rows, err := db.Query(sqlQuery, params...)
+		if err != nil {
+			http.Error(w, "Erro ao buscar tentativas de login: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		tentativas := []models.TentativaLogin{}
+		for rows.Next() {
+			var t models.TentativaLogin
+			if err := rows.Scan(&t.ID, &t.Login, &t.UsuarioID, &t.IP, &t.UserAgent, &t.Sucesso, &t.Motivo, &t.CriadoEm); err != nil {
+				http.Error(w, "Erro ao processar tentativas de login: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+			tentativas = append(tentativas, t)
+		}
+		if err := rows.Err(); err != nil {
+			http.Error(w, "Erro ao processar tentativas de login: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		json.NewEncoder(w).Encode(tentativas)
+	})
+}
+
+// BloqueiosLoginHandler lista (GET) os logins e IPs bloqueados ou em atraso, ou libera (DELETE)
+// um deles, informado por ?login= ou ?ip= (apenas admin)
+func BloqueiosLoginHandler(limitador *auth.Limitador) http.HandlerFunc {
+	return middleware.RequerPermissao(permissao.SegurancaGerenciar)(func(w http.ResponseWriter, r *http.Request) {
+		// Configurar cabeçalhos
+		w.Header().Set("Content-Type", "application/json")
+
+		switch r.Method {
+		case http.MethodGet:
+			json.NewEncoder(w).Encode(limitador.Restricoes())
+		case http.MethodDelete:
+			tipo, valor := auth.ChaveLogin, r.URL.Query().Get("login")
+			if valor == "" {
+				tipo, valor = auth.ChaveIP, r.URL.Query().Get("ip")
+			}
+			if valor == "" {
+				http.Error(w, "Informe o login ou o ip a liberar", http.StatusBadRequest)
+				return
+			}
+			if !limitador.Liberar(tipo, valor) {
+				http.Error(w, "Nenhum bloqueio encontrado", http.StatusNotFound)
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]string{"mensagem": "Bloqueio removido com sucesso"})
+		default:
+			http.Error(w, "Método não permitido", http.StatusMethodNotAllowed)
+		}
+	})
+}
+
+// registrarTentativaLogin grava a tentativa na auditoria; uma falha ao gravar não impede o login
+func registrarTentativaLogin(db *sql.DB, r *http.Request, login string, usuarioID *int, sucesso bool, motivo string) {
+	if err := auth.RegistrarTentativa(db, login, usuarioID, ipCliente(r), r.UserAgent(), sucesso, motivo); err != nil {
+		log.Printf("Erro ao registrar tentativa de login: %v", err)
+	}
+}
+
+// ipCliente retorna o endereço de origem da conexão, sem a porta
+func ipCliente(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
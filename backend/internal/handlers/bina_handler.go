@@ -0,0 +1,210 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/tassyosilva/GestGAS/internal/bina"
+	"github.com/tassyosilva/GestGAS/internal/middleware"
+	"github.com/tassyosilva/GestGAS/internal/models"
+	"github.com/tassyosilva/GestGAS/internal/permissao"
+)
+
+// intervaloPingEventos mantém a conexão de eventos aberta através de proxies que encerram conexões ociosas
+const intervaloPingEventos = 25 * time.Second
+
+// ReceberChamadaHandler registra uma chamada enviada por um usuário autenticado (simulador na tela do atendente)
+func ReceberChamadaHandler(central *bina.Central) http.HandlerFunc {
+	return middleware.RequerPermissao(permissao.BinaAtender)(func(w http.ResponseWriter, r *http.Request) {
+		// Verificar se o usuário está autenticado
+		_, ok := middleware.ObterUsuarioID(r)
+		if !ok {
+			http.Error(w, "Usuário não autenticado", http.StatusUnauthorized)
+			return
+		}
+
+		receberChamada(w, r, central)
+	})
+}
+
+// ReceberChamadaDispositivoHandler registra uma chamada enviada pelo bina, autenticado pelo cabeçalho X-Bina-Token
+func ReceberChamadaDispositivoHandler(central *bina.Central, token string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Sem token configurado, apenas usuários autenticados podem registrar chamadas
+		recebido := r.Header.Get("X-Bina-Token")
+		if token == "" || subtle.ConstantTimeCompare([]byte(recebido), []byte(token)) != 1 {
+			http.Error(w, "Token do bina inválido", http.StatusUnauthorized)
+			return
+		}
+
+		receberChamada(w, r, central)
+	}
+}
+
+// receberChamada decodifica a chamada, identifica o cliente e a repassa às telas conectadas
+func receberChamada(w http.ResponseWriter, r *http.Request, central *bina.Central) {
+	// Configurar cabeçalhos
+	w.Header().Set("Content-Type", "application/json")
+
+	// Verificar método
+	if r.Method != http.MethodPost {
+		http.Error(w, "Método não permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Decodificar requisição
+	var req models.NovaChamadaRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Erro ao processar requisição: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	chamada, ok := bina.InterpretarLinha(req.Numero)
+	if !ok {
+		http.Error(w, "Número da chamada inválido", http.StatusBadRequest)
+		return
+	}
+	if req.Ramal != "" {
+		chamada.Ramal = req.Ramal
+	}
+
+	recebida, err := central.Receber(chamada)
+	if err != nil {
+		http.Error(w, "Erro ao processar chamada: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(recebida)
+}
+
+// ListarChamadasHandler retorna as chamadas recentes (parâmetro limit, padrão 20)
+func ListarChamadasHandler(db *sql.DB) http.HandlerFunc {
+	return middleware.RequerPermissao(permissao.BinaAtender)(func(w http.ResponseWriter, r *http.Request) {
+		// Verificar se o usuário está autenticado
+		_, ok := middleware.ObterUsuarioID(r)
+		if !ok {
+			http.Error(w, "Usuário não autenticado", http.StatusUnauthorized)
+			return
+		}
+
+		// Configurar cabeçalhos
+		w.Header().Set("Content-Type", "application/json")
+
+		// Verificar método
+		if r.Method != http.MethodGet {
+			http.Error(w, "Método não permitido", http.StatusMethodNotAllowed)
+			return
+		}
+
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		if limit < 1 || limit > 100 {
+			limit = 20
+		}
+
+		rows, err := db.Query(`
+			SELECT ch.id, ch.numero, COALESCE(ch.telefone_e164, ''), COALESCE(ch.ramal, ''),
+				ch.cliente_id, COALESCE(c.nome, ''), ch.clientes_encontrados, ch.recebida_em
+			FROM chamadas ch
+			LEFT JOIN clientes c ON ch.cliente_id = c.id
+			ORDER BY ch.recebida_em DESC, ch.id DESC
+			LIMIT $1
+		`, limit)
+		if err != nil {
+			http.Error(w, "Erro ao buscar chamadas: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		chamadas := []models.RegistroChamada{}
+		for rows.Next() {
+			var c models.RegistroChamada
+			var clienteID sql.NullInt64
+			err := rows.Scan(&c.ID, &c.Numero, &c.TelefoneE164, &c.Ramal, &clienteID,
+				&c.NomeCliente, &c.ClientesEncontrados, &c.RecebidaEm)
+			if err != nil {
+				http.Error(w, "Erro ao processar chamadas: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+			if clienteID.Valid {
+				id := int(clienteID.Int64)
+				c.ClienteID = &id
+			}
+			chamadas = append(chamadas, c)
+		}
+		if err := rows.Err(); err != nil {
+			http.Error(w, "Erro ao processar chamadas: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		json.NewEncoder(w).Encode(chamadas)
+	})
+}
+
+// EventosChamadasHandler mantém uma conexão Server-Sent Events com a tela do atendente e envia
+// um evento "chamada" a cada ligação identificada. O parâmetro ramal restringe às chamadas daquele ramal.
+func EventosChamadasHandler(db *sql.DB, central *bina.Central) http.HandlerFunc {
+	return middleware.RequerPermissao(permissao.BinaAtender)(func(w http.ResponseWriter, r *http.Request) {
+		// Verificar se o usuário está autenticado
+		_, ok := middleware.ObterUsuarioID(r)
+		if !ok {
+			http.Error(w, "Usuário não autenticado", http.StatusUnauthorized)
+			return
+		}
+
+		// Verificar método
+		if r.Method != http.MethodGet {
+			http.Error(w, "Método não permitido", http.StatusMethodNotAllowed)
+			return
+		}
+
+		// A conexão fica aberta indefinidamente: remover o prazo de escrita do servidor
+		rc := http.NewResponseController(w)
+		if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+			http.Error(w, "Streaming não suportado", http.StatusInternalServerError)
+			return
+		}
+
+		chamadas, cancelar := central.Assinar(r.URL.Query().Get("ramal"))
+		defer cancelar()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.WriteHeader(http.StatusOK)
+		rc.Flush()
+
+		ping := time.NewTicker(intervaloPingEventos)
+		defer ping.Stop()
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-ping.C:
+				// Encerrar o stream se a sessão foi revogada ou a permissão retirada depois da conexão
+				if !streamAutorizado(db, r, permissao.BinaAtender) {
+					return
+				}
+				if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+					return
+				}
+			case chamada := <-chamadas:
+				dados, err := json.Marshal(chamada)
+				if err != nil {
+					continue
+				}
+				if _, err := fmt.Fprintf(w, "id: %d\nevent: chamada\ndata: %s\n\n", chamada.ID, dados); err != nil {
+					return
+				}
+			}
+			if err := rc.Flush(); err != nil {
+				return
+			}
+		}
+	})
+}
@@ -0,0 +1,782 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/tassyosilva/GestGAS/internal/middleware"
+	"github.com/tassyosilva/GestGAS/internal/models"
+	"github.com/tassyosilva/GestGAS/internal/permissao"
+)
+
+// consultaCaixa seleciona o caixa com os nomes de quem abriu e de quem fechou
+const consultaCaixa = `
+	SELECT
+		c.id, c.usuario_id, u.nome, u.perfil, c.status, c.valor_abertura,
+		c.observacoes_abertura, c.aberto_em, c.fechado_em,
+		c.fechado_por, f.nome, f.perfil, c.observacoes_fechamento
+	FROM caixas c
+	JOIN usuarios u ON c.usuario_id = u.id
+	LEFT JOIN usuarios f ON c.fechado_por = f.id
+`
+
+// consultor é atendido tanto por *sql.DB quanto por *sql.Tx
+type consultor interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// AbrirCaixaHandler abre um caixa para o usuário autenticado
+func AbrirCaixaHandler(db *sql.DB) http.HandlerFunc {
+	return middleware.RequerPermissao(permissao.CaixaOperar)(func(w http.ResponseWriter, r *http.Request) {
+		// Verificar se o usuário está autenticado
+		userID, ok := middleware.ObterUsuarioID(r)
+		if !ok {
+			http.Error(w, "Usuário não autenticado", http.StatusUnauthorized)
+			return
+		}
+
+		// Configurar cabeçalhos
+		w.Header().Set("Content-Type", "application/json")
+
+		// Verificar método
+		if r.Method != http.MethodPost {
+			http.Error(w, "Método não permitido", http.StatusMethodNotAllowed)
+			return
+		}
+
+		// Decodificar requisição
+		var req models.AbrirCaixaRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Erro ao processar requisição: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// Validar dados
+		req.ValorAbertura = arredondarCentavos(req.ValorAbertura)
+		if req.ValorAbertura < 0 {
+			http.Error(w, "Valor de abertura não pode ser negativo", http.StatusBadRequest)
+			return
+		}
+
+		// Verificar se o usuário já tem caixa aberto
+		var existe bool
+		err := db.QueryRow("SELECT EXISTS(SELECT 1 FROM caixas WHERE usuario_id = $1 AND status = $2)", userID, models.CaixaAberto).Scan(&existe)
+		if err != nil {
+			http.Error(w, "Erro ao verificar caixa aberto: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if existe {
+			http.Error(w, "Você já possui um caixa aberto", http.StatusBadRequest)
+			return
+		}
+
+		// Abrir caixa (o índice único protege contra aberturas simultâneas)
+		var caixaID int
+		err = db.QueryRow(`
+			INSERT INTO caixas (usuario_id, status, valor_abertura, observacoes_abertura, aberto_em)
+			VALUES ($1, $2, $3, NULLIF($4, ''), NOW())
+			RETURNING id
+		`, userID, models.CaixaAberto, req.ValorAbertura, req.Observacoes).Scan(&caixaID)
+		if err != nil {
+			if strings.Contains(err.Error(), "idx_caixas_aberto_usuario") {
+				http.Error(w, "Você já possui um caixa aberto", http.StatusBadRequest)
+				return
+			}
+			http.Error(w, "Erro ao abrir caixa: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		caixa, err := buscarCaixa(db, caixaID)
+		if err != nil {
+			http.Error(w, "Caixa aberto, mas erro ao buscar detalhes: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		// Retornar resposta
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(caixa)
+	})
+}
+
+// CaixaAtualHandler retorna o caixa aberto do usuário autenticado, com a conferência parcial
+func CaixaAtualHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Verificar se o usuário está autenticado
+		userID, ok := middleware.ObterUsuarioID(r)
+		if !ok {
+			http.Error(w, "Usuário não autenticado", http.StatusUnauthorized)
+			return
+		}
+
+		// Configurar cabeçalhos
+		w.Header().Set("Content-Type", "application/json")
+
+		// Verificar método
+		if r.Method != http.MethodGet {
+			http.Error(w, "Método não permitido", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var caixaID int
+		err := db.QueryRow("SELECT id FROM caixas WHERE usuario_id = $1 AND status = $2", userID, models.CaixaAberto).Scan(&caixaID)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				http.Error(w, "Nenhum caixa aberto", http.StatusNotFound)
+				return
+			}
+			http.Error(w, "Erro ao buscar caixa: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		caixa, err := buscarCaixa(db, caixaID)
+		if err != nil {
+			http.Error(w, "Erro ao buscar caixa: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		json.NewEncoder(w).Encode(caixa)
+	}
+}
+
+// ListarCaixasHandler lista as sessões de caixa; atendentes veem apenas as próprias
+func ListarCaixasHandler(db *sql.DB) http.HandlerFunc {
+	return middleware.RequerPermissao(permissao.CaixaOperar)(func(w http.ResponseWriter, r *http.Request) {
+		// Verificar se o usuário está autenticado
+		userID, ok := middleware.ObterUsuarioID(r)
+		if !ok {
+			http.Error(w, "Usuário não autenticado", http.StatusUnauthorized)
+			return
+		}
+
+		// Configurar cabeçalhos
+		w.Header().Set("Content-Type", "application/json")
+
+		// Verificar método
+		if r.Method != http.MethodGet {
+			http.Error(w, "Método não permitido", http.StatusMethodNotAllowed)
+			return
+		}
+
+		// Parâmetros de consulta
+		query := r.URL.Query()
+		page, _ := strconv.Atoi(query.Get("page"))
+		limit, _ := strconv.Atoi(query.Get("limit"))
+		if page < 1 {
+			page = 1
+		}
+		if limit < 1 || limit > 100 {
+			limit = 20
+		}
+		offset := (page - 1) * limit
+
+		var params []interface{}
+		var whereConditions []string
+
+		if status := query.Get("status"); status != "" {
+			params = append(params, status)
+			whereConditions = append(whereConditions, "c.status = $"+strconv.Itoa(len(params)))
+		}
+		if !middleware.PossuiPermissao(r, permissao.CaixaSupervisionar) {
+			params = append(params, userID)
+			whereConditions = append(whereConditions, "c.usuario_id = $"+strconv.Itoa(len(params)))
+		} else if usuarioID, err := strconv.Atoi(query.Get("usuario_id")); err == nil {
+			params = append(params, usuarioID)
+			whereConditions = append(whereConditions, "c.usuario_id = $"+strconv.Itoa(len(params)))
+		}
+		if dataInicio := query.Get("data_inicio"); dataInicio != "" {
+			params = append(params, dataInicio)
+			whereConditions = append(whereConditions, "c.aberto_em >= $"+strconv.Itoa(len(params)))
+		}
+		if dataFim := query.Get("data_fim"); dataFim != "" {
+			params = append(params, dataFim)
+			whereConditions = append(whereConditions, "c.aberto_em <= $"+strconv.Itoa(len(params)))
+		}
+
+		where := ""
+		if len(whereConditions) > 0 {
+			where = " WHERE " + strings.Join(whereConditions, " AND ")
+		}
+
+		var total int
+		err := db.QueryRow("SELECT COUNT(*) FROM caixas c"+where, params...).Scan(&total)
+		if err != nil {
+			http.Error(w, "Erro ao contar caixas: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		sqlQuery := consultaCaixa + where +
+			" ORDER BY c.aberto_em DESC LIMIT $" + strconv.Itoa(len(params)+1) + " OFFSET $" + strconv.Itoa(len(params)+2)
+		rows, err := db.Query(sqlQuery, append(params, limit, offset)...)
+		if err != nil {
+			http.Error(w, "Erro ao buscar caixas: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		caixas := []models.Caixa{}
+		for rows.Next() {
+			caixa, err := escanearCaixa(rows)
+			if err != nil {
+				http.Error(w, "Erro ao processar caixas: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+			caixas = append(caixas, caixa)
+		}
+		if err := rows.Err(); err != nil {
+			http.Error(w, "Erro ao processar caixas: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		// Montar resposta
+		response := struct {
+			Caixas []models.Caixa `json:"caixas"`
+			Total  int            `json:"total"`
+			Page   int            `json:"page"`
+			Limit  int            `json:"limit"`
+			Pages  int            `json:"pages"`
+		}{
+			Caixas: caixas,
+			Total:  total,
+			Page:   page,
+			Limit:  limit,
+			Pages:  (total + limit - 1) / limit,
+		}
+
+		json.NewEncoder(w).Encode(response)
+	})
+}
+
+// ObterCaixaHandler retorna um caixa com movimentações e conferência
+func ObterCaixaHandler(db *sql.DB) http.HandlerFunc {
+	return middleware.RequerPermissao(permissao.CaixaOperar)(func(w http.ResponseWriter, r *http.Request) {
+		// Verificar se o usuário está autenticado
+		userID, ok := middleware.ObterUsuarioID(r)
+		if !ok {
+			http.Error(w, "Usuário não autenticado", http.StatusUnauthorized)
+			return
+		}
+
+		// Configurar cabeçalhos
+		w.Header().Set("Content-Type", "application/json")
+
+		// Verificar método
+		if r.Method != http.MethodGet {
+			http.Error(w, "Método não permitido", http.StatusMethodNotAllowed)
+			return
+		}
+
+		// Extrair ID do caixa da URL
+		parts := strings.Split(r.URL.Path, "/")
+		caixaID, err := strconv.Atoi(parts[3])
+		if err != nil {
+			http.Error(w, "ID do caixa inválido", http.StatusBadRequest)
+			return
+		}
+
+		caixa, err := buscarCaixa(db, caixaID)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				http.Error(w, "Caixa não encontrado", http.StatusNotFound)
+				return
+			}
+			http.Error(w, "Erro ao buscar caixa: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !podeOperarCaixa(r, userID, caixa.Usuario.ID) {
+			http.Error(w, "Sem permissão para consultar este caixa", http.StatusForbidden)
+			return
+		}
+
+		json.NewEncoder(w).Encode(caixa)
+	})
+}
+
+// MovimentarCaixaHandler registra uma sangria ou um suprimento (/api/caixas/{id}/sangria ou /suprimento)
+func MovimentarCaixaHandler(db *sql.DB) http.HandlerFunc {
+	return middleware.RequerPermissao(permissao.CaixaOperar)(func(w http.ResponseWriter, r *http.Request) {
+		// Verificar se o usuário está autenticado
+		userID, ok := middleware.ObterUsuarioID(r)
+		if !ok {
+			http.Error(w, "Usuário não autenticado", http.StatusUnauthorized)
+			return
+		}
+
+		// Configurar cabeçalhos
+		w.Header().Set("Content-Type", "application/json")
+
+		// Verificar método
+		if r.Method != http.MethodPost {
+			http.Error(w, "Método não permitido", http.StatusMethodNotAllowed)
+			return
+		}
+
+		// Extrair ID do caixa e tipo da movimentação da URL
+		parts := strings.Split(r.URL.Path, "/")
+		if len(parts) < 5 {
+			http.Error(w, "URL inválida", http.StatusBadRequest)
+			return
+		}
+		caixaID, err := strconv.Atoi(parts[3])
+		if err != nil {
+			http.Error(w, "ID do caixa inválido", http.StatusBadRequest)
+			return
+		}
+		tipo := models.TipoMovimentacaoCaixa(parts[4])
+		if tipo != models.MovimentacaoSangria && tipo != models.MovimentacaoSuprimento {
+			http.Error(w, "Tipo de movimentação inválido", http.StatusBadRequest)
+			return
+		}
+
+		// Decodificar requisição
+		var req models.MovimentacaoCaixaRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Erro ao processar requisição: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		req.Valor = arredondarCentavos(req.Valor)
+		if req.Valor <= 0 {
+			http.Error(w, "Valor deve ser maior que zero", http.StatusBadRequest)
+			return
+		}
+
+		// Iniciar transação
+		tx, err := db.Begin()
+		if err != nil {
+			http.Error(w, "Erro ao iniciar transação: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback() // sem efeito após o commit
+
+		caixa, ok := bloquearCaixaAberto(w, r, tx, caixaID, userID)
+		if !ok {
+			return
+		}
+
+		// A sangria não pode retirar mais dinheiro do que o esperado na gaveta
+		if tipo == models.MovimentacaoSangria {
+			resumo, err := calcularResumoCaixa(tx, caixaID, caixa)
+			if err != nil {
+				http.Error(w, "Erro ao calcular saldo do caixa: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+			if dinheiro := valorEsperado(resumo, models.PagamentoDinheiro); req.Valor > dinheiro {
+				http.Error(w, fmt.Sprintf("Sangria excede o dinheiro esperado no caixa (%.2f)", dinheiro), http.StatusBadRequest)
+				return
+			}
+		}
+
+		_, err = tx.Exec(`
+			INSERT INTO movimentacoes_caixa (caixa_id, tipo, valor, motivo, usuario_id, criado_em)
+			VALUES ($1, $2, $3, NULLIF($4, ''), $5, NOW())
+		`, caixaID, tipo, req.Valor, req.Motivo, userID)
+		if err != nil {
+			http.Error(w, "Erro ao registrar movimentação: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if err := tx.Commit(); err != nil {
+			http.Error(w, "Erro ao finalizar transação: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		caixaResp, err := buscarCaixa(db, caixaID)
+		if err != nil {
+			http.Error(w, "Movimentação registrada, mas erro ao buscar caixa: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(caixaResp)
+	})
+}
+
+// FecharCaixaHandler fecha o caixa gravando a conferência esperado x declarado por forma de pagamento
+func FecharCaixaHandler(db *sql.DB) http.HandlerFunc {
+	return middleware.RequerPermissao(permissao.CaixaOperar)(func(w http.ResponseWriter, r *http.Request) {
+		// Verificar se o usuário está autenticado
+		userID, ok := middleware.ObterUsuarioID(r)
+		if !ok {
+			http.Error(w, "Usuário não autenticado", http.StatusUnauthorized)
+			return
+		}
+
+		// Configurar cabeçalhos
+		w.Header().Set("Content-Type", "application/json")
+
+		// Verificar método
+		if r.Method != http.MethodPost {
+			http.Error(w, "Método não permitido", http.StatusMethodNotAllowed)
+			return
+		}
+
+		// Extrair ID do caixa da URL (/api/caixas/{id}/fechar)
+		parts := strings.Split(r.URL.Path, "/")
+		caixaID, err := strconv.Atoi(parts[3])
+		if err != nil {
+			http.Error(w, "ID do caixa inválido", http.StatusBadRequest)
+			return
+		}
+
+		// Decodificar requisição
+		var req models.FecharCaixaRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Erro ao processar requisição: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// Validar valores declarados
+		for forma, valor := range req.ValoresDeclarados {
+			if !formaPagamentoCaixa(forma) {
+				http.Error(w, "Forma de pagamento inválida: "+string(forma), http.StatusBadRequest)
+				return
+			}
+			if valor < 0 {
+				http.Error(w, "Valor declarado não pode ser negativo", http.StatusBadRequest)
+				return
+			}
+			req.ValoresDeclarados[forma] = arredondarCentavos(valor)
+		}
+		if _, ok := req.ValoresDeclarados[models.PagamentoDinheiro]; !ok {
+			http.Error(w, "Informe o valor contado em dinheiro", http.StatusBadRequest)
+			return
+		}
+
+		// Iniciar transação
+		tx, err := db.Begin()
+		if err != nil {
+			http.Error(w, "Erro ao iniciar transação: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback() // sem efeito após o commit
+
+		caixa, ok := bloquearCaixaAberto(w, r, tx, caixaID, userID)
+		if !ok {
+			return
+		}
+
+		resumo, err := calcularResumoCaixa(tx, caixaID, caixa)
+		if err != nil {
+			http.Error(w, "Erro ao calcular conferência do caixa: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		// Gravar a conferência por forma de pagamento
+		for _, conf := range resumo.Formas {
+			var declarado *float64
+			if valor, ok := req.ValoresDeclarados[conf.FormaPagamento]; ok {
+				declarado = &valor
+			}
+			_, err = tx.Exec(`
+				INSERT INTO fechamentos_caixa
+				(caixa_id, forma_pagamento, quantidade_pedidos, valor_vendas, valor_vendas_antecipadas,
+				valor_recebimentos_fiado, valor_esperado, valor_declarado)
+				VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+			`, caixaID, conf.FormaPagamento, conf.QuantidadePedidos, conf.ValorVendas, conf.ValorVendasAntecipadas,
+				conf.ValorRecebimentosFiado, conf.ValorEsperado, declarado)
+			if err != nil {
+				http.Error(w, "Erro ao registrar fechamento: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+
+		_, err = tx.Exec(`
+			UPDATE caixas
+			SET status = $1, fechado_em = NOW(), fechado_por = $2, observacoes_fechamento = NULLIF($3, '')
+			WHERE id = $4
+		`, models.CaixaFechado, userID, req.Observacoes, caixaID)
+		if err != nil {
+			http.Error(w, "Erro ao fechar caixa: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if err := tx.Commit(); err != nil {
+			http.Error(w, "Erro ao finalizar transação: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		caixaResp, err := buscarCaixa(db, caixaID)
+		if err != nil {
+			http.Error(w, "Caixa fechado, mas erro ao buscar relatório: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		json.NewEncoder(w).Encode(caixaResp)
+	})
+}
+
+// bloquearCaixaAberto bloqueia o caixa e verifica se está aberto e se o usuário pode operá-lo.
+// Em caso de erro, já escreve a resposta e retorna ok = false.
+func bloquearCaixaAberto(w http.ResponseWriter, r *http.Request, tx *sql.Tx, caixaID int, userID int) (models.Caixa, bool) {
+	var caixa models.Caixa
+	err := tx.QueryRow(`
+		SELECT id, usuario_id, status, valor_abertura, aberto_em
+		FROM caixas WHERE id = $1 FOR UPDATE
+	`, caixaID).Scan(&caixa.ID, &caixa.Usuario.ID, &caixa.Status, &caixa.ValorAbertura, &caixa.AbertoEm)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Caixa não encontrado", http.StatusNotFound)
+			return caixa, false
+		}
+		http.Error(w, "Erro ao buscar caixa: "+err.Error(), http.StatusInternalServerError)
+		return caixa, false
+	}
+	if !podeOperarCaixa(r, userID, caixa.Usuario.ID) {
+		http.Error(w, "Sem permissão para operar este caixa", http.StatusForbidden)
+		return caixa, false
+	}
+	if caixa.Status != models.CaixaAberto {
+		http.Error(w, "Caixa já está fechado", http.StatusBadRequest)
+		return caixa, false
+	}
+	return caixa, true
+}
+
+// podeOperarCaixa permite ao dono do caixa e a quem supervisiona caixas operá-lo
+func podeOperarCaixa(r *http.Request, userID, donoID int) bool {
+	return userID == donoID || middleware.PossuiPermissao(r, permissao.CaixaSupervisionar)
+}
+
+// formaPagamentoCaixa indica se a forma de pagamento faz parte da conferência do caixa
+func formaPagamentoCaixa(forma models.FormaPagamento) bool {
+	for _, f := range models.FormasPagamentoCaixa {
+		if f == forma {
+			return true
+		}
+	}
+	return false
+}
+
+// buscarCaixa retorna o caixa com movimentações e conferência (parcial se aberto, gravada se fechado)
+func buscarCaixa(db *sql.DB, caixaID int) (models.Caixa, error) {
+	caixa, err := escanearCaixa(db.QueryRow(consultaCaixa+" WHERE c.id = $1", caixaID))
+	if err != nil {
+		return caixa, err
+	}
+
+	// Movimentações manuais
+	rows, err := db.Query(`
+		SELECT id, caixa_id, tipo, valor, motivo, usuario_id, criado_em
+		FROM movimentacoes_caixa
+		WHERE caixa_id = $1
+		ORDER BY criado_em, id
+	`, caixaID)
+	if err != nil {
+		return caixa, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var m models.MovimentacaoCaixa
+		var motivo sql.NullString
+		if err := rows.Scan(&m.ID, &m.CaixaID, &m.Tipo, &m.Valor, &motivo, &m.UsuarioID, &m.CriadoEm); err != nil {
+			return caixa, err
+		}
+		m.Motivo = motivo.String
+		caixa.Movimentacoes = append(caixa.Movimentacoes, m)
+	}
+	if err := rows.Err(); err != nil {
+		return caixa, err
+	}
+
+	var resumo models.ResumoCaixa
+	if caixa.Status == models.CaixaFechado {
+		resumo, err = buscarFechamentoCaixa(db, caixa)
+	} else {
+		resumo, err = calcularResumoCaixa(db, caixaID, caixa)
+	}
+	if err != nil {
+		return caixa, err
+	}
+	caixa.Resumo = &resumo
+	return caixa, nil
+}
+
+// escanearCaixa lê uma linha de consultaCaixa
+func escanearCaixa(row interface{ Scan(...interface{}) error }) (models.Caixa, error) {
+	var c models.Caixa
+	var obsAbertura, obsFechamento sql.NullString
+	var fechadoEm sql.NullTime
+	var fechadoPor sql.NullInt64
+	var fechadoPorNome, fechadoPorPerfil sql.NullString
+
+	err := row.Scan(
+		&c.ID, &c.Usuario.ID, &c.Usuario.Nome, &c.Usuario.Perfil, &c.Status, &c.ValorAbertura,
+		&obsAbertura, &c.AbertoEm, &fechadoEm,
+		&fechadoPor, &fechadoPorNome, &fechadoPorPerfil, &obsFechamento,
+	)
+	if err != nil {
+		return c, err
+	}
+
+	c.ObservacoesAbertura = obsAbertura.String
+	c.ObservacoesFechamento = obsFechamento.String
+	if fechadoEm.Valid {
+		c.FechadoEm = &fechadoEm.Time
+	}
+	if fechadoPor.Valid {
+		c.FechadoPor = &models.UsuarioBasico{ID: int(fechadoPor.Int64), Nome: fechadoPorNome.String, Perfil: fechadoPorPerfil.String}
+	}
+	return c, nil
+}
+
+// calcularResumoCaixa calcula o esperado por forma de pagamento a partir dos pedidos
+// finalizados no caixa, dos vales-gás vendidos, dos pagamentos de fiado recebidos e das
+// movimentações manuais
+func calcularResumoCaixa(q consultor, caixaID int, caixa models.Caixa) (models.ResumoCaixa, error) {
+	resumo := models.ResumoCaixa{}
+	vendas := map[models.FormaPagamento]*models.ConferenciaFormaPagamento{}
+	for _, forma := range models.FormasPagamentoCaixa {
+		resumo.Formas = append(resumo.Formas, models.ConferenciaFormaPagamento{FormaPagamento: forma})
+	}
+	for i := range resumo.Formas {
+		vendas[resumo.Formas[i].FormaPagamento] = &resumo.Formas[i]
+	}
+
+	rows, err := q.Query(`
+		SELECT forma_pagamento, COUNT(*), COALESCE(SUM(valor_total), 0)
+		FROM pedidos
+		WHERE caixa_id = $1 AND status = $2
+		GROUP BY forma_pagamento
+	`, caixaID, models.StatusFinalizado)
+	if err != nil {
+		return resumo, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var forma models.FormaPagamento
+		var quantidade int
+		var valor float64
+		if err := rows.Scan(&forma, &quantidade, &valor); err != nil {
+			return resumo, err
+		}
+		resumo.TotalPedidos += quantidade
+		resumo.TotalVendas = arredondarCentavos(resumo.TotalVendas + valor)
+		if conf, ok := vendas[forma]; ok {
+			conf.QuantidadePedidos = quantidade
+			conf.ValorVendas = arredondarCentavos(valor)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return resumo, err
+	}
+
+	// Recebimentos fora dos pedidos: vales-gás vendidos e pagamentos de vendas fiadas
+	rows, err = q.Query(`
+		SELECT forma_pagamento, COALESCE(SUM(valor_total), 0), 0
+		FROM vendas_antecipadas
+		WHERE caixa_id = $1
+		GROUP BY forma_pagamento
+		UNION ALL
+		SELECT forma_pagamento, 0, COALESCE(SUM(valor), 0)
+		FROM pagamentos_fiado
+		WHERE caixa_id = $1
+		GROUP BY forma_pagamento
+	`, caixaID)
+	if err != nil {
+		return resumo, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var forma models.FormaPagamento
+		var antecipadas, fiado float64
+		if err := rows.Scan(&forma, &antecipadas, &fiado); err != nil {
+			return resumo, err
+		}
+		resumo.TotalVendasAntecipadas = arredondarCentavos(resumo.TotalVendasAntecipadas + antecipadas)
+		resumo.TotalRecebimentosFiado = arredondarCentavos(resumo.TotalRecebimentosFiado + fiado)
+		if conf, ok := vendas[forma]; ok {
+			conf.ValorVendasAntecipadas = arredondarCentavos(conf.ValorVendasAntecipadas + antecipadas)
+			conf.ValorRecebimentosFiado = arredondarCentavos(conf.ValorRecebimentosFiado + fiado)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return resumo, err
+	}
+
+	err = q.QueryRow(`
+		SELECT
+			COALESCE(SUM(valor) FILTER (WHERE tipo = $2), 0),
+			COALESCE(SUM(valor) FILTER (WHERE tipo = $3), 0)
+		FROM movimentacoes_caixa
+		WHERE caixa_id = $1
+	`, caixaID, models.MovimentacaoSangria, models.MovimentacaoSuprimento).Scan(&resumo.TotalSangrias, &resumo.TotalSuprimentos)
+	if err != nil {
+		return resumo, err
+	}
+
+	for i := range resumo.Formas {
+		conf := &resumo.Formas[i]
+		conf.ValorEsperado = arredondarCentavos(conf.ValorVendas + conf.ValorVendasAntecipadas + conf.ValorRecebimentosFiado)
+		if conf.FormaPagamento == models.PagamentoDinheiro {
+			conf.ValorEsperado = arredondarCentavos(caixa.ValorAbertura + conf.ValorEsperado + resumo.TotalSuprimentos - resumo.TotalSangrias)
+		}
+	}
+	return resumo, nil
+}
+
+// valorEsperado retorna o valor esperado de uma forma de pagamento na conferência
+func valorEsperado(resumo models.ResumoCaixa, forma models.FormaPagamento) float64 {
+	for _, conf := range resumo.Formas {
+		if conf.FormaPagamento == forma {
+			return conf.ValorEsperado
+		}
+	}
+	return 0
+}
+
+// buscarFechamentoCaixa lê a conferência gravada no fechamento
+func buscarFechamentoCaixa(db *sql.DB, caixa models.Caixa) (models.ResumoCaixa, error) {
+	resumo := models.ResumoCaixa{}
+
+	err := db.QueryRow(`
+		SELECT
+			COALESCE(SUM(valor) FILTER (WHERE tipo = $2), 0),
+			COALESCE(SUM(valor) FILTER (WHERE tipo = $3), 0)
+		FROM movimentacoes_caixa
+		WHERE caixa_id = $1
+	`, caixa.ID, models.MovimentacaoSangria, models.MovimentacaoSuprimento).Scan(&resumo.TotalSangrias, &resumo.TotalSuprimentos)
+	if err != nil {
+		return resumo, err
+	}
+
+	rows, err := db.Query(`
+		SELECT forma_pagamento, quantidade_pedidos, valor_vendas, valor_vendas_antecipadas,
+		       valor_recebimentos_fiado, valor_esperado, valor_declarado
+		FROM fechamentos_caixa
+		WHERE caixa_id = $1
+		ORDER BY id
+	`, caixa.ID)
+	if err != nil {
+		return resumo, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var conf models.ConferenciaFormaPagamento
+		var declarado sql.NullFloat64
+		err := rows.Scan(
+			&conf.FormaPagamento, &conf.QuantidadePedidos, &conf.ValorVendas, &conf.ValorVendasAntecipadas,
+			&conf.ValorRecebimentosFiado, &conf.ValorEsperado, &declarado,
+		)
+		if err != nil {
+			return resumo, err
+		}
+		if declarado.Valid {
+			valor := declarado.Float64
+			diferenca := arredondarCentavos(valor - conf.ValorEsperado)
+			conf.ValorDeclarado = &valor
+			conf.Diferenca = &diferenca
+			resumo.DiferencaTotal = arredondarCentavos(resumo.DiferencaTotal + diferenca)
+		}
+		resumo.TotalPedidos += conf.QuantidadePedidos
+		resumo.TotalVendas = arredondarCentavos(resumo.TotalVendas + conf.ValorVendas)
+		resumo.TotalVendasAntecipadas = arredondarCentavos(resumo.TotalVendasAntecipadas + conf.ValorVendasAntecipadas)
+		resumo.TotalRecebimentosFiado = arredondarCentavos(resumo.TotalRecebimentosFiado + conf.ValorRecebimentosFiado)
+		resumo.Formas = append(resumo.Formas, conf)
+	}
+	return resumo, rows.Err()
+}
@@ -0,0 +1,431 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/lib/pq"
+	"github.com/tassyosilva/GestGAS/internal/middleware"
+	"github.com/tassyosilva/GestGAS/internal/models"
+	"github.com/tassyosilva/GestGAS/internal/permissao"
+	"github.com/tassyosilva/GestGAS/internal/telefone"
+)
+
+// similaridadeMinimaNome é a semelhança mínima (0 a 1) entre nomes no mesmo endereço para considerá-los a mesma pessoa
+const similaridadeMinimaNome = 0.85
+
+// Motivos pelos quais clientes são agrupados como duplicados
+const (
+	motivoTelefone     = "telefone"
+	motivoCPF          = "cpf"
+	motivoNomeEndereco = "nome_endereco"
+)
+
+// palavrasIgnoradasNome são preposições desconsideradas na comparação de nomes
+var palavrasIgnoradasNome = map[string]bool{"da": true, "de": true, "do": true, "das": true, "dos": true, "e": true}
+
+// abreviacoesEndereco expande as abreviações mais comuns de logradouro
+var abreviacoesEndereco = map[string]string{"r": "rua", "av": "avenida", "tv": "travessa", "al": "alameda", "est": "estrada", "rod": "rodovia"}
+
+// semAcentosCompleto remove a acentuação de nomes e endereços na busca de duplicados
+var semAcentosCompleto = strings.NewReplacer(
+	"á", "a", "à", "a", "ã", "a", "â", "a", "ä", "a", "é", "e", "ê", "e", "è", "e", "ë", "e",
+	"í", "i", "ì", "i", "î", "i", "ï", "i", "ó", "o", "ò", "o", "õ", "o", "ô", "o", "ö", "o",
+	"ú", "u", "ù", "u", "û", "u", "ü", "u", "ç", "c", "ñ", "n",
+)
+
+// ListarClientesDuplicadosHandler retorna grupos de clientes provavelmente duplicados:
+// mesmo telefone em E.164, mesmo CPF ou nomes semelhantes no mesmo endereço
+func ListarClientesDuplicadosHandler(db *sql.DB) http.HandlerFunc {
+	return middleware.RequerPermissao(permissao.ClienteMesclar)(func(w http.ResponseWriter, r *http.Request) {
+		// Verificar se o usuário está autenticado
+		_, ok := middleware.ObterUsuarioID(r)
+		if !ok {
+			http.Error(w, "Usuário não autenticado", http.StatusUnauthorized)
+			return
+		}
+
+		// Configurar cabeçalhos
+		w.Header().Set("Content-Type", "application/json")
+
+		// Verificar método
+		if r.Method != http.MethodGet {
+			http.Error(w, "Método não permitido", http.StatusMethodNotAllowed)
+			return
+		}
+
+		rows, err := db.Query(`
+			SELECT c.id, c.nome, c.telefone, COALESCE(c.cpf, ''), COALESCE(c.endereco, ''),
+				COALESCE(c.bairro, ''), c.criado_em,
+				(SELECT COUNT(*) FROM pedidos p WHERE p.cliente_id = c.id)
+			FROM clientes c
+			ORDER BY c.id
+		`)
+		if err != nil {
+			http.Error(w, "Erro ao buscar clientes: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		var clientes []models.ClienteDuplicado
+		for rows.Next() {
+			var c models.ClienteDuplicado
+			if err := rows.Scan(&c.ID, &c.Nome, &c.Telefone, &c.CPF, &c.Endereco, &c.Bairro, &c.CriadoEm, &c.TotalPedidos); err != nil {
+				http.Error(w, "Erro ao processar clientes: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+			clientes = append(clientes, c)
+		}
+		if err := rows.Err(); err != nil {
+			http.Error(w, "Erro ao processar clientes: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		json.NewEncoder(w).Encode(agruparDuplicados(clientes))
+	})
+}
+
+// agruparDuplicados liga os clientes que compartilham telefone, CPF ou nome e endereço
+// e retorna os grupos com mais de um cliente
+func agruparDuplicados(clientes []models.ClienteDuplicado) []models.GrupoClientesDuplicados {
+	// União de conjuntos sobre os índices de clientes
+	pai := make([]int, len(clientes))
+	for i := range pai {
+		pai[i] = i
+	}
+	var raiz func(i int) int
+	raiz = func(i int) int {
+		if pai[i] != i {
+			pai[i] = raiz(pai[i])
+		}
+		return pai[i]
+	}
+	motivos := map[[2]int]string{}
+	unir := func(a, b int, motivo string) {
+		ra, rb := raiz(a), raiz(b)
+		if ra != rb {
+			pai[rb] = ra
+		}
+		motivos[[2]int{a, b}] = motivo
+	}
+
+	porTelefone := map[string]int{}
+	porCPF := map[string]int{}
+	porEndereco := map[string][]int{}
+	for i, c := range clientes {
+		numero, ok := telefone.NormalizarE164(c.Telefone)
+		if !ok {
+			numero = apenasDigitos(c.Telefone)
+		}
+		if numero != "" {
+			if j, existe := porTelefone[numero]; existe {
+				unir(j, i, motivoTelefone)
+			} else {
+				porTelefone[numero] = i
+			}
+		}
+
+		if cpf := apenasDigitos(c.CPF); cpf != "" {
+			if j, existe := porCPF[cpf]; existe {
+				unir(j, i, motivoCPF)
+			} else {
+				porCPF[cpf] = i
+			}
+		}
+
+		if strings.TrimSpace(c.Endereco) != "" {
+			endereco := normalizarEndereco(c.Endereco + " " + c.Bairro)
+			porEndereco[endereco] = append(porEndereco[endereco], i)
+		}
+	}
+
+	// Nomes semelhantes no mesmo endereço
+	for _, indices := range porEndereco {
+		for x := 0; x < len(indices); x++ {
+			for y := x + 1; y < len(indices); y++ {
+				a, b := indices[x], indices[y]
+				if nomesSemelhantes(clientes[a].Nome, clientes[b].Nome) {
+					unir(a, b, motivoNomeEndereco)
+				}
+			}
+		}
+	}
+
+	membros := map[int][]int{}
+	for i := range clientes {
+		r := raiz(i)
+		membros[r] = append(membros[r], i)
+	}
+	motivosGrupo := map[int]map[string]bool{}
+	for par, motivo := range motivos {
+		r := raiz(par[0])
+		if motivosGrupo[r] == nil {
+			motivosGrupo[r] = map[string]bool{}
+		}
+		motivosGrupo[r][motivo] = true
+	}
+
+	grupos := []models.GrupoClientesDuplicados{}
+	for r, indices := range membros {
+		if len(indices) < 2 {
+			continue
+		}
+		grupo := models.GrupoClientesDuplicados{Motivos: []string{}}
+		for _, motivo := range []string{motivoTelefone, motivoCPF, motivoNomeEndereco} {
+			if motivosGrupo[r][motivo] {
+				grupo.Motivos = append(grupo.Motivos, motivo)
+			}
+		}
+		sort.Ints(indices)
+		sobrevivente := clientes[indices[0]]
+		for _, i := range indices {
+			c := clientes[i]
+			grupo.Clientes = append(grupo.Clientes, c)
+			if c.TotalPedidos > sobrevivente.TotalPedidos {
+				sobrevivente = c
+			}
+		}
+		grupo.SobreviventeSugerido = sobrevivente.ID
+		grupos = append(grupos, grupo)
+	}
+
+	sort.Slice(grupos, func(i, j int) bool { return grupos[i].Clientes[0].ID < grupos[j].Clientes[0].ID })
+	return grupos
+}
+
+// palavrasNormalizadas converte o texto para minúsculas sem acentos e o divide em palavras, descartando pontuação
+func palavrasNormalizadas(texto string) []string {
+	texto = semAcentosCompleto.Replace(strings.ToLower(texto))
+	return strings.FieldsFunc(texto, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// normalizarEndereco padroniza o endereço para comparação (sem acentos, pontuação e abreviações de logradouro)
+func normalizarEndereco(endereco string) string {
+	palavras := palavrasNormalizadas(endereco)
+	for i, p := range palavras {
+		if expandida, ok := abreviacoesEndereco[p]; ok {
+			palavras[i] = expandida
+		}
+	}
+	return strings.Join(palavras, " ")
+}
+
+// nomesSemelhantes compara nomes ignorando acentos e preposições. São semelhantes quando um contém
+// todas as palavras do outro ("Maria Silva" e "Maria da Silva Santos") ou quando a distância de
+// edição é pequena ("Jose Carlos" e "Jose Calos")
+func nomesSemelhantes(a, b string) bool {
+	var pa, pb []string
+	for _, p := range palavrasNormalizadas(a) {
+		if !palavrasIgnoradasNome[p] {
+			pa = append(pa, p)
+		}
+	}
+	for _, p := range palavrasNormalizadas(b) {
+		if !palavrasIgnoradasNome[p] {
+			pb = append(pb, p)
+		}
+	}
+	if len(pa) == 0 || len(pb) == 0 {
+		return false
+	}
+
+	if len(pa) > len(pb) {
+		pa, pb = pb, pa
+	}
+	contidas := map[string]bool{}
+	for _, p := range pb {
+		contidas[p] = true
+	}
+	todas := len(pa) >= 2
+	for _, p := range pa {
+		if !contidas[p] {
+			todas = false
+			break
+		}
+	}
+	if todas {
+		return true
+	}
+
+	na, nb := []rune(strings.Join(pa, " ")), []rune(strings.Join(pb, " "))
+	maior := len(na)
+	if len(nb) > maior {
+		maior = len(nb)
+	}
+	return 1-float64(distanciaEdicao(na, nb))/float64(maior) >= similaridadeMinimaNome
+}
+
+// distanciaEdicao calcula a distância de Levenshtein entre dois textos
+func distanciaEdicao(a, b []rune) int {
+	anterior := make([]int, len(b)+1)
+	atual := make([]int, len(b)+1)
+	for j := range anterior {
+		anterior[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		atual[0] = i
+		for j := 1; j <= len(b); j++ {
+			custo := 1
+			if a[i-1] == b[j-1] {
+				custo = 0
+			}
+			atual[j] = min(anterior[j]+1, atual[j-1]+1, anterior[j-1]+custo)
+		}
+		anterior, atual = atual, anterior
+	}
+	return anterior[len(b)]
+}
+
+// MesclarClientesHandler incorpora os clientes duplicados ao cliente da URL: pedidos, vendas fiadas e
+// vales-gás passam para o cliente sobrevivente, os duplicados são apagados e cada mesclagem é auditada
+func MesclarClientesHandler(db *sql.DB) http.HandlerFunc {
+	return middleware.RequerPermissao(permissao.ClienteMesclar)(func(w http.ResponseWriter, r *http.Request) {
+		// Verificar se o usuário está autenticado
+		userID, ok := middleware.ObterUsuarioID(r)
+		if !ok {
+			http.Error(w, "Usuário não autenticado", http.StatusUnauthorized)
+			return
+		}
+
+		// Configurar cabeçalhos
+		w.Header().Set("Content-Type", "application/json")
+
+		// Verificar método
+		if r.Method != http.MethodPost {
+			http.Error(w, "Método não permitido", http.StatusMethodNotAllowed)
+			return
+		}
+
+		// Extrair ID do cliente sobrevivente da URL (/api/clientes/{id}/mesclar)
+		parts := strings.Split(r.URL.Path, "/")
+		if len(parts) < 5 {
+			http.Error(w, "ID do cliente não fornecido", http.StatusBadRequest)
+			return
+		}
+		clienteID, err := strconv.Atoi(parts[3])
+		if err != nil {
+			http.Error(w, "ID do cliente inválido", http.StatusBadRequest)
+			return
+		}
+
+		// Decodificar requisição
+		var req models.MesclarClientesRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Erro ao processar requisição: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if len(req.Duplicados) == 0 {
+			http.Error(w, "Informe os clientes duplicados", http.StatusBadRequest)
+			return
+		}
+		vistos := map[int]bool{}
+		for _, id := range req.Duplicados {
+			if id == clienteID {
+				http.Error(w, "O cliente sobrevivente não pode estar entre os duplicados", http.StatusBadRequest)
+				return
+			}
+			if vistos[id] {
+				http.Error(w, "Cliente duplicado informado mais de uma vez", http.StatusBadRequest)
+				return
+			}
+			vistos[id] = true
+		}
+
+		// Iniciar transação
+		tx, err := db.Begin()
+		if err != nil {
+			http.Error(w, "Erro ao iniciar transação: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback() // sem efeito após o commit
+
+		// Bloquear o sobrevivente e os duplicados
+		todos := append([]int{clienteID}, req.Duplicados...)
+		var encontrados int
+		err = tx.QueryRow(`
+			SELECT COUNT(*) FROM (SELECT id FROM clientes WHERE id = ANY($1) FOR UPDATE) c
+		`, pq.Array(todos)).Scan(&encontrados)
+		if err != nil {
+			http.Error(w, "Erro ao buscar clientes: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if encontrados != len(todos) {
+			http.Error(w, "Cliente não encontrado", http.StatusNotFound)
+			return
+		}
+
+		resp := models.MesclarClientesResponse{ClienteID: clienteID, Mesclagens: []models.MesclagemCliente{}}
+		for _, duplicadoID := range req.Duplicados {
+			mesclagem, err := mesclarCliente(tx, clienteID, duplicadoID, userID)
+			if err != nil {
+				http.Error(w, "Erro ao mesclar cliente "+strconv.Itoa(duplicadoID)+": "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+			resp.Mesclagens = append(resp.Mesclagens, mesclagem)
+		}
+
+		if err := tx.Commit(); err != nil {
+			http.Error(w, "Erro ao finalizar transação: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		json.NewEncoder(w).Encode(resp)
+	})
+}
+
+// mesclarCliente transfere os registros do duplicado para o cliente sobrevivente, grava a auditoria
+// com a cópia do cadastro e apaga o duplicado
+func mesclarCliente(tx *sql.Tx, clienteID, duplicadoID, usuarioID int) (models.MesclagemCliente, error) {
+	m := models.MesclagemCliente{ClienteID: clienteID, ClienteRemovidoID: duplicadoID, UsuarioID: usuarioID}
+
+	transferir := func(tabela string, destino *int) error {
+		res, err := tx.Exec("UPDATE "+tabela+" SET cliente_id = $1 WHERE cliente_id = $2", clienteID, duplicadoID)
+		if err != nil {
+			return err
+		}
+		n, err := res.RowsAffected()
+		*destino = int(n)
+		return err
+	}
+	if err := transferir("pedidos", &m.PedidosMovidos); err != nil {
+		return m, err
+	}
+	if err := transferir("vendas_fiadas", &m.FiadosMovidos); err != nil {
+		return m, err
+	}
+	if err := transferir("vendas_antecipadas", &m.ValesMovidos); err != nil {
+		return m, err
+	}
+
+	// Mesclagens anteriores e chamadas do duplicado passam a apontar para o sobrevivente
+	if _, err := tx.Exec("UPDATE clientes_mesclagens SET cliente_id = $1 WHERE cliente_id = $2", clienteID, duplicadoID); err != nil {
+		return m, err
+	}
+	if _, err := tx.Exec("UPDATE chamadas SET cliente_id = $1 WHERE cliente_id = $2", clienteID, duplicadoID); err != nil {
+		return m, err
+	}
+
+	err := tx.QueryRow(`
+		INSERT INTO clientes_mesclagens (
+			cliente_id, cliente_removido_id, dados_removido,
+			pedidos_movidos, fiados_movidos, vales_movidos, usuario_id, criado_em
+		)
+		SELECT $1, c.id, to_jsonb(c), $3, $4, $5, $6, NOW()
+		FROM clientes c
+		WHERE c.id = $2
+		RETURNING id, criado_em
+	`, clienteID, duplicadoID, m.PedidosMovidos, m.FiadosMovidos, m.ValesMovidos, usuarioID).Scan(&m.ID, &m.CriadoEm)
+	if err != nil {
+		return m, err
+	}
+
+	_, err = tx.Exec("DELETE FROM clientes WHERE id = $1", duplicadoID)
+	return m, err
+}
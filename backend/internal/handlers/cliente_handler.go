@@ -5,12 +5,15 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/tassyosilva/GestGAS/internal/middleware"
 	"github.com/tassyosilva/GestGAS/internal/models"
+	"github.com/tassyosilva/GestGAS/internal/permissao"
+	"github.com/tassyosilva/GestGAS/internal/telefone"
 )
 
 // ListarClientesHandler retorna a lista de clientes com paginação e filtros
@@ -36,9 +39,6 @@ func ListarClientesHandler(db *sql.DB) http.HandlerFunc {
 		query := r.URL.Query()
 		page, _ := strconv.Atoi(query.Get("page"))
 		limit, _ := strconv.Atoi(query.Get("limit"))
-		nome := query.Get("nome")
-		telefone := query.Get("telefone")
-		canalOrigem := query.Get("canal_origem")
 
 		if page < 1 {
 			page = 1
@@ -57,22 +57,8 @@ func ListarClientesHandler(db *sql.DB) http.HandlerFunc {
 			FROM clientes
 			WHERE 1=1
 		`
-		var params []interface{}
-		var whereConditions []string
-
-		// Adicionar filtros se fornecidos
-		if nome != "" {
-			whereConditions = append(whereConditions, fmt.Sprintf("LOWER(nome) LIKE LOWER($%d)", len(params)+1))
-			params = append(params, "%"+nome+"%")
-		}
-		if telefone != "" {
-			whereConditions = append(whereConditions, fmt.Sprintf("telefone LIKE $%d", len(params)+1))
-			params = append(params, "%"+telefone+"%")
-		}
-		if canalOrigem != "" {
-			whereConditions = append(whereConditions, fmt.Sprintf("canal_origem = $%d", len(params)+1))
-			params = append(params, canalOrigem)
-		}
+		// Filtros (os mesmos usados na exportação de clientes)
+		whereConditions, params := filtrosClientes(query)
 
 		// Adicionar condições WHERE
 		if len(whereConditions) > 0 {
@@ -182,6 +168,28 @@ func ListarClientesHandler(db *sql.DB) http.HandlerFunc {
 	}
 }
 
+// filtrosClientes monta as condições WHERE a partir dos parâmetros nome, telefone e canal_origem
+func filtrosClientes(query url.Values) ([]string, []interface{}) {
+	var params []interface{}
+	var whereConditions []string
+
+	// Adicionar filtros se fornecidos
+	if nome := query.Get("nome"); nome != "" {
+		whereConditions = append(whereConditions, fmt.Sprintf("LOWER(nome) LIKE LOWER($%d)", len(params)+1))
+		params = append(params, "%"+nome+"%")
+	}
+	if telefone := query.Get("telefone"); telefone != "" {
+		whereConditions = append(whereConditions, fmt.Sprintf("telefone LIKE $%d", len(params)+1))
+		params = append(params, "%"+telefone+"%")
+	}
+	if canalOrigem := query.Get("canal_origem"); canalOrigem != "" {
+		whereConditions = append(whereConditions, fmt.Sprintf("canal_origem = $%d", len(params)+1))
+		params = append(params, canalOrigem)
+	}
+
+	return whereConditions, params
+}
+
 // ObterClienteHandler retorna detalhes de um cliente específico
 func ObterClienteHandler(db *sql.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -223,13 +231,15 @@ func ObterClienteHandler(db *sql.DB) http.HandlerFunc {
 			SELECT 
 				id, nome, telefone, cpf, email, 
 				endereco, complemento, bairro, cidade, estado, 
-				cep, observacoes, canal_origem, criado_em, atualizado_em
+				cep, observacoes, canal_origem, criado_em, atualizado_em,
+				latitude, longitude
 			FROM clientes
 			WHERE id = $1
 		`, clienteID).Scan(
 			&response.ID, &response.Nome, &response.Telefone, &cpf, &email,
 			&endereco, &complemento, &bairro, &cidade, &estado,
 			&cep, &observacoes, &canalOrigem, &criadoEm, &atualizadoEm,
+			&response.Latitude, &response.Longitude,
 		)
 		if err != nil {
 			if err == sql.ErrNoRows {
@@ -282,6 +292,13 @@ func ObterClienteHandler(db *sql.DB) http.HandlerFunc {
 			return
 		}
 
+		// Calcular saldo devedor de vendas fiadas
+		response.SaldoFiado, response.SaldoFiadoVencido, err = buscarSaldoFiadoCliente(db, clienteID)
+		if err != nil {
+			http.Error(w, "Erro ao calcular saldo fiado do cliente: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
 		// Buscar últimos pedidos do cliente 
 		rows, err := db.Query(`
 			SELECT id, status, forma_pagamento, valor_total, criado_em
@@ -318,7 +335,7 @@ func ObterClienteHandler(db *sql.DB) http.HandlerFunc {
 
 // CriarClienteHandler cria um novo cliente
 func CriarClienteHandler(db *sql.DB) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
+	return middleware.RequerPermissao(permissao.ClienteCriar)(func(w http.ResponseWriter, r *http.Request) {
 		// Verificar se o usuário está autenticado
 		_, ok := middleware.ObterUsuarioID(r)
 		if !ok {
@@ -326,13 +343,6 @@ func CriarClienteHandler(db *sql.DB) http.HandlerFunc {
 			return
 		}
 
-		// Verificar permissões (apenas atendentes ou acima podem criar clientes)
-		perfil, perfilOk := middleware.ObterPerfilUsuario(r)
-		if !perfilOk || !middleware.VerificarPerfil(perfil, "atendente") {
-			http.Error(w, "Sem permissão para criar clientes", http.StatusForbidden)
-			return
-		}
-
 		// Configurar cabeçalhos
 		w.Header().Set("Content-Type", "application/json")
 
@@ -358,6 +368,15 @@ func CriarClienteHandler(db *sql.DB) http.HandlerFunc {
 			http.Error(w, "Telefone é obrigatório", http.StatusBadRequest)
 			return
 		}
+		telefoneE164, telefoneValido := telefone.NormalizarE164(req.Telefone)
+		if !telefoneValido {
+			http.Error(w, "Telefone inválido (informe DDD e número)", http.StatusBadRequest)
+			return
+		}
+		if msg := validarCoordenadas(req.Latitude, req.Longitude); msg != "" {
+			http.Error(w, msg, http.StatusBadRequest)
+			return
+		}
 
 		// Verificar se já existe cliente com mesmo CPF ou email (se fornecidos)
 		if req.CPF != "" {
@@ -392,17 +411,18 @@ func CriarClienteHandler(db *sql.DB) http.HandlerFunc {
 			INSERT INTO clientes (
 				nome, telefone, cpf, email,
 				endereco, complemento, bairro, cidade, estado,
-				cep, observacoes, canal_origem,
-				criado_em, atualizado_em
+				cep, observacoes, canal_origem, latitude, longitude,
+				telefone_e164, criado_em, atualizado_em
 			) VALUES (
 				$1, $2, NULLIF($3, ''), NULLIF($4, ''),
 				NULLIF($5, ''), NULLIF($6, ''), NULLIF($7, ''), NULLIF($8, ''), NULLIF($9, ''),
-				NULLIF($10, ''), NULLIF($11, ''), $12,
-				NOW(), NOW()
+				NULLIF($10, ''), NULLIF($11, ''), $12, $13, $14,
+				$15, NOW(), NOW()
 			) RETURNING id
 		`, req.Nome, req.Telefone, req.CPF, req.Email,
 		   req.Endereco, req.Complemento, req.Bairro, req.Cidade, req.Estado,
-		   req.CEP, req.Observacoes, req.CanalOrigem).Scan(&clienteID)
+		   req.CEP, req.Observacoes, req.CanalOrigem, req.Latitude, req.Longitude,
+		   telefoneE164).Scan(&clienteID)
 
 		if err != nil {
 			http.Error(w, "Erro ao criar cliente: "+err.Error(), http.StatusInternalServerError)
@@ -419,13 +439,15 @@ func CriarClienteHandler(db *sql.DB) http.HandlerFunc {
 			SELECT 
 				id, nome, telefone, cpf, email, 
 				endereco, complemento, bairro, cidade, estado, 
-				cep, observacoes, canal_origem, criado_em, atualizado_em
+				cep, observacoes, canal_origem, criado_em, atualizado_em,
+				latitude, longitude
 			FROM clientes
 			WHERE id = $1
 		`, clienteID).Scan(
 			&cliente.ID, &cliente.Nome, &cliente.Telefone, &cpf, &email,
 			&endereco, &complemento, &bairro, &cidade, &estado,
 			&cep, &observacoes, &canalOrigem, &criadoEm, &atualizadoEm,
+			&cliente.Latitude, &cliente.Longitude,
 		)
 		if err != nil {
 			http.Error(w, "Cliente criado, mas erro ao buscar detalhes: "+err.Error(), http.StatusInternalServerError)
@@ -470,12 +492,12 @@ func CriarClienteHandler(db *sql.DB) http.HandlerFunc {
 		// Retornar resposta
 		w.WriteHeader(http.StatusCreated)
 		json.NewEncoder(w).Encode(cliente)
-	}
+	})
 }
 
 // AtualizarClienteHandler atualiza um cliente existente
 func AtualizarClienteHandler(db *sql.DB) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
+	return middleware.RequerPermissao(permissao.ClienteEditar)(func(w http.ResponseWriter, r *http.Request) {
 		// Verificar se o usuário está autenticado
 		_, ok := middleware.ObterUsuarioID(r)
 		if !ok {
@@ -483,13 +505,6 @@ func AtualizarClienteHandler(db *sql.DB) http.HandlerFunc {
 			return
 		}
 
-		// Verificar permissões (apenas atendentes ou acima podem atualizar clientes)
-		perfil, perfilOk := middleware.ObterPerfilUsuario(r)
-		if !perfilOk || !middleware.VerificarPerfil(perfil, "atendente") {
-			http.Error(w, "Sem permissão para atualizar clientes", http.StatusForbidden)
-			return
-		}
-
 		// Configurar cabeçalhos
 		w.Header().Set("Content-Type", "application/json")
 
@@ -531,6 +546,10 @@ func AtualizarClienteHandler(db *sql.DB) http.HandlerFunc {
 				http.Error(w, "Erro ao processar requisição: "+err.Error(), http.StatusBadRequest)
 				return
 			}
+			if msg := validarCoordenadas(req.Latitude, req.Longitude); msg != "" {
+				http.Error(w, msg, http.StatusBadRequest)
+				return
+			}
 
 			// Atualizar endereço
 			_, err = db.Exec(`
@@ -541,9 +560,12 @@ func AtualizarClienteHandler(db *sql.DB) http.HandlerFunc {
 					cidade = NULLIF($4, ''),
 					estado = NULLIF($5, ''),
 					cep = NULLIF($6, ''),
+					latitude = $7,
+					longitude = $8,
 					atualizado_em = NOW()
-				WHERE id = $7
-			`, req.Endereco, req.Complemento, req.Bairro, req.Cidade, req.Estado, req.CEP, clienteID)
+				WHERE id = $9
+			`, req.Endereco, req.Complemento, req.Bairro, req.Cidade, req.Estado, req.CEP,
+				req.Latitude, req.Longitude, clienteID)
 
 			if err != nil {
 				http.Error(w, "Erro ao atualizar endereço: "+err.Error(), http.StatusInternalServerError)
@@ -574,6 +596,15 @@ func AtualizarClienteHandler(db *sql.DB) http.HandlerFunc {
 			http.Error(w, "Telefone é obrigatório", http.StatusBadRequest)
 			return
 		}
+		telefoneE164, telefoneValido := telefone.NormalizarE164(req.Telefone)
+		if !telefoneValido {
+			http.Error(w, "Telefone inválido (informe DDD e número)", http.StatusBadRequest)
+			return
+		}
+		if msg := validarCoordenadas(req.Latitude, req.Longitude); msg != "" {
+			http.Error(w, msg, http.StatusBadRequest)
+			return
+		}
 
 		// Verificar se CPF ou email já existem para outro cliente
 		if req.CPF != "" {
@@ -617,11 +648,15 @@ func AtualizarClienteHandler(db *sql.DB) http.HandlerFunc {
 				cep = NULLIF($10, ''),
 				observacoes = NULLIF($11, ''),
 				canal_origem = $12,
+				latitude = $13,
+				longitude = $14,
+				telefone_e164 = $15,
 				atualizado_em = NOW()
-			WHERE id = $13
+			WHERE id = $16
 		`, req.Nome, req.Telefone, req.CPF, req.Email,
 		   req.Endereco, req.Complemento, req.Bairro, req.Cidade, req.Estado,
-		   req.CEP, req.Observacoes, req.CanalOrigem, clienteID)
+		   req.CEP, req.Observacoes, req.CanalOrigem, req.Latitude, req.Longitude,
+		   telefoneE164, clienteID)
 
 		if err != nil {
 			http.Error(w, "Erro ao atualizar cliente: "+err.Error(), http.StatusInternalServerError)
@@ -638,13 +673,15 @@ func AtualizarClienteHandler(db *sql.DB) http.HandlerFunc {
 			SELECT 
 				id, nome, telefone, cpf, email, 
 				endereco, complemento, bairro, cidade, estado, 
-				cep, observacoes, canal_origem, criado_em, atualizado_em
+				cep, observacoes, canal_origem, criado_em, atualizado_em,
+				latitude, longitude
 			FROM clientes
 			WHERE id = $1
 		`, clienteID).Scan(
 			&response.ID, &response.Nome, &response.Telefone, &cpf, &email,
 			&endereco, &complemento, &bairro, &cidade, &estado,
 			&cep, &observacoes, &canalOrigem, &criadoEm, &atualizadoEm,
+			&response.Latitude, &response.Longitude,
 		)
 		if err != nil {
 			http.Error(w, "Cliente atualizado, mas erro ao buscar detalhes: "+err.Error(), http.StatusInternalServerError)
@@ -688,12 +725,12 @@ func AtualizarClienteHandler(db *sql.DB) http.HandlerFunc {
 
 		// Retornar resposta
 		json.NewEncoder(w).Encode(response)
-	}
+	})
 }
 
 // ExcluirClienteHandler exclui um cliente
 func ExcluirClienteHandler(db *sql.DB) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
+	return middleware.RequerPermissao(permissao.ClienteExcluir)(func(w http.ResponseWriter, r *http.Request) {
 		// Verificar se o usuário está autenticado
 		_, ok := middleware.ObterUsuarioID(r)
 		if !ok {
@@ -701,13 +738,6 @@ func ExcluirClienteHandler(db *sql.DB) http.HandlerFunc {
 			return
 		}
 
-		// Verificar permissões (apenas gerentes ou admin podem excluir clientes)
-		perfil, perfilOk := middleware.ObterPerfilUsuario(r)
-		if !perfilOk || !middleware.VerificarPerfil(perfil, "gerente") {
-			http.Error(w, "Sem permissão para excluir clientes", http.StatusForbidden)
-			return
-		}
-
 		// Configurar cabeçalhos
 		w.Header().Set("Content-Type", "application/json")
 
@@ -765,10 +795,10 @@ func ExcluirClienteHandler(db *sql.DB) http.HandlerFunc {
        json.NewEncoder(w).Encode(map[string]string{
            "mensagem": "Cliente excluído com sucesso",
        })
-   }
+   })
 }
 
-// BuscarClientePorTelefoneHandler busca um cliente pelo telefone
+// BuscarClientePorTelefoneHandler busca os clientes com o telefone informado (comparação exata em E.164)
 func BuscarClientePorTelefoneHandler(db *sql.DB) http.HandlerFunc {
    return func(w http.ResponseWriter, r *http.Request) {
        // Verificar se o usuário está autenticado
@@ -788,79 +818,116 @@ func BuscarClientePorTelefoneHandler(db *sql.DB) http.HandlerFunc {
        }
 
        // Obter telefone da query string
-       telefone := r.URL.Query().Get("telefone")
-       if telefone == "" {
+       numero := r.URL.Query().Get("telefone")
+       if numero == "" {
            http.Error(w, "Telefone é obrigatório", http.StatusBadRequest)
            return
        }
 
-       // Buscar cliente pelo telefone
-       var cliente models.Cliente
-       var cpf, email, endereco, complemento, bairro, cidade, estado, cep, observacoes sql.NullString
-       var canalOrigem sql.NullString
-       var criadoEm, atualizadoEm time.Time
+       // Normalizar para E.164: as variantes com e sem o nono dígito resultam no mesmo número
+       telefoneE164, telefoneValido := telefone.NormalizarE164(numero)
+       if !telefoneValido {
+           http.Error(w, "Telefone inválido (informe DDD e número)", http.StatusBadRequest)
+           return
+       }
 
-       err := db.QueryRow(`
+       // Buscar todos os clientes com o telefone (busca exata pelo índice de telefone_e164)
+       rows, err := db.Query(`
            SELECT 
                id, nome, telefone, cpf, email, 
                endereco, complemento, bairro, cidade, estado, 
-               cep, observacoes, canal_origem, criado_em, atualizado_em
+               cep, observacoes, canal_origem, criado_em, atualizado_em,
+               latitude, longitude
            FROM clientes
-           WHERE telefone LIKE $1
-           LIMIT 1
-       `, "%"+telefone+"%").Scan(
-           &cliente.ID, &cliente.Nome, &cliente.Telefone, &cpf, &email,
-           &endereco, &complemento, &bairro, &cidade, &estado,
-           &cep, &observacoes, &canalOrigem, &criadoEm, &atualizadoEm,
-       )
+           WHERE telefone_e164 = $1
+           ORDER BY atualizado_em DESC, id
+       `, telefoneE164)
        if err != nil {
-           if err == sql.ErrNoRows {
-               // Cliente não encontrado, retornar array vazio
-               w.WriteHeader(http.StatusOK)
-               json.NewEncoder(w).Encode([]interface{}{})
-               return
-           }
            http.Error(w, "Erro ao buscar cliente: "+err.Error(), http.StatusInternalServerError)
            return
        }
+       defer rows.Close()
+
+       // Cliente não encontrado retorna array vazio
+       clientes := []models.Cliente{}
+       for rows.Next() {
+           var cliente models.Cliente
+           var cpf, email, endereco, complemento, bairro, cidade, estado, cep, observacoes sql.NullString
+           var canalOrigem sql.NullString
+           var criadoEm, atualizadoEm time.Time
+
+           err := rows.Scan(
+               &cliente.ID, &cliente.Nome, &cliente.Telefone, &cpf, &email,
+               &endereco, &complemento, &bairro, &cidade, &estado,
+               &cep, &observacoes, &canalOrigem, &criadoEm, &atualizadoEm,
+               &cliente.Latitude, &cliente.Longitude,
+           )
+           if err != nil {
+               http.Error(w, "Erro ao processar cliente: "+err.Error(), http.StatusInternalServerError)
+               return
+           }
 
-       // Converter tipos nulos
-       if cpf.Valid {
-           cliente.CPF = cpf.String
-       }
-       if email.Valid {
-           cliente.Email = email.String
-       }
-       if endereco.Valid {
-           cliente.Endereco = endereco.String
-       }
-       if complemento.Valid {
-           cliente.Complemento = complemento.String
-       }
-       if bairro.Valid {
-           cliente.Bairro = bairro.String
-       }
-       if cidade.Valid {
-           cliente.Cidade = cidade.String
-       }
-       if estado.Valid {
-           cliente.Estado = estado.String
-       }
-       if cep.Valid {
-           cliente.CEP = cep.String
-       }
-       if observacoes.Valid {
-           cliente.Observacoes = observacoes.String
+           // Converter tipos nulos
+           if cpf.Valid {
+               cliente.CPF = cpf.String
+           }
+           if email.Valid {
+               cliente.Email = email.String
+           }
+           if endereco.Valid {
+               cliente.Endereco = endereco.String
+           }
+           if complemento.Valid {
+               cliente.Complemento = complemento.String
+           }
+           if bairro.Valid {
+               cliente.Bairro = bairro.String
+           }
+           if cidade.Valid {
+               cliente.Cidade = cidade.String
+           }
+           if estado.Valid {
+               cliente.Estado = estado.String
+           }
+           if cep.Valid {
+               cliente.CEP = cep.String
+           }
+           if observacoes.Valid {
+               cliente.Observacoes = observacoes.String
+           }
+           if canalOrigem.Valid {
+               cliente.CanalOrigem = models.CanalOrigem(canalOrigem.String)
+           }
+
+           cliente.CriadoEm = criadoEm
+           cliente.AtualizadoEm = atualizadoEm
+
+           clientes = append(clientes, cliente)
        }
-       if canalOrigem.Valid {
-           cliente.CanalOrigem = models.CanalOrigem(canalOrigem.String)
+       if err := rows.Err(); err != nil {
+           http.Error(w, "Erro ao buscar cliente: "+err.Error(), http.StatusInternalServerError)
+           return
        }
-       
-       cliente.CriadoEm = criadoEm
-       cliente.AtualizadoEm = atualizadoEm
 
-       // Retornar resposta como um array contendo o cliente
+       // Retornar resposta como um array com todos os clientes encontrados
        w.WriteHeader(http.StatusOK)
-       json.NewEncoder(w).Encode([]models.Cliente{cliente})
+       json.NewEncoder(w).Encode(clientes)
    }
-}
\ No newline at end of file
+}
+
+// validarCoordenadas exige latitude e longitude juntas e dentro dos limites válidos
+func validarCoordenadas(latitude, longitude *float64) string {
+	if latitude == nil && longitude == nil {
+		return ""
+	}
+	if latitude == nil || longitude == nil {
+		return "Latitude e longitude devem ser informadas juntas"
+	}
+	if *latitude < -90 || *latitude > 90 {
+		return "Latitude inválida"
+	}
+	if *longitude < -180 || *longitude > 180 {
+		return "Longitude inválida"
+	}
+	return ""
+}
@@ -0,0 +1,542 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/lib/pq"
+	"github.com/tassyosilva/GestGAS/internal/middleware"
+	"github.com/tassyosilva/GestGAS/internal/models"
+	"github.com/tassyosilva/GestGAS/internal/permissao"
+	"github.com/tassyosilva/GestGAS/internal/telefone"
+)
+
+// tamanhoMaximoImportacao limita o arquivo CSV aceito na importação de clientes (10 MB)
+const tamanhoMaximoImportacao = 10 << 20
+
+// bomUTF8 é a marca de ordem de bytes que o Excel grava no início de arquivos CSV em UTF-8
+const bomUTF8 = "\ufeff"
+
+// clientesPorLote é a quantidade de clientes gravados em cada INSERT da importação
+const clientesPorLote = 500
+
+// colunasImportacao associa os nomes de coluna aceitos no cabeçalho (já normalizados) aos campos do cliente
+var colunasImportacao = map[string]string{
+	"nome":         "nome",
+	"telefone":     "telefone",
+	"celular":      "telefone",
+	"fone":         "telefone",
+	"whatsapp":     "telefone",
+	"cpf":          "cpf",
+	"email":        "email",
+	"e-mail":       "email",
+	"endereco":     "endereco",
+	"complemento":  "complemento",
+	"bairro":       "bairro",
+	"cidade":       "cidade",
+	"municipio":    "cidade",
+	"estado":       "estado",
+	"uf":           "estado",
+	"cep":          "cep",
+	"observacoes":  "observacoes",
+	"obs":          "observacoes",
+	"canal_origem": "canal_origem",
+	"canal":        "canal_origem",
+	"latitude":     "latitude",
+	"longitude":    "longitude",
+}
+
+// tamanhosMaximosCliente são os limites das colunas de texto da tabela clientes
+var tamanhosMaximosCliente = []struct {
+	campo  string
+	limite int
+	valor  func(c *models.NovoClienteRequest) string
+}{
+	{"nome", 100, func(c *models.NovoClienteRequest) string { return c.Nome }},
+	{"email", 100, func(c *models.NovoClienteRequest) string { return c.Email }},
+	{"endereco", 255, func(c *models.NovoClienteRequest) string { return c.Endereco }},
+	{"complemento", 100, func(c *models.NovoClienteRequest) string { return c.Complemento }},
+	{"bairro", 100, func(c *models.NovoClienteRequest) string { return c.Bairro }},
+	{"cidade", 100, func(c *models.NovoClienteRequest) string { return c.Cidade }},
+}
+
+// semAcentos remove a acentuação dos nomes de coluna do cabeçalho
+var semAcentos = strings.NewReplacer(
+	"á", "a", "à", "a", "ã", "a", "â", "a", "é", "e", "ê", "e", "í", "i",
+	"ó", "o", "ô", "o", "õ", "o", "ú", "u", "ç", "c",
+)
+
+// linhaImportacao é um cliente lido do arquivo, com a linha de origem e os erros encontrados
+type linhaImportacao struct {
+	linha   int
+	cliente models.NovoClienteRequest
+	erros   []string
+}
+
+// ImportarClientesHandler importa clientes de um arquivo CSV.
+// O arquivo pode ser enviado no corpo da requisição ou no campo "arquivo" de um formulário multipart.
+// Com dry_run=true apenas valida e retorna o relatório; caso contrário grava as linhas válidas numa transação.
+func ImportarClientesHandler(db *sql.DB) http.HandlerFunc {
+	return middleware.RequerPermissao(permissao.ClienteImportar)(func(w http.ResponseWriter, r *http.Request) {
+		// Verificar se o usuário está autenticado
+		_, ok := middleware.ObterUsuarioID(r)
+		if !ok {
+			http.Error(w, "Usuário não autenticado", http.StatusUnauthorized)
+			return
+		}
+
+		// Configurar cabeçalhos
+		w.Header().Set("Content-Type", "application/json")
+
+		// Verificar método
+		if r.Method != http.MethodPost {
+			http.Error(w, "Método não permitido", http.StatusMethodNotAllowed)
+			return
+		}
+
+		simulacao := false
+		if dryRun := r.URL.Query().Get("dry_run"); dryRun != "" {
+			valor, err := strconv.ParseBool(dryRun)
+			if err != nil {
+				http.Error(w, "Parâmetro dry_run inválido (use true ou false)", http.StatusBadRequest)
+				return
+			}
+			simulacao = valor
+		}
+
+		arquivo, err := abrirArquivoImportacao(w, r)
+		if err != nil {
+			http.Error(w, "Erro ao ler arquivo: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer arquivo.Close()
+
+		linhas, err := lerClientesCSV(arquivo)
+		if err != nil {
+			http.Error(w, "Erro ao processar CSV: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// Duplicados em relação aos clientes já cadastrados
+		if err := marcarClientesExistentes(db, linhas); err != nil {
+			http.Error(w, "Erro ao verificar clientes existentes: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		resp := models.ImportacaoClientesResponse{
+			Simulacao:   simulacao,
+			TotalLinhas: len(linhas),
+			Erros:       []models.ErroImportacaoCliente{},
+		}
+		var validos []models.NovoClienteRequest
+		for _, l := range linhas {
+			if len(l.erros) > 0 {
+				resp.Erros = append(resp.Erros, models.ErroImportacaoCliente{
+					Linha:    l.linha,
+					Telefone: l.cliente.Telefone,
+					Erros:    l.erros,
+				})
+				continue
+			}
+			validos = append(validos, l.cliente)
+		}
+		resp.Validas = len(validos)
+		resp.Invalidas = len(resp.Erros)
+
+		if !simulacao && len(validos) > 0 {
+			if err := inserirClientesEmLotes(db, validos); err != nil {
+				http.Error(w, "Erro ao importar clientes: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+			resp.Importados = len(validos)
+		}
+
+		json.NewEncoder(w).Encode(resp)
+	})
+}
+
+// abrirArquivoImportacao retorna o CSV enviado no campo "arquivo" (multipart) ou no corpo da requisição
+func abrirArquivoImportacao(w http.ResponseWriter, r *http.Request) (io.ReadCloser, error) {
+	r.Body = http.MaxBytesReader(w, r.Body, tamanhoMaximoImportacao)
+
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		if err := r.ParseMultipartForm(tamanhoMaximoImportacao); err != nil {
+			return nil, err
+		}
+		arquivo, _, err := r.FormFile("arquivo")
+		if err != nil {
+			return nil, errors.New("campo 'arquivo' não enviado")
+		}
+		return arquivo, nil
+	}
+	return r.Body, nil
+}
+
+// lerClientesCSV lê o arquivo inteiro validando cada linha. O separador (';' ou ',') é detectado
+// pelo cabeçalho e um BOM UTF-8 no início do arquivo é ignorado.
+func lerClientesCSV(arquivo io.Reader) ([]linhaImportacao, error) {
+	leitor := bufio.NewReader(arquivo)
+	if inicio, err := leitor.Peek(len(bomUTF8)); err == nil && string(inicio) == bomUTF8 {
+		leitor.Discard(len(bomUTF8))
+	}
+
+	separador := ';'
+	primeiraLinha, _ := leitor.Peek(leitor.Size())
+	if fim := bytes.IndexByte(primeiraLinha, '\n'); fim >= 0 {
+		primeiraLinha = primeiraLinha[:fim]
+	}
+	if bytes.Count(primeiraLinha, []byte(",")) > bytes.Count(primeiraLinha, []byte(";")) {
+		separador = ','
+	}
+
+	cr := csv.NewReader(leitor)
+	cr.Comma = separador
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	cabecalho, err := cr.Read()
+	if err == io.EOF {
+		return nil, errors.New("arquivo vazio")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	colunas := map[string]int{}
+	for i, nome := range cabecalho {
+		chave := strings.ReplaceAll(semAcentos.Replace(strings.ToLower(strings.TrimSpace(nome))), " ", "_")
+		if campo, ok := colunasImportacao[chave]; ok {
+			if _, repetida := colunas[campo]; !repetida {
+				colunas[campo] = i
+			}
+		}
+	}
+	if _, ok := colunas["nome"]; !ok {
+		return nil, errors.New("coluna 'nome' não encontrada no cabeçalho")
+	}
+	if _, ok := colunas["telefone"]; !ok {
+		return nil, errors.New("coluna 'telefone' não encontrada no cabeçalho")
+	}
+
+	var linhas []linhaImportacao
+	telefones := map[string]int{}
+	cpfs := map[string]int{}
+	emails := map[string]int{}
+	for {
+		registro, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		linha, _ := cr.FieldPos(0)
+
+		campo := func(nome string) string {
+			if i, ok := colunas[nome]; ok && i < len(registro) {
+				return strings.TrimSpace(registro[i])
+			}
+			return ""
+		}
+
+		// Ignorar linhas em branco
+		vazia := true
+		for _, v := range registro {
+			if strings.TrimSpace(v) != "" {
+				vazia = false
+				break
+			}
+		}
+		if vazia {
+			continue
+		}
+
+		l := linhaImportacao{linha: linha}
+		l.cliente, l.erros = normalizarClienteImportado(campo)
+
+		// Duplicados dentro do próprio arquivo
+		if anterior, ok := telefones[l.cliente.Telefone]; ok && l.cliente.Telefone != "" {
+			l.erros = append(l.erros, fmt.Sprintf("telefone repetido no arquivo (linha %d)", anterior))
+		} else if l.cliente.Telefone != "" {
+			telefones[l.cliente.Telefone] = linha
+		}
+		if anterior, ok := cpfs[l.cliente.CPF]; ok && l.cliente.CPF != "" {
+			l.erros = append(l.erros, fmt.Sprintf("CPF repetido no arquivo (linha %d)", anterior))
+		} else if l.cliente.CPF != "" {
+			cpfs[l.cliente.CPF] = linha
+		}
+		if anterior, ok := emails[l.cliente.Email]; ok && l.cliente.Email != "" {
+			l.erros = append(l.erros, fmt.Sprintf("email repetido no arquivo (linha %d)", anterior))
+		} else if l.cliente.Email != "" {
+			emails[l.cliente.Email] = linha
+		}
+
+		linhas = append(linhas, l)
+	}
+
+	return linhas, nil
+}
+
+// normalizarClienteImportado monta o cliente a partir das colunas da linha, normalizando telefone,
+// CPF e CEP, e retorna os erros de validação encontrados
+func normalizarClienteImportado(campo func(string) string) (models.NovoClienteRequest, []string) {
+	var erros []string
+	c := models.NovoClienteRequest{
+		Nome:        campo("nome"),
+		Endereco:    campo("endereco"),
+		Complemento: campo("complemento"),
+		Bairro:      campo("bairro"),
+		Cidade:      campo("cidade"),
+		Estado:      strings.ToUpper(campo("estado")),
+		Observacoes: campo("observacoes"),
+		Email:       strings.ToLower(campo("email")),
+		CanalOrigem: models.CanalOrigem(strings.ToLower(campo("canal_origem"))),
+	}
+
+	if c.Nome == "" {
+		erros = append(erros, "nome é obrigatório")
+	}
+
+	numero := campo("telefone")
+	if numero == "" {
+		erros = append(erros, "telefone é obrigatório")
+	} else if e164, ok := telefone.NormalizarE164(numero); ok {
+		c.Telefone = telefone.Formatar(e164)
+	} else {
+		c.Telefone = numero
+		erros = append(erros, "telefone inválido: "+numero)
+	}
+
+	if cpf := campo("cpf"); cpf != "" {
+		if normalizado, ok := normalizarCPF(cpf); ok {
+			c.CPF = normalizado
+		} else {
+			erros = append(erros, "CPF inválido: "+cpf)
+		}
+	}
+
+	if cep := campo("cep"); cep != "" {
+		if normalizado, ok := normalizarCEP(cep); ok {
+			c.CEP = normalizado
+		} else {
+			erros = append(erros, "CEP inválido: "+cep)
+		}
+	}
+
+	if c.Estado != "" && (len(c.Estado) != 2 || strings.Trim(c.Estado, "ABCDEFGHIJKLMNOPQRSTUVWXYZ") != "") {
+		erros = append(erros, "estado inválido (use a sigla da UF): "+c.Estado)
+	}
+	if c.Email != "" && (!strings.Contains(c.Email, "@") || strings.ContainsAny(c.Email, " ;,")) {
+		erros = append(erros, "email inválido: "+c.Email)
+	}
+
+	switch c.CanalOrigem {
+	case "", models.CanalWhatsApp, models.CanalTelefone, models.CanalPresencial, models.CanalAplicativo:
+	default:
+		erros = append(erros, "canal_origem inválido: "+string(c.CanalOrigem))
+	}
+
+	for _, t := range tamanhosMaximosCliente {
+		if utf8.RuneCountInString(t.valor(&c)) > t.limite {
+			erros = append(erros, fmt.Sprintf("%s excede %d caracteres", t.campo, t.limite))
+		}
+	}
+
+	// Coordenadas aceitam vírgula ou ponto como separador decimal
+	var msgCoordenada string
+	c.Latitude, msgCoordenada = lerCoordenada(campo("latitude"), "latitude")
+	if msgCoordenada != "" {
+		erros = append(erros, msgCoordenada)
+	}
+	c.Longitude, msgCoordenada = lerCoordenada(campo("longitude"), "longitude")
+	if msgCoordenada != "" {
+		erros = append(erros, msgCoordenada)
+	}
+	if msg := validarCoordenadas(c.Latitude, c.Longitude); msg != "" {
+		erros = append(erros, msg)
+	}
+
+	return c, erros
+}
+
+// lerCoordenada converte o texto de uma coordenada; vazio significa não informada
+func lerCoordenada(valor, nome string) (*float64, string) {
+	if valor == "" {
+		return nil, ""
+	}
+	numero, err := strconv.ParseFloat(strings.Replace(valor, ",", ".", 1), 64)
+	if err != nil {
+		return nil, nome + " inválida: " + valor
+	}
+	return &numero, ""
+}
+
+// apenasDigitos remove tudo que não for dígito
+func apenasDigitos(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if r >= '0' && r <= '9' {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// normalizarCPF valida os dígitos verificadores e retorna o CPF no formato 000.000.000-00
+func normalizarCPF(cpf string) (string, bool) {
+	d := apenasDigitos(cpf)
+	if len(d) != 11 || strings.Count(d, d[:1]) == 11 {
+		return "", false
+	}
+	for _, tamanho := range []int{9, 10} {
+		soma := 0
+		for i := 0; i < tamanho; i++ {
+			soma += int(d[i]-'0') * (tamanho + 1 - i)
+		}
+		digito := soma * 10 % 11
+		if digito == 10 {
+			digito = 0
+		}
+		if digito != int(d[tamanho]-'0') {
+			return "", false
+		}
+	}
+	return d[:3] + "." + d[3:6] + "." + d[6:9] + "-" + d[9:], true
+}
+
+// normalizarCEP retorna o CEP no formato 00000-000
+func normalizarCEP(cep string) (string, bool) {
+	d := apenasDigitos(cep)
+	if len(d) != 8 {
+		return "", false
+	}
+	return d[:5] + "-" + d[5:], true
+}
+
+// marcarClientesExistentes acrescenta erro às linhas cujo telefone, CPF ou email já pertence a um cliente cadastrado.
+// Telefones são comparados pelo formato E.164; CPF ignora a formatação gravada.
+func marcarClientesExistentes(db *sql.DB, linhas []linhaImportacao) error {
+	var telefones, cpfs, emails []string
+	for _, l := range linhas {
+		if e164, ok := telefone.NormalizarE164(l.cliente.Telefone); ok {
+			telefones = append(telefones, e164)
+		}
+		if l.cliente.CPF != "" {
+			cpfs = append(cpfs, apenasDigitos(l.cliente.CPF))
+		}
+		if l.cliente.Email != "" {
+			emails = append(emails, l.cliente.Email)
+		}
+	}
+	if len(telefones) == 0 && len(cpfs) == 0 && len(emails) == 0 {
+		return nil
+	}
+
+	rows, err := db.Query(`
+		SELECT id, COALESCE(telefone_e164, ''),
+			COALESCE(regexp_replace(cpf, '\D', '', 'g'), ''), COALESCE(LOWER(email), '')
+		FROM clientes
+		WHERE telefone_e164 = ANY($1)
+			OR regexp_replace(cpf, '\D', '', 'g') = ANY($2)
+			OR LOWER(email) = ANY($3)
+	`, pq.Array(telefones), pq.Array(cpfs), pq.Array(emails))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	telefoneExistente := map[string]int{}
+	cpfExistente := map[string]int{}
+	emailExistente := map[string]int{}
+	for rows.Next() {
+		var id int
+		var e164, cpf, email string
+		if err := rows.Scan(&id, &e164, &cpf, &email); err != nil {
+			return err
+		}
+		if e164 != "" {
+			telefoneExistente[e164] = id
+		}
+		if cpf != "" {
+			cpfExistente[cpf] = id
+		}
+		if email != "" {
+			emailExistente[email] = id
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for i := range linhas {
+		c := &linhas[i].cliente
+		e164, _ := telefone.NormalizarE164(c.Telefone)
+		if id, ok := telefoneExistente[e164]; ok {
+			linhas[i].erros = append(linhas[i].erros, fmt.Sprintf("telefone já cadastrado (cliente %d)", id))
+		}
+		if id, ok := cpfExistente[apenasDigitos(c.CPF)]; ok && c.CPF != "" {
+			linhas[i].erros = append(linhas[i].erros, fmt.Sprintf("CPF já cadastrado (cliente %d)", id))
+		}
+		if id, ok := emailExistente[c.Email]; ok && c.Email != "" {
+			linhas[i].erros = append(linhas[i].erros, fmt.Sprintf("email já cadastrado (cliente %d)", id))
+		}
+	}
+	return nil
+}
+
+// inserirClientesEmLotes grava os clientes numa única transação, com um INSERT para cada lote
+func inserirClientesEmLotes(db *sql.DB, clientes []models.NovoClienteRequest) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() // sem efeito após o commit
+
+	const colunasPorCliente = 15
+	for inicio := 0; inicio < len(clientes); inicio += clientesPorLote {
+		fim := inicio + clientesPorLote
+		if fim > len(clientes) {
+			fim = len(clientes)
+		}
+
+		valores := make([]string, 0, fim-inicio)
+		params := make([]interface{}, 0, (fim-inicio)*colunasPorCliente)
+		for _, c := range clientes[inicio:fim] {
+			n := len(params)
+			valores = append(valores, fmt.Sprintf(
+				"($%d, $%d, NULLIF($%d, ''), NULLIF($%d, ''), NULLIF($%d, ''), NULLIF($%d, ''), NULLIF($%d, ''), "+
+					"NULLIF($%d, ''), NULLIF($%d, ''), NULLIF($%d, ''), NULLIF($%d, ''), NULLIF($%d, ''), $%d, $%d, NULLIF($%d, ''), NOW(), NOW())",
+				n+1, n+2, n+3, n+4, n+5, n+6, n+7, n+8, n+9, n+10, n+11, n+12, n+13, n+14, n+15,
+			))
+			e164, _ := telefone.NormalizarE164(c.Telefone)
+			params = append(params,
+				c.Nome, c.Telefone, c.CPF, c.Email,
+				c.Endereco, c.Complemento, c.Bairro, c.Cidade, c.Estado,
+				c.CEP, c.Observacoes, string(c.CanalOrigem), c.Latitude, c.Longitude, e164,
+			)
+		}
+
+		_, err := tx.Exec(`
+			INSERT INTO clientes (
+				nome, telefone, cpf, email,
+				endereco, complemento, bairro, cidade, estado,
+				cep, observacoes, canal_origem, latitude, longitude, telefone_e164,
+				criado_em, atualizado_em
+			) VALUES `+strings.Join(valores, ", "), params...)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,362 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/tassyosilva/GestGAS/internal/conciliacao"
+	"github.com/tassyosilva/GestGAS/internal/middleware"
+	"github.com/tassyosilva/GestGAS/internal/models"
+	"github.com/tassyosilva/GestGAS/internal/permissao"
+	"github.com/tassyosilva/GestGAS/internal/pix"
+)
+
+// consultaTransacaoBancaria seleciona as colunas lidas por escanearTransacaoBancaria
+const consultaTransacaoBancaria = `
+	SELECT id, importacao_id, identificador, data, valor, COALESCE(descricao, ''), COALESCE(txid, ''),
+	       pedido_id, conciliacao, conciliado_em, criado_em
+	FROM transacoes_bancarias`
+
+// ImportarExtratoHandler importa um extrato bancário em OFX ou CSV, enviado no campo "arquivo" de um
+// formulário multipart ou no corpo da requisição, e concilia automaticamente os créditos
+func ImportarExtratoHandler(db *sql.DB) http.HandlerFunc {
+	return middleware.RequerPermissao(permissao.ConciliacaoGerenciar)(func(w http.ResponseWriter, r *http.Request) {
+		// Verificar se o usuário está autenticado
+		userID, ok := middleware.ObterUsuarioID(r)
+		if !ok {
+			http.Error(w, "Usuário não autenticado", http.StatusUnauthorized)
+			return
+		}
+
+		// Configurar cabeçalhos
+		w.Header().Set("Content-Type", "application/json")
+
+		// Verificar método
+		if r.Method != http.MethodPost {
+			http.Error(w, "Método não permitido", http.StatusMethodNotAllowed)
+			return
+		}
+
+		arquivo, err := abrirArquivoImportacao(w, r)
+		if err != nil {
+			http.Error(w, "Erro ao ler arquivo: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer arquivo.Close()
+
+		conteudo, err := io.ReadAll(arquivo)
+		if err != nil {
+			http.Error(w, "Erro ao ler arquivo: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		nomeArquivo := r.URL.Query().Get("arquivo")
+		if r.MultipartForm != nil && len(r.MultipartForm.File["arquivo"]) > 0 {
+			nomeArquivo = r.MultipartForm.File["arquivo"][0].Filename
+		}
+
+		formato, transacoes, err := conciliacao.LerExtrato(conteudo)
+		if err != nil {
+			http.Error(w, "Erro ao processar extrato: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			http.Error(w, "Erro ao iniciar transação: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback() // sem efeito após o commit
+
+		resp, err := conciliacao.Importar(tx, nomeArquivo, formato, transacoes, userID)
+		if err != nil {
+			http.Error(w, "Erro ao importar extrato: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if err := tx.Commit(); err != nil {
+			http.Error(w, "Erro ao confirmar importação: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(resp)
+	})
+}
+
+// ListarTransacoesBancariasHandler lista os créditos importados. Com status=pendente, apenas os
+// ainda não vinculados a um pedido; com status=conciliado, apenas os vinculados.
+func ListarTransacoesBancariasHandler(db *sql.DB) http.HandlerFunc {
+	return middleware.RequerPermissao(permissao.ConciliacaoGerenciar)(func(w http.ResponseWriter, r *http.Request) {
+		// Verificar se o usuário está autenticado
+		_, ok := middleware.ObterUsuarioID(r)
+		if !ok {
+			http.Error(w, "Usuário não autenticado", http.StatusUnauthorized)
+			return
+		}
+
+		// Configurar cabeçalhos
+		w.Header().Set("Content-Type", "application/json")
+
+		// Verificar método
+		if r.Method != http.MethodGet {
+			http.Error(w, "Método não permitido", http.StatusMethodNotAllowed)
+			return
+		}
+
+		query := r.URL.Query()
+		var condicoes []string
+		var params []interface{}
+
+		switch models.StatusConciliacao(query.Get("status")) {
+		case "":
+		case models.ConciliacaoPendente:
+			condicoes = append(condicoes, "pedido_id IS NULL")
+		case models.ConciliacaoConciliado:
+			condicoes = append(condicoes, "pedido_id IS NOT NULL")
+		default:
+			http.Error(w, "Status inválido (use pendente ou conciliado)", http.StatusBadRequest)
+			return
+		}
+
+		if query.Get("data_inicio") != "" || query.Get("data_fim") != "" {
+			inicio, fim, msg := lerPeriodo(query.Get("data_inicio"), query.Get("data_fim"))
+			if msg != "" {
+				http.Error(w, msg, http.StatusBadRequest)
+				return
+			}
+			params = append(params, inicio.Format(formatoData), fim.Format(formatoData))
+			condicoes = append(condicoes, "data >= $1 AND data < $2")
+		}
+
+		limit, _ := strconv.Atoi(query.Get("limit"))
+		if limit < 1 || limit > 500 {
+			limit = 100
+		}
+
+		sqlQuery := consultaTransacaoBancaria
+		if len(condicoes) > 0 {
+			sqlQuery += " WHERE " + strings.Join(condicoes, " AND ")
+		}
+		params = append(params, limit)
+		sqlQuery += " ORDER BY data DESC, id DESC LIMIT $" + strconv.Itoa(len(params))
+
+		rows, err := db.Query(sqlQuery, params...)
+		if err != nil {
+			http.Error(w, "Erro ao buscar transações: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		transacoes := []models.TransacaoBancaria{}
+		for rows.Next() {
+			t, err := escanearTransacaoBancaria(rows)
+			if err != nil {
+				http.Error(w, "Erro ao processar transações: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+			transacoes = append(transacoes, t)
+		}
+		if err := rows.Err(); err != nil {
+			http.Error(w, "Erro ao processar transações: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		json.NewEncoder(w).Encode(transacoes)
+	})
+}
+
+// ListarPedidosNaoConciliadosHandler lista os pedidos entregues e pagos por Pix ou cartão que ainda
+// não têm crédito correspondente no extrato, para a conciliação manual
+func ListarPedidosNaoConciliadosHandler(db *sql.DB) http.HandlerFunc {
+	return middleware.RequerPermissao(permissao.ConciliacaoGerenciar)(func(w http.ResponseWriter, r *http.Request) {
+		// Verificar se o usuário está autenticado
+		_, ok := middleware.ObterUsuarioID(r)
+		if !ok {
+			http.Error(w, "Usuário não autenticado", http.StatusUnauthorized)
+			return
+		}
+
+		// Configurar cabeçalhos
+		w.Header().Set("Content-Type", "application/json")
+
+		// Verificar método
+		if r.Method != http.MethodGet {
+			http.Error(w, "Método não permitido", http.StatusMethodNotAllowed)
+			return
+		}
+
+		query := r.URL.Query()
+		condicoes := []string{conciliacao.StatusPedidoSQL + " = 'pendente'"}
+		var params []interface{}
+
+		if forma := query.Get("forma_pagamento"); forma != "" {
+			params = append(params, forma)
+			condicoes = append(condicoes, "p.forma_pagamento = $"+strconv.Itoa(len(params)))
+		}
+		if query.Get("data_inicio") != "" || query.Get("data_fim") != "" {
+			inicio, fim, msg := lerPeriodo(query.Get("data_inicio"), query.Get("data_fim"))
+			if msg != "" {
+				http.Error(w, msg, http.StatusBadRequest)
+				return
+			}
+			params = append(params, inicio, fim)
+			condicoes = append(condicoes, "COALESCE(p.data_entrega, p.criado_em) >= $"+strconv.Itoa(len(params)-1)+
+				" AND COALESCE(p.data_entrega, p.criado_em) < $"+strconv.Itoa(len(params)))
+		}
+
+		limit, _ := strconv.Atoi(query.Get("limit"))
+		if limit < 1 || limit > 500 {
+			limit = 100
+		}
+		params = append(params, limit)
+
+		rows, err := db.Query(`
+			SELECT p.id, c.nome, p.forma_pagamento, p.valor_total, p.status, p.data_entrega, p.criado_em
+			FROM pedidos p
+			JOIN clientes c ON c.id = p.cliente_id
+			WHERE `+strings.Join(condicoes, " AND ")+`
+			ORDER BY COALESCE(p.data_entrega, p.criado_em) DESC, p.id DESC
+			LIMIT $`+strconv.Itoa(len(params)), params...)
+		if err != nil {
+			http.Error(w, "Erro ao buscar pedidos: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		pedidos := []models.PedidoNaoConciliado{}
+		for rows.Next() {
+			var p models.PedidoNaoConciliado
+			var dataEntrega sql.NullTime
+			if err := rows.Scan(&p.ID, &p.ClienteNome, &p.FormaPagamento, &p.ValorTotal, &p.Status, &dataEntrega, &p.CriadoEm); err != nil {
+				http.Error(w, "Erro ao processar pedidos: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+			if dataEntrega.Valid {
+				p.DataEntrega = &dataEntrega.Time
+			}
+			if p.FormaPagamento == models.PagamentoPix {
+				p.TxID = pix.TxIDPedido(p.ID)
+			}
+			pedidos = append(pedidos, p)
+		}
+		if err := rows.Err(); err != nil {
+			http.Error(w, "Erro ao processar pedidos: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		json.NewEncoder(w).Encode(pedidos)
+	})
+}
+
+// ConciliarTransacaoHandler vincula manualmente (POST) uma transação a um pedido ou desfaz (DELETE)
+// o vínculo existente, seja ele automático ou manual
+func ConciliarTransacaoHandler(db *sql.DB) http.HandlerFunc {
+	return middleware.RequerPermissao(permissao.ConciliacaoGerenciar)(func(w http.ResponseWriter, r *http.Request) {
+		// Verificar se o usuário está autenticado
+		userID, ok := middleware.ObterUsuarioID(r)
+		if !ok {
+			http.Error(w, "Usuário não autenticado", http.StatusUnauthorized)
+			return
+		}
+
+		// Configurar cabeçalhos
+		w.Header().Set("Content-Type", "application/json")
+
+		// Verificar método
+		if r.Method != http.MethodPost && r.Method != http.MethodDelete {
+			http.Error(w, "Método não permitido", http.StatusMethodNotAllowed)
+			return
+		}
+
+		// Extrair ID da URL (/api/conciliacao/transacoes/{id}/conciliar)
+		transacaoID, err := strconv.Atoi(strings.Split(r.URL.Path, "/")[4])
+		if err != nil {
+			http.Error(w, "ID da transação inválido", http.StatusBadRequest)
+			return
+		}
+
+		if r.Method == http.MethodDelete {
+			if err := conciliacao.Desfazer(db, transacaoID); err != nil {
+				responderErroConciliacao(w, err)
+				return
+			}
+		} else {
+			var req models.ConciliarTransacaoRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, "Erro ao decodificar requisição: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			if req.PedidoID <= 0 {
+				http.Error(w, "Pedido não informado", http.StatusBadRequest)
+				return
+			}
+
+			tx, err := db.Begin()
+			if err != nil {
+				http.Error(w, "Erro ao iniciar transação: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+			defer tx.Rollback() // sem efeito após o commit
+
+			if err := conciliacao.Conciliar(tx, transacaoID, req.PedidoID, userID); err != nil {
+				responderErroConciliacao(w, err)
+				return
+			}
+			if err := tx.Commit(); err != nil {
+				http.Error(w, "Erro ao confirmar conciliação: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+
+		t, err := escanearTransacaoBancaria(db.QueryRow(consultaTransacaoBancaria+" WHERE id = $1", transacaoID))
+		if err != nil {
+			http.Error(w, "Erro ao buscar transação: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(t)
+	})
+}
+
+// responderErroConciliacao traduz os erros da conciliação manual em códigos HTTP
+func responderErroConciliacao(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, conciliacao.ErrTransacaoNaoEncontrada), errors.Is(err, conciliacao.ErrPedidoNaoEncontrado):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, conciliacao.ErrJaConciliado):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, conciliacao.ErrDadosInvalidos):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func escanearTransacaoBancaria(row interface{ Scan(...interface{}) error }) (models.TransacaoBancaria, error) {
+	var t models.TransacaoBancaria
+	var pedidoID sql.NullInt64
+	var tipo sql.NullString
+	var conciliadoEm sql.NullTime
+	err := row.Scan(&t.ID, &t.ImportacaoID, &t.Identificador, &t.Data, &t.Valor, &t.Descricao, &t.TxID,
+		&pedidoID, &tipo, &conciliadoEm, &t.CriadoEm)
+	if err != nil {
+		return t, err
+	}
+	if pedidoID.Valid {
+		id := int(pedidoID.Int64)
+		t.PedidoID = &id
+	}
+	if tipo.Valid {
+		c := models.TipoConciliacao(tipo.String)
+		t.Conciliacao = &c
+	}
+	if conciliadoEm.Valid {
+		t.ConciliadoEm = &conciliadoEm.Time
+	}
+	return t, nil
+}
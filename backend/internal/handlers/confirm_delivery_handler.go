@@ -7,17 +7,20 @@ import (
     "strconv"
 
     "github.com/tassyosilva/GestGAS/internal/middleware"
+    "github.com/tassyosilva/GestGAS/internal/models"
+    "github.com/tassyosilva/GestGAS/internal/pedido"
 )
 
 // ConfirmarEntregaSimples é um handler simplificado para confirmar a entrega de um pedido
 func ConfirmarEntregaSimples(db *sql.DB) http.HandlerFunc {
     return func(w http.ResponseWriter, r *http.Request) {
         // Verificar se o usuário está autenticado
-        _, ok := middleware.ObterUsuarioID(r)
+        userID, ok := middleware.ObterUsuarioID(r)
         if !ok {
             http.Error(w, "Usuário não autenticado", http.StatusUnauthorized)
             return
         }
+        perfil, _ := middleware.ObterPerfilUsuario(r)
 
         // Configurar cabeçalhos
         w.Header().Set("Content-Type", "application/json")
@@ -44,33 +47,15 @@ func ConfirmarEntregaSimples(db *sql.DB) http.HandlerFunc {
             return
         }
 
-        // Buscar status atual do pedido
-        var statusAtual string
-        err := db.QueryRow("SELECT status FROM pedidos WHERE id = $1", req.PedidoID).Scan(&statusAtual)
-        if err != nil {
-            if err == sql.ErrNoRows {
-                http.Error(w, "Pedido não encontrado", http.StatusNotFound)
-                return
-            }
-            http.Error(w, "Erro ao buscar pedido: "+err.Error(), http.StatusInternalServerError)
-            return
-        }
-
-        // Verificar se o status atual é compatível
-        if statusAtual != "em_entrega" {
-            http.Error(w, "O pedido deve estar em entrega para confirmar a entrega", http.StatusBadRequest)
-            return
-        }
-
-        // Atualizar status do pedido para "entregue"
-        _, err = db.Exec(`
-            UPDATE pedidos
-            SET status = 'entregue', data_entrega = NOW(), atualizado_em = NOW()
-            WHERE id = $1
-        `, req.PedidoID)
-        
+        // Confirmar a entrega pelo serviço de pedidos
+        err := pedido.AlterarStatus(db, pedido.Alteracao{
+            PedidoID:  req.PedidoID,
+            UsuarioID: userID,
+            Perfil:    perfil,
+            Status:    models.StatusEntregue,
+        })
         if err != nil {
-            http.Error(w, "Erro ao atualizar status do pedido: "+err.Error(), http.StatusInternalServerError)
+            responderErroPedido(w, err)
             return
         }
 
@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/tassyosilva/GestGAS/internal/entrega"
+	"github.com/tassyosilva/GestGAS/internal/middleware"
+	"github.com/tassyosilva/GestGAS/internal/models"
+	"github.com/tassyosilva/GestGAS/internal/permissao"
+)
+
+// ListarLotesEntregaHandler lista os pedidos em preparo sem entregador, agrupados por bairro/CEP
+func ListarLotesEntregaHandler(db *sql.DB, planejador *entrega.Planejador) http.HandlerFunc {
+	return middleware.RequerPermissao(permissao.EntregaConsultar)(func(w http.ResponseWriter, r *http.Request) {
+		// Verificar se o usuário está autenticado
+		_, ok := middleware.ObterUsuarioID(r)
+		if !ok {
+			http.Error(w, "Usuário não autenticado", http.StatusUnauthorized)
+			return
+		}
+
+		// Configurar cabeçalhos
+		w.Header().Set("Content-Type", "application/json")
+
+		// Verificar método
+		if r.Method != http.MethodGet {
+			http.Error(w, "Método não permitido", http.StatusMethodNotAllowed)
+			return
+		}
+
+		lotes, err := planejador.LotesPendentes(db)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		json.NewEncoder(w).Encode(lotes)
+	})
+}
+
+// AtribuirLoteEntregaHandler atribui um lote de pedidos em preparo a um entregador
+func AtribuirLoteEntregaHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Verificar se o usuário está autenticado
+		userID, ok := middleware.ObterUsuarioID(r)
+		if !ok {
+			http.Error(w, "Usuário não autenticado", http.StatusUnauthorized)
+			return
+		}
+		perfil, _ := middleware.ObterPerfilUsuario(r)
+
+		// Configurar cabeçalhos
+		w.Header().Set("Content-Type", "application/json")
+
+		// Verificar método
+		if r.Method != http.MethodPost {
+			http.Error(w, "Método não permitido", http.StatusMethodNotAllowed)
+			return
+		}
+
+		// Decodificar requisição
+		var req models.AtribuirLoteRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Erro ao processar requisição: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if err := entrega.AtribuirLote(db, req, userID, perfil); err != nil {
+			responderErroPedido(w, err)
+			return
+		}
+
+		// Retornar resposta
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"mensagem":      "Lote atribuído com sucesso",
+			"entregador_id": req.EntregadorID,
+			"pedido_ids":    req.PedidoIDs,
+		})
+	}
+}
+
+// RotaEntregadorHandler retorna as paradas do entregador na ordem sugerida de visita
+func RotaEntregadorHandler(db *sql.DB, planejador *entrega.Planejador) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Verificar se o usuário está autenticado
+		userID, ok := middleware.ObterUsuarioID(r)
+		if !ok {
+			http.Error(w, "Usuário não autenticado", http.StatusUnauthorized)
+			return
+		}
+
+		// Configurar cabeçalhos
+		w.Header().Set("Content-Type", "application/json")
+
+		// Verificar método
+		if r.Method != http.MethodGet {
+			http.Error(w, "Método não permitido", http.StatusMethodNotAllowed)
+			return
+		}
+
+		// Extrair ID do entregador da URL (/api/entregadores/{id}/rota)
+		parts := strings.Split(r.URL.Path, "/")
+		if len(parts) < 5 {
+			http.Error(w, "ID do entregador não fornecido", http.StatusBadRequest)
+			return
+		}
+		entregadorID, err := strconv.Atoi(parts[3])
+		if err != nil {
+			http.Error(w, "ID do entregador inválido", http.StatusBadRequest)
+			return
+		}
+
+		// O entregador só pode consultar o próprio roteiro
+		if userID != entregadorID && !middleware.PossuiPermissao(r, permissao.EntregaConsultar) {
+			http.Error(w, "Sem permissão para consultar este roteiro", http.StatusForbidden)
+			return
+		}
+
+		rota, err := planejador.Rota(db, entregadorID)
+		if err != nil {
+			if errors.Is(err, entrega.ErrEntregadorNaoEncontrado) {
+				http.Error(w, "Entregador não encontrado", http.StatusNotFound)
+				return
+			}
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		json.NewEncoder(w).Encode(rota)
+	}
+}
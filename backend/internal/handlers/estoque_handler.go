@@ -10,6 +10,8 @@ import (
 
 	"github.com/tassyosilva/GestGAS/internal/middleware"
 	"github.com/tassyosilva/GestGAS/internal/models"
+	"github.com/tassyosilva/GestGAS/internal/pedido"
+	"github.com/tassyosilva/GestGAS/internal/permissao"
 )
 
 // ListarEstoqueHandler retorna a lista de itens no estoque
@@ -39,7 +41,7 @@ func ListarEstoqueHandler(db *sql.DB) http.HandlerFunc {
 
 		// Construir consulta SQL
 		sqlQuery := `
-			SELECT e.id, e.produto_id, p.nome, p.categoria, e.quantidade, 
+			SELECT e.id, e.produto_id, p.nome, p.categoria, e.quantidade, e.reservado,
 			       e.botijas_vazias, e.botijas_emprestadas, e.alerta_minimo, e.atualizado_em
 			FROM estoque e
 			JOIN produtos p ON e.produto_id = p.id
@@ -54,7 +56,7 @@ func ListarEstoqueHandler(db *sql.DB) http.HandlerFunc {
 		}
 
 		if alertas {
-			sqlQuery += fmt.Sprintf(" AND e.quantidade <= e.alerta_minimo")
+			sqlQuery += fmt.Sprintf(" AND e.quantidade - e.reservado <= e.alerta_minimo")
 		}
 
 		if botijasVazias {
@@ -78,7 +80,7 @@ func ListarEstoqueHandler(db *sql.DB) http.HandlerFunc {
 			var botijasVazias, botijasEmprestadas, alertaMinimo sql.NullInt64
 
 			err := rows.Scan(
-				&e.ID, &e.ProdutoID, &e.NomeProduto, &e.Categoria, &e.Quantidade,
+				&e.ID, &e.ProdutoID, &e.NomeProduto, &e.Categoria, &e.Quantidade, &e.Reservado,
 				&botijasVazias, &botijasEmprestadas, &alertaMinimo, &e.AtualizadoEm,
 			)
 			if err != nil {
@@ -86,6 +88,9 @@ func ListarEstoqueHandler(db *sql.DB) http.HandlerFunc {
 				return
 			}
 
+			// Calcular a quantidade disponível para venda
+			e.Disponivel = e.Quantidade - e.Reservado
+
 			// Converter tipos nulos
 			if botijasVazias.Valid {
 				e.BotijasVazias = int(botijasVazias.Int64)
@@ -99,8 +104,8 @@ func ListarEstoqueHandler(db *sql.DB) http.HandlerFunc {
 
 			// Determinar status do estoque
 			e.Status = "normal"
-			if alertaMinimo.Valid && e.Quantidade <= int(alertaMinimo.Int64) {
-				if e.Quantidade <= int(alertaMinimo.Int64/2) {
+			if alertaMinimo.Valid && e.Disponivel <= int(alertaMinimo.Int64) {
+				if e.Disponivel <= int(alertaMinimo.Int64/2) {
 					e.Status = "critico"
 				} else {
 					e.Status = "baixo"
@@ -151,13 +156,13 @@ func ObterEstoqueItemHandler(db *sql.DB) http.HandlerFunc {
 		var botijasVazias, botijasEmprestadas, alertaMinimo sql.NullInt64
 
 		err = db.QueryRow(`
-			SELECT e.id, e.produto_id, p.nome, p.categoria, e.quantidade, 
+			SELECT e.id, e.produto_id, p.nome, p.categoria, e.quantidade, e.reservado,
 			       e.botijas_vazias, e.botijas_emprestadas, e.alerta_minimo, e.atualizado_em
 			FROM estoque e
 			JOIN produtos p ON e.produto_id = p.id
 			WHERE e.produto_id = $1
 		`, produtoID).Scan(
-			&e.ID, &e.ProdutoID, &e.NomeProduto, &e.Categoria, &e.Quantidade,
+			&e.ID, &e.ProdutoID, &e.NomeProduto, &e.Categoria, &e.Quantidade, &e.Reservado,
 			&botijasVazias, &botijasEmprestadas, &alertaMinimo, &e.AtualizadoEm,
 		)
 		if err != nil {
@@ -169,6 +174,9 @@ func ObterEstoqueItemHandler(db *sql.DB) http.HandlerFunc {
 			return
 		}
 
+		// Calcular a quantidade disponível para venda
+		e.Disponivel = e.Quantidade - e.Reservado
+
 		// Converter tipos nulos
 		if botijasVazias.Valid {
 			e.BotijasVazias = int(botijasVazias.Int64)
@@ -182,8 +190,8 @@ func ObterEstoqueItemHandler(db *sql.DB) http.HandlerFunc {
 
 		// Determinar status do estoque
 		e.Status = "normal"
-		if alertaMinimo.Valid && e.Quantidade <= int(alertaMinimo.Int64) {
-			if e.Quantidade <= int(alertaMinimo.Int64/2) {
+		if alertaMinimo.Valid && e.Disponivel <= int(alertaMinimo.Int64) {
+			if e.Disponivel <= int(alertaMinimo.Int64/2) {
 				e.Status = "critico"
 			} else {
 				e.Status = "baixo"
@@ -271,11 +279,11 @@ func ListarAlertasEstoqueHandler(db *sql.DB) http.HandlerFunc {
 
 		// Buscar produtos com estoque baixo
 		rows, err := db.Query(`
-			SELECT p.id, p.nome, e.quantidade, e.alerta_minimo
+			SELECT p.id, p.nome, e.quantidade, e.reservado, e.alerta_minimo
 			FROM estoque e
 			JOIN produtos p ON e.produto_id = p.id
-			WHERE e.quantidade <= e.alerta_minimo AND e.alerta_minimo > 0
-			ORDER BY (e.quantidade::float / e.alerta_minimo) ASC
+			WHERE e.quantidade - e.reservado <= e.alerta_minimo AND e.alerta_minimo > 0
+			ORDER BY ((e.quantidade - e.reservado)::float / e.alerta_minimo) ASC
 		`)
 		if err != nil {
 			http.Error(w, "Erro ao buscar alertas de estoque: "+err.Error(), http.StatusInternalServerError)
@@ -287,14 +295,15 @@ func ListarAlertasEstoqueHandler(db *sql.DB) http.HandlerFunc {
 		var alertas []models.EstoqueAlertaResponse
 		for rows.Next() {
 			var a models.EstoqueAlertaResponse
-			err := rows.Scan(&a.ProdutoID, &a.NomeProduto, &a.Quantidade, &a.AlertaMinimo)
+			err := rows.Scan(&a.ProdutoID, &a.NomeProduto, &a.Quantidade, &a.Reservado, &a.AlertaMinimo)
 			if err != nil {
 				http.Error(w, "Erro ao processar alertas de estoque: "+err.Error(), http.StatusInternalServerError)
 				return
 			}
+			a.Disponivel = a.Quantidade - a.Reservado
 
 			// Determinar status
-			if a.Quantidade <= a.AlertaMinimo/2 {
+			if a.Disponivel <= a.AlertaMinimo/2 {
 				a.Status = "critico"
 			} else {
 				a.Status = "baixo"
@@ -310,7 +319,7 @@ func ListarAlertasEstoqueHandler(db *sql.DB) http.HandlerFunc {
 
 // AtualizarEstoqueHandler atualiza a quantidade em estoque de um produto
 func AtualizarEstoqueHandler(db *sql.DB) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
+	return middleware.RequerPermissao(permissao.EstoqueAjustar)(func(w http.ResponseWriter, r *http.Request) {
 		// Verificar se o usuário está autenticado
 		userID, ok := middleware.ObterUsuarioID(r)
 		if !ok {
@@ -318,13 +327,6 @@ func AtualizarEstoqueHandler(db *sql.DB) http.HandlerFunc {
 			return
 		}
 
-		// Verificar permissões (apenas gerentes ou admins podem atualizar estoque manualmente)
-		perfil, perfilOk := middleware.ObterPerfilUsuario(r)
-		if !perfilOk || !middleware.VerificarPerfil(perfil, "gerente") {
-			http.Error(w, "Sem permissão para atualizar estoque", http.StatusForbidden)
-			return
-		}
-
 		// Configurar cabeçalhos
 		w.Header().Set("Content-Type", "application/json")
 
@@ -378,12 +380,7 @@ func AtualizarEstoqueHandler(db *sql.DB) http.HandlerFunc {
 			http.Error(w, "Erro ao iniciar transação: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
-		defer func() {
-			if err != nil {
-				tx.Rollback()
-				return
-			}
-		}()
+		defer tx.Rollback() // sem efeito após o commit
 
 		// Atualizar estoque conforme o tipo de movimentação
 		var query string
@@ -395,15 +392,15 @@ func AtualizarEstoqueHandler(db *sql.DB) http.HandlerFunc {
 				WHERE produto_id = $2
 			`
 		case models.MovimentacaoSaida:
-			// Verificar se há estoque suficiente
-			var qtdEstoque int
-			err = tx.QueryRow("SELECT quantidade FROM estoque WHERE produto_id = $1", produtoID).Scan(&qtdEstoque)
+			// Verificar se há estoque disponível suficiente (unidades reservadas não podem sair)
+			var qtdDisponivel int
+			err = tx.QueryRow("SELECT quantidade - reservado FROM estoque WHERE produto_id = $1 FOR UPDATE", produtoID).Scan(&qtdDisponivel)
 			if err != nil {
 				http.Error(w, "Erro ao verificar estoque: "+err.Error(), http.StatusInternalServerError)
 				return
 			}
-			if qtdEstoque < req.Quantidade {
-				http.Error(w, fmt.Sprintf("Estoque insuficiente para o produto %s", produtoNome), http.StatusBadRequest)
+			if qtdDisponivel < req.Quantidade {
+				http.Error(w, fmt.Sprintf("Estoque disponível insuficiente para o produto %s", produtoNome), http.StatusBadRequest)
 				return
 			}
 			query = `
@@ -412,6 +409,17 @@ func AtualizarEstoqueHandler(db *sql.DB) http.HandlerFunc {
 				WHERE produto_id = $2
 			`
 		case models.MovimentacaoAjuste:
+			// O ajuste não pode deixar o estoque abaixo do que já está reservado para pedidos
+			var qtdReservada int
+			err = tx.QueryRow("SELECT reservado FROM estoque WHERE produto_id = $1 FOR UPDATE", produtoID).Scan(&qtdReservada)
+			if err != nil {
+				http.Error(w, "Erro ao verificar estoque: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+			if req.Quantidade < qtdReservada {
+				http.Error(w, fmt.Sprintf("Quantidade menor que as %d unidades reservadas para pedidos do produto %s", qtdReservada, produtoNome), http.StatusBadRequest)
+				return
+			}
 			// Ajuste direto na quantidade
 			query = `
 				UPDATE estoque 
@@ -487,6 +495,12 @@ func AtualizarEstoqueHandler(db *sql.DB) http.HandlerFunc {
 			return
 		}
 
+		// Avisar os webhooks se o produto ficou no nível de alerta
+		if err := pedido.EnfileirarAlertasEstoque(tx, []int{produtoID}); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
 		// Commit da transação
 		err = tx.Commit()
 		if err != nil {
@@ -499,13 +513,13 @@ func AtualizarEstoqueHandler(db *sql.DB) http.HandlerFunc {
 		var botijasVazias, botijasEmprestadas, alertaMinimo sql.NullInt64
 
 		err = db.QueryRow(`
-			SELECT e.id, e.produto_id, p.nome, p.categoria, e.quantidade, 
+			SELECT e.id, e.produto_id, p.nome, p.categoria, e.quantidade, e.reservado,
 			       e.botijas_vazias, e.botijas_emprestadas, e.alerta_minimo, e.atualizado_em
 			FROM estoque e
 			JOIN produtos p ON e.produto_id = p.id
 			WHERE e.produto_id = $1
 		`, produtoID).Scan(
-			&e.ID, &e.ProdutoID, &e.NomeProduto, &e.Categoria, &e.Quantidade,
+			&e.ID, &e.ProdutoID, &e.NomeProduto, &e.Categoria, &e.Quantidade, &e.Reservado,
 			&botijasVazias, &botijasEmprestadas, &alertaMinimo, &e.AtualizadoEm,
 		)
 		if err != nil {
@@ -513,6 +527,9 @@ func AtualizarEstoqueHandler(db *sql.DB) http.HandlerFunc {
 			return
 		}
 
+		// Calcular a quantidade disponível para venda
+		e.Disponivel = e.Quantidade - e.Reservado
+
 		// Converter tipos nulos
 		if botijasVazias.Valid {
 			e.BotijasVazias = int(botijasVazias.Int64)
@@ -526,23 +543,28 @@ func AtualizarEstoqueHandler(db *sql.DB) http.HandlerFunc {
 
 		// Determinar status do estoque
 		e.Status = "normal"
-		if alertaMinimo.Valid && e.Quantidade <= int(alertaMinimo.Int64) {
-			if e.Quantidade <= int(alertaMinimo.Int64/2) {
+		if alertaMinimo.Valid && e.Disponivel <= int(alertaMinimo.Int64) {
+			if e.Disponivel <= int(alertaMinimo.Int64/2) {
 				e.Status = "critico"
 			} else {
 				e.Status = "baixo"
 			}
 		}
 
+		// Avisar as telas conectadas quando o produto fica no nível de alerta
+		if e.Status != "normal" {
+			publicarAlertaEstoque(e)
+		}
+
 		// Retornar resposta
 		w.WriteHeader(http.StatusOK)
 		json.NewEncoder(w).Encode(e)
-	}
+	})
 }
 
 // AtualizarAlertaMinimoHandler atualiza o alerta mínimo de estoque de um produto
 func AtualizarAlertaMinimoHandler(db *sql.DB) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
+	return middleware.RequerPermissao(permissao.EstoqueAjustar)(func(w http.ResponseWriter, r *http.Request) {
 		// Verificar se o usuário está autenticado
 		_, ok := middleware.ObterUsuarioID(r)
 		if !ok {
@@ -550,13 +572,6 @@ func AtualizarAlertaMinimoHandler(db *sql.DB) http.HandlerFunc {
 			return
 		}
 
-		// Verificar permissões (apenas gerentes ou admins podem atualizar alerta mínimo)
-		perfil, perfilOk := middleware.ObterPerfilUsuario(r)
-		if !perfilOk || !middleware.VerificarPerfil(perfil, "gerente") {
-			http.Error(w, "Sem permissão para atualizar alerta mínimo", http.StatusForbidden)
-			return
-		}
-
 		// Configurar cabeçalhos
 		w.Header().Set("Content-Type", "application/json")
 
@@ -605,8 +620,16 @@ func AtualizarAlertaMinimoHandler(db *sql.DB) http.HandlerFunc {
 			return
 		}
 
+		// Iniciar transação
+		tx, err := db.Begin()
+		if err != nil {
+			http.Error(w, "Erro ao iniciar transação: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback() // sem efeito após o commit
+
 		// Atualizar alerta mínimo
-		_, err = db.Exec(`
+		_, err = tx.Exec(`
 			UPDATE estoque 
 			SET alerta_minimo = $1, atualizado_em = NOW() 
 			WHERE produto_id = $2
@@ -616,18 +639,31 @@ func AtualizarAlertaMinimoHandler(db *sql.DB) http.HandlerFunc {
 			return
 		}
 
+		// Avisar os webhooks se o novo mínimo deixou o produto no nível de alerta
+		if err := pedido.EnfileirarAlertasEstoque(tx, []int{produtoID}); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		// Commit da transação
+		err = tx.Commit()
+		if err != nil {
+			http.Error(w, "Erro ao finalizar transação: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
 		// Buscar informações atualizadas do estoque
 		var e models.EstoqueResponse
 		var botijasVazias, botijasEmprestadas, alertaMinimo sql.NullInt64
 
 		err = db.QueryRow(`
-			SELECT e.id, e.produto_id, p.nome, p.categoria, e.quantidade, 
+			SELECT e.id, e.produto_id, p.nome, p.categoria, e.quantidade, e.reservado,
 			       e.botijas_vazias, e.botijas_emprestadas, e.alerta_minimo, e.atualizado_em
 			FROM estoque e
 			JOIN produtos p ON e.produto_id = p.id
 			WHERE e.produto_id = $1
 		`, produtoID).Scan(
-			&e.ID, &e.ProdutoID, &e.NomeProduto, &e.Categoria, &e.Quantidade,
+			&e.ID, &e.ProdutoID, &e.NomeProduto, &e.Categoria, &e.Quantidade, &e.Reservado,
 			&botijasVazias, &botijasEmprestadas, &alertaMinimo, &e.AtualizadoEm,
 		)
 		if err != nil {
@@ -635,6 +671,9 @@ func AtualizarAlertaMinimoHandler(db *sql.DB) http.HandlerFunc {
 			return
 		}
 
+		// Calcular a quantidade disponível para venda
+		e.Disponivel = e.Quantidade - e.Reservado
+
 		// Converter tipos nulos
 		if botijasVazias.Valid {
 			e.BotijasVazias = int(botijasVazias.Int64)
@@ -648,23 +687,28 @@ func AtualizarAlertaMinimoHandler(db *sql.DB) http.HandlerFunc {
 
 		// Determinar status do estoque
 		e.Status = "normal"
-		if alertaMinimo.Valid && e.Quantidade <= int(alertaMinimo.Int64) {
-			if e.Quantidade <= int(alertaMinimo.Int64/2) {
+		if alertaMinimo.Valid && e.Disponivel <= int(alertaMinimo.Int64) {
+			if e.Disponivel <= int(alertaMinimo.Int64/2) {
 				e.Status = "critico"
 			} else {
 				e.Status = "baixo"
 			}
 		}
 
+		// Avisar as telas conectadas quando o produto fica no nível de alerta
+		if e.Status != "normal" {
+			publicarAlertaEstoque(e)
+		}
+
 		// Retornar resposta
 		w.WriteHeader(http.StatusOK)
 		json.NewEncoder(w).Encode(e)
-	}
+	})
 }
 
 // EmprestimoBotijasHandler registra empréstimo de botijas vazias ao caminhoneiro
 func EmprestimoBotijasHandler(db *sql.DB) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
+	return middleware.RequerPermissao(permissao.EstoqueEmprestimo)(func(w http.ResponseWriter, r *http.Request) {
 		// Verificar se o usuário está autenticado
 		userID, ok := middleware.ObterUsuarioID(r)
 		if !ok {
@@ -672,13 +716,6 @@ func EmprestimoBotijasHandler(db *sql.DB) http.HandlerFunc {
 			return
 		}
 
-		// Verificar permissões (apenas atendentes ou acima podem registrar empréstimos)
-		perfil, perfilOk := middleware.ObterPerfilUsuario(r)
-		if !perfilOk || !middleware.VerificarPerfil(perfil, "atendente") {
-			http.Error(w, "Sem permissão para registrar empréstimo", http.StatusForbidden)
-			return
-		}
-
 		// Configurar cabeçalhos
 		w.Header().Set("Content-Type", "application/json")
 
@@ -778,13 +815,13 @@ func EmprestimoBotijasHandler(db *sql.DB) http.HandlerFunc {
 		var botijasVazias, botijasEmprestadas, alertaMinimo sql.NullInt64
 
 		err = db.QueryRow(`
-			SELECT e.id, e.produto_id, p.nome, p.categoria, e.quantidade, 
+			SELECT e.id, e.produto_id, p.nome, p.categoria, e.quantidade, e.reservado,
 			       e.botijas_vazias, e.botijas_emprestadas, e.alerta_minimo, e.atualizado_em
 			FROM estoque e
 			JOIN produtos p ON e.produto_id = p.id
 			WHERE e.produto_id = $1
 		`, req.ProdutoID).Scan(
-			&e.ID, &e.ProdutoID, &e.NomeProduto, &e.Categoria, &e.Quantidade,
+			&e.ID, &e.ProdutoID, &e.NomeProduto, &e.Categoria, &e.Quantidade, &e.Reservado,
 			&botijasVazias, &botijasEmprestadas, &alertaMinimo, &e.AtualizadoEm,
 		)
 		if err != nil {
@@ -792,6 +829,9 @@ func EmprestimoBotijasHandler(db *sql.DB) http.HandlerFunc {
 			return
 		}
 
+		// Calcular a quantidade disponível para venda
+		e.Disponivel = e.Quantidade - e.Reservado
+
 		// Converter tipos nulos
 		if botijasVazias.Valid {
 			e.BotijasVazias = int(botijasVazias.Int64)
@@ -815,12 +855,12 @@ func EmprestimoBotijasHandler(db *sql.DB) http.HandlerFunc {
 		// Retornar resposta
 		w.WriteHeader(http.StatusOK)
 		json.NewEncoder(w).Encode(response)
-	}
+	})
 }
 
 // DevolucaoBotijasEmprestimoHandler registra devolução de botijas emprestadas (troca por botijas cheias)
 func DevolucaoBotijasEmprestimoHandler(db *sql.DB) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
+	return middleware.RequerPermissao(permissao.EstoqueEmprestimo)(func(w http.ResponseWriter, r *http.Request) {
 		// Verificar se o usuário está autenticado
 		userID, ok := middleware.ObterUsuarioID(r)
 		if !ok {
@@ -828,13 +868,6 @@ func DevolucaoBotijasEmprestimoHandler(db *sql.DB) http.HandlerFunc {
 			return
 		}
 
-		// Verificar permissões (apenas atendentes ou acima podem registrar devoluções)
-		perfil, perfilOk := middleware.ObterPerfilUsuario(r)
-		if !perfilOk || !middleware.VerificarPerfil(perfil, "atendente") {
-			http.Error(w, "Sem permissão para registrar devolução", http.StatusForbidden)
-			return
-		}
-
 		// Configurar cabeçalhos
 		w.Header().Set("Content-Type", "application/json")
 
@@ -934,13 +967,13 @@ func DevolucaoBotijasEmprestimoHandler(db *sql.DB) http.HandlerFunc {
 		var botijasVazias, botijasEmprestadas, alertaMinimo sql.NullInt64
 
 		err = db.QueryRow(`
-			SELECT e.id, e.produto_id, p.nome, p.categoria, e.quantidade, 
+			SELECT e.id, e.produto_id, p.nome, p.categoria, e.quantidade, e.reservado,
 			       e.botijas_vazias, e.botijas_emprestadas, e.alerta_minimo, e.atualizado_em
 			FROM estoque e
 			JOIN produtos p ON e.produto_id = p.id
 			WHERE e.produto_id = $1
 		`, req.ProdutoID).Scan(
-			&e.ID, &e.ProdutoID, &e.NomeProduto, &e.Categoria, &e.Quantidade,
+			&e.ID, &e.ProdutoID, &e.NomeProduto, &e.Categoria, &e.Quantidade, &e.Reservado,
 			&botijasVazias, &botijasEmprestadas, &alertaMinimo, &e.AtualizadoEm,
 		)
 		if err != nil {
@@ -948,6 +981,9 @@ func DevolucaoBotijasEmprestimoHandler(db *sql.DB) http.HandlerFunc {
 			return
 		}
 
+		// Calcular a quantidade disponível para venda
+		e.Disponivel = e.Quantidade - e.Reservado
+
 		// Converter tipos nulos
 		if botijasVazias.Valid {
 			e.BotijasVazias = int(botijasVazias.Int64)
@@ -971,5 +1007,5 @@ func DevolucaoBotijasEmprestimoHandler(db *sql.DB) http.HandlerFunc {
 		// Retornar resposta
 		w.WriteHeader(http.StatusOK)
 		json.NewEncoder(w).Encode(response)
-	}
+	})
 }
\ No newline at end of file
@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/tassyosilva/GestGAS/internal/auth"
+	"github.com/tassyosilva/GestGAS/internal/eventos"
+	"github.com/tassyosilva/GestGAS/internal/middleware"
+	"github.com/tassyosilva/GestGAS/internal/models"
+	"github.com/tassyosilva/GestGAS/internal/pedido"
+	"github.com/tassyosilva/GestGAS/internal/permissao"
+)
+
+// EventosHandler mantém uma conexão Server-Sent Events com o painel e envia os eventos do sistema
+// (pedido.criado, pedido.status_alterado, entrega.confirmada e estoque.alerta). Cada aba abre a
+// própria conexão. Ao reconectar, o navegador envia o cabeçalho Last-Event-ID (ou o parâmetro
+// ultimo_id) e recebe os eventos perdidos; se não for possível reenviá-los, recebe o evento
+// "sincronizar" e deve recarregar os dados.
+func EventosHandler(db *sql.DB) http.HandlerFunc {
+	return middleware.RequerPermissao(permissao.PedidoAcompanhar)(func(w http.ResponseWriter, r *http.Request) {
+		// Verificar se o usuário está autenticado
+		_, ok := middleware.ObterUsuarioID(r)
+		if !ok {
+			http.Error(w, "Usuário não autenticado", http.StatusUnauthorized)
+			return
+		}
+
+		// Verificar método
+		if r.Method != http.MethodGet {
+			http.Error(w, "Método não permitido", http.StatusMethodNotAllowed)
+			return
+		}
+
+		ultimoID := r.Header.Get("Last-Event-ID")
+		if ultimoID == "" {
+			ultimoID = r.URL.Query().Get("ultimo_id")
+		}
+		var desde int64
+		if ultimoID != "" {
+			var err error
+			desde, err = strconv.ParseInt(ultimoID, 10, 64)
+			if err != nil || desde < 0 {
+				http.Error(w, "Último ID de evento inválido", http.StatusBadRequest)
+				return
+			}
+		}
+
+		// A conexão fica aberta indefinidamente: remover o prazo de escrita do servidor
+		rc := http.NewResponseController(w)
+		if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+			http.Error(w, "Streaming não suportado", http.StatusInternalServerError)
+			return
+		}
+
+		perdidos, completo, fila, cancelar := eventos.Assinar(desde)
+		defer cancelar()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.WriteHeader(http.StatusOK)
+
+		if !completo {
+			if _, err := fmt.Fprint(w, "event: sincronizar\ndata: {}\n\n"); err != nil {
+				return
+			}
+		}
+		for _, evento := range perdidos {
+			if err := escreverEvento(w, evento); err != nil {
+				return
+			}
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+
+		ping := time.NewTicker(intervaloPingEventos)
+		defer ping.Stop()
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-ping.C:
+				// Encerrar o stream se a sessão foi revogada ou a permissão retirada depois da conexão
+				if !streamAutorizado(db, r, permissao.PedidoAcompanhar) {
+					return
+				}
+				if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+					return
+				}
+			case evento, aberto := <-fila:
+				if !aberto {
+					// Conexão lenta demais: o navegador reconecta e recebe o que perdeu
+					return
+				}
+				if err := escreverEvento(w, evento); err != nil {
+					return
+				}
+			}
+			if err := rc.Flush(); err != nil {
+				return
+			}
+		}
+	})
+}
+
+// streamAutorizado confere de novo se a sessão que abriu um stream continua ativa, sem troca de
+// senha pendente, e se o perfil ainda tem a permissão. Em caso de erro o stream é encerrado e o
+// navegador reconecta passando pela autenticação.
+func streamAutorizado(db *sql.DB, r *http.Request, codigo string) bool {
+	userID, _ := middleware.ObterUsuarioID(r)
+	sessaoID, _ := middleware.ObterSessaoID(r)
+	ativa, deveTrocarSenha, err := auth.SessaoAtiva(db, sessaoID, userID)
+	if err != nil {
+		log.Printf("Erro ao verificar sessão do stream de eventos: %v", err)
+		return false
+	}
+	return ativa && !deveTrocarSenha && middleware.PossuiPermissao(r, codigo)
+}
+
+// escreverEvento envia um evento no formato Server-Sent Events
+func escreverEvento(w http.ResponseWriter, evento eventos.Evento) error {
+	dados, err := json.Marshal(evento)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", evento.ID, evento.Tipo, dados)
+	return err
+}
+
+// publicarAlertasEstoque publica um evento estoque.alerta para cada produto informado que ficou
+// com o disponível no alerta mínimo ou abaixo dele. Chamado após o commit da movimentação;
+// uma falha aqui não desfaz a operação, apenas deixa de avisar as telas.
+func publicarAlertasEstoque(db *sql.DB, produtoIDs []int) {
+	alertas, err := pedido.AlertasEstoque(db, produtoIDs)
+	if err != nil {
+		log.Printf("Erro ao publicar alertas de estoque: %v", err)
+		return
+	}
+	for _, a := range alertas {
+		eventos.Publicar(eventos.EstoqueAlerta, a)
+	}
+}
+
+// publicarAlertaEstoque publica o evento estoque.alerta a partir do estoque já consultado
+func publicarAlertaEstoque(e models.EstoqueResponse) {
+	eventos.Publicar(eventos.EstoqueAlerta, models.EstoqueAlertaResponse{
+		ProdutoID:    e.ProdutoID,
+		NomeProduto:  e.NomeProduto,
+		Quantidade:   e.Quantidade,
+		Reservado:    e.Reservado,
+		Disponivel:   e.Disponivel,
+		AlertaMinimo: e.AlertaMinimo,
+		Status:       e.Status,
+	})
+}
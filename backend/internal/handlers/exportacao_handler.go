@@ -0,0 +1,368 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tassyosilva/GestGAS/internal/exportacao"
+	"github.com/tassyosilva/GestGAS/internal/middleware"
+	"github.com/tassyosilva/GestGAS/internal/permissao"
+)
+
+// linhasPorEnvio define a cada quantas linhas a resposta é enviada ao cliente durante a exportação
+const linhasPorEnvio = 500
+
+// prazoExportacao substitui o prazo de escrita do servidor, curto demais para arquivos grandes
+const prazoExportacao = 10 * time.Minute
+
+// opcoesExportacao são os parâmetros de formato aceitos pelos endpoints de exportação
+type opcoesExportacao struct {
+	formato   exportacao.Formato
+	separador rune
+	bom       bool
+}
+
+// ExportarPedidosHandler exporta os pedidos com os mesmos filtros da listagem (status, cliente_id, data_inicio, data_fim)
+func ExportarPedidosHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, opcoes, ok := prepararExportacao(w, r)
+		if !ok {
+			return
+		}
+
+		whereConditions, params := filtrosPedidos(r, userID)
+		query := `
+			SELECT p.id, p.criado_em, p.status, c.nome, c.telefone, a.nome, e.nome,
+				p.forma_pagamento, p.canal_origem, p.endereco_entrega,
+				(SELECT string_agg(i.quantidade || 'x ' || pr.nome, ', ' ORDER BY i.id)
+					FROM itens_pedido i JOIN produtos pr ON i.produto_id = pr.id
+					WHERE i.pedido_id = p.id),
+				p.valor_total, p.data_entrega, p.motivo_cancelamento, p.observacoes
+			FROM pedidos p
+			JOIN clientes c ON p.cliente_id = c.id
+			JOIN usuarios a ON p.atendente_id = a.id
+			LEFT JOIN usuarios e ON p.entregador_id = e.id
+		`
+		if len(whereConditions) > 0 {
+			query += " WHERE " + strings.Join(whereConditions, " AND ")
+		}
+		query += " ORDER BY p.criado_em, p.id"
+
+		rows, err := db.Query(query, params...)
+		if err != nil {
+			http.Error(w, "Erro ao consultar pedidos: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		cabecalho := []interface{}{
+			"ID", "Data", "Status", "Cliente", "Telefone", "Atendente", "Entregador",
+			"Forma de pagamento", "Canal de origem", "Endereço de entrega", "Itens",
+			"Valor total", "Data de entrega", "Motivo do cancelamento", "Observações",
+		}
+		exportarLinhas(w, opcoes, "pedidos", cabecalho, rows, func() ([]interface{}, error) {
+			var (
+				id                                            int
+				criadoEm                                      time.Time
+				status, cliente, telefone, atendente, forma   string
+				endereco                                      string
+				entregador, canal, itens, motivo, observacoes sql.NullString
+				valorTotal                                    float64
+				dataEntrega                                   sql.NullTime
+			)
+			err := rows.Scan(&id, &criadoEm, &status, &cliente, &telefone, &atendente, &entregador,
+				&forma, &canal, &endereco, &itens, &valorTotal, &dataEntrega, &motivo, &observacoes)
+			if err != nil {
+				return nil, err
+			}
+			return []interface{}{
+				id, criadoEm, status, cliente, telefone, atendente, textoNulo(entregador),
+				forma, textoNulo(canal), endereco, textoNulo(itens),
+				valorTotal, dataNula(dataEntrega), textoNulo(motivo), textoNulo(observacoes),
+			}, nil
+		})
+	}
+}
+
+// ExportarClientesHandler exporta os clientes com os mesmos filtros da listagem (nome, telefone, canal_origem)
+func ExportarClientesHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		_, opcoes, ok := prepararExportacao(w, r)
+		if !ok {
+			return
+		}
+
+		whereConditions, params := filtrosClientes(r.URL.Query())
+		query := `
+			SELECT id, nome, telefone, cpf, email, endereco, complemento, bairro, cidade, estado, cep,
+				canal_origem, observacoes, criado_em
+			FROM clientes
+		`
+		if len(whereConditions) > 0 {
+			query += " WHERE " + strings.Join(whereConditions, " AND ")
+		}
+		query += " ORDER BY nome, id"
+
+		rows, err := db.Query(query, params...)
+		if err != nil {
+			http.Error(w, "Erro ao consultar clientes: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		cabecalho := []interface{}{
+			"ID", "Nome", "Telefone", "CPF", "Email", "Endereço", "Complemento", "Bairro",
+			"Cidade", "Estado", "CEP", "Canal de origem", "Observações", "Cadastrado em",
+		}
+		exportarLinhas(w, opcoes, "clientes", cabecalho, rows, func() ([]interface{}, error) {
+			var (
+				id                                int
+				nome, telefone                    string
+				cpf, email, endereco, complemento sql.NullString
+				bairro, cidade, estado, cep       sql.NullString
+				canal, observacoes                sql.NullString
+				criadoEm                          time.Time
+			)
+			err := rows.Scan(&id, &nome, &telefone, &cpf, &email, &endereco, &complemento, &bairro,
+				&cidade, &estado, &cep, &canal, &observacoes, &criadoEm)
+			if err != nil {
+				return nil, err
+			}
+			return []interface{}{
+				id, nome, telefone, textoNulo(cpf), textoNulo(email), textoNulo(endereco),
+				textoNulo(complemento), textoNulo(bairro), textoNulo(cidade), textoNulo(estado),
+				textoNulo(cep), textoNulo(canal), textoNulo(observacoes), criadoEm,
+			}, nil
+		})
+	}
+}
+
+// ExportarMovimentacoesEstoqueHandler exporta as movimentações de estoque (filtros: produto_id, tipo, data_inicio, data_fim)
+func ExportarMovimentacoesEstoqueHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		_, opcoes, ok := prepararExportacao(w, r)
+		if !ok {
+			return
+		}
+
+		whereConditions, params, msg := filtrosMovimentacoesEstoque(r.URL.Query())
+		if msg != "" {
+			http.Error(w, msg, http.StatusBadRequest)
+			return
+		}
+		query := `
+			SELECT m.id, m.criado_em, pr.nome, m.tipo, m.quantidade, u.nome, m.pedido_id, m.observacoes
+			FROM movimentacoes_estoque m
+			JOIN produtos pr ON m.produto_id = pr.id
+			JOIN usuarios u ON m.usuario_id = u.id
+		`
+		if len(whereConditions) > 0 {
+			query += " WHERE " + strings.Join(whereConditions, " AND ")
+		}
+		query += " ORDER BY m.criado_em, m.id"
+
+		rows, err := db.Query(query, params...)
+		if err != nil {
+			http.Error(w, "Erro ao consultar movimentações de estoque: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		cabecalho := []interface{}{"ID", "Data", "Produto", "Tipo", "Quantidade", "Usuário", "Pedido", "Observações"}
+		exportarLinhas(w, opcoes, "movimentacoes_estoque", cabecalho, rows, func() ([]interface{}, error) {
+			var (
+				id, quantidade         int
+				criadoEm               time.Time
+				produto, tipo, usuario string
+				pedidoID               sql.NullInt64
+				observacoes            sql.NullString
+			)
+			err := rows.Scan(&id, &criadoEm, &produto, &tipo, &quantidade, &usuario, &pedidoID, &observacoes)
+			if err != nil {
+				return nil, err
+			}
+			var pedido interface{}
+			if pedidoID.Valid {
+				pedido = pedidoID.Int64
+			}
+			return []interface{}{id, criadoEm, produto, tipo, quantidade, usuario, pedido, textoNulo(observacoes)}, nil
+		})
+	}
+}
+
+// filtrosMovimentacoesEstoque monta as condições da exportação de movimentações; data_fim é inclusiva
+func filtrosMovimentacoesEstoque(query url.Values) ([]string, []interface{}, string) {
+	var params []interface{}
+	var whereConditions []string
+
+	if produtoID := query.Get("produto_id"); produtoID != "" {
+		id, err := strconv.Atoi(produtoID)
+		if err != nil {
+			return nil, nil, "produto_id inválido"
+		}
+		whereConditions = append(whereConditions, "m.produto_id = $"+strconv.Itoa(len(params)+1))
+		params = append(params, id)
+	}
+	if tipo := query.Get("tipo"); tipo != "" {
+		whereConditions = append(whereConditions, "m.tipo = $"+strconv.Itoa(len(params)+1))
+		params = append(params, tipo)
+	}
+	if dataInicio := query.Get("data_inicio"); dataInicio != "" {
+		d, err := time.ParseInLocation(formatoData, dataInicio, time.Local)
+		if err != nil {
+			return nil, nil, "data_inicio inválida (use AAAA-MM-DD)"
+		}
+		whereConditions = append(whereConditions, "m.criado_em >= $"+strconv.Itoa(len(params)+1))
+		params = append(params, d)
+	}
+	if dataFim := query.Get("data_fim"); dataFim != "" {
+		d, err := time.ParseInLocation(formatoData, dataFim, time.Local)
+		if err != nil {
+			return nil, nil, "data_fim inválida (use AAAA-MM-DD)"
+		}
+		whereConditions = append(whereConditions, "m.criado_em < $"+strconv.Itoa(len(params)+1))
+		params = append(params, d.AddDate(0, 0, 1))
+	}
+
+	return whereConditions, params, ""
+}
+
+// prepararExportacao valida autenticação, permissão, método e opções de formato
+func prepararExportacao(w http.ResponseWriter, r *http.Request) (int, opcoesExportacao, bool) {
+	// Verificar se o usuário está autenticado
+	userID, ok := middleware.ObterUsuarioID(r)
+	if !ok {
+		http.Error(w, "Usuário não autenticado", http.StatusUnauthorized)
+		return 0, opcoesExportacao{}, false
+	}
+
+	// Verificar permissões
+	if !middleware.PossuiPermissao(r, permissao.DadosExportar) {
+		http.Error(w, "Sem permissão para exportar dados", http.StatusForbidden)
+		return 0, opcoesExportacao{}, false
+	}
+
+	// Verificar método
+	if r.Method != http.MethodGet {
+		http.Error(w, "Método não permitido", http.StatusMethodNotAllowed)
+		return 0, opcoesExportacao{}, false
+	}
+
+	opcoes, msg := lerOpcoesExportacao(r.URL.Query())
+	if msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return 0, opcoesExportacao{}, false
+	}
+	return userID, opcoes, true
+}
+
+// lerOpcoesExportacao interpreta formato (csv ou xlsx), separador (';' ou ',') e bom (true ou false).
+// O padrão é CSV com ';' e BOM, que o Excel em português abre sem ajustes.
+func lerOpcoesExportacao(query url.Values) (opcoesExportacao, string) {
+	opcoes := opcoesExportacao{formato: exportacao.FormatoCSV, separador: ';', bom: true}
+
+	switch formato := query.Get("formato"); formato {
+	case "", string(exportacao.FormatoCSV):
+	case string(exportacao.FormatoXLSX):
+		opcoes.formato = exportacao.FormatoXLSX
+	default:
+		return opcoes, "Formato inválido (use csv ou xlsx)"
+	}
+
+	switch separador := query.Get("separador"); separador {
+	case "", ";":
+	case ",":
+		opcoes.separador = ','
+	default:
+		return opcoes, "Separador inválido (use ; ou ,)"
+	}
+
+	if bom := query.Get("bom"); bom != "" {
+		valor, err := strconv.ParseBool(bom)
+		if err != nil {
+			return opcoes, "Parâmetro bom inválido (use true ou false)"
+		}
+		opcoes.bom = valor
+	}
+	return opcoes, ""
+}
+
+// exportarLinhas grava o cabeçalho e as linhas da consulta à medida que são lidas, enviando a resposta aos poucos
+func exportarLinhas(w http.ResponseWriter, opcoes opcoesExportacao, nome string, cabecalho []interface{}, rows *sql.Rows, linha func() ([]interface{}, error)) {
+	// Estender o prazo de escrita antes de enviar qualquer byte
+	rc := http.NewResponseController(w)
+	if err := rc.SetWriteDeadline(time.Now().Add(prazoExportacao)); err != nil {
+		log.Printf("Erro ao estender prazo de escrita da exportação de %s: %v", nome, err)
+	}
+
+	nomeArquivo := fmt.Sprintf("%s_%s.%s", nome, time.Now().Format(formatoData), opcoes.formato)
+	w.Header().Set("Content-Type", opcoes.formato.ContentType())
+	w.Header().Set("Content-Disposition", `attachment; filename="`+nomeArquivo+`"`)
+
+	var escritor exportacao.Escritor
+	var err error
+	if opcoes.formato == exportacao.FormatoXLSX {
+		escritor, err = exportacao.NovoXLSX(w, nome)
+	} else {
+		escritor, err = exportacao.NovoCSV(w, opcoes.separador, opcoes.bom)
+	}
+	if err != nil {
+		log.Printf("Erro ao iniciar exportação de %s: %v", nome, err)
+		return
+	}
+
+	// A partir daqui a resposta já começou; falhas só podem ser registradas no log
+	if err := escritor.Escrever(cabecalho...); err != nil {
+		log.Printf("Erro ao exportar %s: %v", nome, err)
+		return
+	}
+
+	flusher, _ := w.(http.Flusher)
+	total := 0
+	for rows.Next() {
+		valores, err := linha()
+		if err != nil {
+			log.Printf("Erro ao ler linha da exportação de %s: %v", nome, err)
+			return
+		}
+		if err := escritor.Escrever(valores...); err != nil {
+			log.Printf("Erro ao exportar %s: %v", nome, err)
+			return
+		}
+
+		total++
+		if flusher != nil && total%linhasPorEnvio == 0 {
+			flusher.Flush()
+		}
+	}
+	if err := rows.Err(); err != nil {
+		log.Printf("Erro ao percorrer exportação de %s: %v", nome, err)
+		return
+	}
+
+	if err := escritor.Fechar(); err != nil {
+		log.Printf("Erro ao finalizar exportação de %s: %v", nome, err)
+	}
+}
+
+// textoNulo converte um texto opcional em célula (vazia quando nulo)
+func textoNulo(s sql.NullString) interface{} {
+	if !s.Valid {
+		return nil
+	}
+	return s.String
+}
+
+// dataNula converte uma data opcional em célula (vazia quando nula)
+func dataNula(t sql.NullTime) interface{} {
+	if !t.Valid {
+		return nil
+	}
+	return t.Time
+}
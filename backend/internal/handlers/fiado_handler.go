@@ -0,0 +1,505 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tassyosilva/GestGAS/internal/middleware"
+	"github.com/tassyosilva/GestGAS/internal/models"
+	"github.com/tassyosilva/GestGAS/internal/pedido"
+	"github.com/tassyosilva/GestGAS/internal/permissao"
+)
+
+// consultaVendaFiada seleciona as vendas fiadas com o valor já pago calculado a partir dos pagamentos
+const consultaVendaFiada = `
+	SELECT v.id, v.pedido_id, v.cliente_id, c.nome, c.telefone,
+	       v.valor_total, COALESCE(pg.total_pago, 0), v.data_vencimento,
+	       v.status, v.data_pagamento, v.criado_em, v.atualizado_em
+	FROM vendas_fiadas v
+	JOIN clientes c ON v.cliente_id = c.id
+	LEFT JOIN (
+		SELECT venda_fiada_id, SUM(valor) AS total_pago
+		FROM pagamentos_fiado
+		GROUP BY venda_fiada_id
+	) pg ON pg.venda_fiada_id = v.id
+`
+
+// ListarFiadosHandler retorna as vendas fiadas com filtros por cliente, status e vencimento
+func ListarFiadosHandler(db *sql.DB) http.HandlerFunc {
+	return middleware.RequerPermissao(permissao.FiadoConsultar)(func(w http.ResponseWriter, r *http.Request) {
+		// Verificar se o usuário está autenticado
+		_, ok := middleware.ObterUsuarioID(r)
+		if !ok {
+			http.Error(w, "Usuário não autenticado", http.StatusUnauthorized)
+			return
+		}
+
+		// Configurar cabeçalhos
+		w.Header().Set("Content-Type", "application/json")
+
+		// Verificar método
+		if r.Method != http.MethodGet {
+			http.Error(w, "Método não permitido", http.StatusMethodNotAllowed)
+			return
+		}
+
+		// Parâmetros de consulta
+		query := r.URL.Query()
+		page, _ := strconv.Atoi(query.Get("page"))
+		limit, _ := strconv.Atoi(query.Get("limit"))
+		clienteID := query.Get("cliente_id")
+		status := query.Get("status")
+		vencidos := query.Get("vencidos") == "true"
+
+		if page < 1 {
+			page = 1
+		}
+		if limit < 1 || limit > 100 {
+			limit = 20
+		}
+		offset := (page - 1) * limit
+
+		var params []interface{}
+		var whereConditions []string
+
+		// Por padrão, apenas as contas em aberto são listadas
+		switch status {
+		case "", "aberto":
+			whereConditions = append(whereConditions, "v.status <> 'pago'")
+		case "todos":
+		case string(models.FiadoPendente), string(models.FiadoParcial), string(models.FiadoPago):
+			whereConditions = append(whereConditions, fmt.Sprintf("v.status = $%d", len(params)+1))
+			params = append(params, status)
+		default:
+			http.Error(w, "Status inválido", http.StatusBadRequest)
+			return
+		}
+		if clienteID != "" {
+			id, err := strconv.Atoi(clienteID)
+			if err != nil {
+				http.Error(w, "ID do cliente inválido", http.StatusBadRequest)
+				return
+			}
+			whereConditions = append(whereConditions, fmt.Sprintf("v.cliente_id = $%d", len(params)+1))
+			params = append(params, id)
+		}
+		if vencidos {
+			whereConditions = append(whereConditions, "v.status <> 'pago' AND v.data_vencimento < NOW()")
+		}
+
+		where := ""
+		if len(whereConditions) > 0 {
+			where = " WHERE " + strings.Join(whereConditions, " AND ")
+		}
+
+		sqlQuery := consultaVendaFiada + where +
+			" ORDER BY v.data_vencimento ASC LIMIT $" + strconv.Itoa(len(params)+1) + " OFFSET $" + strconv.Itoa(len(params)+2)
+
+		// Executar consulta
+		rows, err := db.Query(sqlQuery, append(params, limit, offset)...)
+		if err != nil {
+			http.Error(w, "Erro ao buscar vendas fiadas: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		vendas := []models.VendaFiada{}
+		for rows.Next() {
+			v, err := escanearVendaFiada(rows)
+			if err != nil {
+				http.Error(w, "Erro ao processar vendas fiadas: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+			vendas = append(vendas, v)
+		}
+
+		// Contar total de registros para paginação
+		var total int
+		err = db.QueryRow("SELECT COUNT(*) FROM vendas_fiadas v"+where, params...).Scan(&total)
+		if err != nil {
+			http.Error(w, "Erro ao contar vendas fiadas: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		// Montar resposta
+		response := struct {
+			Fiados []models.VendaFiada `json:"fiados"`
+			Total  int                 `json:"total"`
+			Page   int                 `json:"page"`
+			Limit  int                 `json:"limit"`
+			Pages  int                 `json:"pages"`
+		}{
+			Fiados: vendas,
+			Total:  total,
+			Page:   page,
+			Limit:  limit,
+			Pages:  (total + limit - 1) / limit,
+		}
+
+		// Retornar resposta
+		json.NewEncoder(w).Encode(response)
+	})
+}
+
+// ObterFiadoHandler retorna uma venda fiada com o histórico de pagamentos
+func ObterFiadoHandler(db *sql.DB) http.HandlerFunc {
+	return middleware.RequerPermissao(permissao.FiadoConsultar)(func(w http.ResponseWriter, r *http.Request) {
+		// Verificar se o usuário está autenticado
+		_, ok := middleware.ObterUsuarioID(r)
+		if !ok {
+			http.Error(w, "Usuário não autenticado", http.StatusUnauthorized)
+			return
+		}
+
+		// Configurar cabeçalhos
+		w.Header().Set("Content-Type", "application/json")
+
+		// Verificar método
+		if r.Method != http.MethodGet {
+			http.Error(w, "Método não permitido", http.StatusMethodNotAllowed)
+			return
+		}
+
+		// Extrair ID da venda fiada da URL
+		parts := strings.Split(r.URL.Path, "/")
+		if len(parts) < 4 {
+			http.Error(w, "ID da venda fiada não fornecido", http.StatusBadRequest)
+			return
+		}
+		fiadoID, err := strconv.Atoi(parts[3])
+		if err != nil {
+			http.Error(w, "ID da venda fiada inválido", http.StatusBadRequest)
+			return
+		}
+
+		venda, err := buscarVendaFiada(db, fiadoID)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				http.Error(w, "Venda fiada não encontrada", http.StatusNotFound)
+				return
+			}
+			http.Error(w, "Erro ao buscar venda fiada: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		// Retornar resposta
+		json.NewEncoder(w).Encode(venda)
+	})
+}
+
+// RegistrarPagamentoFiadoHandler registra um pagamento total ou parcial de uma venda fiada
+func RegistrarPagamentoFiadoHandler(db *sql.DB) http.HandlerFunc {
+	return middleware.RequerPermissao(permissao.FiadoReceber)(func(w http.ResponseWriter, r *http.Request) {
+		// Verificar se o usuário está autenticado
+		userID, ok := middleware.ObterUsuarioID(r)
+		if !ok {
+			http.Error(w, "Usuário não autenticado", http.StatusUnauthorized)
+			return
+		}
+
+		// Configurar cabeçalhos
+		w.Header().Set("Content-Type", "application/json")
+
+		// Verificar método
+		if r.Method != http.MethodPost {
+			http.Error(w, "Método não permitido", http.StatusMethodNotAllowed)
+			return
+		}
+
+		// Extrair ID da venda fiada da URL (/api/fiados/{id}/pagamentos)
+		parts := strings.Split(r.URL.Path, "/")
+		if len(parts) < 5 || parts[4] != "pagamentos" {
+			http.Error(w, "URL inválida", http.StatusBadRequest)
+			return
+		}
+		fiadoID, err := strconv.Atoi(parts[3])
+		if err != nil {
+			http.Error(w, "ID da venda fiada inválido", http.StatusBadRequest)
+			return
+		}
+
+		// Decodificar requisição
+		var req models.NovoPagamentoFiadoRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Erro ao processar requisição: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// Validar dados
+		req.Valor = arredondarCentavos(req.Valor)
+		if req.Valor <= 0 {
+			http.Error(w, "Valor deve ser maior que zero", http.StatusBadRequest)
+			return
+		}
+		switch req.FormaPagamento {
+		case models.PagamentoDinheiro, models.PagamentoPix, models.PagamentoCartaoDebito, models.PagamentoCartaoCredito:
+		default:
+			http.Error(w, "Forma de pagamento inválida", http.StatusBadRequest)
+			return
+		}
+
+		// Iniciar transação
+		tx, err := db.Begin()
+		if err != nil {
+			http.Error(w, "Erro ao iniciar transação: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback() // sem efeito após o commit
+
+		// Bloquear a venda fiada para evitar pagamentos simultâneos acima do saldo
+		var valorTotal float64
+		var status models.StatusVendaFiada
+		err = tx.QueryRow("SELECT valor_total, status FROM vendas_fiadas WHERE id = $1 FOR UPDATE", fiadoID).Scan(&valorTotal, &status)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				http.Error(w, "Venda fiada não encontrada", http.StatusNotFound)
+				return
+			}
+			http.Error(w, "Erro ao buscar venda fiada: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if status == models.FiadoPago {
+			http.Error(w, "Venda fiada já está quitada", http.StatusBadRequest)
+			return
+		}
+
+		var valorPago float64
+		err = tx.QueryRow("SELECT COALESCE(SUM(valor), 0) FROM pagamentos_fiado WHERE venda_fiada_id = $1", fiadoID).Scan(&valorPago)
+		if err != nil {
+			http.Error(w, "Erro ao calcular saldo: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		saldo := arredondarCentavos(valorTotal - valorPago)
+		if req.Valor > saldo {
+			http.Error(w, fmt.Sprintf("Valor excede o saldo devedor de %.2f", saldo), http.StatusBadRequest)
+			return
+		}
+
+		// O pagamento entra no caixa aberto de quem recebeu, se houver
+		caixaID, err := pedido.CaixaAberto(tx, userID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		// Registrar pagamento
+		_, err = tx.Exec(`
+			INSERT INTO pagamentos_fiado
+			(venda_fiada_id, valor, forma_pagamento, observacoes, usuario_id, caixa_id, criado_em)
+			VALUES
+			($1, $2, $3, NULLIF($4, ''), $5, $6, NOW())
+		`, fiadoID, req.Valor, req.FormaPagamento, req.Observacoes, userID, caixaID)
+		if err != nil {
+			http.Error(w, "Erro ao registrar pagamento: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		// Atualizar status da venda fiada
+		if arredondarCentavos(saldo-req.Valor) <= 0 {
+			_, err = tx.Exec(`
+				UPDATE vendas_fiadas
+				SET status = $1, data_pagamento = NOW(), atualizado_em = NOW()
+				WHERE id = $2
+			`, models.FiadoPago, fiadoID)
+		} else {
+			_, err = tx.Exec(`
+				UPDATE vendas_fiadas
+				SET status = $1, atualizado_em = NOW()
+				WHERE id = $2
+			`, models.FiadoParcial, fiadoID)
+		}
+		if err != nil {
+			http.Error(w, "Erro ao atualizar venda fiada: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		// Commit da transação
+		err = tx.Commit()
+		if err != nil {
+			http.Error(w, "Erro ao finalizar transação: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		// Buscar venda fiada atualizada para resposta
+		venda, err := buscarVendaFiada(db, fiadoID)
+		if err != nil {
+			http.Error(w, "Pagamento registrado, mas erro ao buscar detalhes: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		// Retornar resposta
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(venda)
+	})
+}
+
+// ListarSaldosFiadoHandler retorna o saldo devedor consolidado por cliente
+func ListarSaldosFiadoHandler(db *sql.DB) http.HandlerFunc {
+	return middleware.RequerPermissao(permissao.FiadoConsultar)(func(w http.ResponseWriter, r *http.Request) {
+		// Verificar se o usuário está autenticado
+		_, ok := middleware.ObterUsuarioID(r)
+		if !ok {
+			http.Error(w, "Usuário não autenticado", http.StatusUnauthorized)
+			return
+		}
+
+		// Configurar cabeçalhos
+		w.Header().Set("Content-Type", "application/json")
+
+		// Verificar método
+		if r.Method != http.MethodGet {
+			http.Error(w, "Método não permitido", http.StatusMethodNotAllowed)
+			return
+		}
+
+		// Filtrar apenas clientes com contas vencidas, se solicitado
+		having := ""
+		if r.URL.Query().Get("vencidos") == "true" {
+			having = " HAVING COUNT(*) FILTER (WHERE f.data_vencimento < NOW()) > 0"
+		}
+
+		rows, err := db.Query(`
+			SELECT f.cliente_id, f.nome, f.telefone,
+			       SUM(f.saldo),
+			       COALESCE(SUM(f.saldo) FILTER (WHERE f.data_vencimento < NOW()), 0),
+			       COUNT(*),
+			       COUNT(*) FILTER (WHERE f.data_vencimento < NOW()),
+			       MIN(f.data_vencimento)
+			FROM (
+				SELECT v.cliente_id, c.nome, c.telefone, v.data_vencimento,
+				       v.valor_total - COALESCE((
+				           SELECT SUM(pf.valor) FROM pagamentos_fiado pf WHERE pf.venda_fiada_id = v.id
+				       ), 0) AS saldo
+				FROM vendas_fiadas v
+				JOIN clientes c ON v.cliente_id = c.id
+				WHERE v.status <> 'pago'
+			) f
+			GROUP BY f.cliente_id, f.nome, f.telefone` + having + `
+			ORDER BY 5 DESC, 4 DESC
+		`)
+		if err != nil {
+			http.Error(w, "Erro ao buscar saldos de clientes: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		saldos := []models.SaldoFiadoCliente{}
+		for rows.Next() {
+			var s models.SaldoFiadoCliente
+			var proximoVencimento sql.NullTime
+			err := rows.Scan(
+				&s.Cliente.ID, &s.Cliente.Nome, &s.Cliente.Telefone,
+				&s.SaldoAberto, &s.SaldoVencido, &s.ContasAbertas, &s.ContasVencidas, &proximoVencimento,
+			)
+			if err != nil {
+				http.Error(w, "Erro ao processar saldos de clientes: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+			if proximoVencimento.Valid {
+				s.ProximoVencimento = &proximoVencimento.Time
+			}
+			s.SaldoAberto = arredondarCentavos(s.SaldoAberto)
+			s.SaldoVencido = arredondarCentavos(s.SaldoVencido)
+			saldos = append(saldos, s)
+		}
+
+		// Retornar resposta
+		json.NewEncoder(w).Encode(saldos)
+	})
+}
+
+// buscarSaldoFiadoCliente retorna o saldo em aberto e o saldo vencido de um cliente
+func buscarSaldoFiadoCliente(db *sql.DB, clienteID int) (float64, float64, error) {
+	var aberto, vencido float64
+	err := db.QueryRow(`
+		SELECT COALESCE(SUM(f.saldo), 0),
+		       COALESCE(SUM(f.saldo) FILTER (WHERE f.data_vencimento < NOW()), 0)
+		FROM (
+			SELECT v.data_vencimento,
+			       v.valor_total - COALESCE((
+			           SELECT SUM(pf.valor) FROM pagamentos_fiado pf WHERE pf.venda_fiada_id = v.id
+			       ), 0) AS saldo
+			FROM vendas_fiadas v
+			WHERE v.cliente_id = $1 AND v.status <> 'pago'
+		) f
+	`, clienteID).Scan(&aberto, &vencido)
+	if err != nil {
+		return 0, 0, err
+	}
+	return arredondarCentavos(aberto), arredondarCentavos(vencido), nil
+}
+
+// buscarVendaFiada busca uma venda fiada com seus pagamentos
+func buscarVendaFiada(db *sql.DB, fiadoID int) (models.VendaFiada, error) {
+	venda, err := escanearVendaFiada(db.QueryRow(consultaVendaFiada+" WHERE v.id = $1", fiadoID))
+	if err != nil {
+		return venda, err
+	}
+
+	rows, err := db.Query(`
+		SELECT id, venda_fiada_id, valor, forma_pagamento, observacoes, usuario_id, caixa_id, criado_em
+		FROM pagamentos_fiado
+		WHERE venda_fiada_id = $1
+		ORDER BY criado_em
+	`, fiadoID)
+	if err != nil {
+		return venda, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var p models.PagamentoVendaFiada
+		var observacoes sql.NullString
+		var caixaID sql.NullInt64
+		err := rows.Scan(&p.ID, &p.VendaFiadaID, &p.Valor, &p.FormaPagamento, &observacoes, &p.UsuarioID, &caixaID, &p.CriadoEm)
+		if err != nil {
+			return venda, err
+		}
+		if observacoes.Valid {
+			p.Observacoes = observacoes.String
+		}
+		if caixaID.Valid {
+			id := int(caixaID.Int64)
+			p.CaixaID = &id
+		}
+		venda.Pagamentos = append(venda.Pagamentos, p)
+	}
+	return venda, rows.Err()
+}
+
+// escanearVendaFiada converte uma linha de consultaVendaFiada em models.VendaFiada
+func escanearVendaFiada(row interface{ Scan(...interface{}) error }) (models.VendaFiada, error) {
+	var v models.VendaFiada
+	var cliente models.ClienteBasico
+	var dataPagamento sql.NullTime
+
+	err := row.Scan(
+		&v.ID, &v.PedidoID, &v.ClienteID, &cliente.Nome, &cliente.Telefone,
+		&v.ValorTotal, &v.ValorPago, &v.DataVencimento,
+		&v.Status, &dataPagamento, &v.CriadoEm, &v.AtualizadoEm,
+	)
+	if err != nil {
+		return v, err
+	}
+
+	cliente.ID = v.ClienteID
+	v.Cliente = &cliente
+	if dataPagamento.Valid {
+		v.DataPagamento = &dataPagamento.Time
+	}
+	v.ValorPago = arredondarCentavos(v.ValorPago)
+	v.Saldo = arredondarCentavos(v.ValorTotal - v.ValorPago)
+	v.Vencido = v.Status != models.FiadoPago && v.DataVencimento.Before(time.Now())
+	return v, nil
+}
+
+// arredondarCentavos arredonda um valor monetário para duas casas decimais
+func arredondarCentavos(valor float64) float64 {
+	return math.Round(valor*100) / 100
+}
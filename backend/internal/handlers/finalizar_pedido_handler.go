@@ -7,17 +7,20 @@ import (
 	"strconv"
 
 	"github.com/tassyosilva/GestGAS/internal/middleware"
+	"github.com/tassyosilva/GestGAS/internal/models"
+	"github.com/tassyosilva/GestGAS/internal/pedido"
 )
 
-// FinalizarPedidoHandler manipula a finalização de um pedido sem processamento adicional
+// FinalizarPedidoHandler manipula a finalização de um pedido entregue
 func FinalizarPedidoHandler(db *sql.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// Verificar se o usuário está autenticado
-		_, ok := middleware.ObterUsuarioID(r)
+		userID, ok := middleware.ObterUsuarioID(r)
 		if !ok {
 			http.Error(w, "Usuário não autenticado", http.StatusUnauthorized)
 			return
 		}
+		perfil, _ := middleware.ObterPerfilUsuario(r)
 
 		// Configurar cabeçalhos
 		w.Header().Set("Content-Type", "application/json")
@@ -44,32 +47,15 @@ func FinalizarPedidoHandler(db *sql.DB) http.HandlerFunc {
 			return
 		}
 
-		// Verificar se o pedido existe e está no status 'entregue'
-		var status string
-		err := db.QueryRow("SELECT status FROM pedidos WHERE id = $1", req.PedidoID).Scan(&status)
-		if err != nil {
-			if err == sql.ErrNoRows {
-				http.Error(w, "Pedido não encontrado", http.StatusNotFound)
-				return
-			}
-			http.Error(w, "Erro ao verificar pedido: "+err.Error(), http.StatusInternalServerError)
-			return
-		}
-
-		if status != "entregue" {
-			http.Error(w, "Apenas pedidos com status 'entregue' podem ser finalizados", http.StatusBadRequest)
-			return
-		}
-
-		// Atualizar apenas o status para 'finalizado'
-		_, err = db.Exec(`
-            UPDATE pedidos
-            SET status = 'finalizado', atualizado_em = NOW()
-            WHERE id = $1
-        `, req.PedidoID)
-		
+		// Finalizar pelo serviço de pedidos (gera a conta a receber dos pedidos fiados)
+		err := pedido.AlterarStatus(db, pedido.Alteracao{
+			PedidoID:  req.PedidoID,
+			UsuarioID: userID,
+			Perfil:    perfil,
+			Status:    models.StatusFinalizado,
+		})
 		if err != nil {
-			http.Error(w, "Erro ao finalizar pedido: "+err.Error(), http.StatusInternalServerError)
+			responderErroPedido(w, err)
 			return
 		}
 
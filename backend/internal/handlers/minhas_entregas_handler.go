@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/tassyosilva/GestGAS/internal/middleware"
+	"github.com/tassyosilva/GestGAS/internal/models"
+	"github.com/tassyosilva/GestGAS/internal/pedido"
+	"github.com/tassyosilva/GestGAS/internal/permissao"
+)
+
+// MinhasEntregasHandler lista os pedidos atribuídos ao entregador autenticado.
+// Sem filtro, retorna as entregas em aberto (em preparo e em entrega).
+func MinhasEntregasHandler(db *sql.DB) http.HandlerFunc {
+	return middleware.RequerPermissao(permissao.EntregaRealizar)(func(w http.ResponseWriter, r *http.Request) {
+		// Verificar se o usuário está autenticado
+		userID, ok := middleware.ObterUsuarioID(r)
+		if !ok {
+			http.Error(w, "Usuário não autenticado", http.StatusUnauthorized)
+			return
+		}
+
+		// Configurar cabeçalhos
+		w.Header().Set("Content-Type", "application/json")
+
+		// Verificar método
+		if r.Method != http.MethodGet {
+			http.Error(w, "Método não permitido", http.StatusMethodNotAllowed)
+			return
+		}
+
+		// Filtro opcional de status
+		status := r.URL.Query().Get("status")
+		var rows *sql.Rows
+		var err error
+		if status != "" {
+			rows, err = db.Query(`
+				SELECT id FROM pedidos
+				WHERE entregador_id = $1 AND status = $2
+				ORDER BY criado_em DESC
+				LIMIT 100
+			`, userID, status)
+		} else {
+			rows, err = db.Query(`
+				SELECT id FROM pedidos
+				WHERE entregador_id = $1 AND status IN ($2, $3)
+				ORDER BY criado_em
+			`, userID, models.StatusEmPreparo, models.StatusEmEntrega)
+		}
+		if err != nil {
+			http.Error(w, "Erro ao buscar entregas: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		var ids []int
+		for rows.Next() {
+			var id int
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				http.Error(w, "Erro ao processar entregas: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+			ids = append(ids, id)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			http.Error(w, "Erro ao processar entregas: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		// Montar os detalhes de cada pedido (itens, endereço e cliente)
+		entregas := []models.PedidoResponse{}
+		for _, id := range ids {
+			pedidoResp, err := buscarPedidoDetalhado(db, id)
+			if err != nil {
+				http.Error(w, "Erro ao buscar detalhes da entrega: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+			entregas = append(entregas, pedidoResp)
+		}
+
+		json.NewEncoder(w).Encode(entregas)
+	})
+}
+
+// AcaoMinhaEntregaHandler executa as ações do entregador sobre um pedido atribuído a ele:
+// POST /api/minhas-entregas/{id}/aceitar, /iniciar, /entregar ou /falha
+func AcaoMinhaEntregaHandler(db *sql.DB) http.HandlerFunc {
+	return middleware.RequerPermissao(permissao.EntregaRealizar)(func(w http.ResponseWriter, r *http.Request) {
+		// Verificar se o usuário está autenticado
+		userID, ok := middleware.ObterUsuarioID(r)
+		if !ok {
+			http.Error(w, "Usuário não autenticado", http.StatusUnauthorized)
+			return
+		}
+
+		// Configurar cabeçalhos
+		w.Header().Set("Content-Type", "application/json")
+
+		// Verificar método
+		if r.Method != http.MethodPost {
+			http.Error(w, "Método não permitido", http.StatusMethodNotAllowed)
+			return
+		}
+
+		// Extrair ID do pedido e ação da URL
+		parts := strings.Split(r.URL.Path, "/")
+		if len(parts) != 5 {
+			http.Error(w, "Rota não encontrada", http.StatusNotFound)
+			return
+		}
+		pedidoID, err := strconv.Atoi(parts[3])
+		if err != nil {
+			http.Error(w, "ID do pedido inválido", http.StatusBadRequest)
+			return
+		}
+
+		perfil, _ := middleware.ObterPerfilUsuario(r)
+		alteracao := pedido.Alteracao{PedidoID: pedidoID, UsuarioID: userID, Perfil: perfil}
+		switch parts[4] {
+		case "aceitar":
+			err = pedido.AceitarEntrega(db, pedidoID, userID)
+		case "iniciar":
+			alteracao.Status = models.StatusEmEntrega
+			err = pedido.AlterarStatus(db, alteracao)
+		case "entregar":
+			alteracao.Status = models.StatusEntregue
+			err = pedido.AlterarStatus(db, alteracao)
+		case "falha":
+			var req struct {
+				Motivo string `json:"motivo"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, "Erro ao processar requisição: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			alteracao.Status = models.StatusEmPreparo
+			alteracao.Motivo = strings.TrimSpace(req.Motivo)
+			err = pedido.AlterarStatus(db, alteracao)
+		default:
+			http.Error(w, "Ação inválida", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			responderErroPedido(w, err)
+			return
+		}
+
+		// Após a falha o pedido deixa de ser do entregador; não expor mais os detalhes
+		if parts[4] == "falha" {
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"mensagem":  "Falha na entrega registrada",
+				"pedido_id": pedidoID,
+			})
+			return
+		}
+
+		// Buscar pedido atualizado para resposta
+		pedidoResp, err := buscarPedidoDetalhado(db, pedidoID)
+		if err != nil {
+			http.Error(w, "Ação concluída, mas erro ao buscar detalhes: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(pedidoResp)
+	})
+}
@@ -3,14 +3,19 @@ package handlers
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"sort"
 	"strconv"
 	"strings"
-	"time"
 
+	"github.com/tassyosilva/GestGAS/internal/eventos"
+	"github.com/tassyosilva/GestGAS/internal/conciliacao"
 	"github.com/tassyosilva/GestGAS/internal/middleware"
 	"github.com/tassyosilva/GestGAS/internal/models"
+	"github.com/tassyosilva/GestGAS/internal/pedido"
+	"github.com/tassyosilva/GestGAS/internal/permissao"
 )
 
 // ListarPedidosHandler retorna a lista de pedidos com paginação e filtros
@@ -18,7 +23,7 @@ func ListarPedidosHandler(db *sql.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		fmt.Println("Iniciando ListarPedidosHandler")
 		// Verificar se o usuário está autenticado
-		_, ok := middleware.ObterUsuarioID(r)
+		userID, ok := middleware.ObterUsuarioID(r)
 		if !ok {
 			fmt.Println("ERRO: Usuário não autenticado")
 			http.Error(w, "Usuário não autenticado", http.StatusUnauthorized)
@@ -41,11 +46,7 @@ func ListarPedidosHandler(db *sql.DB) http.HandlerFunc {
 		query := r.URL.Query()
 		page, _ := strconv.Atoi(query.Get("page"))
 		limit, _ := strconv.Atoi(query.Get("limit"))
-		status := query.Get("status")
-		clienteID := query.Get("cliente_id")
-		dataInicio := query.Get("data_inicio")
-		dataFim := query.Get("data_fim")
-		fmt.Println("Parâmetros de consulta:", "page=", page, "limit=", limit, "status=", status, "cliente_id=", clienteID, "data_inicio=", dataInicio, "data_fim=", dataFim)
+		fmt.Println("Parâmetros de consulta:", "page=", page, "limit=", limit)
 
 		if page < 1 {
 			page = 1
@@ -61,40 +62,14 @@ func ListarPedidosHandler(db *sql.DB) http.HandlerFunc {
 			SELECT p.id, p.cliente_id, c.nome AS cliente_nome, c.telefone AS cliente_telefone, 
 			       p.atendente_id, p.entregador_id, p.status, 
 			       p.forma_pagamento, p.valor_total, p.observacoes, p.endereco_entrega, 
-				   p.canal_origem, p.data_entrega, p.criado_em, p.atualizado_em
+				   p.canal_origem, p.data_entrega, p.criado_em, p.atualizado_em, p.aceito_em,
+			       `+conciliacao.StatusPedidoSQL+`
 			FROM pedidos p
 			JOIN clientes c ON p.cliente_id = c.id
 			WHERE 1=1
 		`
-		var params []interface{}
-		var whereConditions []string
-
-		// Adicionar filtros se fornecidos
-		if status != "" {
-			whereConditions = append(whereConditions, "p.status = $"+strconv.Itoa(len(params)+1))
-			params = append(params, status)
-			fmt.Println("Adicionado filtro de status:", status)
-		}
-		if clienteID != "" {
-			id, err := strconv.Atoi(clienteID)
-			if err == nil {
-				whereConditions = append(whereConditions, "p.cliente_id = $"+strconv.Itoa(len(params)+1))
-				params = append(params, id)
-				fmt.Println("Adicionado filtro de cliente_id:", id)
-			} else {
-				fmt.Println("AVISO: cliente_id não é um número válido:", clienteID)
-			}
-		}
-		if dataInicio != "" {
-			whereConditions = append(whereConditions, "p.criado_em >= $"+strconv.Itoa(len(params)+1))
-			params = append(params, dataInicio)
-			fmt.Println("Adicionado filtro de data_inicio:", dataInicio)
-		}
-		if dataFim != "" {
-			whereConditions = append(whereConditions, "p.criado_em <= $"+strconv.Itoa(len(params)+1))
-			params = append(params, dataFim)
-			fmt.Println("Adicionado filtro de data_fim:", dataFim)
-		}
+		// Filtros (os mesmos usados na exportação de pedidos)
+		whereConditions, params := filtrosPedidos(r, userID)
 
 		// Adicionar condições WHERE
 		if len(whereConditions) > 0 {
@@ -126,7 +101,7 @@ func ListarPedidosHandler(db *sql.DB) http.HandlerFunc {
 		for rows.Next() {
 			var p models.Pedido
 			var entregadorID sql.NullInt64
-			var dataEntrega sql.NullTime
+			var dataEntrega, aceitoEm sql.NullTime
 			var canalOrigem sql.NullString
 			var clienteNome string
 			var clienteTelefone string
@@ -136,7 +111,8 @@ func ListarPedidosHandler(db *sql.DB) http.HandlerFunc {
 				&p.ID, &p.ClienteID, &clienteNome, &clienteTelefone,
 				&p.AtendenteID, &entregadorID, &p.Status,
 				&p.FormaPagamento, &p.ValorTotal, &observacoes, &p.EnderecoEntrega,
-				&canalOrigem, &dataEntrega, &p.CriadoEm, &p.AtualizadoEm,
+				&canalOrigem, &dataEntrega, &p.CriadoEm, &p.AtualizadoEm, &aceitoEm,
+				&p.StatusConciliacao,
 			)
 			if err != nil {
 				fmt.Println("ERRO ao processar pedido:", err)
@@ -152,6 +128,9 @@ func ListarPedidosHandler(db *sql.DB) http.HandlerFunc {
 			if dataEntrega.Valid {
 				p.DataEntrega = &dataEntrega.Time
 			}
+			if aceitoEm.Valid {
+				p.AceitoEm = &aceitoEm.Time
+			}
 			if canalOrigem.Valid {
 				p.CanalOrigem = models.CanalOrigem(canalOrigem.String)
 			}
@@ -221,12 +200,68 @@ func ListarPedidosHandler(db *sql.DB) http.HandlerFunc {
 	}
 }
 
+// filtrosPedidos monta as condições WHERE (sobre o alias p) a partir dos parâmetros
+// status, cliente_id, data_inicio, data_fim e status_conciliacao. Entregadores só enxergam os pedidos atribuídos a eles.
+func filtrosPedidos(r *http.Request, userID int) ([]string, []interface{}) {
+	query := r.URL.Query()
+	status := query.Get("status")
+	clienteID := query.Get("cliente_id")
+	dataInicio := query.Get("data_inicio")
+	dataFim := query.Get("data_fim")
+	statusConciliacao := query.Get("status_conciliacao")
+	fmt.Println("Filtros de pedidos:", "status=", status, "cliente_id=", clienteID, "data_inicio=", dataInicio, "data_fim=", dataFim, "status_conciliacao=", statusConciliacao)
+
+	var params []interface{}
+	var whereConditions []string
+
+	// Adicionar filtros se fornecidos
+	if status != "" {
+		whereConditions = append(whereConditions, "p.status = $"+strconv.Itoa(len(params)+1))
+		params = append(params, status)
+		fmt.Println("Adicionado filtro de status:", status)
+	}
+	if clienteID != "" {
+		id, err := strconv.Atoi(clienteID)
+		if err == nil {
+			whereConditions = append(whereConditions, "p.cliente_id = $"+strconv.Itoa(len(params)+1))
+			params = append(params, id)
+			fmt.Println("Adicionado filtro de cliente_id:", id)
+		} else {
+			fmt.Println("AVISO: cliente_id não é um número válido:", clienteID)
+		}
+	}
+	if dataInicio != "" {
+		whereConditions = append(whereConditions, "p.criado_em >= $"+strconv.Itoa(len(params)+1))
+		params = append(params, dataInicio)
+		fmt.Println("Adicionado filtro de data_inicio:", dataInicio)
+	}
+	if dataFim != "" {
+		whereConditions = append(whereConditions, "p.criado_em <= $"+strconv.Itoa(len(params)+1))
+		params = append(params, dataFim)
+		fmt.Println("Adicionado filtro de data_fim:", dataFim)
+	}
+	if statusConciliacao != "" {
+		whereConditions = append(whereConditions, conciliacao.StatusPedidoSQL+" = $"+strconv.Itoa(len(params)+1))
+		params = append(params, statusConciliacao)
+		fmt.Println("Adicionado filtro de status_conciliacao:", statusConciliacao)
+	}
+
+	// Sem permissão para consultar todos, o usuário só enxerga os pedidos atribuídos a ele
+	if !middleware.PossuiPermissao(r, permissao.PedidoConsultarTodos) {
+		whereConditions = append(whereConditions, "p.entregador_id = $"+strconv.Itoa(len(params)+1))
+		params = append(params, userID)
+		fmt.Println("Adicionado filtro de entregador_id:", userID)
+	}
+
+	return whereConditions, params
+}
+
 // ObterPedidoHandler retorna detalhes de um pedido específico
 func ObterPedidoHandler(db *sql.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		fmt.Println("Iniciando ObterPedidoHandler")
 		// Verificar se o usuário está autenticado
-		_, ok := middleware.ObterUsuarioID(r)
+		userID, ok := middleware.ObterUsuarioID(r)
 		if !ok {
 			fmt.Println("ERRO: Usuário não autenticado")
 			http.Error(w, "Usuário não autenticado", http.StatusUnauthorized)
@@ -275,15 +310,83 @@ func ObterPedidoHandler(db *sql.DB) http.HandlerFunc {
 		}
 		fmt.Println("Pedido encontrado com sucesso ID:", pedidoID)
 
+		// Entregadores só podem consultar os pedidos atribuídos a eles
+		if !pedidoVisivel(r, userID, pedidoResp.Entregador) {
+			fmt.Println("ERRO: Pedido de outro entregador, ID:", pedidoID)
+			http.Error(w, "Pedido não encontrado", http.StatusNotFound)
+			return
+		}
+
 		// Retornar resposta
 		fmt.Println("Retornando detalhes do pedido ID:", pedidoID)
 		json.NewEncoder(w).Encode(pedidoResp)
 	}
 }
 
+// ObterHistoricoPedidoHandler retorna a linha do tempo de status de um pedido
+func ObterHistoricoPedidoHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Verificar se o usuário está autenticado
+		userID, ok := middleware.ObterUsuarioID(r)
+		if !ok {
+			http.Error(w, "Usuário não autenticado", http.StatusUnauthorized)
+			return
+		}
+
+		// Configurar cabeçalhos
+		w.Header().Set("Content-Type", "application/json")
+
+		// Verificar método
+		if r.Method != http.MethodGet {
+			http.Error(w, "Método não permitido", http.StatusMethodNotAllowed)
+			return
+		}
+
+		// Extrair ID do pedido da URL (/api/pedidos/{id}/historico)
+		parts := strings.Split(r.URL.Path, "/")
+		if len(parts) < 5 {
+			http.Error(w, "ID do pedido não fornecido", http.StatusBadRequest)
+			return
+		}
+		pedidoID, err := strconv.Atoi(parts[3])
+		if err != nil {
+			http.Error(w, "ID do pedido inválido", http.StatusBadRequest)
+			return
+		}
+
+		// Verificar se o pedido existe e está visível para o usuário
+		var entregadorID sql.NullInt64
+		err = db.QueryRow("SELECT entregador_id FROM pedidos WHERE id = $1", pedidoID).Scan(&entregadorID)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				http.Error(w, "Pedido não encontrado", http.StatusNotFound)
+				return
+			}
+			http.Error(w, "Erro ao buscar pedido: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		var entregador *models.UsuarioBasico
+		if entregadorID.Valid {
+			entregador = &models.UsuarioBasico{ID: int(entregadorID.Int64)}
+		}
+		if !pedidoVisivel(r, userID, entregador) {
+			http.Error(w, "Pedido não encontrado", http.StatusNotFound)
+			return
+		}
+
+		historico, err := pedido.BuscarHistorico(db, pedidoID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		json.NewEncoder(w).Encode(historico)
+	}
+}
+
 // CriarPedidoHandler cria um novo pedido
 func CriarPedidoHandler(db *sql.DB) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
+	return middleware.RequerPermissao(permissao.PedidoCriar)(func(w http.ResponseWriter, r *http.Request) {
 		fmt.Println("Iniciando CriarPedidoHandler")
 		// Verificar se o usuário está autenticado
 		userID, ok := middleware.ObterUsuarioID(r)
@@ -294,16 +397,6 @@ func CriarPedidoHandler(db *sql.DB) http.HandlerFunc {
 		}
 		fmt.Println("Usuário autenticado com sucesso, ID:", userID)
 
-		// Verificar permissões (apenas atendentes ou admins podem criar pedidos)
-		fmt.Println("Verificando permissões do usuário ID:", userID)
-		perfil, perfilOk := middleware.ObterPerfilUsuario(r)
-		if !perfilOk || !middleware.VerificarPerfil(perfil, "atendente") {
-			fmt.Println("ERRO: Usuário sem permissão, ID:", userID)
-			http.Error(w, "Sem permissão para criar pedidos", http.StatusForbidden)
-			return
-		}
-		fmt.Println("Usuário tem permissão para criar pedidos")
-
 		// Configurar cabeçalhos
 		w.Header().Set("Content-Type", "application/json")
 
@@ -352,13 +445,7 @@ func CriarPedidoHandler(db *sql.DB) http.HandlerFunc {
 			http.Error(w, "Erro ao iniciar transação: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
-		defer func() {
-			if err != nil {
-				fmt.Println("Realizando rollback da transação devido a erro")
-				tx.Rollback()
-				return
-			}
-		}()
+		defer tx.Rollback() // sem efeito após o commit
 
 		// Verificar se cliente existe
 		fmt.Println("Verificando se cliente existe, ID:", req.ClienteID)
@@ -382,6 +469,11 @@ func CriarPedidoHandler(db *sql.DB) http.HandlerFunc {
 		fmt.Println("Processando", len(req.Itens), "itens do pedido")
 		for i, item := range req.Itens {
 			fmt.Println("Processando item", i+1, "produto ID:", item.ProdutoID)
+			if item.Quantidade <= 0 {
+				fmt.Println("ERRO: Quantidade inválida para produto ID:", item.ProdutoID)
+				http.Error(w, fmt.Sprintf("Quantidade do produto ID %d deve ser maior que zero", item.ProdutoID), http.StatusBadRequest)
+				return
+			}
 			// Buscar produto
 			var produto struct {
 				ID    int
@@ -403,22 +495,6 @@ func CriarPedidoHandler(db *sql.DB) http.HandlerFunc {
 			}
 			fmt.Println("Produto encontrado:", produto.Nome, "preço:", produto.Preco)
 
-			// Verificar estoque
-			fmt.Println("Verificando estoque para produto ID:", item.ProdutoID)
-			var qtdEstoque int
-			err = tx.QueryRow("SELECT quantidade FROM estoque WHERE produto_id = $1", item.ProdutoID).Scan(&qtdEstoque)
-			if err != nil {
-				fmt.Println("ERRO ao verificar estoque:", err)
-				http.Error(w, "Erro ao verificar estoque: "+err.Error(), http.StatusInternalServerError)
-				return
-			}
-			fmt.Println("Estoque disponível:", qtdEstoque, "unidades. Solicitado:", item.Quantidade)
-			if qtdEstoque < item.Quantidade {
-				fmt.Println("ERRO: Estoque insuficiente para produto", produto.Nome)
-				http.Error(w, fmt.Sprintf("Estoque insuficiente para produto %s", produto.Nome), http.StatusBadRequest)
-				return
-			}
-
 			// Calcular subtotal
 			subtotal := float64(item.Quantidade) * produto.Preco
 			valorTotal += subtotal
@@ -469,35 +545,44 @@ func CriarPedidoHandler(db *sql.DB) http.HandlerFunc {
 				http.Error(w, "Erro ao inserir item do pedido: "+err.Error(), http.StatusInternalServerError)
 				return
 			}
+		}
 
-			// Atualizar estoque
-			fmt.Println("Atualizando estoque para produto ID:", item.ProdutoID)
-			_, err = tx.Exec(`
-				UPDATE estoque
-				SET quantidade = quantidade - $1, atualizado_em = NOW()
-				WHERE produto_id = $2
-			`, item.Quantidade, item.ProdutoID)
+		// Reservar estoque por produto, sempre na mesma ordem, para evitar deadlock entre pedidos simultâneos.
+		// A baixa efetiva acontece na confirmação da entrega.
+		fmt.Println("Reservando estoque para os itens do pedido")
+		quantidadePorProduto := map[int]int{}
+		nomePorProduto := map[int]string{}
+		var produtoIDs []int
+		for _, item := range itensPedido {
+			if _, existe := quantidadePorProduto[item.ProdutoID]; !existe {
+				produtoIDs = append(produtoIDs, item.ProdutoID)
+			}
+			quantidadePorProduto[item.ProdutoID] += item.Quantidade
+			nomePorProduto[item.ProdutoID] = item.NomeProduto
+		}
+		sort.Ints(produtoIDs)
+		for _, produtoID := range produtoIDs {
+			var reservado bool
+			reservado, err = pedido.ReservarEstoque(tx, pedidoID, produtoID, quantidadePorProduto[produtoID])
 			if err != nil {
-				fmt.Println("ERRO ao atualizar estoque:", err)
-				http.Error(w, "Erro ao atualizar estoque: "+err.Error(), http.StatusInternalServerError)
+				fmt.Println("ERRO ao reservar estoque:", err)
+				http.Error(w, err.Error(), http.StatusInternalServerError)
 				return
 			}
-
-			// Registrar movimentação de estoque
-			fmt.Println("Registrando movimentação de estoque para produto ID:", item.ProdutoID)
-			_, err = tx.Exec(`
-				INSERT INTO movimentacoes_estoque
-				(produto_id, tipo, quantidade, usuario_id, pedido_id, criado_em)
-				VALUES
-				($1, $2, $3, $4, $5, NOW())
-			`, item.ProdutoID, models.MovimentacaoSaida, item.Quantidade, userID, pedidoID)
-			if err != nil {
-				fmt.Println("ERRO ao registrar movimentação de estoque:", err)
-				http.Error(w, "Erro ao registrar movimentação de estoque: "+err.Error(), http.StatusInternalServerError)
+			if !reservado {
+				fmt.Println("ERRO: Estoque insuficiente para produto", nomePorProduto[produtoID])
+				http.Error(w, fmt.Sprintf("Estoque insuficiente para produto %s", nomePorProduto[produtoID]), http.StatusBadRequest)
 				return
 			}
 		}
 
+		// Abrir a linha do tempo do pedido
+		if err = pedido.RegistrarCriacao(tx, pedidoID, userID); err != nil {
+			fmt.Println("ERRO ao registrar histórico do pedido:", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
 		// Commit da transação
 		fmt.Println("Realizando commit da transação")
 		err = tx.Commit()
@@ -508,6 +593,9 @@ func CriarPedidoHandler(db *sql.DB) http.HandlerFunc {
 		}
 		fmt.Println("Transação finalizada com sucesso")
 
+		// A reserva pode ter deixado produtos no nível de alerta
+		publicarAlertasEstoque(db, produtoIDs)
+
 		// Buscar pedido completo para resposta
 		fmt.Println("Buscando detalhes completos do pedido para resposta")
 		pedidoResp, err := buscarPedidoDetalhado(db, pedidoID)
@@ -518,11 +606,14 @@ func CriarPedidoHandler(db *sql.DB) http.HandlerFunc {
 		}
 		fmt.Println("Detalhes do pedido obtidos com sucesso")
 
+		// Avisar as telas conectadas
+		eventos.Publicar(eventos.PedidoCriado, pedidoResp)
+
 		// Retornar resposta
 		fmt.Println("Retornando resposta com detalhes do pedido ID:", pedidoID)
 		w.WriteHeader(http.StatusCreated)
 		json.NewEncoder(w).Encode(pedidoResp)
-	}
+	})
 }
 
 // AtualizarStatusPedidoHandler atualiza o status de um pedido
@@ -573,23 +664,6 @@ func AtualizarStatusPedidoHandler(db *sql.DB) http.HandlerFunc {
 		}
 		fmt.Println("ID do pedido extraído da URL:", pedidoID)
 
-		// Verificar se o pedido existe
-		fmt.Println("Verificando se pedido existe, ID:", pedidoID)
-		var pedidoExiste bool
-		var statusAtual models.StatusPedido
-		err = db.QueryRow("SELECT EXISTS(SELECT 1 FROM pedidos WHERE id = $1), status FROM pedidos WHERE id = $1", pedidoID).Scan(&pedidoExiste, &statusAtual)
-		if err != nil {
-			fmt.Println("ERRO ao verificar pedido:", err)
-			http.Error(w, "Erro ao verificar pedido: "+err.Error(), http.StatusInternalServerError)
-			return
-		}
-		if !pedidoExiste {
-			fmt.Println("ERRO: Pedido não encontrado, ID:", pedidoID)
-			http.Error(w, "Pedido não encontrado", http.StatusNotFound)
-			return
-		}
-		fmt.Println("Pedido encontrado, status atual:", statusAtual)
-
 		// Decodificar requisição
 		fmt.Println("Decodificando corpo da requisição")
 		var req models.AtualizarStatusRequest
@@ -600,199 +674,23 @@ func AtualizarStatusPedidoHandler(db *sql.DB) http.HandlerFunc {
 		}
 		fmt.Println("Requisição decodificada, novo status solicitado:", req.Status)
 
-		// Validar status
-		if req.Status == "" {
-			fmt.Println("ERRO: Status não fornecido")
-			http.Error(w, "Status é obrigatório", http.StatusBadRequest)
-			return
-		}
-
-		// Validar transição de status
-		fmt.Println("Validando transição de status:", statusAtual, "->", req.Status)
-		if !validarTransicaoStatus(statusAtual, req.Status) {
-			fmt.Println("ERRO: Transição de status inválida:", statusAtual, "->", req.Status)
-			http.Error(w, fmt.Sprintf("Transição de status inválida: %s -> %s", statusAtual, req.Status), http.StatusBadRequest)
-			return
-		}
-		fmt.Println("Transição de status validada com sucesso")
-
-		// Verificar permissões baseado no status
-		fmt.Println("Verificando permissões para atualização de status")
-		switch req.Status {
-		case models.StatusCancelado, models.StatusEmPreparo:
-			// Atendentes e acima podem cancelar ou preparar pedidos
-			if !middleware.VerificarPerfil(perfil, "atendente") {
-				fmt.Println("ERRO: Usuário sem permissão para esta atualização, ID:", userID)
-				http.Error(w, "Sem permissão para esta atualização", http.StatusForbidden)
-				return
-			}
-		case models.StatusEmEntrega, models.StatusEntregue, models.StatusFinalizado:
-			// Verificar se o entregador foi definido
-			if req.EntregadorID == nil && req.Status == models.StatusEmEntrega {
-				fmt.Println("ERRO: Entregador não definido para status em entrega")
-				http.Error(w, "É necessário definir um entregador para iniciar a entrega", http.StatusBadRequest)
-				return
-			}
-		}
-		fmt.Println("Permissões validadas com sucesso")
-
-		// Iniciar transação
-		fmt.Println("Iniciando transação no banco de dados")
-		tx, err := db.Begin()
-		if err != nil {
-			fmt.Println("ERRO ao iniciar transação:", err)
-			http.Error(w, "Erro ao iniciar transação: "+err.Error(), http.StatusInternalServerError)
-			return
-		}
-		defer func() {
-			if err != nil {
-				fmt.Println("Realizando rollback da transação devido a erro")
-				tx.Rollback()
-				return
-			}
-		}()
-
-		// Atualizar status do pedido
-		var updateQuery string
-		var args []interface{}
-		fmt.Println("Preparando query de atualização")
-
-		// Preparar query de atualização baseada nos campos fornecidos
-		if req.Status == models.StatusCancelado {
-			// Quando o status é cancelado, incluir o motivo do cancelamento
-			updateQuery = `
-				UPDATE pedidos 
-				SET status = $1, motivo_cancelamento = $2, atualizado_em = NOW() 
-				WHERE id = $3
-			`
-			args = []interface{}{req.Status, req.MotivoCancelamento, pedidoID}
-		} else if req.Status == models.StatusEmEntrega && req.EntregadorID != nil {
-			// Se estiver iniciando entrega, atualizar entregador também
-			updateQuery = `
-				UPDATE pedidos
-				SET status = $1, entregador_id = $2, atualizado_em = NOW()
-				WHERE id = $3
-			`
-			args = []interface{}{req.Status, *req.EntregadorID, pedidoID}
-			fmt.Println("Atualizando para status Em Entrega com entregador ID:", *req.EntregadorID)
-		} else if req.Status == models.StatusEntregue || req.Status == models.StatusFinalizado {
-			// Se estiver finalizando entrega, registrar data de entrega
-			dataEntrega := time.Now()
-			if req.DataEntrega != nil {
-				dataEntrega = *req.DataEntrega
-			}
-			updateQuery = `
-				UPDATE pedidos 
-				SET status = $1, data_entrega = $2, atualizado_em = NOW() 
-				WHERE id = $3
-			`
-			args = []interface{}{req.Status, dataEntrega, pedidoID}
-			fmt.Println("Atualizando para status", req.Status, "com data de entrega:", dataEntrega)
-
-			// Verificar se existem botijas retornadas pelo cliente
-			fmt.Println("Verificando existência de botijas retornadas")
-			var temnBotijasRetornadas bool
-			err = tx.QueryRow(`
-				SELECT EXISTS(
-					SELECT 1 FROM itens_pedido 
-					WHERE pedido_id = $1 AND retorna_botija = TRUE
-				)
-			`, pedidoID).Scan(&temnBotijasRetornadas)
-			if err != nil {
-				fmt.Println("ERRO ao verificar botijas retornadas:", err)
-				http.Error(w, "Erro ao verificar botijas retornadas: "+err.Error(), http.StatusInternalServerError)
-				return
-			}
-			fmt.Println("Botijas retornadas:", temnBotijasRetornadas)
-
-			// Se houver botijas retornadas, atualizar estoque
-			if temnBotijasRetornadas {
-				fmt.Println("Processando retorno de botijas")
-				// Buscar todas as botijas retornadas
-				rows, err := tx.Query(`
-					SELECT ip.produto_id, ip.quantidade 
-					FROM itens_pedido ip
-					JOIN produtos p ON ip.produto_id = p.id
-					WHERE ip.pedido_id = $1 AND ip.retorna_botija = TRUE
-					AND p.categoria LIKE 'botija_gas%'
-				`, pedidoID)
-				if err != nil {
-					fmt.Println("ERRO ao buscar botijas retornadas:", err)
-					http.Error(w, "Erro ao buscar botijas retornadas: "+err.Error(), http.StatusInternalServerError)
-					return
-				}
-				defer rows.Close()
-
-				for rows.Next() {
-					var produtoID, quantidade int
-					err = rows.Scan(&produtoID, &quantidade)
-					if err != nil {
-						fmt.Println("ERRO ao processar botijas retornadas:", err)
-						http.Error(w, "Erro ao processar botijas retornadas: "+err.Error(), http.StatusInternalServerError)
-						return
-					}
-					fmt.Println("Botija retornada: produto ID:", produtoID, "quantidade:", quantidade)
-
-					// Atualizar estoque de botijas vazias
-					fmt.Println("Atualizando estoque de botijas vazias")
-					_, err = tx.Exec(`
-						UPDATE estoque 
-						SET botijas_vazias = botijas_vazias + $1, atualizado_em = NOW() 
-						WHERE produto_id = $2
-					`, quantidade, produtoID)
-					if err != nil {
-						fmt.Println("ERRO ao atualizar estoque de botijas vazias:", err)
-						http.Error(w, "Erro ao atualizar estoque de botijas vazias: "+err.Error(), http.StatusInternalServerError)
-						return
-					}
-
-					// Registrar movimentação de estoque
-					fmt.Println("Registrando movimentação de estoque para botijas vazias")
-					_, err = tx.Exec(`
-						INSERT INTO movimentacoes_estoque
-						(produto_id, tipo, quantidade, usuario_id, pedido_id, criado_em)
-						VALUES
-						($1, $2, $3, $4, $5, NOW())
-					`, produtoID, models.MovimentacaoBotijasVazias, quantidade, userID, pedidoID)
-					if err != nil {
-						fmt.Println("ERRO ao registrar movimentação de botijas vazias:", err)
-						http.Error(w, "Erro ao registrar movimentação de botijas vazias: "+err.Error(), http.StatusInternalServerError)
-						return
-					}
-				}
-			}
-		} else {
-			// Atualização padrão somente do status
-			updateQuery = `
-				UPDATE pedidos 
-				SET status = $1, atualizado_em = NOW() 
-				WHERE id = $2
-			`
-			args = []interface{}{req.Status, pedidoID}
-			fmt.Println("Atualizando para status:", req.Status)
-		}
-
-		// Executar atualização
-		fmt.Println("Executando query de atualização:", updateQuery)
-		fmt.Println("Parâmetros:", args)
-		_, err = tx.Exec(updateQuery, args...)
+		// Aplicar a transição pelo serviço de pedidos (validação, permissões e efeitos na mesma transação)
+		err = pedido.AlterarStatus(db, pedido.Alteracao{
+			PedidoID:           pedidoID,
+			UsuarioID:          userID,
+			Perfil:             perfil,
+			Status:             req.Status,
+			EntregadorID:       req.EntregadorID,
+			DataEntrega:        req.DataEntrega,
+			Motivo:             motivoAlteracao(req),
+		})
 		if err != nil {
 			fmt.Println("ERRO ao atualizar status do pedido:", err)
-			http.Error(w, "Erro ao atualizar status do pedido: "+err.Error(), http.StatusInternalServerError)
+			responderErroPedido(w, err)
 			return
 		}
 		fmt.Println("Status atualizado com sucesso")
 
-		// Commit da transação
-		fmt.Println("Realizando commit da transação")
-		err = tx.Commit()
-		if err != nil {
-			fmt.Println("ERRO ao realizar commit da transação:", err)
-			http.Error(w, "Erro ao finalizar transação: "+err.Error(), http.StatusInternalServerError)
-			return
-		}
-		fmt.Println("Transação finalizada com sucesso")
-
 		// Buscar pedido atualizado para resposta
 		fmt.Println("Buscando detalhes atualizados do pedido para resposta")
 		pedidoResp, err := buscarPedidoDetalhado(db, pedidoID)
@@ -864,7 +762,8 @@ func buscarPedidoDetalhado(db *sql.DB, pedidoID int) (models.PedidoResponse, err
             p.status, p.forma_pagamento, p.valor_total,
             p.observacoes, p.endereco_entrega,
             p.canal_origem, p.data_entrega, p.motivo_cancelamento,
-            p.criado_em, p.atualizado_em
+            p.criado_em, p.atualizado_em, p.aceito_em,
+            `+conciliacao.StatusPedidoSQL+`
         FROM pedidos p
         JOIN clientes c ON p.cliente_id = c.id
         JOIN usuarios a ON p.atendente_id = a.id
@@ -875,7 +774,7 @@ func buscarPedidoDetalhado(db *sql.DB, pedidoID int) (models.PedidoResponse, err
 
     var entregadorID sql.NullInt64
     var entregadorNome, entregadorPerfil sql.NullString
-    var dataEntrega sql.NullTime
+    var dataEntrega, aceitoEm sql.NullTime
     var observacoes, canalOrigem, motivoCancelamento sql.NullString
 
     err := db.QueryRow(query, pedidoID).Scan(
@@ -885,7 +784,8 @@ func buscarPedidoDetalhado(db *sql.DB, pedidoID int) (models.PedidoResponse, err
         &resp.Status, &resp.FormaPagamento, &resp.ValorTotal,
         &observacoes, &resp.EnderecoEntrega,
         &canalOrigem, &dataEntrega, &motivoCancelamento,
-        &resp.CriadoEm, &resp.AtualizadoEm,
+        &resp.CriadoEm, &resp.AtualizadoEm, &aceitoEm,
+        &resp.StatusConciliacao,
     )
     if err != nil {
         fmt.Println("ERRO ao buscar dados do pedido:", err)
@@ -914,6 +814,9 @@ func buscarPedidoDetalhado(db *sql.DB, pedidoID int) (models.PedidoResponse, err
         resp.CanalOrigem = models.CanalOrigem(canalOrigem.String)
         fmt.Println("Canal de origem:", canalOrigem.String)
     }
+    if aceitoEm.Valid {
+        resp.AceitoEm = &aceitoEm.Time
+    }
     if motivoCancelamento.Valid {
         resp.MotivoCancelamento = motivoCancelamento.String
         fmt.Println("Motivo do cancelamento:", motivoCancelamento.String)
@@ -929,24 +832,44 @@ func buscarPedidoDetalhado(db *sql.DB, pedidoID int) (models.PedidoResponse, err
 	resp.Itens = itens
 	fmt.Println("Itens do pedido obtidos com sucesso, total:", len(itens))
 
+	// Buscar linha do tempo do pedido
+	historico, err := pedido.BuscarHistorico(db, pedidoID)
+	if err != nil {
+		fmt.Println("ERRO ao buscar histórico do pedido:", err)
+		return resp, err
+	}
+	resp.Historico = historico
+
 	return resp, nil
 }
 
-// validarTransicaoStatus verifica se uma transição de status é válida
-func validarTransicaoStatus(atual, nova models.StatusPedido) bool {
-	fmt.Println("Validando transição de status:", atual, "->", nova)
-	switch atual {
-	case models.StatusNovo:
-		return nova == models.StatusEmPreparo || nova == models.StatusCancelado
-	case models.StatusEmPreparo:
-		return nova == models.StatusEmEntrega || nova == models.StatusCancelado
-	case models.StatusEmEntrega:
-		return nova == models.StatusEntregue || nova == models.StatusCancelado
-	case models.StatusEntregue:
-		return nova == models.StatusFinalizado
-	case models.StatusCancelado, models.StatusFinalizado:
-		return false // Estados finais, não podem ser alterados
+// responderErroPedido traduz os erros do serviço de pedidos para o status HTTP correspondente
+func responderErroPedido(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, pedido.ErrPedidoNaoEncontrado):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, pedido.ErrSemPermissao):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, pedido.ErrTransicaoInvalida), errors.Is(err, pedido.ErrDadosInvalidos):
+		http.Error(w, err.Error(), http.StatusBadRequest)
 	default:
-		return false
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// motivoAlteracao escolhe o motivo informado conforme o status solicitado
+func motivoAlteracao(req models.AtualizarStatusRequest) string {
+	if req.Status == models.StatusCancelado {
+		return req.MotivoCancelamento
 	}
-}
\ No newline at end of file
+	return req.Motivo
+}
+
+// pedidoVisivel indica se o usuário pode consultar o pedido: sem permissão para consultar todos,
+// só os atribuídos a ele
+func pedidoVisivel(r *http.Request, userID int, entregador *models.UsuarioBasico) bool {
+	if middleware.PossuiPermissao(r, permissao.PedidoConsultarTodos) {
+		return true
+	}
+	return entregador != nil && entregador.ID == userID
+}
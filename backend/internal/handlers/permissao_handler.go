@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/tassyosilva/GestGAS/internal/middleware"
+	"github.com/tassyosilva/GestGAS/internal/models"
+	"github.com/tassyosilva/GestGAS/internal/permissao"
+)
+
+// ListarPermissoesHandler retorna a matriz de permissões: cada ação e os perfis que podem executá-la
+func ListarPermissoesHandler(db *sql.DB) http.HandlerFunc {
+	return middleware.RequerPermissao(permissao.PermissaoGerenciar)(func(w http.ResponseWriter, r *http.Request) {
+		// Configurar cabeçalhos
+		w.Header().Set("Content-Type", "application/json")
+
+		// Verificar método
+		if r.Method != http.MethodGet {
+			http.Error(w, "Método não permitido", http.StatusMethodNotAllowed)
+			return
+		}
+
+		json.NewEncoder(w).Encode(models.MatrizPermissoesResponse{
+			PerfisEditaveis: permissao.Perfis,
+			Permissoes:      permissao.Matriz(),
+		})
+	})
+}
+
+// MinhasPermissoesHandler retorna as permissões do perfil do usuário autenticado, para que o
+// frontend mostre apenas as ações disponíveis
+func MinhasPermissoesHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Verificar se o usuário está autenticado
+		perfil, ok := middleware.ObterPerfilUsuario(r)
+		if !ok {
+			http.Error(w, "Usuário não autenticado", http.StatusUnauthorized)
+			return
+		}
+
+		// Configurar cabeçalhos
+		w.Header().Set("Content-Type", "application/json")
+
+		// Verificar método
+		if r.Method != http.MethodGet {
+			http.Error(w, "Método não permitido", http.StatusMethodNotAllowed)
+			return
+		}
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"perfil":     perfil,
+			"permissoes": permissao.DoPerfil(perfil),
+		})
+	}
+}
+
+// DefinirPermissoesPerfilHandler substitui as permissões de um perfil (PUT /api/permissoes/{perfil}).
+// O perfil admin não é editável: ele sempre tem todas as permissões.
+func DefinirPermissoesPerfilHandler(db *sql.DB) http.HandlerFunc {
+	return middleware.RequerPermissao(permissao.PermissaoGerenciar)(func(w http.ResponseWriter, r *http.Request) {
+		// Configurar cabeçalhos
+		w.Header().Set("Content-Type", "application/json")
+
+		// Verificar método
+		if r.Method != http.MethodPut {
+			http.Error(w, "Método não permitido", http.StatusMethodNotAllowed)
+			return
+		}
+
+		// Extrair o perfil da URL (/api/permissoes/{perfil})
+		perfil := strings.Split(r.URL.Path, "/")[3]
+		if perfil == models.PerfilAdmin {
+			http.Error(w, "O perfil admin sempre tem todas as permissões", http.StatusBadRequest)
+			return
+		}
+
+		var req models.DefinirPermissoesRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Erro ao decodificar requisição", http.StatusBadRequest)
+			return
+		}
+		if req.Permissoes == nil {
+			http.Error(w, "Informe a lista de permissões do perfil (pode ser vazia)", http.StatusBadRequest)
+			return
+		}
+
+		err := permissao.Definir(db, perfil, req.Permissoes)
+		if errors.Is(err, permissao.ErrPerfilInvalido) {
+			http.Error(w, "Perfil inválido", http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, permissao.ErrPermissaoInvalida) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Erro ao salvar permissões: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"perfil":     perfil,
+			"permissoes": permissao.DoPerfil(perfil),
+		})
+	})
+}
@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/tassyosilva/GestGAS/internal/config"
+	"github.com/tassyosilva/GestGAS/internal/middleware"
+	"github.com/tassyosilva/GestGAS/internal/models"
+	"github.com/tassyosilva/GestGAS/internal/pix"
+)
+
+// ObterPixPedidoHandler gera o QR Code Pix (BR Code) do valor total de um pedido, com o txid do
+// pedido para a conciliação. Com formato=png, responde só a imagem.
+func ObterPixPedidoHandler(db *sql.DB, cfg config.Pix) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Verificar se o usuário está autenticado
+		userID, ok := middleware.ObterUsuarioID(r)
+		if !ok {
+			http.Error(w, "Usuário não autenticado", http.StatusUnauthorized)
+			return
+		}
+
+		// Configurar cabeçalhos
+		w.Header().Set("Content-Type", "application/json")
+
+		// Verificar método
+		if r.Method != http.MethodGet {
+			http.Error(w, "Método não permitido", http.StatusMethodNotAllowed)
+			return
+		}
+
+		if cfg.Chave == "" {
+			http.Error(w, "Cobrança por Pix não configurada (informe a chave Pix da revenda)", http.StatusServiceUnavailable)
+			return
+		}
+
+		// Extrair ID do pedido da URL (/api/pedidos/{id}/pix)
+		parts := strings.Split(r.URL.Path, "/")
+		if len(parts) < 5 {
+			http.Error(w, "ID do pedido não fornecido", http.StatusBadRequest)
+			return
+		}
+		pedidoID, err := strconv.Atoi(parts[3])
+		if err != nil {
+			http.Error(w, "ID do pedido inválido", http.StatusBadRequest)
+			return
+		}
+
+		var status models.StatusPedido
+		var valorTotal float64
+		var entregadorID sql.NullInt64
+		err = db.QueryRow("SELECT status, valor_total, entregador_id FROM pedidos WHERE id = $1", pedidoID).Scan(
+			&status, &valorTotal, &entregadorID,
+		)
+		if err == sql.ErrNoRows {
+			http.Error(w, "Pedido não encontrado", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Erro ao buscar pedido: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		// Entregadores só podem gerar a cobrança dos pedidos atribuídos a eles
+		var entregador *models.UsuarioBasico
+		if entregadorID.Valid {
+			entregador = &models.UsuarioBasico{ID: int(entregadorID.Int64)}
+		}
+		if !pedidoVisivel(r, userID, entregador) {
+			http.Error(w, "Pedido não encontrado", http.StatusNotFound)
+			return
+		}
+
+		if status == models.StatusCancelado {
+			http.Error(w, "Pedido cancelado não pode ser cobrado", http.StatusBadRequest)
+			return
+		}
+		if valorTotal <= 0 {
+			http.Error(w, "Pedido sem valor a cobrar", http.StatusBadRequest)
+			return
+		}
+
+		cobranca := models.CobrancaPix{
+			PedidoID: pedidoID,
+			TxID:     pix.TxIDPedido(pedidoID),
+			Valor:    arredondarCentavos(valorTotal),
+		}
+		cobranca.Payload, err = pix.GerarPayload(pix.Cobranca{
+			Chave:         cfg.Chave,
+			NomeRecebedor: cfg.NomeRecebedor,
+			Cidade:        cfg.Cidade,
+			Valor:         cobranca.Valor,
+			TxID:          cobranca.TxID,
+		})
+		if err != nil {
+			http.Error(w, "Erro ao gerar cobrança Pix: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		imagem, err := pix.GerarQRCodePNG(cobranca.Payload)
+		if err != nil {
+			http.Error(w, "Erro ao gerar QR Code: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if r.URL.Query().Get("formato") == "png" {
+			w.Header().Set("Content-Type", "image/png")
+			w.Header().Set("Cache-Control", "no-store")
+			w.Write(imagem)
+			return
+		}
+
+		cobranca.ImagemPNG = "data:image/png;base64," + base64.StdEncoding.EncodeToString(imagem)
+		json.NewEncoder(w).Encode(cobranca)
+	}
+}
@@ -10,6 +10,7 @@ import (
 
 	"github.com/tassyosilva/GestGAS/internal/middleware"
 	"github.com/tassyosilva/GestGAS/internal/models"
+	"github.com/tassyosilva/GestGAS/internal/permissao"
 )
 
 // ListarProdutosHandler retorna a lista de produtos
@@ -123,7 +124,7 @@ func ObterProdutoHandler(db *sql.DB) http.HandlerFunc {
 
 // CriarProdutoHandler cria um novo produto
 func CriarProdutoHandler(db *sql.DB) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
+	return middleware.RequerPermissao(permissao.ProdutoCriar)(func(w http.ResponseWriter, r *http.Request) {
 		// Verificar se é uma requisição POST
 		if r.Method != http.MethodPost {
 			http.Error(w, "Método não permitido", http.StatusMethodNotAllowed)
@@ -137,13 +138,6 @@ func CriarProdutoHandler(db *sql.DB) http.HandlerFunc {
 			return
 		}
 
-		// Verificar se o usuário tem permissão para criar produtos
-		perfil, perfilOk := middleware.ObterPerfilUsuario(r)
-		if !perfilOk || !middleware.VerificarPerfil(perfil, "gerente") {
-			http.Error(w, "Permissão negada", http.StatusForbidden)
-			return
-		}
-
 		// Decodificar o corpo da requisição
 		var produto models.Produto
 		decoder := json.NewDecoder(r.Body)
@@ -212,12 +206,12 @@ func CriarProdutoHandler(db *sql.DB) http.HandlerFunc {
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusCreated)
 		json.NewEncoder(w).Encode(produto)
-	}
+	})
 }
 
 // AtualizarProdutoHandler atualiza um produto existente
 func AtualizarProdutoHandler(db *sql.DB) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
+	return middleware.RequerPermissao(permissao.ProdutoEditar)(func(w http.ResponseWriter, r *http.Request) {
 		// Verificar se é uma requisição PUT
 		if r.Method != http.MethodPut {
 			http.Error(w, "Método não permitido", http.StatusMethodNotAllowed)
@@ -245,13 +239,6 @@ func AtualizarProdutoHandler(db *sql.DB) http.HandlerFunc {
 			return
 		}
 
-		// Verificar se o usuário tem permissão para atualizar produtos
-		perfil, perfilOk := middleware.ObterPerfilUsuario(r)
-		if !perfilOk || !middleware.VerificarPerfil(perfil, "gerente") {
-			http.Error(w, "Permissão negada", http.StatusForbidden)
-			return
-		}
-
 		// Verificar se o produto existe
 		var existe bool
 		err = db.QueryRow("SELECT EXISTS(SELECT 1 FROM produtos WHERE id = $1)", produtoID).Scan(&existe)
@@ -322,12 +309,12 @@ func AtualizarProdutoHandler(db *sql.DB) http.HandlerFunc {
 		// Retornar o produto atualizado
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(produto)
-	}
+	})
 }
 
 // ExcluirProdutoHandler remove um produto
 func ExcluirProdutoHandler(db *sql.DB) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
+	return middleware.RequerPermissao(permissao.ProdutoExcluir)(func(w http.ResponseWriter, r *http.Request) {
 		// Verificar se é uma requisição DELETE
 		if r.Method != http.MethodDelete {
 			http.Error(w, "Método não permitido", http.StatusMethodNotAllowed)
@@ -355,13 +342,6 @@ func ExcluirProdutoHandler(db *sql.DB) http.HandlerFunc {
 			return
 		}
 
-		// Apenas administradores podem excluir produtos
-		perfil, perfilOk := middleware.ObterPerfilUsuario(r)
-		if !perfilOk || !middleware.VerificarPerfil(perfil, "admin") {
-			http.Error(w, "Permissão negada", http.StatusForbidden)
-			return
-		}
-
 		// Verificar se o produto existe
 		var existe bool
 		err = db.QueryRow("SELECT EXISTS(SELECT 1 FROM produtos WHERE id = $1)", produtoID).Scan(&existe)
@@ -397,5 +377,5 @@ func ExcluirProdutoHandler(db *sql.DB) http.HandlerFunc {
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusOK)
 		json.NewEncoder(w).Encode(map[string]string{"message": "Produto excluído com sucesso"})
-	}
+	})
 }
\ No newline at end of file
@@ -0,0 +1,242 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/tassyosilva/GestGAS/internal/middleware"
+	"github.com/tassyosilva/GestGAS/internal/models"
+	"github.com/tassyosilva/GestGAS/internal/permissao"
+)
+
+// formatoData é o formato das datas recebidas e retornadas pelos relatórios
+const formatoData = "2006-01-02"
+
+// periodoPadraoDias é o período do dashboard quando as datas não são informadas
+const periodoPadraoDias = 30
+
+// filtroConcluido restringe as agregações de faturamento aos pedidos entregues ou finalizados ($3 e $4)
+const filtroConcluido = "p.status IN ($3, $4)"
+
+// DashboardHandler retorna os indicadores de vendas e operação do período informado
+func DashboardHandler(db *sql.DB) http.HandlerFunc {
+	return middleware.RequerPermissao(permissao.RelatorioVisualizar)(func(w http.ResponseWriter, r *http.Request) {
+		// Verificar se o usuário está autenticado
+		_, ok := middleware.ObterUsuarioID(r)
+		if !ok {
+			http.Error(w, "Usuário não autenticado", http.StatusUnauthorized)
+			return
+		}
+
+		// Configurar cabeçalhos
+		w.Header().Set("Content-Type", "application/json")
+
+		// Verificar método
+		if r.Method != http.MethodGet {
+			http.Error(w, "Método não permitido", http.StatusMethodNotAllowed)
+			return
+		}
+
+		// Período: data_fim inclusiva; padrão são os últimos 30 dias
+		inicio, fim, msg := lerPeriodo(r.URL.Query().Get("data_inicio"), r.URL.Query().Get("data_fim"))
+		if msg != "" {
+			http.Error(w, msg, http.StatusBadRequest)
+			return
+		}
+
+		resp, err := montarDashboard(db, inicio, fim)
+		if err != nil {
+			http.Error(w, "Erro ao gerar dashboard: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		json.NewEncoder(w).Encode(resp)
+	})
+}
+
+// lerPeriodo interpreta data_inicio e data_fim (AAAA-MM-DD) e retorna o intervalo [inicio, fim+1 dia)
+func lerPeriodo(dataInicio, dataFim string) (time.Time, time.Time, string) {
+	hoje := time.Now()
+	hoje = time.Date(hoje.Year(), hoje.Month(), hoje.Day(), 0, 0, 0, 0, time.Local)
+
+	fim := hoje
+	if dataFim != "" {
+		d, err := time.ParseInLocation(formatoData, dataFim, time.Local)
+		if err != nil {
+			return time.Time{}, time.Time{}, "data_fim inválida (use AAAA-MM-DD)"
+		}
+		fim = d
+	}
+
+	inicio := fim.AddDate(0, 0, -(periodoPadraoDias - 1))
+	if dataInicio != "" {
+		d, err := time.ParseInLocation(formatoData, dataInicio, time.Local)
+		if err != nil {
+			return time.Time{}, time.Time{}, "data_inicio inválida (use AAAA-MM-DD)"
+		}
+		inicio = d
+	}
+
+	if inicio.After(fim) {
+		return time.Time{}, time.Time{}, "data_inicio deve ser anterior ou igual a data_fim"
+	}
+	return inicio, fim.AddDate(0, 0, 1), ""
+}
+
+// montarDashboard executa as agregações do período [inicio, fim)
+func montarDashboard(db *sql.DB, inicio, fim time.Time) (models.DashboardResponse, error) {
+	resp := models.DashboardResponse{
+		DataInicio:          inicio.Format(formatoData),
+		DataFim:             fim.AddDate(0, 0, -1).Format(formatoData),
+		PorDia:              []models.FaturamentoDia{},
+		PorFormaPagamento:   []models.FaturamentoGrupo{},
+		PorCanalOrigem:      []models.FaturamentoGrupo{},
+		PorProduto:          []models.FaturamentoProduto{},
+		MotivosCancelamento: []models.MotivoCancelamento{},
+	}
+	params := []interface{}{inicio, fim, models.StatusEntregue, models.StatusFinalizado}
+
+	// Totais, cancelamentos e tempo médio de entrega numa única varredura
+	var tempoMedio sql.NullFloat64
+	err := db.QueryRow(`
+		SELECT
+			COUNT(*),
+			COUNT(*) FILTER (WHERE `+filtroConcluido+`),
+			COALESCE(SUM(p.valor_total) FILTER (WHERE `+filtroConcluido+`), 0),
+			COUNT(*) FILTER (WHERE p.status = $5),
+			AVG(EXTRACT(EPOCH FROM (p.data_entrega - p.criado_em)) / 60)
+				FILTER (WHERE `+filtroConcluido+` AND p.data_entrega IS NOT NULL)
+		FROM pedidos p
+		WHERE p.criado_em >= $1 AND p.criado_em < $2
+	`, append(params, models.StatusCancelado)...).Scan(
+		&resp.Resumo.TotalPedidos, &resp.Resumo.PedidosConcluidos, &resp.Resumo.Faturamento,
+		&resp.Resumo.PedidosCancelados, &tempoMedio,
+	)
+	if err != nil {
+		return resp, err
+	}
+
+	resp.Resumo.Faturamento = arredondarCentavos(resp.Resumo.Faturamento)
+	if resp.Resumo.PedidosConcluidos > 0 {
+		resp.Resumo.TicketMedio = arredondarCentavos(resp.Resumo.Faturamento / float64(resp.Resumo.PedidosConcluidos))
+	}
+	if resp.Resumo.TotalPedidos > 0 {
+		resp.Resumo.TaxaCancelamento = arredondarCentavos(float64(resp.Resumo.PedidosCancelados) * 100 / float64(resp.Resumo.TotalPedidos))
+	}
+	if tempoMedio.Valid {
+		minutos := math.Round(tempoMedio.Float64*10) / 10
+		resp.Resumo.TempoMedioEntregaMinutos = &minutos
+	}
+
+	// Faturamento por dia
+	rows, err := db.Query(`
+		SELECT date_trunc('day', p.criado_em)::date, COUNT(*), SUM(p.valor_total)
+		FROM pedidos p
+		WHERE p.criado_em >= $1 AND p.criado_em < $2 AND `+filtroConcluido+`
+		GROUP BY 1
+		ORDER BY 1
+	`, params...)
+	if err != nil {
+		return resp, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var dia models.FaturamentoDia
+		var data time.Time
+		if err := rows.Scan(&data, &dia.Pedidos, &dia.Valor); err != nil {
+			return resp, err
+		}
+		dia.Data = data.Format(formatoData)
+		dia.Valor = arredondarCentavos(dia.Valor)
+		resp.PorDia = append(resp.PorDia, dia)
+	}
+	if err := rows.Err(); err != nil {
+		return resp, err
+	}
+
+	// Faturamento por forma de pagamento e por canal de origem
+	resp.PorFormaPagamento, err = agruparFaturamento(db, "p.forma_pagamento", params)
+	if err != nil {
+		return resp, err
+	}
+	resp.PorCanalOrigem, err = agruparFaturamento(db, "COALESCE(NULLIF(p.canal_origem, ''), 'nao_informado')", params)
+	if err != nil {
+		return resp, err
+	}
+
+	// Faturamento por produto
+	rowsProdutos, err := db.Query(`
+		SELECT i.produto_id, pr.nome, SUM(i.quantidade), SUM(i.subtotal)
+		FROM itens_pedido i
+		JOIN pedidos p ON i.pedido_id = p.id
+		JOIN produtos pr ON i.produto_id = pr.id
+		WHERE p.criado_em >= $1 AND p.criado_em < $2 AND `+filtroConcluido+`
+		GROUP BY i.produto_id, pr.nome
+		ORDER BY SUM(i.subtotal) DESC, pr.nome
+	`, params...)
+	if err != nil {
+		return resp, err
+	}
+	defer rowsProdutos.Close()
+	for rowsProdutos.Next() {
+		var produto models.FaturamentoProduto
+		if err := rowsProdutos.Scan(&produto.ProdutoID, &produto.Nome, &produto.Quantidade, &produto.Valor); err != nil {
+			return resp, err
+		}
+		produto.Valor = arredondarCentavos(produto.Valor)
+		resp.PorProduto = append(resp.PorProduto, produto)
+	}
+	if err := rowsProdutos.Err(); err != nil {
+		return resp, err
+	}
+
+	// Cancelamentos por motivo
+	rowsMotivos, err := db.Query(`
+		SELECT COALESCE(NULLIF(TRIM(p.motivo_cancelamento), ''), 'Não informado'), COUNT(*)
+		FROM pedidos p
+		WHERE p.criado_em >= $1 AND p.criado_em < $2 AND p.status = $3
+		GROUP BY 1
+		ORDER BY 2 DESC, 1
+	`, inicio, fim, models.StatusCancelado)
+	if err != nil {
+		return resp, err
+	}
+	defer rowsMotivos.Close()
+	for rowsMotivos.Next() {
+		var motivo models.MotivoCancelamento
+		if err := rowsMotivos.Scan(&motivo.Motivo, &motivo.Quantidade); err != nil {
+			return resp, err
+		}
+		resp.MotivosCancelamento = append(resp.MotivosCancelamento, motivo)
+	}
+	return resp, rowsMotivos.Err()
+}
+
+// agruparFaturamento soma os pedidos concluídos do período agrupando pela expressão informada
+func agruparFaturamento(db *sql.DB, expressao string, params []interface{}) ([]models.FaturamentoGrupo, error) {
+	rows, err := db.Query(`
+		SELECT `+expressao+`, COUNT(*), SUM(p.valor_total)
+		FROM pedidos p
+		WHERE p.criado_em >= $1 AND p.criado_em < $2 AND `+filtroConcluido+`
+		GROUP BY 1
+		ORDER BY 3 DESC, 1
+	`, params...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	grupos := []models.FaturamentoGrupo{}
+	for rows.Next() {
+		var grupo models.FaturamentoGrupo
+		if err := rows.Scan(&grupo.Chave, &grupo.Pedidos, &grupo.Valor); err != nil {
+			return nil, err
+		}
+		grupo.Valor = arredondarCentavos(grupo.Valor)
+		grupos = append(grupos, grupo)
+	}
+	return grupos, rows.Err()
+}
@@ -11,30 +11,38 @@ import (
 	"github.com/tassyosilva/GestGAS/internal/auth"
 	"github.com/tassyosilva/GestGAS/internal/middleware"
 	"github.com/tassyosilva/GestGAS/internal/models"
+	"github.com/tassyosilva/GestGAS/internal/permissao"
 )
 
 // ListarUsuariosHandler retorna a lista de todos os usuários
 func ListarUsuariosHandler(db *sql.DB) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
+	return middleware.RequerPermissao(permissao.UsuarioConsultar)(func(w http.ResponseWriter, r *http.Request) {
 		// Verificar se é um método GET
 		if r.Method != http.MethodGet {
 			http.Error(w, "Método não permitido", http.StatusMethodNotAllowed)
 			return
 		}
 
-		// Verificar permissões (apenas admin e gerente podem listar todos os usuários)
-		perfil, ok := middleware.ObterPerfilUsuario(r)
-		if !ok || !middleware.VerificarPerfil(perfil, "gerente") {
-			http.Error(w, "Acesso negado", http.StatusForbidden)
-			return
+		// Filtro opcional por situação (ativo=true ou ativo=false); sem ele, lista todos
+		filtro := ""
+		var params []interface{}
+		if ativo := r.URL.Query().Get("ativo"); ativo != "" {
+			valor, err := strconv.ParseBool(ativo)
+			if err != nil {
+				http.Error(w, "Parâmetro ativo inválido (use true ou false)", http.StatusBadRequest)
+				return
+			}
+			filtro = "WHERE ativo = $1"
+			params = append(params, valor)
 		}
 
 		// Consultar usuários no banco de dados
 		rows, err := db.Query(`
-			SELECT id, nome, login, cpf, email, perfil, criado_em, atualizado_em 
+			SELECT id, nome, login, cpf, email, perfil, ativo, desativado_em, deve_trocar_senha, criado_em, atualizado_em 
 			FROM usuarios 
+			`+filtro+`
 			ORDER BY nome
-		`)
+		`, params...)
 		if err != nil {
 			http.Error(w, "Erro ao consultar usuários", http.StatusInternalServerError)
 			return
@@ -54,6 +62,9 @@ func ListarUsuariosHandler(db *sql.DB) http.HandlerFunc {
 				&usuario.CPF,
 				&usuario.Email,
 				&usuario.Perfil,
+				&usuario.Ativo,
+				&usuario.DesativadoEm,
+				&usuario.DeveTrocarSenha,
 				&usuario.CriadoEm,
 				&usuario.AtualizadoEm,
 			)
@@ -73,7 +84,7 @@ func ListarUsuariosHandler(db *sql.DB) http.HandlerFunc {
 		// Retornar a lista de usuários como JSON
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(usuarios)
-	}
+	})
 }
 
 // ObterUsuarioHandler retorna um usuário específico pelo ID
@@ -101,11 +112,9 @@ func ObterUsuarioHandler(db *sql.DB) http.HandlerFunc {
 
 		// Verificar permissões
 		userID, okID := middleware.ObterUsuarioID(r)
-		perfil, okPerfil := middleware.ObterPerfilUsuario(r)
 		
-		// Apenas admin e gerente podem ver detalhes de qualquer usuário
-		// Outros usuários só podem ver seus próprios detalhes
-		if !okID || !okPerfil || (userID != id && !middleware.VerificarPerfil(perfil, "gerente")) {
+		// Quem não consulta outros usuários só pode ver seus próprios detalhes
+		if !okID || (userID != id && !middleware.PossuiPermissao(r, permissao.UsuarioConsultar)) {
 			http.Error(w, "Acesso negado", http.StatusForbidden)
 			return
 		}
@@ -113,7 +122,7 @@ func ObterUsuarioHandler(db *sql.DB) http.HandlerFunc {
 		// Consultar usuário no banco de dados
 		var usuario models.Usuario
 		err = db.QueryRow(`
-			SELECT id, nome, login, cpf, email, perfil, criado_em, atualizado_em 
+			SELECT id, nome, login, cpf, email, perfil, ativo, desativado_em, deve_trocar_senha, criado_em, atualizado_em 
 			FROM usuarios 
 			WHERE id = $1
 		`, id).Scan(
@@ -123,6 +132,9 @@ func ObterUsuarioHandler(db *sql.DB) http.HandlerFunc {
 			&usuario.CPF,
 			&usuario.Email,
 			&usuario.Perfil,
+			&usuario.Ativo,
+			&usuario.DesativadoEm,
+			&usuario.DeveTrocarSenha,
 			&usuario.CriadoEm,
 			&usuario.AtualizadoEm,
 		)
@@ -144,20 +156,13 @@ func ObterUsuarioHandler(db *sql.DB) http.HandlerFunc {
 
 // CriarUsuarioHandler cadastra um novo usuário
 func CriarUsuarioHandler(db *sql.DB) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
+	return middleware.RequerPermissao(permissao.UsuarioGerenciar)(func(w http.ResponseWriter, r *http.Request) {
 		// Verificar se é um método POST
 		if r.Method != http.MethodPost {
 			http.Error(w, "Método não permitido", http.StatusMethodNotAllowed)
 			return
 		}
 
-		// Verificar permissões (apenas admin pode criar usuários)
-		perfil, ok := middleware.ObterPerfilUsuario(r)
-		if !ok || perfil != "admin" {
-			http.Error(w, "Apenas administradores podem criar usuários", http.StatusForbidden)
-			return
-		}
-
 		// Decodificar o corpo da requisição
 		var req struct {
 			Nome    string `json:"nome"`
@@ -185,6 +190,10 @@ func CriarUsuarioHandler(db *sql.DB) http.HandlerFunc {
 			http.Error(w, "Perfil inválido", http.StatusBadRequest)
 			return
 		}
+		if req.Perfil == models.PerfilAdmin && !ehAdmin(r) {
+			http.Error(w, "Apenas administradores podem criar usuários admin", http.StatusForbidden)
+			return
+		}
 
 		// Verificar se login já existe
 		var count int
@@ -224,6 +233,12 @@ func CriarUsuarioHandler(db *sql.DB) http.HandlerFunc {
 			}
 		}
 
+		// Aplicar a política de senhas
+		if err := auth.ValidarSenha(req.Senha, req.Login); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
 		// Gerar hash da senha
 		senhaHash, err := auth.HashSenha(req.Senha)
 		if err != nil {
@@ -247,7 +262,7 @@ func CriarUsuarioHandler(db *sql.DB) http.HandlerFunc {
 		// Buscar o usuário recém-criado (sem a senha)
 		var usuario models.Usuario
 		err = db.QueryRow(`
-			SELECT id, nome, login, cpf, email, perfil, criado_em, atualizado_em 
+			SELECT id, nome, login, cpf, email, perfil, ativo, desativado_em, deve_trocar_senha, criado_em, atualizado_em 
 			FROM usuarios 
 			WHERE id = $1
 		`, usuarioID).Scan(
@@ -257,6 +272,9 @@ func CriarUsuarioHandler(db *sql.DB) http.HandlerFunc {
 			&usuario.CPF,
 			&usuario.Email,
 			&usuario.Perfil,
+			&usuario.Ativo,
+			&usuario.DesativadoEm,
+			&usuario.DeveTrocarSenha,
 			&usuario.CriadoEm,
 			&usuario.AtualizadoEm,
 		)
@@ -270,7 +288,7 @@ func CriarUsuarioHandler(db *sql.DB) http.HandlerFunc {
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusCreated)
 		json.NewEncoder(w).Encode(usuario)
-	}
+	})
 }
 
 // AtualizarUsuarioHandler atualiza um usuário existente
@@ -299,17 +317,17 @@ func AtualizarUsuarioHandler(db *sql.DB) http.HandlerFunc {
 		// Verificar permissões
 		userID, okID := middleware.ObterUsuarioID(r)
 		perfil, okPerfil := middleware.ObterPerfilUsuario(r)
+		gerenciaUsuarios := middleware.PossuiPermissao(r, permissao.UsuarioGerenciar)
 		
-		// Apenas admin pode atualizar qualquer usuário
-		// Outros usuários só podem atualizar seus próprios dados
-		if !okID || !okPerfil || (userID != id && perfil != "admin") {
+		// Quem não gerencia usuários só pode atualizar seus próprios dados
+		if !okID || !okPerfil || (userID != id && !gerenciaUsuarios) {
 			http.Error(w, "Acesso negado", http.StatusForbidden)
 			return
 		}
 
 		// Verificar se o usuário existe
 		var usuarioAtual models.Usuario
-		err = db.QueryRow("SELECT id, perfil FROM usuarios WHERE id = $1", id).Scan(&usuarioAtual.ID, &usuarioAtual.Perfil)
+		err = db.QueryRow("SELECT id, login, perfil FROM usuarios WHERE id = $1", id).Scan(&usuarioAtual.ID, &usuarioAtual.Login, &usuarioAtual.Perfil)
 		if err != nil {
 			if err == sql.ErrNoRows {
 				http.Error(w, "Usuário não encontrado", http.StatusNotFound)
@@ -341,9 +359,19 @@ func AtualizarUsuarioHandler(db *sql.DB) http.HandlerFunc {
 			return
 		}
 
-		// Apenas admin pode mudar o perfil
-		if perfil != "admin" && req.Perfil != "" && req.Perfil != usuarioAtual.Perfil {
-			http.Error(w, "Apenas administradores podem alterar o perfil", http.StatusForbidden)
+		// Apenas quem gerencia usuários pode mudar o perfil
+		if !gerenciaUsuarios && req.Perfil != "" && req.Perfil != usuarioAtual.Perfil {
+			http.Error(w, "Sem permissão para alterar o perfil", http.StatusForbidden)
+			return
+		}
+		if req.Perfil != "" && req.Perfil != "admin" && req.Perfil != "gerente" && req.Perfil != "atendente" && req.Perfil != "entregador" {
+			http.Error(w, "Perfil inválido", http.StatusBadRequest)
+			return
+		}
+
+		// Contas admin (e o próprio perfil admin) só são alteradas por administradores
+		if perfil != models.PerfilAdmin && (usuarioAtual.Perfil == models.PerfilAdmin || req.Perfil == models.PerfilAdmin) {
+			http.Error(w, "Apenas administradores podem alterar usuários admin", http.StatusForbidden)
 			return
 		}
 
@@ -406,6 +434,16 @@ func AtualizarUsuarioHandler(db *sql.DB) http.HandlerFunc {
 		}
 
 		if req.Senha != "" {
+			// Aplicar a política de senhas (comparando com o login que ficará valendo)
+			login := usuarioAtual.Login
+			if req.Login != "" {
+				login = req.Login
+			}
+			if err := auth.ValidarSenha(req.Senha, login); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
 			// Gerar hash da nova senha
 			senhaHash, err := auth.HashSenha(req.Senha)
 			if err != nil {
@@ -415,6 +453,11 @@ func AtualizarUsuarioHandler(db *sql.DB) http.HandlerFunc {
 			query += fmt.Sprintf(", senha = $%d", paramCount)
 			params = append(params, senhaHash)
 			paramCount++
+
+			// Senha definida pelo admin para outro usuário é provisória; a do próprio usuário não
+			query += fmt.Sprintf(", deve_trocar_senha = $%d, senha_alterada_em = CURRENT_TIMESTAMP", paramCount)
+			params = append(params, userID != id)
+			paramCount++
 		}
 
 		if req.CPF != "" {
@@ -429,7 +472,7 @@ func AtualizarUsuarioHandler(db *sql.DB) http.HandlerFunc {
 			paramCount++
 		}
 
-		if req.Perfil != "" && perfil == "admin" {
+		if req.Perfil != "" && gerenciaUsuarios {
 			query += fmt.Sprintf(", perfil = $%d", paramCount)
 			params = append(params, req.Perfil)
 			paramCount++
@@ -449,7 +492,7 @@ func AtualizarUsuarioHandler(db *sql.DB) http.HandlerFunc {
 		// Buscar o usuário atualizado
 		var usuario models.Usuario
 		err = db.QueryRow(`
-			SELECT id, nome, login, cpf, email, perfil, criado_em, atualizado_em 
+			SELECT id, nome, login, cpf, email, perfil, ativo, desativado_em, deve_trocar_senha, criado_em, atualizado_em 
 			FROM usuarios 
 			WHERE id = $1
 		`, id).Scan(
@@ -459,6 +502,9 @@ func AtualizarUsuarioHandler(db *sql.DB) http.HandlerFunc {
 			&usuario.CPF,
 			&usuario.Email,
 			&usuario.Perfil,
+			&usuario.Ativo,
+			&usuario.DesativadoEm,
+			&usuario.DeveTrocarSenha,
 			&usuario.CriadoEm,
 			&usuario.AtualizadoEm,
 		)
@@ -476,20 +522,13 @@ func AtualizarUsuarioHandler(db *sql.DB) http.HandlerFunc {
 
 // ExcluirUsuarioHandler remove um usuário
 func ExcluirUsuarioHandler(db *sql.DB) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
+	return middleware.RequerPermissao(permissao.UsuarioGerenciar)(func(w http.ResponseWriter, r *http.Request) {
 		// Verificar se é um método DELETE
 		if r.Method != http.MethodDelete {
 			http.Error(w, "Método não permitido", http.StatusMethodNotAllowed)
 			return
 		}
 
-		// Verificar permissões (apenas admin pode excluir usuários)
-		perfil, ok := middleware.ObterPerfilUsuario(r)
-		if !ok || perfil != "admin" {
-			http.Error(w, "Apenas administradores podem excluir usuários", http.StatusForbidden)
-			return
-		}
-
 		// Extrair ID do usuário da URL
 		path := strings.Split(r.URL.Path, "/")
 		if len(path) < 4 {
@@ -511,6 +550,10 @@ func ExcluirUsuarioHandler(db *sql.DB) http.HandlerFunc {
 			return
 		}
 
+		if !protegerUsuarioAdmin(w, r, db, id) {
+			return
+		}
+
 		// Verificar se o usuário existe
 		var existe bool
 		err = db.QueryRow("SELECT EXISTS(SELECT 1 FROM usuarios WHERE id = $1)", id).Scan(&existe)
@@ -536,7 +579,7 @@ func ExcluirUsuarioHandler(db *sql.DB) http.HandlerFunc {
 			return
 		}
 		if temPedidos {
-			http.Error(w, "Não é possível excluir o usuário pois existem pedidos associados", http.StatusBadRequest)
+			http.Error(w, "Não é possível excluir o usuário pois existem pedidos associados; desative-o para bloquear o acesso", http.StatusBadRequest)
 			return
 		}
 
@@ -551,10 +594,163 @@ func ExcluirUsuarioHandler(db *sql.DB) http.HandlerFunc {
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusOK)
 		json.NewEncoder(w).Encode(map[string]string{"mensagem": "Usuário excluído com sucesso"})
+	})
+}
+
+// DesativarUsuarioHandler bloqueia o acesso de um usuário sem apagar o cadastro: as sessões dele
+// são revogadas e os pedidos antigos continuam apontando para ele
+func DesativarUsuarioHandler(db *sql.DB) http.HandlerFunc {
+	return middleware.RequerPermissao(permissao.UsuarioGerenciar)(func(w http.ResponseWriter, r *http.Request) {
+		// Verificar se é um método POST
+		if r.Method != http.MethodPost {
+			http.Error(w, "Método não permitido", http.StatusMethodNotAllowed)
+			return
+		}
+
+		// Extrair ID do usuário da URL (/api/usuarios/{id}/desativar)
+		id, err := strconv.Atoi(strings.Split(r.URL.Path, "/")[3])
+		if err != nil {
+			http.Error(w, "ID inválido", http.StatusBadRequest)
+			return
+		}
+
+		// Impedir desativação do próprio usuário
+		userID, _ := middleware.ObterUsuarioID(r)
+		if userID == id {
+			http.Error(w, "Não é possível desativar o próprio usuário", http.StatusBadRequest)
+			return
+		}
+
+		if !protegerUsuarioAdmin(w, r, db, id) {
+			return
+		}
+
+		// Entregas em andamento precisam ser repassadas antes
+		var emAndamento int
+		err = db.QueryRow(`
+			SELECT COUNT(*) FROM pedidos
+			WHERE entregador_id = $1 AND status IN ($2, $3)
+		`, id, models.StatusEmPreparo, models.StatusEmEntrega).Scan(&emAndamento)
+		if err != nil {
+			http.Error(w, "Erro ao verificar pedidos do usuário", http.StatusInternalServerError)
+			return
+		}
+		if emAndamento > 0 {
+			http.Error(w, fmt.Sprintf("O usuário tem %d pedido(s) em andamento; atribua-os a outro entregador antes de desativá-lo", emAndamento), http.StatusBadRequest)
+			return
+		}
+
+		res, err := db.Exec(`
+			UPDATE usuarios SET ativo = FALSE, desativado_em = NOW(), atualizado_em = NOW()
+			WHERE id = $1 AND ativo
+		`, id)
+		if err != nil {
+			http.Error(w, "Erro ao desativar usuário: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if n, _ := res.RowsAffected(); n > 0 {
+			if _, err := auth.RevogarSessoesUsuario(db, id, auth.MotivoUsuarioDesativado); err != nil {
+				http.Error(w, "Erro ao revogar sessões: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+
+		responderUsuario(w, db, id)
+	})
+}
+
+// ReativarUsuarioHandler devolve o acesso a um usuário desativado
+func ReativarUsuarioHandler(db *sql.DB) http.HandlerFunc {
+	return middleware.RequerPermissao(permissao.UsuarioGerenciar)(func(w http.ResponseWriter, r *http.Request) {
+		// Verificar se é um método POST
+		if r.Method != http.MethodPost {
+			http.Error(w, "Método não permitido", http.StatusMethodNotAllowed)
+			return
+		}
+
+		// Extrair ID do usuário da URL (/api/usuarios/{id}/reativar)
+		id, err := strconv.Atoi(strings.Split(r.URL.Path, "/")[3])
+		if err != nil {
+			http.Error(w, "ID inválido", http.StatusBadRequest)
+			return
+		}
+
+		if !protegerUsuarioAdmin(w, r, db, id) {
+			return
+		}
+
+		_, err = db.Exec(`
+			UPDATE usuarios SET ativo = TRUE, desativado_em = NULL, atualizado_em = NOW()
+			WHERE id = $1 AND NOT ativo
+		`, id)
+		if err != nil {
+			http.Error(w, "Erro ao reativar usuário: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		responderUsuario(w, db, id)
+	})
+}
+
+// ehAdmin indica se o usuário autenticado tem o perfil admin
+func ehAdmin(r *http.Request) bool {
+	perfil, _ := middleware.ObterPerfilUsuario(r)
+	return perfil == models.PerfilAdmin
+}
+
+// protegerUsuarioAdmin recusa com 403 a operação sobre um usuário admin quando quem a pede não é
+// admin: a permissão usuario.gerenciar pode ser concedida a outros perfis, mas não sobre contas admin
+func protegerUsuarioAdmin(w http.ResponseWriter, r *http.Request, db *sql.DB, id int) bool {
+	if ehAdmin(r) {
+		return true
+	}
+	var perfil string
+	err := db.QueryRow("SELECT perfil FROM usuarios WHERE id = $1", id).Scan(&perfil)
+	if err != nil && err != sql.ErrNoRows {
+		http.Error(w, "Erro ao verificar usuário", http.StatusInternalServerError)
+		return false
 	}
+	if perfil == models.PerfilAdmin {
+		http.Error(w, "Apenas administradores podem gerenciar usuários admin", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// responderUsuario retorna o cadastro atual do usuário (sem a senha) como JSON
+func responderUsuario(w http.ResponseWriter, db *sql.DB, id int) {
+	var usuario models.Usuario
+	err := db.QueryRow(`
+		SELECT id, nome, login, COALESCE(cpf, ''), COALESCE(email, ''), perfil, ativo, desativado_em, deve_trocar_senha, criado_em, atualizado_em
+		FROM usuarios
+		WHERE id = $1
+	`, id).Scan(
+		&usuario.ID,
+		&usuario.Nome,
+		&usuario.Login,
+		&usuario.CPF,
+		&usuario.Email,
+		&usuario.Perfil,
+		&usuario.Ativo,
+		&usuario.DesativadoEm,
+		&usuario.DeveTrocarSenha,
+		&usuario.CriadoEm,
+		&usuario.AtualizadoEm,
+	)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Usuário não encontrado", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Erro ao buscar usuário", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(usuario)
 }
 
-// ListarEntregadoresHandler retorna a lista de usuários com perfil entregador
+// ListarEntregadoresHandler retorna a lista de usuários ativos com perfil entregador
 func ListarEntregadoresHandler(db *sql.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// Verificar se é um método GET
@@ -567,7 +763,7 @@ func ListarEntregadoresHandler(db *sql.DB) http.HandlerFunc {
 		rows, err := db.Query(`
 			SELECT id, nome, login, cpf, email, criado_em, atualizado_em 
 			FROM usuarios 
-			WHERE perfil = 'entregador'
+			WHERE perfil = 'entregador' AND ativo
 			ORDER BY nome
 		`)
 		if err != nil {
@@ -583,6 +779,7 @@ func ListarEntregadoresHandler(db *sql.DB) http.HandlerFunc {
 		for rows.Next() {
 			var entregador models.Usuario
 			entregador.Perfil = "entregador" // Já sabemos que é entregador
+			entregador.Ativo = true
 			
 			err := rows.Scan(
 				&entregador.ID,
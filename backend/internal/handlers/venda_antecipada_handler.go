@@ -0,0 +1,710 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/tassyosilva/GestGAS/internal/eventos"
+	"github.com/tassyosilva/GestGAS/internal/middleware"
+	"github.com/tassyosilva/GestGAS/internal/models"
+	"github.com/tassyosilva/GestGAS/internal/pedido"
+	"github.com/tassyosilva/GestGAS/internal/permissao"
+)
+
+// consultaVendaAntecipada seleciona as vendas antecipadas com cliente e produto
+const consultaVendaAntecipada = `
+	SELECT va.id, va.cliente_id, c.nome, c.telefone, va.produto_id, p.nome,
+	       va.quantidade, va.quantidade_resgatada, va.valor_total, va.forma_pagamento,
+	       va.data_pagamento, va.data_entrega_prevista, va.status, va.pedido_id, va.caixa_id,
+	       va.valor_reembolsado, va.motivo_cancelamento, va.criado_em, va.atualizado_em
+	FROM vendas_antecipadas va
+	JOIN clientes c ON va.cliente_id = c.id
+	JOIN produtos p ON va.produto_id = p.id
+`
+
+// ListarVendasAntecipadasHandler retorna os vales-gás com filtros por cliente e status
+func ListarVendasAntecipadasHandler(db *sql.DB) http.HandlerFunc {
+	return middleware.RequerPermissao(permissao.VendaAntecipadaConsultar)(func(w http.ResponseWriter, r *http.Request) {
+		// Verificar se o usuário está autenticado
+		_, ok := middleware.ObterUsuarioID(r)
+		if !ok {
+			http.Error(w, "Usuário não autenticado", http.StatusUnauthorized)
+			return
+		}
+
+		// Configurar cabeçalhos
+		w.Header().Set("Content-Type", "application/json")
+
+		// Verificar método
+		if r.Method != http.MethodGet {
+			http.Error(w, "Método não permitido", http.StatusMethodNotAllowed)
+			return
+		}
+
+		// Parâmetros de consulta
+		query := r.URL.Query()
+		clienteID := query.Get("cliente_id")
+		status := query.Get("status")
+
+		var params []interface{}
+		var whereConditions []string
+
+		// Por padrão, apenas os vales com unidades a resgatar são listados
+		switch status {
+		case "":
+			whereConditions = append(whereConditions, fmt.Sprintf("va.status = $%d", len(params)+1))
+			params = append(params, models.VendaAntecipadaPendente)
+		case "todos":
+		case string(models.VendaAntecipadaPendente), string(models.VendaAntecipadaResgatada), string(models.VendaAntecipadaCancelada):
+			whereConditions = append(whereConditions, fmt.Sprintf("va.status = $%d", len(params)+1))
+			params = append(params, status)
+		default:
+			http.Error(w, "Status inválido", http.StatusBadRequest)
+			return
+		}
+		if clienteID != "" {
+			id, err := strconv.Atoi(clienteID)
+			if err != nil {
+				http.Error(w, "ID do cliente inválido", http.StatusBadRequest)
+				return
+			}
+			whereConditions = append(whereConditions, fmt.Sprintf("va.cliente_id = $%d", len(params)+1))
+			params = append(params, id)
+		}
+
+		sqlQuery := consultaVendaAntecipada
+		if len(whereConditions) > 0 {
+			sqlQuery += " WHERE " + strings.Join(whereConditions, " AND ")
+		}
+		sqlQuery += " ORDER BY va.data_entrega_prevista ASC, va.id ASC"
+
+		// Executar consulta
+		rows, err := db.Query(sqlQuery, params...)
+		if err != nil {
+			http.Error(w, "Erro ao buscar vendas antecipadas: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		vendas := []models.VendaAntecipada{}
+		for rows.Next() {
+			v, err := escanearVendaAntecipada(rows)
+			if err != nil {
+				http.Error(w, "Erro ao processar vendas antecipadas: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+			vendas = append(vendas, v)
+		}
+
+		// Retornar resposta
+		json.NewEncoder(w).Encode(vendas)
+	})
+}
+
+// ObterVendaAntecipadaHandler retorna um vale-gás com seus resgates
+func ObterVendaAntecipadaHandler(db *sql.DB) http.HandlerFunc {
+	return middleware.RequerPermissao(permissao.VendaAntecipadaConsultar)(func(w http.ResponseWriter, r *http.Request) {
+		// Verificar se o usuário está autenticado
+		_, ok := middleware.ObterUsuarioID(r)
+		if !ok {
+			http.Error(w, "Usuário não autenticado", http.StatusUnauthorized)
+			return
+		}
+
+		// Configurar cabeçalhos
+		w.Header().Set("Content-Type", "application/json")
+
+		// Verificar método
+		if r.Method != http.MethodGet {
+			http.Error(w, "Método não permitido", http.StatusMethodNotAllowed)
+			return
+		}
+
+		// Extrair ID do vale da URL
+		parts := strings.Split(r.URL.Path, "/")
+		if len(parts) < 4 {
+			http.Error(w, "ID da venda antecipada não fornecido", http.StatusBadRequest)
+			return
+		}
+		vendaID, err := strconv.Atoi(parts[3])
+		if err != nil {
+			http.Error(w, "ID da venda antecipada inválido", http.StatusBadRequest)
+			return
+		}
+
+		venda, err := buscarVendaAntecipada(db, vendaID)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				http.Error(w, "Venda antecipada não encontrada", http.StatusNotFound)
+				return
+			}
+			http.Error(w, "Erro ao buscar venda antecipada: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		// Retornar resposta
+		json.NewEncoder(w).Encode(venda)
+	})
+}
+
+// CriarVendaAntecipadaHandler registra a venda antecipada de N unidades de um produto
+func CriarVendaAntecipadaHandler(db *sql.DB) http.HandlerFunc {
+	return middleware.RequerPermissao(permissao.VendaAntecipadaRegistrar)(func(w http.ResponseWriter, r *http.Request) {
+		// Verificar se o usuário está autenticado
+		userID, ok := middleware.ObterUsuarioID(r)
+		if !ok {
+			http.Error(w, "Usuário não autenticado", http.StatusUnauthorized)
+			return
+		}
+
+		// Configurar cabeçalhos
+		w.Header().Set("Content-Type", "application/json")
+
+		// Verificar método
+		if r.Method != http.MethodPost {
+			http.Error(w, "Método não permitido", http.StatusMethodNotAllowed)
+			return
+		}
+
+		// Decodificar requisição
+		var req models.NovaVendaAntecipadaRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Erro ao processar requisição: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// Validar dados
+		if req.ClienteID <= 0 {
+			http.Error(w, "ID do cliente é obrigatório", http.StatusBadRequest)
+			return
+		}
+		if req.ProdutoID <= 0 {
+			http.Error(w, "ID do produto é obrigatório", http.StatusBadRequest)
+			return
+		}
+		if req.Quantidade <= 0 {
+			http.Error(w, "Quantidade deve ser maior que zero", http.StatusBadRequest)
+			return
+		}
+		if req.DataEntregaPrevista.IsZero() {
+			http.Error(w, "Data de entrega prevista é obrigatória", http.StatusBadRequest)
+			return
+		}
+		// A venda antecipada é sempre paga no ato
+		switch req.FormaPagamento {
+		case models.PagamentoDinheiro, models.PagamentoPix, models.PagamentoCartaoDebito, models.PagamentoCartaoCredito:
+		default:
+			http.Error(w, "Forma de pagamento inválida para venda antecipada", http.StatusBadRequest)
+			return
+		}
+
+		// Verificar se o cliente existe
+		var clienteExiste bool
+		err := db.QueryRow("SELECT EXISTS(SELECT 1 FROM clientes WHERE id = $1)", req.ClienteID).Scan(&clienteExiste)
+		if err != nil {
+			http.Error(w, "Erro ao verificar cliente: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !clienteExiste {
+			http.Error(w, "Cliente não encontrado", http.StatusBadRequest)
+			return
+		}
+
+		// Buscar preço do produto
+		var preco float64
+		err = db.QueryRow("SELECT preco FROM produtos WHERE id = $1", req.ProdutoID).Scan(&preco)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				http.Error(w, fmt.Sprintf("Produto ID %d não encontrado", req.ProdutoID), http.StatusBadRequest)
+				return
+			}
+			http.Error(w, "Erro ao buscar produto: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		valorTotal := arredondarCentavos(float64(req.Quantidade) * preco)
+
+		// Iniciar transação
+		tx, err := db.Begin()
+		if err != nil {
+			http.Error(w, "Erro ao iniciar transação: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback() // sem efeito após o commit
+
+		// O pagamento entra no caixa aberto de quem vendeu, se houver
+		caixaID, err := pedido.CaixaAberto(tx, userID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		// Inserir venda antecipada
+		var vendaID int
+		err = tx.QueryRow(`
+			INSERT INTO vendas_antecipadas
+			(cliente_id, produto_id, quantidade, valor_total, forma_pagamento, data_pagamento,
+			data_entrega_prevista, status, quantidade_resgatada, caixa_id, criado_em, atualizado_em)
+			VALUES
+			($1, $2, $3, $4, $5, NOW(), $6, $7, 0, $8, NOW(), NOW())
+			RETURNING id
+		`, req.ClienteID, req.ProdutoID, req.Quantidade, valorTotal, req.FormaPagamento,
+			req.DataEntregaPrevista, models.VendaAntecipadaPendente, caixaID).Scan(&vendaID)
+		if err != nil {
+			http.Error(w, "Erro ao criar venda antecipada: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		// Commit da transação
+		err = tx.Commit()
+		if err != nil {
+			http.Error(w, "Erro ao finalizar transação: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		// Buscar venda criada para resposta
+		venda, err := buscarVendaAntecipada(db, vendaID)
+		if err != nil {
+			http.Error(w, "Venda antecipada criada, mas erro ao buscar detalhes: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		// Retornar resposta
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(venda)
+	})
+}
+
+// ResgatarVendaAntecipadaHandler resgata uma unidade de um vale-gás, gerando um pedido sem valor a pagar
+func ResgatarVendaAntecipadaHandler(db *sql.DB) http.HandlerFunc {
+	return middleware.RequerPermissao(permissao.VendaAntecipadaResgatar)(func(w http.ResponseWriter, r *http.Request) {
+		// Verificar se o usuário está autenticado
+		userID, ok := middleware.ObterUsuarioID(r)
+		if !ok {
+			http.Error(w, "Usuário não autenticado", http.StatusUnauthorized)
+			return
+		}
+
+		// Configurar cabeçalhos
+		w.Header().Set("Content-Type", "application/json")
+
+		// Verificar método
+		if r.Method != http.MethodPost {
+			http.Error(w, "Método não permitido", http.StatusMethodNotAllowed)
+			return
+		}
+
+		// Extrair ID do vale da URL (/api/vales-gas/{id}/resgatar)
+		parts := strings.Split(r.URL.Path, "/")
+		if len(parts) < 5 || parts[4] != "resgatar" {
+			http.Error(w, "URL inválida", http.StatusBadRequest)
+			return
+		}
+		vendaID, err := strconv.Atoi(parts[3])
+		if err != nil {
+			http.Error(w, "ID da venda antecipada inválido", http.StatusBadRequest)
+			return
+		}
+
+		// Decodificar requisição (corpo opcional)
+		var req models.ResgatarVendaAntecipadaRequest
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, "Erro ao processar requisição: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+
+		// Iniciar transação
+		tx, err := db.Begin()
+		if err != nil {
+			http.Error(w, "Erro ao iniciar transação: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback() // sem efeito após o commit
+
+		// Bloquear o vale para evitar resgates simultâneos da mesma unidade
+		var clienteID, produtoID, quantidade, quantidadeResgatada int
+		var formaPagamento models.FormaPagamento
+		var status models.StatusVendaAntecipada
+		err = tx.QueryRow(`
+			SELECT cliente_id, produto_id, quantidade, quantidade_resgatada, forma_pagamento, status
+			FROM vendas_antecipadas
+			WHERE id = $1
+			FOR UPDATE
+		`, vendaID).Scan(&clienteID, &produtoID, &quantidade, &quantidadeResgatada, &formaPagamento, &status)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				http.Error(w, "Venda antecipada não encontrada", http.StatusNotFound)
+				return
+			}
+			http.Error(w, "Erro ao buscar venda antecipada: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if status != models.VendaAntecipadaPendente || quantidadeResgatada >= quantidade {
+			http.Error(w, "Venda antecipada não possui unidades disponíveis para resgate", http.StatusBadRequest)
+			return
+		}
+
+		// Usar o endereço do cliente quando não for informado outro
+		enderecoEntrega := strings.TrimSpace(req.EnderecoEntrega)
+		if enderecoEntrega == "" {
+			var endereco, complemento, bairro sql.NullString
+			err = tx.QueryRow("SELECT endereco, complemento, bairro FROM clientes WHERE id = $1", clienteID).Scan(
+				&endereco, &complemento, &bairro,
+			)
+			if err != nil {
+				http.Error(w, "Erro ao buscar endereço do cliente: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+			var partes []string
+			for _, parte := range []sql.NullString{endereco, complemento, bairro} {
+				if parte.Valid && strings.TrimSpace(parte.String) != "" {
+					partes = append(partes, strings.TrimSpace(parte.String))
+				}
+			}
+			enderecoEntrega = strings.Join(partes, ", ")
+		}
+		if enderecoEntrega == "" {
+			http.Error(w, "Endereço de entrega é obrigatório", http.StatusBadRequest)
+			return
+		}
+
+		// Buscar nome do produto para as mensagens
+		var nomeProduto string
+		err = tx.QueryRow("SELECT nome FROM produtos WHERE id = $1", produtoID).Scan(&nomeProduto)
+		if err != nil {
+			http.Error(w, "Erro ao buscar produto: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		// Inserir pedido sem valor a pagar
+		observacoes := fmt.Sprintf("Resgate do vale-gás #%d", vendaID)
+		if req.Observacoes != "" {
+			observacoes += " - " + req.Observacoes
+		}
+		var pedidoID int
+		err = tx.QueryRow(`
+			INSERT INTO pedidos
+			(cliente_id, atendente_id, status, forma_pagamento, valor_total, observacoes,
+			endereco_entrega, canal_origem, criado_em, atualizado_em)
+			VALUES
+			($1, $2, $3, $4, 0, $5, $6, $7, NOW(), NOW())
+			RETURNING id
+		`, clienteID, userID, models.StatusNovo, formaPagamento, observacoes,
+			enderecoEntrega, req.CanalOrigem).Scan(&pedidoID)
+		if err != nil {
+			http.Error(w, "Erro ao criar pedido: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		// Inserir item do pedido
+		_, err = tx.Exec(`
+			INSERT INTO itens_pedido
+			(pedido_id, produto_id, quantidade, preco_unitario, subtotal, retorna_botija)
+			VALUES
+			($1, $2, 1, 0, 0, $3)
+		`, pedidoID, produtoID, req.RetornaBotija)
+		if err != nil {
+			http.Error(w, "Erro ao inserir item do pedido: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		// Reservar a unidade no estoque; a baixa acontece na confirmação da entrega
+		reservado, err := pedido.ReservarEstoque(tx, pedidoID, produtoID, 1)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !reservado {
+			http.Error(w, fmt.Sprintf("Estoque insuficiente para produto %s", nomeProduto), http.StatusBadRequest)
+			return
+		}
+
+		// Abrir a linha do tempo do pedido
+		if err = pedido.RegistrarCriacao(tx, pedidoID, userID); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		// Registrar resgate e consumir a unidade do vale
+		if err = pedido.RegistrarResgate(tx, vendaID, pedidoID, userID); err != nil {
+			responderErroPedido(w, err)
+			return
+		}
+
+		// Commit da transação
+		err = tx.Commit()
+		if err != nil {
+			http.Error(w, "Erro ao finalizar transação: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		// A reserva pode ter deixado o produto no nível de alerta
+		publicarAlertasEstoque(db, []int{produtoID})
+
+		// Buscar vale e pedido atualizados para resposta
+		venda, err := buscarVendaAntecipada(db, vendaID)
+		if err != nil {
+			http.Error(w, "Resgate registrado, mas erro ao buscar venda antecipada: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		pedidoResp, err := buscarPedidoDetalhado(db, pedidoID)
+		if err != nil {
+			http.Error(w, "Resgate registrado, mas erro ao buscar pedido: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		// Avisar as telas conectadas
+		eventos.Publicar(eventos.PedidoCriado, pedidoResp)
+
+		// Retornar resposta
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(struct {
+			VendaAntecipada models.VendaAntecipada `json:"venda_antecipada"`
+			Pedido          models.PedidoResponse  `json:"pedido"`
+		}{
+			VendaAntecipada: venda,
+			Pedido:          pedidoResp,
+		})
+	})
+}
+
+// CancelarVendaAntecipadaHandler cancela um vale-gás, reembolsando as unidades ainda não resgatadas
+func CancelarVendaAntecipadaHandler(db *sql.DB) http.HandlerFunc {
+	return middleware.RequerPermissao(permissao.VendaAntecipadaCancelar)(func(w http.ResponseWriter, r *http.Request) {
+		// Verificar se o usuário está autenticado
+		_, ok := middleware.ObterUsuarioID(r)
+		if !ok {
+			http.Error(w, "Usuário não autenticado", http.StatusUnauthorized)
+			return
+		}
+
+		// Configurar cabeçalhos
+		w.Header().Set("Content-Type", "application/json")
+
+		// Verificar método
+		if r.Method != http.MethodPost {
+			http.Error(w, "Método não permitido", http.StatusMethodNotAllowed)
+			return
+		}
+
+		// Extrair ID do vale da URL (/api/vales-gas/{id}/cancelar)
+		parts := strings.Split(r.URL.Path, "/")
+		if len(parts) < 5 || parts[4] != "cancelar" {
+			http.Error(w, "URL inválida", http.StatusBadRequest)
+			return
+		}
+		vendaID, err := strconv.Atoi(parts[3])
+		if err != nil {
+			http.Error(w, "ID da venda antecipada inválido", http.StatusBadRequest)
+			return
+		}
+
+		// Decodificar requisição (corpo opcional)
+		var req models.CancelarVendaAntecipadaRequest
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, "Erro ao processar requisição: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+
+		// Iniciar transação
+		tx, err := db.Begin()
+		if err != nil {
+			http.Error(w, "Erro ao iniciar transação: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback() // sem efeito após o commit
+
+		var quantidade, quantidadeResgatada int
+		var valorTotal float64
+		var status models.StatusVendaAntecipada
+		err = tx.QueryRow(`
+			SELECT quantidade, quantidade_resgatada, valor_total, status
+			FROM vendas_antecipadas
+			WHERE id = $1
+			FOR UPDATE
+		`, vendaID).Scan(&quantidade, &quantidadeResgatada, &valorTotal, &status)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				http.Error(w, "Venda antecipada não encontrada", http.StatusNotFound)
+				return
+			}
+			http.Error(w, "Erro ao buscar venda antecipada: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if status != models.VendaAntecipadaPendente {
+			http.Error(w, "Apenas vendas antecipadas pendentes podem ser canceladas", http.StatusBadRequest)
+			return
+		}
+
+		// Reembolsar proporcionalmente as unidades não resgatadas
+		valorReembolsado := arredondarCentavos(valorTotal * float64(quantidade-quantidadeResgatada) / float64(quantidade))
+		_, err = tx.Exec(`
+			UPDATE vendas_antecipadas
+			SET status = $1, valor_reembolsado = $2, motivo_cancelamento = NULLIF($3, ''), atualizado_em = NOW()
+			WHERE id = $4
+		`, models.VendaAntecipadaCancelada, valorReembolsado, req.MotivoCancelamento, vendaID)
+		if err != nil {
+			http.Error(w, "Erro ao cancelar venda antecipada: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		// Commit da transação
+		err = tx.Commit()
+		if err != nil {
+			http.Error(w, "Erro ao finalizar transação: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		venda, err := buscarVendaAntecipada(db, vendaID)
+		if err != nil {
+			http.Error(w, "Venda antecipada cancelada, mas erro ao buscar detalhes: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		// Retornar resposta
+		json.NewEncoder(w).Encode(venda)
+	})
+}
+
+// PassivoVendasAntecipadasHandler retorna as unidades pagas e ainda não entregues, por produto
+func PassivoVendasAntecipadasHandler(db *sql.DB) http.HandlerFunc {
+	return middleware.RequerPermissao(permissao.VendaAntecipadaPassivo)(func(w http.ResponseWriter, r *http.Request) {
+		// Verificar se o usuário está autenticado
+		_, ok := middleware.ObterUsuarioID(r)
+		if !ok {
+			http.Error(w, "Usuário não autenticado", http.StatusUnauthorized)
+			return
+		}
+
+		// Configurar cabeçalhos
+		w.Header().Set("Content-Type", "application/json")
+
+		// Verificar método
+		if r.Method != http.MethodGet {
+			http.Error(w, "Método não permitido", http.StatusMethodNotAllowed)
+			return
+		}
+
+		rows, err := db.Query(`
+			SELECT va.produto_id, p.nome, COUNT(*),
+			       SUM(va.quantidade - va.quantidade_resgatada),
+			       SUM(va.valor_total * (va.quantidade - va.quantidade_resgatada) / va.quantidade)
+			FROM vendas_antecipadas va
+			JOIN produtos p ON va.produto_id = p.id
+			WHERE va.status = $1
+			GROUP BY va.produto_id, p.nome
+			ORDER BY p.nome
+		`, models.VendaAntecipadaPendente)
+		if err != nil {
+			http.Error(w, "Erro ao calcular passivo: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		itens := []models.PassivoVendaAntecipada{}
+		var quantidadeTotal int
+		var valorTotal float64
+		for rows.Next() {
+			var item models.PassivoVendaAntecipada
+			err := rows.Scan(&item.ProdutoID, &item.NomeProduto, &item.Vales, &item.QuantidadePendente, &item.ValorPendente)
+			if err != nil {
+				http.Error(w, "Erro ao processar passivo: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+			item.ValorPendente = arredondarCentavos(item.ValorPendente)
+			quantidadeTotal += item.QuantidadePendente
+			valorTotal += item.ValorPendente
+			itens = append(itens, item)
+		}
+
+		// Montar resposta
+		response := struct {
+			Produtos        []models.PassivoVendaAntecipada `json:"produtos"`
+			QuantidadeTotal int                             `json:"quantidade_total"`
+			ValorTotal      float64                         `json:"valor_total"`
+		}{
+			Produtos:        itens,
+			QuantidadeTotal: quantidadeTotal,
+			ValorTotal:      arredondarCentavos(valorTotal),
+		}
+
+		// Retornar resposta
+		json.NewEncoder(w).Encode(response)
+	})
+}
+
+// buscarVendaAntecipada busca um vale-gás com o histórico de resgates
+func buscarVendaAntecipada(db *sql.DB, vendaID int) (models.VendaAntecipada, error) {
+	venda, err := escanearVendaAntecipada(db.QueryRow(consultaVendaAntecipada+" WHERE va.id = $1", vendaID))
+	if err != nil {
+		return venda, err
+	}
+
+	rows, err := db.Query(`
+		SELECT id, venda_antecipada_id, pedido_id, usuario_id, criado_em
+		FROM resgates_vendas_antecipadas
+		WHERE venda_antecipada_id = $1
+		ORDER BY criado_em
+	`, vendaID)
+	if err != nil {
+		return venda, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var resgate models.ResgateVendaAntecipada
+		err := rows.Scan(&resgate.ID, &resgate.VendaAntecipadaID, &resgate.PedidoID, &resgate.UsuarioID, &resgate.CriadoEm)
+		if err != nil {
+			return venda, err
+		}
+		venda.Resgates = append(venda.Resgates, resgate)
+	}
+	return venda, rows.Err()
+}
+
+// escanearVendaAntecipada converte uma linha de consultaVendaAntecipada em models.VendaAntecipada
+func escanearVendaAntecipada(row interface{ Scan(...interface{}) error }) (models.VendaAntecipada, error) {
+	var v models.VendaAntecipada
+	var cliente models.ClienteBasico
+	var pedidoID, caixaID sql.NullInt64
+	var valorReembolsado sql.NullFloat64
+	var motivoCancelamento sql.NullString
+
+	err := row.Scan(
+		&v.ID, &v.ClienteID, &cliente.Nome, &cliente.Telefone, &v.ProdutoID, &v.NomeProduto,
+		&v.Quantidade, &v.QuantidadeResgatada, &v.ValorTotal, &v.FormaPagamento,
+		&v.DataPagamento, &v.DataEntregaPrevista, &v.Status, &pedidoID, &caixaID,
+		&valorReembolsado, &motivoCancelamento, &v.CriadoEm, &v.AtualizadoEm,
+	)
+	if err != nil {
+		return v, err
+	}
+
+	cliente.ID = v.ClienteID
+	v.Cliente = &cliente
+	if v.Status != models.VendaAntecipadaCancelada {
+		v.QuantidadeRestante = v.Quantidade - v.QuantidadeResgatada
+	}
+	if pedidoID.Valid {
+		id := int(pedidoID.Int64)
+		v.PedidoID = &id
+	}
+	if caixaID.Valid {
+		id := int(caixaID.Int64)
+		v.CaixaID = &id
+	}
+	if valorReembolsado.Valid {
+		v.ValorReembolsado = &valorReembolsado.Float64
+	}
+	if motivoCancelamento.Valid {
+		v.MotivoCancelamento = motivoCancelamento.String
+	}
+	return v, nil
+}
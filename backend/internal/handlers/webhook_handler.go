@@ -0,0 +1,488 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/lib/pq"
+	"github.com/tassyosilva/GestGAS/internal/middleware"
+	"github.com/tassyosilva/GestGAS/internal/models"
+	"github.com/tassyosilva/GestGAS/internal/permissao"
+	"github.com/tassyosilva/GestGAS/internal/webhook"
+)
+
+// tamanhoMinimoSegredoWebhook evita segredos fáceis de adivinhar na assinatura HMAC
+const tamanhoMinimoSegredoWebhook = 16
+
+// consultaWebhook seleciona os webhooks sem o segredo, que nunca é devolvido pela API
+const consultaWebhook = `
+	SELECT id, url, eventos, COALESCE(descricao, ''), ativo, criado_em, atualizado_em
+	FROM webhooks
+`
+
+// ListarWebhooksHandler retorna os webhooks cadastrados
+func ListarWebhooksHandler(db *sql.DB) http.HandlerFunc {
+	return middleware.RequerPermissao(permissao.WebhookGerenciar)(func(w http.ResponseWriter, r *http.Request) {
+		// Configurar cabeçalhos
+		w.Header().Set("Content-Type", "application/json")
+
+		// Verificar método
+		if r.Method != http.MethodGet {
+			http.Error(w, "Método não permitido", http.StatusMethodNotAllowed)
+			return
+		}
+
+		rows, err := db.Query(consultaWebhook + " ORDER BY id")
+		if err != nil {
+			http.Error(w, "Erro ao buscar webhooks: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		webhooks := []models.Webhook{}
+		for rows.Next() {
+			wh, err := escanearWebhook(rows)
+			if err != nil {
+				http.Error(w, "Erro ao processar webhooks: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+			webhooks = append(webhooks, wh)
+		}
+		if err := rows.Err(); err != nil {
+			http.Error(w, "Erro ao processar webhooks: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		json.NewEncoder(w).Encode(webhooks)
+	})
+}
+
+// CriarWebhookHandler cadastra um webhook
+func CriarWebhookHandler(db *sql.DB) http.HandlerFunc {
+	return middleware.RequerPermissao(permissao.WebhookGerenciar)(func(w http.ResponseWriter, r *http.Request) {
+		// Configurar cabeçalhos
+		w.Header().Set("Content-Type", "application/json")
+
+		// Verificar método
+		if r.Method != http.MethodPost {
+			http.Error(w, "Método não permitido", http.StatusMethodNotAllowed)
+			return
+		}
+
+		// Decodificar requisição
+		var req models.WebhookRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Erro ao processar requisição: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// Validar dados
+		if msg := validarWebhook(&req, true); msg != "" {
+			http.Error(w, msg, http.StatusBadRequest)
+			return
+		}
+		ativo := true
+		if req.Ativo != nil {
+			ativo = *req.Ativo
+		}
+
+		var id int
+		err := db.QueryRow(`
+			INSERT INTO webhooks (url, segredo, eventos, descricao, ativo)
+			VALUES ($1, $2, $3, NULLIF($4, ''), $5)
+			RETURNING id
+		`, req.URL, req.Segredo, pq.Array(req.Eventos), req.Descricao, ativo).Scan(&id)
+		if err != nil {
+			http.Error(w, "Erro ao cadastrar webhook: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		wh, err := escanearWebhook(db.QueryRow(consultaWebhook+" WHERE id = $1", id))
+		if err != nil {
+			http.Error(w, "Webhook cadastrado, mas erro ao buscar informações: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(wh)
+	})
+}
+
+// ObterWebhookHandler retorna um webhook pelo ID
+func ObterWebhookHandler(db *sql.DB) http.HandlerFunc {
+	return middleware.RequerPermissao(permissao.WebhookGerenciar)(func(w http.ResponseWriter, r *http.Request) {
+		// Configurar cabeçalhos
+		w.Header().Set("Content-Type", "application/json")
+
+		// Verificar método
+		if r.Method != http.MethodGet {
+			http.Error(w, "Método não permitido", http.StatusMethodNotAllowed)
+			return
+		}
+
+		// Extrair ID da URL (/api/webhooks/{id})
+		id, err := strconv.Atoi(strings.Split(r.URL.Path, "/")[3])
+		if err != nil {
+			http.Error(w, "ID do webhook inválido", http.StatusBadRequest)
+			return
+		}
+
+		wh, err := escanearWebhook(db.QueryRow(consultaWebhook+" WHERE id = $1", id))
+		if err == sql.ErrNoRows {
+			http.Error(w, "Webhook não encontrado", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Erro ao buscar webhook: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		json.NewEncoder(w).Encode(wh)
+	})
+}
+
+// AtualizarWebhookHandler altera um webhook. Segredo vazio mantém o atual; ativo omitido não muda.
+func AtualizarWebhookHandler(db *sql.DB) http.HandlerFunc {
+	return middleware.RequerPermissao(permissao.WebhookGerenciar)(func(w http.ResponseWriter, r *http.Request) {
+		// Configurar cabeçalhos
+		w.Header().Set("Content-Type", "application/json")
+
+		// Verificar método
+		if r.Method != http.MethodPut && r.Method != http.MethodPatch {
+			http.Error(w, "Método não permitido", http.StatusMethodNotAllowed)
+			return
+		}
+
+		// Extrair ID da URL (/api/webhooks/{id})
+		id, err := strconv.Atoi(strings.Split(r.URL.Path, "/")[3])
+		if err != nil {
+			http.Error(w, "ID do webhook inválido", http.StatusBadRequest)
+			return
+		}
+
+		// Decodificar requisição
+		var req models.WebhookRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Erro ao processar requisição: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// Validar dados
+		if msg := validarWebhook(&req, false); msg != "" {
+			http.Error(w, msg, http.StatusBadRequest)
+			return
+		}
+
+		res, err := db.Exec(`
+			UPDATE webhooks
+			SET url = $1, segredo = COALESCE(NULLIF($2, ''), segredo), eventos = $3,
+				descricao = NULLIF($4, ''), ativo = COALESCE($5, ativo), atualizado_em = NOW()
+			WHERE id = $6
+		`, req.URL, req.Segredo, pq.Array(req.Eventos), req.Descricao, req.Ativo, id)
+		if err != nil {
+			http.Error(w, "Erro ao atualizar webhook: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			http.Error(w, "Webhook não encontrado", http.StatusNotFound)
+			return
+		}
+
+		wh, err := escanearWebhook(db.QueryRow(consultaWebhook+" WHERE id = $1", id))
+		if err != nil {
+			http.Error(w, "Webhook atualizado, mas erro ao buscar informações: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		json.NewEncoder(w).Encode(wh)
+	})
+}
+
+// ExcluirWebhookHandler remove um webhook e o registro das suas entregas
+func ExcluirWebhookHandler(db *sql.DB) http.HandlerFunc {
+	return middleware.RequerPermissao(permissao.WebhookGerenciar)(func(w http.ResponseWriter, r *http.Request) {
+		// Configurar cabeçalhos
+		w.Header().Set("Content-Type", "application/json")
+
+		// Verificar método
+		if r.Method != http.MethodDelete {
+			http.Error(w, "Método não permitido", http.StatusMethodNotAllowed)
+			return
+		}
+
+		// Extrair ID da URL (/api/webhooks/{id})
+		id, err := strconv.Atoi(strings.Split(r.URL.Path, "/")[3])
+		if err != nil {
+			http.Error(w, "ID do webhook inválido", http.StatusBadRequest)
+			return
+		}
+
+		res, err := db.Exec("DELETE FROM webhooks WHERE id = $1", id)
+		if err != nil {
+			http.Error(w, "Erro ao excluir webhook: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			http.Error(w, "Webhook não encontrado", http.StatusNotFound)
+			return
+		}
+
+		json.NewEncoder(w).Encode(map[string]string{
+			"message": "Webhook excluído com sucesso",
+		})
+	})
+}
+
+// ListarEntregasWebhookHandler retorna o registro de entregas de um webhook, das mais recentes
+// para as mais antigas (parâmetros status e limit, padrão 50)
+func ListarEntregasWebhookHandler(db *sql.DB) http.HandlerFunc {
+	return middleware.RequerPermissao(permissao.WebhookGerenciar)(func(w http.ResponseWriter, r *http.Request) {
+		// Configurar cabeçalhos
+		w.Header().Set("Content-Type", "application/json")
+
+		// Verificar método
+		if r.Method != http.MethodGet {
+			http.Error(w, "Método não permitido", http.StatusMethodNotAllowed)
+			return
+		}
+
+		// Extrair ID da URL (/api/webhooks/{id}/entregas)
+		webhookID, err := strconv.Atoi(strings.Split(r.URL.Path, "/")[3])
+		if err != nil {
+			http.Error(w, "ID do webhook inválido", http.StatusBadRequest)
+			return
+		}
+
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		if limit < 1 || limit > 500 {
+			limit = 50
+		}
+
+		query := consultaEntregaWebhook + " WHERE e.webhook_id = $1"
+		params := []interface{}{webhookID}
+		if status := r.URL.Query().Get("status"); status != "" {
+			params = append(params, status)
+			query += " AND e.status = $" + strconv.Itoa(len(params))
+		}
+		params = append(params, limit)
+		query += " ORDER BY e.id DESC LIMIT $" + strconv.Itoa(len(params))
+
+		rows, err := db.Query(query, params...)
+		if err != nil {
+			http.Error(w, "Erro ao buscar entregas: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		entregas := []models.EntregaWebhook{}
+		for rows.Next() {
+			e, err := escanearEntregaWebhook(rows)
+			if err != nil {
+				http.Error(w, "Erro ao processar entregas: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+			entregas = append(entregas, e)
+		}
+		if err := rows.Err(); err != nil {
+			http.Error(w, "Erro ao processar entregas: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		json.NewEncoder(w).Encode(entregas)
+	})
+}
+
+// ObterEntregaWebhookHandler retorna uma entrega com o conteúdo do evento e todas as tentativas
+func ObterEntregaWebhookHandler(db *sql.DB) http.HandlerFunc {
+	return middleware.RequerPermissao(permissao.WebhookGerenciar)(func(w http.ResponseWriter, r *http.Request) {
+		// Configurar cabeçalhos
+		w.Header().Set("Content-Type", "application/json")
+
+		// Verificar método
+		if r.Method != http.MethodGet {
+			http.Error(w, "Método não permitido", http.StatusMethodNotAllowed)
+			return
+		}
+
+		// Extrair ID da URL (/api/webhooks/entregas/{id})
+		entregaID, err := strconv.ParseInt(strings.Split(r.URL.Path, "/")[4], 10, 64)
+		if err != nil {
+			http.Error(w, "ID da entrega inválido", http.StatusBadRequest)
+			return
+		}
+
+		e, err := escanearEntregaWebhook(db.QueryRow(consultaEntregaWebhook+" WHERE e.id = $1", entregaID))
+		if err == sql.ErrNoRows {
+			http.Error(w, "Entrega não encontrada", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Erro ao buscar entrega: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		err = db.QueryRow("SELECT dados FROM webhooks_outbox WHERE id = $1", e.EventoID).Scan(&e.Dados)
+		if err != nil {
+			http.Error(w, "Erro ao buscar evento da entrega: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		rows, err := db.Query(`
+			SELECT id, status_http, COALESCE(erro, ''), duracao_ms, criado_em
+			FROM webhooks_tentativas
+			WHERE entrega_id = $1
+			ORDER BY id
+		`, entregaID)
+		if err != nil {
+			http.Error(w, "Erro ao buscar tentativas: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		e.HistoricoEnvios = []models.TentativaWebhook{}
+		for rows.Next() {
+			var t models.TentativaWebhook
+			var statusHTTP sql.NullInt64
+			if err := rows.Scan(&t.ID, &statusHTTP, &t.Erro, &t.DuracaoMs, &t.CriadoEm); err != nil {
+				http.Error(w, "Erro ao processar tentativas: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+			if statusHTTP.Valid {
+				status := int(statusHTTP.Int64)
+				t.StatusHTTP = &status
+			}
+			e.HistoricoEnvios = append(e.HistoricoEnvios, t)
+		}
+		if err := rows.Err(); err != nil {
+			http.Error(w, "Erro ao processar tentativas: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		json.NewEncoder(w).Encode(e)
+	})
+}
+
+// ReenviarEntregaWebhookHandler coloca uma entrega (falha ou já entregue) de novo na fila de envio
+func ReenviarEntregaWebhookHandler(db *sql.DB) http.HandlerFunc {
+	return middleware.RequerPermissao(permissao.WebhookGerenciar)(func(w http.ResponseWriter, r *http.Request) {
+		// Configurar cabeçalhos
+		w.Header().Set("Content-Type", "application/json")
+
+		// Verificar método
+		if r.Method != http.MethodPost {
+			http.Error(w, "Método não permitido", http.StatusMethodNotAllowed)
+			return
+		}
+
+		// Extrair ID da URL (/api/webhooks/entregas/{id}/reenviar)
+		entregaID, err := strconv.ParseInt(strings.Split(r.URL.Path, "/")[4], 10, 64)
+		if err != nil {
+			http.Error(w, "ID da entrega inválido", http.StatusBadRequest)
+			return
+		}
+
+		if err := webhook.Reenviar(db, entregaID); err != nil {
+			if err == sql.ErrNoRows {
+				http.Error(w, "Entrega não encontrada", http.StatusNotFound)
+				return
+			}
+			http.Error(w, "Erro ao reenviar entrega: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		e, err := escanearEntregaWebhook(db.QueryRow(consultaEntregaWebhook+" WHERE e.id = $1", entregaID))
+		if err != nil {
+			http.Error(w, "Entrega reenviada, mas erro ao buscar informações: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(e)
+	})
+}
+
+// consultaEntregaWebhook seleciona as entregas com o tipo do evento
+const consultaEntregaWebhook = `
+	SELECT e.id, e.webhook_id, e.evento_id, o.tipo, e.status, e.tentativas, e.proxima_tentativa,
+	       e.ultimo_status_http, COALESCE(e.ultimo_erro, ''), e.entregue_em, e.criado_em
+	FROM webhooks_entregas e
+	JOIN webhooks_outbox o ON e.evento_id = o.id
+`
+
+func escanearEntregaWebhook(row interface{ Scan(...interface{}) error }) (models.EntregaWebhook, error) {
+	var e models.EntregaWebhook
+	var proxima, entregueEm sql.NullTime
+	var statusHTTP sql.NullInt64
+	err := row.Scan(&e.ID, &e.WebhookID, &e.EventoID, &e.TipoEvento, &e.Status, &e.Tentativas, &proxima,
+		&statusHTTP, &e.UltimoErro, &entregueEm, &e.CriadoEm)
+	if err != nil {
+		return e, err
+	}
+	if proxima.Valid && e.Status == models.EntregaWebhookPendente {
+		e.ProximaTentativa = &proxima.Time
+	}
+	if statusHTTP.Valid {
+		status := int(statusHTTP.Int64)
+		e.UltimoStatusHTTP = &status
+	}
+	if entregueEm.Valid {
+		e.EntregueEm = &entregueEm.Time
+	}
+	return e, nil
+}
+
+func escanearWebhook(row interface{ Scan(...interface{}) error }) (models.Webhook, error) {
+	var wh models.Webhook
+	err := row.Scan(&wh.ID, &wh.URL, pq.Array(&wh.Eventos), &wh.Descricao, &wh.Ativo, &wh.CriadoEm, &wh.AtualizadoEm)
+	return wh, err
+}
+
+// validarWebhook normaliza e valida o cadastro; o segredo só é obrigatório na criação
+func validarWebhook(req *models.WebhookRequest, novo bool) string {
+	req.URL = strings.TrimSpace(req.URL)
+	u, err := url.Parse(req.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "URL inválida (use http:// ou https://)"
+	}
+	if len(req.URL) > 500 {
+		return "URL muito longa (máximo de 500 caracteres)"
+	}
+	if err := webhook.ValidarDestino(req.URL); err != nil {
+		return err.Error()
+	}
+
+	if req.Segredo != "" || novo {
+		if len(req.Segredo) < tamanhoMinimoSegredoWebhook {
+			return "O segredo deve ter ao menos " + strconv.Itoa(tamanhoMinimoSegredoWebhook) + " caracteres"
+		}
+		if len(req.Segredo) > 200 {
+			return "Segredo muito longo (máximo de 200 caracteres)"
+		}
+	}
+
+	if len(req.Eventos) == 0 {
+		return "Informe ao menos um evento"
+	}
+	vistos := map[string]bool{}
+	eventos := make([]string, 0, len(req.Eventos))
+	for _, evento := range req.Eventos {
+		if !webhook.EventoSuportado(evento) {
+			return "Evento não suportado: " + evento + " (use " + strings.Join(webhook.EventosSuportados, ", ") + ")"
+		}
+		if !vistos[evento] {
+			vistos[evento] = true
+			eventos = append(eventos, evento)
+		}
+	}
+	req.Eventos = eventos
+
+	req.Descricao = strings.TrimSpace(req.Descricao)
+	if len([]rune(req.Descricao)) > 200 {
+		return "Descrição muito longa (máximo de 200 caracteres)"
+	}
+	return ""
+}
@@ -3,10 +3,12 @@ package middleware
 import (
 	"context"
 	"database/sql"
+	"fmt"
 	"net/http"
 	"strings"
 
 	"github.com/tassyosilva/GestGAS/internal/auth"
+	"github.com/tassyosilva/GestGAS/internal/permissao"
 )
 
 // UsuarioKey é a chave utilizada para armazenar o ID do usuário no contexto
@@ -15,6 +17,12 @@ type UsuarioKey string
 // PerfilKey é a chave utilizada para armazenar o perfil do usuário no contexto
 type PerfilKey string
 
+// SessaoKey é a chave utilizada para armazenar a sessão do token no contexto
+type SessaoKey string
+
+// RotaTrocarSenha é a única rota liberada enquanto o usuário precisa trocar a senha provisória
+const RotaTrocarSenha = "/api/usuarios/me/senha"
+
 // AuthMiddleware verifica se a requisição possui um token JWT válido
 func AuthMiddleware(db *sql.DB) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
@@ -42,24 +50,68 @@ func AuthMiddleware(db *sql.DB) func(http.Handler) http.Handler {
 				return
 			}
 			
-			// Verificar se o usuário ainda existe e está ativo
-			var exists bool
-			err = db.QueryRow("SELECT EXISTS(SELECT 1 FROM usuarios WHERE id = $1)", claims.UserID).Scan(&exists)
-			if err != nil || !exists {
-				http.Error(w, "Usuário não encontrado ou inativo", http.StatusUnauthorized)
+			seguirAutenticado(db, w, r, next, claims)
+		})
+	}
+}
+
+// AuthStreamMiddleware autentica as conexões de eventos (Server-Sent Events). Clientes que
+// conseguem enviar cabeçalhos usam o JWT de acesso normalmente; o EventSource do navegador,
+// que não envia cabeçalhos, passa no parâmetro token um token de stream obtido em
+// POST /api/eventos/token. O token de acesso nunca é aceito na URL.
+func AuthStreamMiddleware(db *sql.DB) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		autenticado := AuthMiddleware(db)(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") != "" {
+				autenticado.ServeHTTP(w, r)
 				return
 			}
-			
-			// Adicionar o ID do usuário e perfil ao contexto da requisição
-			ctx := context.WithValue(r.Context(), UsuarioKey("usuarioID"), claims.UserID)
-			ctx = context.WithValue(ctx, PerfilKey("perfil"), claims.Perfil)
-			
-			// Chamar o próximo handler com o contexto atualizado
-			next.ServeHTTP(w, r.WithContext(ctx))
+
+			tokenString := r.URL.Query().Get("token")
+			if tokenString == "" {
+				http.Error(w, "Token de autenticação não fornecido", http.StatusUnauthorized)
+				return
+			}
+			claims, err := auth.ValidarTokenStream(tokenString)
+			if err != nil {
+				http.Error(w, "Token de stream inválido ou expirado: "+err.Error(), http.StatusUnauthorized)
+				return
+			}
+			seguirAutenticado(db, w, r, next, claims)
 		})
 	}
 }
 
+// seguirAutenticado confere a sessão do token e chama o próximo handler com o usuário no contexto
+func seguirAutenticado(db *sql.DB, w http.ResponseWriter, r *http.Request, next http.Handler, claims *auth.Claims) {
+	// Verificar se a sessão do token não foi encerrada ou revogada e se o usuário
+	// ainda existe e está ativo
+	ativa, deveTrocarSenha, err := auth.SessaoAtiva(db, claims.SessaoID, claims.UserID)
+	if err != nil {
+		http.Error(w, "Erro ao verificar sessão", http.StatusInternalServerError)
+		return
+	}
+	if !ativa {
+		http.Error(w, "Sessão encerrada ou revogada, ou usuário inativo", http.StatusUnauthorized)
+		return
+	}
+
+	// Com senha provisória, o usuário só pode trocar a senha (ou sair)
+	if deveTrocarSenha && !(r.Method == http.MethodPost && (r.URL.Path == RotaTrocarSenha || r.URL.Path == "/api/logout")) {
+		http.Error(w, "É necessário trocar a senha antes de continuar", http.StatusForbidden)
+		return
+	}
+
+	// Adicionar o ID do usuário, o perfil e a sessão ao contexto da requisição
+	ctx := context.WithValue(r.Context(), UsuarioKey("usuarioID"), claims.UserID)
+	ctx = context.WithValue(ctx, PerfilKey("perfil"), claims.Perfil)
+	ctx = context.WithValue(ctx, SessaoKey("sessaoID"), claims.SessaoID)
+
+	// Chamar o próximo handler com o contexto atualizado
+	next.ServeHTTP(w, r.WithContext(ctx))
+}
+
 // ObterUsuarioID extrai o ID do usuário do contexto da requisição
 func ObterUsuarioID(r *http.Request) (int, bool) {
 	userID, ok := r.Context().Value(UsuarioKey("usuarioID")).(int)
@@ -72,22 +124,28 @@ func ObterPerfilUsuario(r *http.Request) (string, bool) {
 	return perfil, ok
 }
 
-// VerificarPerfil verifica se o usuário possui um determinado perfil
-func VerificarPerfil(perfil string, perfilRequerido string) bool {
-	// Se o perfil requerido for "admin", apenas admin pode acessar
-	// Se for "gerente", admin e gerente podem acessar
-	// Se for "atendente", admin, gerente e atendente podem acessar
-	// Se for "entregador", qualquer um pode acessar
-	switch perfilRequerido {
-	case "admin":
-		return perfil == "admin"
-	case "gerente":
-		return perfil == "admin" || perfil == "gerente"
-	case "atendente":
-		return perfil == "admin" || perfil == "gerente" || perfil == "atendente"
-	case "entregador":
-		return true // Todos os perfis podem acessar
-	default:
-		return false
+// ObterSessaoID extrai a sessão do token do contexto da requisição
+func ObterSessaoID(r *http.Request) (int, bool) {
+	sessaoID, ok := r.Context().Value(SessaoKey("sessaoID")).(int)
+	return sessaoID, ok
+}
+
+// PossuiPermissao indica se o perfil do usuário autenticado tem a permissão
+func PossuiPermissao(r *http.Request, codigo string) bool {
+	perfil, ok := ObterPerfilUsuario(r)
+	return ok && permissao.Possui(perfil, codigo)
+}
+
+// RequerPermissao envolve um handler para que ele só seja executado por perfis com a permissão;
+// os demais recebem 403
+func RequerPermissao(codigo string) func(http.HandlerFunc) http.HandlerFunc {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if !PossuiPermissao(r, codigo) {
+				http.Error(w, fmt.Sprintf("Sem permissão: %s (%s)", permissao.Descricao(codigo), codigo), http.StatusForbidden)
+				return
+			}
+			next(w, r)
+		}
 	}
-}
\ No newline at end of file
+}
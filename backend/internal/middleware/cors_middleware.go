@@ -5,27 +5,32 @@ import (
 )
 
 // CorsMiddleware configura o middleware CORS para permitir requisições cross-origin
-func CorsMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Obtenha a origem da requisição
-		origin := r.Header.Get("Origin")
-		
-		// LISTA DE ORIGENS PERMITIDAS
-		allowedOrigins := map[string]bool{
-			"http://localhost:3000": true,
-		}
-		
-		// Verifique se a origem está na lista de permitidas
-		if allowedOrigins[origin] {
-			w.Header().Set("Access-Control-Allow-Origin", origin)
-		}
-		
-		w.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, DELETE, PATCH")
-		w.Header().Set("Access-Control-Allow-Headers", "Accept, Content-Type, Content-Length, Authorization")
-		if r.Method == "OPTIONS" {
-			w.WriteHeader(http.StatusOK)
-			return
-		}
-		next.ServeHTTP(w, r)
-	})
-}
\ No newline at end of file
+// das origens informadas ("*" libera qualquer origem)
+func CorsMiddleware(origensPermitidas []string) func(http.Handler) http.Handler {
+	// LISTA DE ORIGENS PERMITIDAS
+	allowedOrigins := make(map[string]bool, len(origensPermitidas))
+	for _, origem := range origensPermitidas {
+		allowedOrigins[origem] = true
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Obtenha a origem da requisição
+			origin := r.Header.Get("Origin")
+
+			// Verifique se a origem está na lista de permitidas
+			if origin != "" && (allowedOrigins[origin] || allowedOrigins["*"]) {
+				w.Header().Set("Access-Control-Allow-Origin", origin)
+				w.Header().Add("Vary", "Origin")
+			}
+
+			w.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, DELETE, PATCH")
+			w.Header().Set("Access-Control-Allow-Headers", "Accept, Content-Type, Content-Length, Authorization")
+			if r.Method == "OPTIONS" {
+				w.WriteHeader(http.StatusOK)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
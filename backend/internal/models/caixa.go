@@ -0,0 +1,94 @@
+package models
+
+import "time"
+
+// StatusCaixa define os possíveis estados de uma sessão de caixa
+type StatusCaixa string
+
+const (
+	CaixaAberto  StatusCaixa = "aberto"
+	CaixaFechado StatusCaixa = "fechado"
+)
+
+// TipoMovimentacaoCaixa define as movimentações manuais de dinheiro no caixa
+type TipoMovimentacaoCaixa string
+
+const (
+	MovimentacaoSangria    TipoMovimentacaoCaixa = "sangria"    // Retirada de dinheiro da gaveta
+	MovimentacaoSuprimento TipoMovimentacaoCaixa = "suprimento" // Reforço de troco
+)
+
+// FormasPagamentoCaixa são as formas conferidas no fechamento, na ordem do relatório
+var FormasPagamentoCaixa = []FormaPagamento{
+	PagamentoDinheiro, PagamentoPix, PagamentoCartaoDebito, PagamentoCartaoCredito, PagamentoFiado,
+}
+
+// Caixa representa uma sessão de caixa de um atendente
+type Caixa struct {
+	ID                    int                 `json:"id"`
+	Usuario               UsuarioBasico       `json:"usuario"`
+	Status                StatusCaixa         `json:"status"`
+	ValorAbertura         float64             `json:"valor_abertura"`
+	ObservacoesAbertura   string              `json:"observacoes_abertura,omitempty"`
+	AbertoEm              time.Time           `json:"aberto_em"`
+	FechadoEm             *time.Time          `json:"fechado_em,omitempty"`
+	FechadoPor            *UsuarioBasico      `json:"fechado_por,omitempty"`
+	ObservacoesFechamento string              `json:"observacoes_fechamento,omitempty"`
+	Movimentacoes         []MovimentacaoCaixa `json:"movimentacoes,omitempty"`
+	Resumo                *ResumoCaixa        `json:"resumo,omitempty"`
+}
+
+// MovimentacaoCaixa representa uma sangria ou um suprimento
+type MovimentacaoCaixa struct {
+	ID        int                   `json:"id"`
+	CaixaID   int                   `json:"caixa_id"`
+	Tipo      TipoMovimentacaoCaixa `json:"tipo"`
+	Valor     float64               `json:"valor"`
+	Motivo    string                `json:"motivo,omitempty"`
+	UsuarioID int                   `json:"usuario_id"`
+	CriadoEm  time.Time             `json:"criado_em"`
+}
+
+// ResumoCaixa é a conferência do caixa: parcial enquanto aberto, definitiva após o fechamento
+type ResumoCaixa struct {
+	TotalPedidos           int                         `json:"total_pedidos"`
+	TotalVendas            float64                     `json:"total_vendas"`
+	TotalVendasAntecipadas float64                     `json:"total_vendas_antecipadas"` // Vales-gás vendidos no caixa
+	TotalRecebimentosFiado float64                     `json:"total_recebimentos_fiado"` // Pagamentos de vendas fiadas recebidos no caixa
+	TotalSangrias          float64                     `json:"total_sangrias"`
+	TotalSuprimentos       float64                     `json:"total_suprimentos"`
+	Formas                 []ConferenciaFormaPagamento `json:"formas"`
+	DiferencaTotal         float64                     `json:"diferenca_total"` // Soma das diferenças das formas declaradas
+}
+
+// ConferenciaFormaPagamento compara o esperado com o declarado para uma forma de pagamento.
+// O esperado soma os pedidos, os vales-gás vendidos e os pagamentos de fiado recebidos; no
+// dinheiro, inclui também o troco inicial, os suprimentos e desconta as sangrias.
+type ConferenciaFormaPagamento struct {
+	FormaPagamento         FormaPagamento `json:"forma_pagamento"`
+	QuantidadePedidos      int            `json:"quantidade_pedidos"`
+	ValorVendas            float64        `json:"valor_vendas"`
+	ValorVendasAntecipadas float64        `json:"valor_vendas_antecipadas"`
+	ValorRecebimentosFiado float64        `json:"valor_recebimentos_fiado"`
+	ValorEsperado          float64        `json:"valor_esperado"`
+	ValorDeclarado         *float64       `json:"valor_declarado,omitempty"`
+	Diferenca              *float64       `json:"diferenca,omitempty"` // Declarado - esperado
+}
+
+// AbrirCaixaRequest é a estrutura para abrir um caixa
+type AbrirCaixaRequest struct {
+	ValorAbertura float64 `json:"valor_abertura"`
+	Observacoes   string  `json:"observacoes,omitempty"`
+}
+
+// MovimentacaoCaixaRequest é a estrutura para registrar uma sangria ou um suprimento
+type MovimentacaoCaixaRequest struct {
+	Valor  float64 `json:"valor"`
+	Motivo string  `json:"motivo,omitempty"`
+}
+
+// FecharCaixaRequest é a estrutura para fechar um caixa com os valores contados
+type FecharCaixaRequest struct {
+	ValoresDeclarados map[FormaPagamento]float64 `json:"valores_declarados"`
+	Observacoes       string                     `json:"observacoes,omitempty"`
+}
@@ -0,0 +1,47 @@
+package models
+
+import (
+	"time"
+)
+
+// NovaChamadaRequest é a chamada enviada por um bina ou simulador via HTTP
+type NovaChamadaRequest struct {
+	Numero string `json:"numero"`
+	Ramal  string `json:"ramal,omitempty"`
+}
+
+// ChamadaRecebida é o evento enviado à tela do atendente quando o bina identifica uma ligação
+type ChamadaRecebida struct {
+	ID           int              `json:"id"`
+	Numero       string           `json:"numero"` // Como recebido do bina
+	TelefoneE164 string           `json:"telefone_e164,omitempty"`
+	Ramal        string           `json:"ramal,omitempty"`
+	Clientes     []ClienteChamada `json:"clientes"` // Vazio quando o número não está cadastrado
+	RecebidaEm   time.Time        `json:"recebida_em"`
+}
+
+// ClienteChamada reúne o cliente identificado na ligação e o último pedido dele
+type ClienteChamada struct {
+	ID             int                `json:"id"`
+	Nome           string             `json:"nome"`
+	Telefone       string             `json:"telefone"`
+	Endereco       string             `json:"endereco,omitempty"`
+	Complemento    string             `json:"complemento,omitempty"`
+	Bairro         string             `json:"bairro,omitempty"`
+	Cidade         string             `json:"cidade,omitempty"`
+	Observacoes    string             `json:"observacoes,omitempty"`
+	UltimoPedido   *PedidoResumido    `json:"ultimo_pedido,omitempty"`
+	PedidoSugerido *NovoPedidoRequest `json:"pedido_sugerido,omitempty"` // Repetição do último pedido, pronta para POST /api/pedidos/
+}
+
+// RegistroChamada é uma ligação já recebida, exibida na lista de chamadas recentes
+type RegistroChamada struct {
+	ID                  int       `json:"id"`
+	Numero              string    `json:"numero"`
+	TelefoneE164        string    `json:"telefone_e164,omitempty"`
+	Ramal               string    `json:"ramal,omitempty"`
+	ClienteID           *int      `json:"cliente_id,omitempty"`
+	NomeCliente         string    `json:"nome_cliente,omitempty"`
+	ClientesEncontrados int       `json:"clientes_encontrados"`
+	RecebidaEm          time.Time `json:"recebida_em"`
+}
@@ -19,6 +19,8 @@ type Cliente struct {
 	CEP          string     `json:"cep,omitempty"`
 	Observacoes  string     `json:"observacoes,omitempty"`
 	CanalOrigem  CanalOrigem `json:"canal_origem,omitempty"`
+	Latitude     *float64   `json:"latitude,omitempty"`
+	Longitude    *float64   `json:"longitude,omitempty"`
 	CriadoEm     time.Time  `json:"criado_em"`
 	AtualizadoEm time.Time  `json:"atualizado_em"`
 }
@@ -37,6 +39,8 @@ type NovoClienteRequest struct {
 	CEP         string     `json:"cep,omitempty"`
 	Observacoes string     `json:"observacoes,omitempty"`
 	CanalOrigem CanalOrigem `json:"canal_origem,omitempty"`
+	Latitude    *float64   `json:"latitude,omitempty"`  // Coordenadas do endereço, usadas no roteiro de entregas
+	Longitude   *float64   `json:"longitude,omitempty"`
 }
 
 // ClienteResponse é a estrutura de resposta para consulta de clientes
@@ -54,8 +58,12 @@ type ClienteResponse struct {
 	CEP          string     `json:"cep,omitempty"`
 	Observacoes  string     `json:"observacoes,omitempty"`
 	CanalOrigem  CanalOrigem `json:"canal_origem,omitempty"`
+	Latitude     *float64   `json:"latitude,omitempty"`
+	Longitude    *float64   `json:"longitude,omitempty"`
 	UltimosPedidos []PedidoResumido `json:"ultimos_pedidos,omitempty"`
 	TotalPedidos int        `json:"total_pedidos"`
+	SaldoFiado   float64    `json:"saldo_fiado"`          // Total em aberto de vendas fiadas
+	SaldoFiadoVencido float64 `json:"saldo_fiado_vencido"` // Parte do saldo fiado já vencida
 	CriadoEm     time.Time  `json:"criado_em"`
 	AtualizadoEm time.Time  `json:"atualizado_em"`
 }
@@ -77,4 +85,64 @@ type ClienteEnderecoRequest struct {
 	Cidade      string `json:"cidade,omitempty"`
 	Estado      string `json:"estado,omitempty"`
 	CEP         string `json:"cep,omitempty"`
-}
\ No newline at end of file
+	Latitude    *float64 `json:"latitude,omitempty"`
+	Longitude   *float64 `json:"longitude,omitempty"`
+}
+// ImportacaoClientesResponse é o relatório da importação de clientes via CSV
+type ImportacaoClientesResponse struct {
+	Simulacao   bool                    `json:"simulacao"` // true quando nada foi gravado (dry_run)
+	TotalLinhas int                     `json:"total_linhas"`
+	Validas     int                     `json:"validas"`
+	Invalidas   int                     `json:"invalidas"`
+	Importados  int                     `json:"importados"`
+	Erros       []ErroImportacaoCliente `json:"erros"`
+}
+
+// ErroImportacaoCliente lista os problemas encontrados numa linha do arquivo
+type ErroImportacaoCliente struct {
+	Linha    int      `json:"linha"` // Linha do arquivo, contando o cabeçalho como 1
+	Telefone string   `json:"telefone,omitempty"`
+	Erros    []string `json:"erros"`
+}
+
+// GrupoClientesDuplicados reúne cadastros que provavelmente são da mesma pessoa
+type GrupoClientesDuplicados struct {
+	Motivos              []string           `json:"motivos"` // telefone, cpf e/ou nome_endereco
+	SobreviventeSugerido int                `json:"sobrevivente_sugerido"` // Cliente com mais pedidos (o mais antigo em caso de empate)
+	Clientes             []ClienteDuplicado `json:"clientes"`
+}
+
+// ClienteDuplicado é o resumo de um cliente dentro de um grupo de duplicados
+type ClienteDuplicado struct {
+	ID           int       `json:"id"`
+	Nome         string    `json:"nome"`
+	Telefone     string    `json:"telefone"`
+	CPF          string    `json:"cpf,omitempty"`
+	Endereco     string    `json:"endereco,omitempty"`
+	Bairro       string    `json:"bairro,omitempty"`
+	TotalPedidos int       `json:"total_pedidos"`
+	CriadoEm     time.Time `json:"criado_em"`
+}
+
+// MesclarClientesRequest lista os clientes que serão incorporados ao cliente da URL
+type MesclarClientesRequest struct {
+	Duplicados []int `json:"duplicados"`
+}
+
+// MesclagemCliente é o registro de auditoria de um cliente incorporado a outro
+type MesclagemCliente struct {
+	ID                int       `json:"id"`
+	ClienteID         int       `json:"cliente_id"`
+	ClienteRemovidoID int       `json:"cliente_removido_id"`
+	PedidosMovidos    int       `json:"pedidos_movidos"`
+	FiadosMovidos     int       `json:"fiados_movidos"`
+	ValesMovidos      int       `json:"vales_movidos"`
+	UsuarioID         int       `json:"usuario_id"`
+	CriadoEm          time.Time `json:"criado_em"`
+}
+
+// MesclarClientesResponse é a resposta da mesclagem de clientes
+type MesclarClientesResponse struct {
+	ClienteID  int                `json:"cliente_id"`
+	Mesclagens []MesclagemCliente `json:"mesclagens"`
+}
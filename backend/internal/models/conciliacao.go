@@ -0,0 +1,65 @@
+package models
+
+import (
+	"time"
+)
+
+// StatusConciliacao indica se o recebimento de um pedido foi encontrado no extrato bancário
+type StatusConciliacao string
+
+const (
+	ConciliacaoConciliado   StatusConciliacao = "conciliado"    // Vinculado a um crédito do extrato
+	ConciliacaoPendente     StatusConciliacao = "pendente"      // Pago por Pix ou cartão, ainda sem crédito no extrato
+	ConciliacaoNaoAplicavel StatusConciliacao = "nao_aplicavel" // Outras formas de pagamento ou pedido não entregue
+)
+
+// TipoConciliacao indica como a transação foi vinculada ao pedido
+type TipoConciliacao string
+
+const (
+	ConciliacaoAutomatica TipoConciliacao = "automatica"
+	ConciliacaoManual     TipoConciliacao = "manual"
+)
+
+// TransacaoBancaria é um crédito importado do extrato bancário
+type TransacaoBancaria struct {
+	ID            int              `json:"id"`
+	ImportacaoID  int              `json:"importacao_id"`
+	Identificador string           `json:"identificador"` // FITID do OFX ou identificador calculado para o CSV
+	Data          time.Time        `json:"data"`
+	Valor         float64          `json:"valor"`
+	Descricao     string           `json:"descricao,omitempty"`
+	TxID          string           `json:"txid,omitempty"`
+	PedidoID      *int             `json:"pedido_id,omitempty"`
+	Conciliacao   *TipoConciliacao `json:"conciliacao,omitempty"`
+	ConciliadoEm  *time.Time       `json:"conciliado_em,omitempty"`
+	CriadoEm      time.Time        `json:"criado_em"`
+}
+
+// ImportacaoExtratoResponse resume a importação de um extrato
+type ImportacaoExtratoResponse struct {
+	ImportacaoID          int    `json:"importacao_id"`
+	Formato               string `json:"formato"`
+	TransacoesLidas       int    `json:"transacoes_lidas"`
+	TransacoesImportadas  int    `json:"transacoes_importadas"`
+	TransacoesRepetidas   int    `json:"transacoes_repetidas"` // Já importadas em outro extrato
+	DebitosIgnorados      int    `json:"debitos_ignorados"`
+	ConciliadasAutomatico int    `json:"conciliadas_automaticamente"`
+}
+
+// PedidoNaoConciliado é um pedido pago por Pix ou cartão sem crédito correspondente no extrato
+type PedidoNaoConciliado struct {
+	ID             int            `json:"id"`
+	ClienteNome    string         `json:"cliente_nome"`
+	FormaPagamento FormaPagamento `json:"forma_pagamento"`
+	ValorTotal     float64        `json:"valor_total"`
+	Status         StatusPedido   `json:"status"`
+	TxID           string         `json:"txid,omitempty"` // Apenas para pedidos pagos por Pix
+	DataEntrega    *time.Time     `json:"data_entrega,omitempty"`
+	CriadoEm       time.Time      `json:"criado_em"`
+}
+
+// ConciliarTransacaoRequest vincula manualmente uma transação a um pedido
+type ConciliarTransacaoRequest struct {
+	PedidoID int `json:"pedido_id"`
+}
@@ -0,0 +1,37 @@
+package models
+
+// ParadaEntrega representa um pedido no roteiro de um entregador ou num lote de entrega
+type ParadaEntrega struct {
+	Ordem           int            `json:"ordem"`
+	PedidoID        int            `json:"pedido_id"`
+	Status          StatusPedido   `json:"status"`
+	Cliente         ClienteBasico  `json:"cliente"`
+	EnderecoEntrega string         `json:"endereco_entrega"`
+	Bairro          string         `json:"bairro,omitempty"`
+	CEP             string         `json:"cep,omitempty"`
+	Latitude        *float64       `json:"latitude,omitempty"`
+	Longitude       *float64       `json:"longitude,omitempty"`
+	DistanciaKm     *float64       `json:"distancia_km,omitempty"` // Distância desde a parada anterior (ou do depósito)
+	ValorTotal      float64        `json:"valor_total"`
+	FormaPagamento  FormaPagamento `json:"forma_pagamento"`
+}
+
+// RotaEntregador é a lista ordenada de paradas de um entregador
+type RotaEntregador struct {
+	Entregador       UsuarioBasico   `json:"entregador"`
+	Paradas          []ParadaEntrega `json:"paradas"`
+	DistanciaTotalKm float64         `json:"distancia_total_km"`
+	SemCoordenadas   int             `json:"sem_coordenadas"` // Paradas sem coordenadas, colocadas no fim do roteiro
+}
+
+// LoteEntrega agrupa pedidos em preparo, ainda sem entregador, da mesma região
+type LoteEntrega struct {
+	Regiao  string          `json:"regiao"` // Bairro ou, na falta dele, o prefixo do CEP
+	Pedidos []ParadaEntrega `json:"pedidos"`
+}
+
+// AtribuirLoteRequest é a estrutura para atribuir um lote de pedidos a um entregador
+type AtribuirLoteRequest struct {
+	EntregadorID int   `json:"entregador_id"`
+	PedidoIDs    []int `json:"pedido_ids"`
+}
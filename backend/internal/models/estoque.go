@@ -17,6 +17,26 @@ const (
 	MovimentacaoDevolucaoEmprestimo TipoMovimentacao = "devolucao_emprestimo" // Devolução de botijas emprestadas
 )
 
+// StatusReserva define os possíveis estados de uma reserva de estoque
+type StatusReserva string
+
+const (
+	ReservaAtiva      StatusReserva = "ativa"      // Unidades comprometidas com um pedido em aberto
+	ReservaConvertida StatusReserva = "convertida" // Pedido entregue, reserva convertida em saída
+	ReservaLiberada   StatusReserva = "liberada"   // Pedido cancelado, unidades devolvidas ao disponível
+)
+
+// ReservaEstoque representa as unidades de um produto reservadas para um pedido
+type ReservaEstoque struct {
+	ID           int           `json:"id"`
+	PedidoID     int           `json:"pedido_id"`
+	ProdutoID    int           `json:"produto_id"`
+	Quantidade   int           `json:"quantidade"`
+	Status       StatusReserva `json:"status"`
+	CriadoEm     time.Time     `json:"criado_em"`
+	AtualizadoEm time.Time     `json:"atualizado_em"`
+}
+
 // Estoque representa o estado atual do estoque de um produto
 type Estoque struct {
 	ID                int       `json:"id"`
@@ -58,10 +78,12 @@ type EstoqueResponse struct {
 	NomeProduto       string    `json:"nome_produto"`
 	Categoria         string    `json:"categoria"` // Categoria do produto
 	Quantidade        int       `json:"quantidade"`
+	Reservado         int       `json:"reservado"`  // Unidades comprometidas com pedidos ainda não entregues
+	Disponivel        int       `json:"disponivel"` // Quantidade que ainda pode ser vendida (quantidade - reservado)
 	BotijasVazias     int       `json:"botijas_vazias,omitempty"`
 	BotijasEmprestadas int       `json:"botijas_emprestadas,omitempty"`
 	AlertaMinimo      int       `json:"alerta_minimo,omitempty"`
-	Status            string    `json:"status"` // "normal", "baixo", "critico" baseado no alerta mínimo
+	Status            string    `json:"status"` // "normal", "baixo", "critico" baseado no alerta mínimo e no disponível
 	AtualizadoEm      time.Time `json:"atualizado_em"`
 }
 
@@ -84,6 +106,8 @@ type EstoqueAlertaResponse struct {
 	ProdutoID    int    `json:"produto_id"`
 	NomeProduto  string `json:"nome_produto"`
 	Quantidade   int    `json:"quantidade"`
+	Reservado    int    `json:"reservado"`
+	Disponivel   int    `json:"disponivel"`
 	AlertaMinimo int    `json:"alerta_minimo"`
 	Status       string `json:"status"` // "baixo" ou "critico"
 }
\ No newline at end of file
@@ -0,0 +1,62 @@
+package models
+
+import "time"
+
+// StatusVendaFiada define os possíveis estados de uma venda fiada
+type StatusVendaFiada string
+
+const (
+	FiadoPendente StatusVendaFiada = "pendente" // Nenhum pagamento registrado
+	FiadoParcial  StatusVendaFiada = "parcial"  // Pago parcialmente
+	FiadoPago     StatusVendaFiada = "pago"     // Quitado
+)
+
+// PrazoPadraoFiadoDias é o prazo de vencimento aplicado às vendas fiadas
+const PrazoPadraoFiadoDias = 30
+
+// VendaFiada representa uma conta a receber gerada por um pedido fiado
+type VendaFiada struct {
+	ID             int                   `json:"id"`
+	PedidoID       int                   `json:"pedido_id"`
+	ClienteID      int                   `json:"cliente_id"`
+	Cliente        *ClienteBasico        `json:"cliente,omitempty"`
+	ValorTotal     float64               `json:"valor_total"`
+	ValorPago      float64               `json:"valor_pago"`
+	Saldo          float64               `json:"saldo"`
+	DataVencimento time.Time             `json:"data_vencimento"`
+	Status         StatusVendaFiada      `json:"status"`
+	Vencido        bool                  `json:"vencido"` // Saldo em aberto com vencimento já ultrapassado
+	DataPagamento  *time.Time            `json:"data_pagamento,omitempty"`
+	Pagamentos     []PagamentoVendaFiada `json:"pagamentos,omitempty"`
+	CriadoEm       time.Time             `json:"criado_em"`
+	AtualizadoEm   time.Time             `json:"atualizado_em"`
+}
+
+// PagamentoVendaFiada representa um pagamento (total ou parcial) de uma venda fiada
+type PagamentoVendaFiada struct {
+	ID             int            `json:"id"`
+	VendaFiadaID   int            `json:"venda_fiada_id"`
+	Valor          float64        `json:"valor"`
+	FormaPagamento FormaPagamento `json:"forma_pagamento"`
+	Observacoes    string         `json:"observacoes,omitempty"`
+	UsuarioID      int            `json:"usuario_id"`
+	CaixaID        *int           `json:"caixa_id,omitempty"` // Caixa que recebeu o pagamento; vazio se quem recebeu não tinha caixa aberto
+	CriadoEm       time.Time      `json:"criado_em"`
+}
+
+// NovoPagamentoFiadoRequest é a estrutura para registrar um pagamento de venda fiada
+type NovoPagamentoFiadoRequest struct {
+	Valor          float64        `json:"valor"`
+	FormaPagamento FormaPagamento `json:"forma_pagamento"`
+	Observacoes    string         `json:"observacoes,omitempty"`
+}
+
+// SaldoFiadoCliente resume o que um cliente deve em vendas fiadas
+type SaldoFiadoCliente struct {
+	Cliente           ClienteBasico `json:"cliente"`
+	SaldoAberto       float64       `json:"saldo_aberto"`
+	SaldoVencido      float64       `json:"saldo_vencido"`
+	ContasAbertas     int           `json:"contas_abertas"`
+	ContasVencidas    int           `json:"contas_vencidas"`
+	ProximoVencimento *time.Time    `json:"proximo_vencimento,omitempty"`
+}
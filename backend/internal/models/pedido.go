@@ -51,6 +51,8 @@ type Pedido struct {
 	EnderecoEntrega string        `json:"endereco_entrega"`
 	CanalOrigem    CanalOrigem   `json:"canal_origem"`
 	DataEntrega    *time.Time    `json:"data_entrega,omitempty"` // Pode ser nulo inicialmente
+	AceitoEm       *time.Time    `json:"aceito_em,omitempty"`    // Quando o entregador aceitou a entrega
+	StatusConciliacao StatusConciliacao `json:"status_conciliacao"` // Recebimento encontrado no extrato bancário
 	Itens          []ItemPedido  `json:"itens,omitempty"` // Itens do pedido
 	CriadoEm       time.Time     `json:"criado_em"`
 	AtualizadoEm   time.Time     `json:"atualizado_em"`
@@ -85,12 +87,20 @@ type ItemPedidoRequest struct {
 	RetornaBotija bool `json:"retorna_botija,omitempty"`
 }
 
+// BotijaRetornada representa as botijas vazias de um produto devolvidas pelo cliente na entrega
+type BotijaRetornada struct {
+	ProdutoID   int    `json:"produto_id"`
+	NomeProduto string `json:"nome_produto"`
+	Quantidade  int    `json:"quantidade"`
+}
+
 // AtualizarStatusRequest é a estrutura para atualizar o status de um pedido
 type AtualizarStatusRequest struct {
 	Status             StatusPedido `json:"status"`
 	EntregadorID       *int         `json:"entregador_id,omitempty"`
 	DataEntrega        *time.Time   `json:"data_entrega,omitempty"`
 	MotivoCancelamento string       `json:"motivo_cancelamento,omitempty"`
+	Motivo             string       `json:"motivo,omitempty"` // Motivo da falha ao devolver um pedido em entrega para preparo
 }
 
 // PedidoResponse é a estrutura de resposta para pedidos
@@ -106,8 +116,11 @@ type PedidoResponse struct {
 	EnderecoEntrega string         `json:"endereco_entrega"`
 	CanalOrigem    CanalOrigem    `json:"canal_origem"`
 	DataEntrega    *time.Time     `json:"data_entrega,omitempty"`
+	AceitoEm       *time.Time     `json:"aceito_em,omitempty"`
 	MotivoCancelamento string          `json:"motivo_cancelamento,omitempty"`
+	StatusConciliacao StatusConciliacao `json:"status_conciliacao"`
 	Itens          []ItemPedido   `json:"itens"`
+	Historico      []HistoricoPedido `json:"historico"`
 	CriadoEm       time.Time      `json:"criado_em"`
 	AtualizadoEm   time.Time      `json:"atualizado_em"`
 }
@@ -125,3 +138,34 @@ type UsuarioBasico struct {
 	Nome   string `json:"nome"`
 	Perfil string `json:"perfil"`
 }
+
+// HistoricoPedido representa uma mudança de status registrada na linha do tempo do pedido
+type HistoricoPedido struct {
+	ID             int            `json:"id"`
+	PedidoID       int            `json:"pedido_id"`
+	StatusAnterior *StatusPedido  `json:"status_anterior,omitempty"` // Nulo na criação do pedido
+	StatusNovo     StatusPedido   `json:"status_novo"`
+	Usuario        *UsuarioBasico `json:"usuario,omitempty"`
+	Entregador     *UsuarioBasico `json:"entregador,omitempty"`
+	Motivo         string         `json:"motivo,omitempty"`
+	CriadoEm       time.Time      `json:"criado_em"`
+}
+
+// EventoPedido é o conteúdo dos eventos em tempo real de mudança de status e de entrega confirmada
+type EventoPedido struct {
+	PedidoID       int          `json:"pedido_id"`
+	StatusAnterior StatusPedido `json:"status_anterior"`
+	StatusNovo     StatusPedido `json:"status_novo"`
+	UsuarioID      int          `json:"usuario_id"`
+	EntregadorID   *int         `json:"entregador_id,omitempty"`
+	Motivo         string       `json:"motivo,omitempty"`
+}
+
+// CobrancaPix é o QR Code Pix de um pedido, exibido pelo entregador na hora do pagamento
+type CobrancaPix struct {
+	PedidoID  int     `json:"pedido_id"`
+	TxID      string  `json:"txid"`
+	Valor     float64 `json:"valor"`
+	Payload   string  `json:"payload"`    // Conteúdo do QR Code e texto do "Pix copia e cola"
+	ImagemPNG string  `json:"imagem_png"` // QR Code em PNG, como data URI
+}
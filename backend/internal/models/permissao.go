@@ -0,0 +1,19 @@
+package models
+
+// Permissao é uma ação do sistema e os perfis autorizados a executá-la
+type Permissao struct {
+	Codigo    string   `json:"codigo"`
+	Descricao string   `json:"descricao"`
+	Perfis    []string `json:"perfis"` // admin sempre aparece: tem todas as permissões
+}
+
+// MatrizPermissoesResponse é a resposta da consulta da matriz de permissões
+type MatrizPermissoesResponse struct {
+	PerfisEditaveis []string    `json:"perfis_editaveis"`
+	Permissoes      []Permissao `json:"permissoes"`
+}
+
+// DefinirPermissoesRequest substitui as permissões de um perfil
+type DefinirPermissoesRequest struct {
+	Permissoes []string `json:"permissoes"`
+}
@@ -0,0 +1,53 @@
+package models
+
+// DashboardResponse reúne os indicadores de vendas e operação de um período.
+// Faturamento considera apenas pedidos concluídos (entregues ou finalizados).
+type DashboardResponse struct {
+	DataInicio          string               `json:"data_inicio"`
+	DataFim             string               `json:"data_fim"`
+	Resumo              ResumoDashboard      `json:"resumo"`
+	PorDia              []FaturamentoDia     `json:"por_dia"`
+	PorFormaPagamento   []FaturamentoGrupo   `json:"por_forma_pagamento"`
+	PorCanalOrigem      []FaturamentoGrupo   `json:"por_canal_origem"`
+	PorProduto          []FaturamentoProduto `json:"por_produto"`
+	MotivosCancelamento []MotivoCancelamento `json:"motivos_cancelamento"`
+}
+
+// ResumoDashboard contém os totais do período
+type ResumoDashboard struct {
+	TotalPedidos             int      `json:"total_pedidos"`
+	PedidosConcluidos        int      `json:"pedidos_concluidos"`
+	Faturamento              float64  `json:"faturamento"`
+	TicketMedio              float64  `json:"ticket_medio"`
+	PedidosCancelados        int      `json:"pedidos_cancelados"`
+	TaxaCancelamento         float64  `json:"taxa_cancelamento"`                     // Percentual sobre o total de pedidos
+	TempoMedioEntregaMinutos *float64 `json:"tempo_medio_entrega_minutos,omitempty"` // De criado_em até data_entrega
+}
+
+// FaturamentoDia é o faturamento de um dia do período
+type FaturamentoDia struct {
+	Data    string  `json:"data"`
+	Pedidos int     `json:"pedidos"`
+	Valor   float64 `json:"valor"`
+}
+
+// FaturamentoGrupo é o faturamento agrupado por forma de pagamento ou canal de origem
+type FaturamentoGrupo struct {
+	Chave   string  `json:"chave"`
+	Pedidos int     `json:"pedidos"`
+	Valor   float64 `json:"valor"`
+}
+
+// FaturamentoProduto é o total vendido de um produto no período
+type FaturamentoProduto struct {
+	ProdutoID  int     `json:"produto_id"`
+	Nome       string  `json:"nome"`
+	Quantidade int     `json:"quantidade"`
+	Valor      float64 `json:"valor"`
+}
+
+// MotivoCancelamento conta os pedidos cancelados por motivo
+type MotivoCancelamento struct {
+	Motivo     string `json:"motivo"`
+	Quantidade int    `json:"quantidade"`
+}
@@ -11,6 +11,9 @@ type Usuario struct {
 	CPF         string    `json:"cpf,omitempty"`
 	Email       string    `json:"email,omitempty"`
 	Perfil      string    `json:"perfil"`
+	Ativo       bool      `json:"ativo"`
+	DesativadoEm *time.Time `json:"desativado_em,omitempty"`
+	DeveTrocarSenha bool  `json:"deve_trocar_senha"` // Senha provisória: só pode trocar a senha até defini-la
 	CriadoEm    time.Time `json:"criado_em"`
 	AtualizadoEm time.Time `json:"atualizado_em"`
 }
@@ -36,4 +39,15 @@ type LoginResponse struct {
 	Login  string `json:"login"`
 	Perfil string `json:"perfil"`
 	Token  string `json:"token"` // Token JWT para autenticação
-}
\ No newline at end of file
+}
+// TentativaLogin é um registro da auditoria de tentativas de login
+type TentativaLogin struct {
+	ID        int64     `json:"id"`
+	Login     string    `json:"login"`
+	UsuarioID *int      `json:"usuario_id,omitempty"` // Vazio quando o login não existe
+	IP        string    `json:"ip,omitempty"`
+	UserAgent string    `json:"user_agent,omitempty"`
+	Sucesso   bool      `json:"sucesso"`
+	Motivo    string    `json:"motivo"` // sucesso, senha_incorreta, usuario_inexistente, usuario_desativado ou bloqueado
+	CriadoEm  time.Time `json:"criado_em"`
+}
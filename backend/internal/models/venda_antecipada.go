@@ -0,0 +1,76 @@
+package models
+
+import "time"
+
+// StatusVendaAntecipada define os possíveis estados de uma venda antecipada (vale-gás)
+type StatusVendaAntecipada string
+
+const (
+	VendaAntecipadaPendente  StatusVendaAntecipada = "pendente"  // Ainda possui unidades a resgatar
+	VendaAntecipadaResgatada StatusVendaAntecipada = "resgatada" // Todas as unidades foram resgatadas
+	VendaAntecipadaCancelada StatusVendaAntecipada = "cancelada" // Cancelada com reembolso do saldo restante
+)
+
+// VendaAntecipada representa um vale-gás: unidades pagas antecipadamente e resgatadas aos poucos
+type VendaAntecipada struct {
+	ID                  int                      `json:"id"`
+	ClienteID           int                      `json:"cliente_id"`
+	Cliente             *ClienteBasico           `json:"cliente,omitempty"`
+	ProdutoID           int                      `json:"produto_id"`
+	NomeProduto         string                   `json:"nome_produto"`
+	Quantidade          int                      `json:"quantidade"`
+	QuantidadeResgatada int                      `json:"quantidade_resgatada"`
+	QuantidadeRestante  int                      `json:"quantidade_restante"`
+	ValorTotal          float64                  `json:"valor_total"`
+	FormaPagamento      FormaPagamento           `json:"forma_pagamento"`
+	DataPagamento       time.Time                `json:"data_pagamento"`
+	DataEntregaPrevista time.Time                `json:"data_entrega_prevista"`
+	Status              StatusVendaAntecipada    `json:"status"`
+	PedidoID            *int                     `json:"pedido_id,omitempty"` // Pedido do resgate mais recente
+	CaixaID             *int                     `json:"caixa_id,omitempty"`  // Caixa que recebeu o pagamento; vazio se quem vendeu não tinha caixa aberto
+	ValorReembolsado    *float64                 `json:"valor_reembolsado,omitempty"`
+	MotivoCancelamento  string                   `json:"motivo_cancelamento,omitempty"`
+	Resgates            []ResgateVendaAntecipada `json:"resgates,omitempty"`
+	CriadoEm            time.Time                `json:"criado_em"`
+	AtualizadoEm        time.Time                `json:"atualizado_em"`
+}
+
+// ResgateVendaAntecipada representa o resgate de uma unidade de um vale-gás
+type ResgateVendaAntecipada struct {
+	ID                int       `json:"id"`
+	VendaAntecipadaID int       `json:"venda_antecipada_id"`
+	PedidoID          int       `json:"pedido_id"`
+	UsuarioID         int       `json:"usuario_id"`
+	CriadoEm          time.Time `json:"criado_em"`
+}
+
+// NovaVendaAntecipadaRequest é a estrutura para receber uma nova venda antecipada via API
+type NovaVendaAntecipadaRequest struct {
+	ClienteID           int            `json:"cliente_id"`
+	ProdutoID           int            `json:"produto_id"`
+	Quantidade          int            `json:"quantidade"`
+	FormaPagamento      FormaPagamento `json:"forma_pagamento"`
+	DataEntregaPrevista time.Time      `json:"data_entrega_prevista"`
+}
+
+// ResgatarVendaAntecipadaRequest é a estrutura para resgatar uma unidade de um vale-gás
+type ResgatarVendaAntecipadaRequest struct {
+	EnderecoEntrega string      `json:"endereco_entrega,omitempty"` // Se vazio, usa o endereço do cliente
+	CanalOrigem     CanalOrigem `json:"canal_origem,omitempty"`
+	Observacoes     string      `json:"observacoes,omitempty"`
+	RetornaBotija   bool        `json:"retorna_botija,omitempty"`
+}
+
+// CancelarVendaAntecipadaRequest é a estrutura para cancelar um vale-gás
+type CancelarVendaAntecipadaRequest struct {
+	MotivoCancelamento string `json:"motivo_cancelamento,omitempty"`
+}
+
+// PassivoVendaAntecipada resume as unidades já pagas e ainda não entregues de um produto
+type PassivoVendaAntecipada struct {
+	ProdutoID          int     `json:"produto_id"`
+	NomeProduto        string  `json:"nome_produto"`
+	Vales              int     `json:"vales"`
+	QuantidadePendente int     `json:"quantidade_pendente"`
+	ValorPendente      float64 `json:"valor_pendente"`
+}
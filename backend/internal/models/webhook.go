@@ -0,0 +1,83 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// StatusEntregaWebhook define os estados de uma entrega de evento a um webhook
+type StatusEntregaWebhook string
+
+const (
+	EntregaWebhookPendente StatusEntregaWebhook = "pendente" // Aguardando a primeira tentativa ou uma nova tentativa
+	EntregaWebhookEntregue StatusEntregaWebhook = "entregue" // O destino respondeu com status 2xx
+	EntregaWebhookFalhou   StatusEntregaWebhook = "falhou"   // Tentativas esgotadas; pode ser reenviada manualmente
+)
+
+// Webhook é uma assinatura de eventos enviada por HTTP POST a um sistema externo
+type Webhook struct {
+	ID           int       `json:"id"`
+	URL          string    `json:"url"`
+	Eventos      []string  `json:"eventos"`
+	Descricao    string    `json:"descricao,omitempty"`
+	Ativo        bool      `json:"ativo"`
+	CriadoEm     time.Time `json:"criado_em"`
+	AtualizadoEm time.Time `json:"atualizado_em"`
+}
+
+// WebhookRequest é a estrutura para cadastrar ou atualizar um webhook.
+// Na atualização, o segredo vazio mantém o atual.
+type WebhookRequest struct {
+	URL       string   `json:"url"`
+	Segredo   string   `json:"segredo"`
+	Eventos   []string `json:"eventos"`
+	Descricao string   `json:"descricao,omitempty"`
+	Ativo     *bool    `json:"ativo,omitempty"`
+}
+
+// EntregaWebhook é o envio de um evento a um webhook, com o resultado da última tentativa
+type EntregaWebhook struct {
+	ID               int64                `json:"id"`
+	WebhookID        int                  `json:"webhook_id"`
+	EventoID         int64                `json:"evento_id"`
+	TipoEvento       string               `json:"tipo_evento"`
+	Status           StatusEntregaWebhook `json:"status"`
+	Tentativas       int                  `json:"tentativas"`
+	ProximaTentativa *time.Time           `json:"proxima_tentativa,omitempty"` // Apenas para entregas pendentes
+	UltimoStatusHTTP *int                 `json:"ultimo_status_http,omitempty"`
+	UltimoErro       string               `json:"ultimo_erro,omitempty"`
+	EntregueEm       *time.Time           `json:"entregue_em,omitempty"`
+	CriadoEm         time.Time            `json:"criado_em"`
+	Dados            json.RawMessage      `json:"dados,omitempty"`            // Conteúdo do evento, no detalhe da entrega
+	HistoricoEnvios  []TentativaWebhook   `json:"historico_envios,omitempty"` // Tentativas, no detalhe da entrega
+}
+
+// TentativaWebhook registra uma tentativa de envio de uma entrega
+type TentativaWebhook struct {
+	ID         int64     `json:"id"`
+	StatusHTTP *int      `json:"status_http,omitempty"` // Vazio quando não houve resposta
+	Erro       string    `json:"erro,omitempty"`
+	DuracaoMs  int       `json:"duracao_ms"`
+	CriadoEm   time.Time `json:"criado_em"`
+}
+
+// EventoBotijasRetornadas é o conteúdo do evento botijas.retornadas
+type EventoBotijasRetornadas struct {
+	PedidoID  int               `json:"pedido_id"`
+	UsuarioID int               `json:"usuario_id"`
+	Botijas   []BotijaRetornada `json:"botijas"`
+}
+
+// EventoPedidoCriado é o conteúdo do evento pedido.criado enviado aos webhooks
+type EventoPedidoCriado struct {
+	PedidoID        int            `json:"pedido_id"`
+	ClienteID       int            `json:"cliente_id"`
+	AtendenteID     int            `json:"atendente_id"`
+	Status          StatusPedido   `json:"status"`
+	FormaPagamento  FormaPagamento `json:"forma_pagamento"`
+	ValorTotal      float64        `json:"valor_total"`
+	EnderecoEntrega string         `json:"endereco_entrega"`
+	CanalOrigem     CanalOrigem    `json:"canal_origem,omitempty"`
+	Itens           []ItemPedido   `json:"itens"`
+	CriadoEm        time.Time      `json:"criado_em"`
+}
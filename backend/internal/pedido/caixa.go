@@ -0,0 +1,44 @@
+package pedido
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/tassyosilva/GestGAS/internal/models"
+)
+
+// MotivoSemCaixa é registrado no histórico do pedido finalizado por quem não tem caixa aberto
+const MotivoSemCaixa = "Finalizado sem caixa aberto: o valor não entrou na conferência de nenhum caixa"
+
+// CaixaAberto retorna o caixa aberto do usuário, ou nil se ele não tiver um. O caixa fica
+// bloqueado para fechamento até o fim da transação, para que o valor vinculado a ele não
+// fique fora da conferência.
+func CaixaAberto(tx *sql.Tx, usuarioID int) (*int, error) {
+	var caixaID int
+	err := tx.QueryRow(`
+		SELECT id FROM caixas
+		WHERE usuario_id = $1 AND status = $2
+		FOR SHARE
+	`, usuarioID, models.CaixaAberto).Scan(&caixaID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("erro ao buscar caixa aberto: %w", err)
+	}
+	return &caixaID, nil
+}
+
+// vincularCaixa associa o pedido finalizado ao caixa aberto de quem o finalizou. Se essa pessoa
+// não tem caixa aberto (por exemplo, o entregador), o pedido fica sem caixa e vinculado é false:
+// o valor não é atribuído ao caixa de outro operador.
+func vincularCaixa(tx *sql.Tx, pedidoID, usuarioID int) (vinculado bool, err error) {
+	caixaID, err := CaixaAberto(tx, usuarioID)
+	if err != nil || caixaID == nil {
+		return false, err
+	}
+	if _, err := tx.Exec("UPDATE pedidos SET caixa_id = $1 WHERE id = $2", *caixaID, pedidoID); err != nil {
+		return false, fmt.Errorf("erro ao vincular pedido ao caixa: %w", err)
+	}
+	return true, nil
+}
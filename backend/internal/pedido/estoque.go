@@ -0,0 +1,348 @@
+package pedido
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/lib/pq"
+	"github.com/tassyosilva/GestGAS/internal/eventos"
+	"github.com/tassyosilva/GestGAS/internal/models"
+	"github.com/tassyosilva/GestGAS/internal/webhook"
+)
+
+// Consultor é satisfeito por *sql.DB e *sql.Tx
+type Consultor interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// ReservarEstoque reserva unidades de um produto para um pedido.
+// A reserva só acontece se houver quantidade disponível (quantidade - reservado); o UPDATE
+// condicional bloqueia a linha do estoque, então dois pedidos simultâneos não reservam a mesma unidade.
+// Retorna false quando não há estoque disponível suficiente.
+func ReservarEstoque(tx *sql.Tx, pedidoID, produtoID, quantidade int) (bool, error) {
+	res, err := tx.Exec(`
+		UPDATE estoque
+		SET reservado = reservado + $1, atualizado_em = NOW()
+		WHERE produto_id = $2 AND quantidade - reservado >= $1
+	`, quantidade, produtoID)
+	if err != nil {
+		return false, fmt.Errorf("erro ao reservar estoque do produto %d: %w", produtoID, err)
+	}
+	linhas, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("erro ao reservar estoque do produto %d: %w", produtoID, err)
+	}
+	if linhas == 0 {
+		return false, nil
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO reservas_estoque (pedido_id, produto_id, quantidade, status, criado_em, atualizado_em)
+		VALUES ($1, $2, $3, $4, NOW(), NOW())
+	`, pedidoID, produtoID, quantidade, models.ReservaAtiva)
+	if err != nil {
+		return false, fmt.Errorf("erro ao registrar reserva do produto %d: %w", produtoID, err)
+	}
+	return true, nil
+}
+
+// possuiReservas indica se o pedido foi criado com reserva de estoque.
+// Pedidos anteriores ao controle de reservas baixaram o estoque já na criação.
+func possuiReservas(tx *sql.Tx, pedidoID int) (bool, error) {
+	var existe bool
+	err := tx.QueryRow("SELECT EXISTS(SELECT 1 FROM reservas_estoque WHERE pedido_id = $1)", pedidoID).Scan(&existe)
+	if err != nil {
+		return false, fmt.Errorf("erro ao verificar reservas do pedido: %w", err)
+	}
+	return existe, nil
+}
+
+// liberarReservas devolve ao disponível as reservas ativas de um pedido cancelado
+func liberarReservas(tx *sql.Tx, pedidoID int) error {
+	reservas, err := buscarReservasAtivas(tx, pedidoID)
+	if err != nil {
+		return err
+	}
+
+	for _, reserva := range reservas {
+		_, err = tx.Exec(`
+			UPDATE estoque
+			SET reservado = reservado - $1, atualizado_em = NOW()
+			WHERE produto_id = $2
+		`, reserva.Quantidade, reserva.ProdutoID)
+		if err != nil {
+			return fmt.Errorf("erro ao liberar reserva do produto %d: %w", reserva.ProdutoID, err)
+		}
+
+		_, err = tx.Exec(`
+			UPDATE reservas_estoque SET status = $1, atualizado_em = NOW() WHERE id = $2
+		`, models.ReservaLiberada, reserva.ID)
+		if err != nil {
+			return fmt.Errorf("erro ao atualizar reserva %d: %w", reserva.ID, err)
+		}
+	}
+	return nil
+}
+
+// converterReservas transforma as reservas ativas de um pedido entregue em saída de estoque
+func converterReservas(tx *sql.Tx, pedidoID, userID int) error {
+	reservas, err := buscarReservasAtivas(tx, pedidoID)
+	if err != nil {
+		return err
+	}
+
+	for _, reserva := range reservas {
+		_, err = tx.Exec(`
+			UPDATE estoque
+			SET quantidade = quantidade - $1, reservado = reservado - $1, atualizado_em = NOW()
+			WHERE produto_id = $2
+		`, reserva.Quantidade, reserva.ProdutoID)
+		if err != nil {
+			return fmt.Errorf("erro ao baixar estoque do produto %d: %w", reserva.ProdutoID, err)
+		}
+
+		// Registrar movimentação de saída
+		_, err = tx.Exec(`
+			INSERT INTO movimentacoes_estoque
+			(produto_id, tipo, quantidade, usuario_id, pedido_id, criado_em)
+			VALUES
+			($1, $2, $3, $4, $5, NOW())
+		`, reserva.ProdutoID, models.MovimentacaoSaida, reserva.Quantidade, userID, pedidoID)
+		if err != nil {
+			return fmt.Errorf("erro ao registrar movimentação do produto %d: %w", reserva.ProdutoID, err)
+		}
+
+		_, err = tx.Exec(`
+			UPDATE reservas_estoque SET status = $1, atualizado_em = NOW() WHERE id = $2
+		`, models.ReservaConvertida, reserva.ID)
+		if err != nil {
+			return fmt.Errorf("erro ao atualizar reserva %d: %w", reserva.ID, err)
+		}
+	}
+	return nil
+}
+
+// devolverEstoque desfaz o efeito de um pedido cancelado sobre o estoque
+func devolverEstoque(tx *sql.Tx, pedidoID, userID int) error {
+	// 1. Pedidos com reserva apenas liberam as unidades reservadas
+	comReserva, err := possuiReservas(tx, pedidoID)
+	if err != nil {
+		return err
+	}
+	if comReserva {
+		return liberarReservas(tx, pedidoID)
+	}
+
+	// 2. Pedidos anteriores à reserva já baixaram o estoque: devolver os itens
+	type itemPedido struct {
+		ProdutoID  int
+		Quantidade int
+	}
+	itens := []itemPedido{}
+	rows, err := tx.Query(`SELECT produto_id, quantidade FROM itens_pedido WHERE pedido_id = $1`, pedidoID)
+	if err != nil {
+		return fmt.Errorf("erro ao buscar itens do pedido: %w", err)
+	}
+	for rows.Next() {
+		var item itemPedido
+		if err := rows.Scan(&item.ProdutoID, &item.Quantidade); err != nil {
+			rows.Close()
+			return fmt.Errorf("erro ao ler item do pedido: %w", err)
+		}
+		itens = append(itens, item)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("erro ao iterar sobre itens do pedido: %w", err)
+	}
+
+	// 3. Processar cada item
+	for _, item := range itens {
+		// Atualizar ou inserir no estoque
+		res, err := tx.Exec(`
+			UPDATE estoque
+			SET quantidade = quantidade + $1, atualizado_em = NOW()
+			WHERE produto_id = $2
+		`, item.Quantidade, item.ProdutoID)
+		if err != nil {
+			return fmt.Errorf("erro ao atualizar estoque para produto %d: %w", item.ProdutoID, err)
+		}
+		if linhas, _ := res.RowsAffected(); linhas == 0 {
+			_, err = tx.Exec(`
+				INSERT INTO estoque (produto_id, quantidade, botijas_vazias, alerta_minimo, atualizado_em)
+				VALUES ($1, $2, 0, 0, NOW())
+			`, item.ProdutoID, item.Quantidade)
+			if err != nil {
+				return fmt.Errorf("erro ao inserir estoque para produto %d: %w", item.ProdutoID, err)
+			}
+		}
+
+		// Registrar movimentação
+		_, err = tx.Exec(`
+			INSERT INTO movimentacoes_estoque
+			(produto_id, tipo, quantidade, usuario_id, pedido_id, criado_em)
+			VALUES
+			($1, $2, $3, $4, $5, NOW())
+		`, item.ProdutoID, models.MovimentacaoDevolucao, item.Quantidade, userID, pedidoID)
+		if err != nil {
+			return fmt.Errorf("erro ao registrar movimentação para produto %d: %w", item.ProdutoID, err)
+		}
+	}
+	return nil
+}
+
+// registrarBotijasRetornadas dá entrada nas botijas vazias devolvidas pelo cliente.
+// O retorno é registrado uma única vez por pedido (pedidos.botijas_registradas).
+func registrarBotijasRetornadas(tx *sql.Tx, pedidoID, userID int) ([]models.BotijaRetornada, error) {
+	var jaRegistradas bool
+	err := tx.QueryRow("SELECT botijas_registradas FROM pedidos WHERE id = $1", pedidoID).Scan(&jaRegistradas)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao verificar botijas do pedido: %w", err)
+	}
+	if jaRegistradas {
+		return nil, nil
+	}
+
+	// Buscar todos os itens do pedido que são botijas com retorno
+	rows, err := tx.Query(`
+		SELECT ip.produto_id, p.nome, SUM(ip.quantidade)
+		FROM itens_pedido ip
+		JOIN produtos p ON ip.produto_id = p.id
+		WHERE ip.pedido_id = $1 AND ip.retorna_botija = TRUE
+		AND p.categoria LIKE 'botija_gas%'
+		GROUP BY ip.produto_id, p.nome
+		ORDER BY ip.produto_id
+	`, pedidoID)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao buscar botijas retornadas: %w", err)
+	}
+	var botijas []models.BotijaRetornada
+	for rows.Next() {
+		var b models.BotijaRetornada
+		if err := rows.Scan(&b.ProdutoID, &b.NomeProduto, &b.Quantidade); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("erro ao processar botijas retornadas: %w", err)
+		}
+		botijas = append(botijas, b)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("erro ao iterar sobre botijas retornadas: %w", err)
+	}
+
+	for _, b := range botijas {
+		// Atualizar estoque de botijas vazias
+		_, err = tx.Exec(`
+			UPDATE estoque
+			SET botijas_vazias = COALESCE(botijas_vazias, 0) + $1, atualizado_em = NOW()
+			WHERE produto_id = $2
+		`, b.Quantidade, b.ProdutoID)
+		if err != nil {
+			return nil, fmt.Errorf("erro ao atualizar estoque de botijas vazias: %w", err)
+		}
+
+		// Registrar movimentação de estoque
+		_, err = tx.Exec(`
+			INSERT INTO movimentacoes_estoque
+			(produto_id, tipo, quantidade, usuario_id, pedido_id, criado_em)
+			VALUES
+			($1, $2, $3, $4, $5, NOW())
+		`, b.ProdutoID, models.MovimentacaoBotijasVazias, b.Quantidade, userID, pedidoID)
+		if err != nil {
+			return nil, fmt.Errorf("erro ao registrar movimentação de botijas vazias: %w", err)
+		}
+	}
+
+	if len(botijas) > 0 {
+		_, err = tx.Exec("UPDATE pedidos SET botijas_registradas = TRUE WHERE id = $1", pedidoID)
+		if err != nil {
+			return nil, fmt.Errorf("erro ao marcar botijas do pedido como registradas: %w", err)
+		}
+
+		evento := models.EventoBotijasRetornadas{PedidoID: pedidoID, UsuarioID: userID, Botijas: botijas}
+		if err := webhook.Enfileirar(tx, eventos.BotijasRetornadas, evento); err != nil {
+			return nil, err
+		}
+	}
+	return botijas, nil
+}
+
+// buscarReservasAtivas retorna, bloqueadas para atualização, as reservas ativas de um pedido
+func buscarReservasAtivas(tx *sql.Tx, pedidoID int) ([]models.ReservaEstoque, error) {
+	rows, err := tx.Query(`
+		SELECT id, pedido_id, produto_id, quantidade, status, criado_em, atualizado_em
+		FROM reservas_estoque
+		WHERE pedido_id = $1 AND status = $2
+		ORDER BY produto_id
+		FOR UPDATE
+	`, pedidoID, models.ReservaAtiva)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao buscar reservas do pedido: %w", err)
+	}
+	defer rows.Close()
+
+	var reservas []models.ReservaEstoque
+	for rows.Next() {
+		var reserva models.ReservaEstoque
+		err := rows.Scan(&reserva.ID, &reserva.PedidoID, &reserva.ProdutoID, &reserva.Quantidade,
+			&reserva.Status, &reserva.CriadoEm, &reserva.AtualizadoEm)
+		if err != nil {
+			return nil, fmt.Errorf("erro ao ler reserva do pedido: %w", err)
+		}
+		reservas = append(reservas, reserva)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("erro ao iterar sobre reservas do pedido: %w", err)
+	}
+	return reservas, nil
+}
+
+// AlertasEstoque retorna, entre os produtos informados, os que estão com o disponível
+// (quantidade - reservado) no alerta mínimo ou abaixo dele
+func AlertasEstoque(q Consultor, produtoIDs []int) ([]models.EstoqueAlertaResponse, error) {
+	if len(produtoIDs) == 0 {
+		return nil, nil
+	}
+
+	rows, err := q.Query(`
+		SELECT p.id, p.nome, e.quantidade, e.reservado, e.alerta_minimo
+		FROM estoque e
+		JOIN produtos p ON e.produto_id = p.id
+		WHERE e.produto_id = ANY($1) AND e.quantidade - e.reservado <= e.alerta_minimo AND e.alerta_minimo > 0
+		ORDER BY p.id
+	`, pq.Array(produtoIDs))
+	if err != nil {
+		return nil, fmt.Errorf("erro ao verificar alertas de estoque: %w", err)
+	}
+	defer rows.Close()
+
+	var alertas []models.EstoqueAlertaResponse
+	for rows.Next() {
+		var a models.EstoqueAlertaResponse
+		if err := rows.Scan(&a.ProdutoID, &a.NomeProduto, &a.Quantidade, &a.Reservado, &a.AlertaMinimo); err != nil {
+			return nil, fmt.Errorf("erro ao processar alertas de estoque: %w", err)
+		}
+		a.Disponivel = a.Quantidade - a.Reservado
+		a.Status = "baixo"
+		if a.Disponivel <= a.AlertaMinimo/2 {
+			a.Status = "critico"
+		}
+		alertas = append(alertas, a)
+	}
+	return alertas, rows.Err()
+}
+
+// EnfileirarAlertasEstoque grava na outbox de webhooks um evento estoque.alerta para cada produto
+// informado que ficou no nível de alerta na transação
+func EnfileirarAlertasEstoque(tx *sql.Tx, produtoIDs []int) error {
+	alertas, err := AlertasEstoque(tx, produtoIDs)
+	if err != nil {
+		return err
+	}
+	for _, a := range alertas {
+		if err := webhook.Enfileirar(tx, eventos.EstoqueAlerta, a); err != nil {
+			return err
+		}
+	}
+	return nil
+}
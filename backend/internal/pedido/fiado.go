@@ -0,0 +1,45 @@
+package pedido
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/tassyosilva/GestGAS/internal/models"
+)
+
+// registrarVendaFiada cria a conta a receber de um pedido fiado finalizado.
+// Pedidos com outra forma de pagamento ou que já possuam registro são ignorados.
+func registrarVendaFiada(tx *sql.Tx, pedidoID int) error {
+	var clienteID int
+	var formaPagamento models.FormaPagamento
+	var valorTotal float64
+	err := tx.QueryRow("SELECT cliente_id, forma_pagamento, valor_total FROM pedidos WHERE id = $1", pedidoID).Scan(
+		&clienteID, &formaPagamento, &valorTotal,
+	)
+	if err != nil {
+		return fmt.Errorf("erro ao buscar pedido para venda fiada: %w", err)
+	}
+	if formaPagamento != models.PagamentoFiado {
+		return nil
+	}
+
+	var existe bool
+	err = tx.QueryRow("SELECT EXISTS(SELECT 1 FROM vendas_fiadas WHERE pedido_id = $1)", pedidoID).Scan(&existe)
+	if err != nil {
+		return fmt.Errorf("erro ao verificar venda fiada do pedido: %w", err)
+	}
+	if existe {
+		return nil
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO vendas_fiadas
+		(pedido_id, cliente_id, valor_total, data_vencimento, status, criado_em, atualizado_em)
+		VALUES
+		($1, $2, $3, NOW() + make_interval(days => $4), $5, NOW(), NOW())
+	`, pedidoID, clienteID, valorTotal, models.PrazoPadraoFiadoDias, models.FiadoPendente)
+	if err != nil {
+		return fmt.Errorf("erro ao registrar venda fiada: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+package pedido
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/tassyosilva/GestGAS/internal/eventos"
+	"github.com/tassyosilva/GestGAS/internal/models"
+	"github.com/tassyosilva/GestGAS/internal/webhook"
+)
+
+// RegistrarCriacao abre a linha do tempo de um pedido recém-criado e grava na outbox de webhooks
+// o evento pedido.criado e os alertas dos produtos que a reserva deixou no mínimo.
+// Deve ser chamada depois que os itens foram inseridos e o estoque reservado.
+func RegistrarCriacao(tx *sql.Tx, pedidoID, usuarioID int) error {
+	if err := registrarHistorico(tx, pedidoID, "", models.StatusNovo, usuarioID, nil, ""); err != nil {
+		return err
+	}
+
+	evento, err := eventoCriacao(tx, pedidoID)
+	if err != nil {
+		return err
+	}
+	if err := webhook.Enfileirar(tx, eventos.PedidoCriado, evento); err != nil {
+		return err
+	}
+
+	produtoIDs := make([]int, 0, len(evento.Itens))
+	for _, item := range evento.Itens {
+		produtoIDs = append(produtoIDs, item.ProdutoID)
+	}
+	return EnfileirarAlertasEstoque(tx, produtoIDs)
+}
+
+// eventoCriacao lê, dentro da transação, o pedido recém-criado e seus itens
+func eventoCriacao(tx *sql.Tx, pedidoID int) (models.EventoPedidoCriado, error) {
+	var e models.EventoPedidoCriado
+	err := tx.QueryRow(`
+		SELECT id, cliente_id, atendente_id, status, forma_pagamento, valor_total,
+			COALESCE(endereco_entrega, ''), COALESCE(canal_origem, ''), criado_em
+		FROM pedidos
+		WHERE id = $1
+	`, pedidoID).Scan(&e.PedidoID, &e.ClienteID, &e.AtendenteID, &e.Status, &e.FormaPagamento, &e.ValorTotal,
+		&e.EnderecoEntrega, &e.CanalOrigem, &e.CriadoEm)
+	if err != nil {
+		return e, fmt.Errorf("erro ao buscar pedido criado: %w", err)
+	}
+
+	rows, err := tx.Query(`
+		SELECT ip.id, ip.pedido_id, ip.produto_id, p.nome, ip.quantidade, ip.preco_unitario, ip.subtotal,
+			COALESCE(ip.retorna_botija, false)
+		FROM itens_pedido ip
+		JOIN produtos p ON ip.produto_id = p.id
+		WHERE ip.pedido_id = $1
+		ORDER BY ip.id
+	`, pedidoID)
+	if err != nil {
+		return e, fmt.Errorf("erro ao buscar itens do pedido criado: %w", err)
+	}
+	defer rows.Close()
+
+	e.Itens = []models.ItemPedido{}
+	for rows.Next() {
+		var item models.ItemPedido
+		err := rows.Scan(&item.ID, &item.PedidoID, &item.ProdutoID, &item.NomeProduto, &item.Quantidade,
+			&item.PrecoUnitario, &item.Subtotal, &item.RetornaBotija)
+		if err != nil {
+			return e, fmt.Errorf("erro ao ler itens do pedido criado: %w", err)
+		}
+		e.Itens = append(e.Itens, item)
+	}
+	if err := rows.Err(); err != nil {
+		return e, fmt.Errorf("erro ao ler itens do pedido criado: %w", err)
+	}
+	return e, nil
+}
+
+// registrarHistorico grava uma transição de status; statusAnterior vazio indica a criação do pedido
+func registrarHistorico(tx *sql.Tx, pedidoID int, statusAnterior, statusNovo models.StatusPedido, usuarioID int, entregadorID *int, motivo string) error {
+	var anterior, motivoNulo sql.NullString
+	if statusAnterior != "" {
+		anterior = sql.NullString{String: string(statusAnterior), Valid: true}
+	}
+	if motivo != "" {
+		motivoNulo = sql.NullString{String: motivo, Valid: true}
+	}
+
+	_, err := tx.Exec(`
+		INSERT INTO pedidos_historico (pedido_id, status_anterior, status_novo, usuario_id, entregador_id, motivo)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`, pedidoID, anterior, statusNovo, usuarioID, entregadorID, motivoNulo)
+	if err != nil {
+		return fmt.Errorf("erro ao registrar histórico do pedido: %w", err)
+	}
+	return nil
+}
+
+// BuscarHistorico retorna a linha do tempo do pedido, da mais antiga para a mais recente
+func BuscarHistorico(db *sql.DB, pedidoID int) ([]models.HistoricoPedido, error) {
+	rows, err := db.Query(`
+		SELECT
+			h.id, h.pedido_id, h.status_anterior, h.status_novo,
+			h.usuario_id, u.nome, u.perfil,
+			h.entregador_id, e.nome, e.perfil,
+			h.motivo, h.criado_em
+		FROM pedidos_historico h
+		LEFT JOIN usuarios u ON h.usuario_id = u.id
+		LEFT JOIN usuarios e ON h.entregador_id = e.id
+		WHERE h.pedido_id = $1
+		ORDER BY h.criado_em, h.id
+	`, pedidoID)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao buscar histórico do pedido: %w", err)
+	}
+	defer rows.Close()
+
+	historico := []models.HistoricoPedido{}
+	for rows.Next() {
+		var h models.HistoricoPedido
+		var statusAnterior, motivo sql.NullString
+		var usuarioID, entregadorID sql.NullInt64
+		var usuarioNome, usuarioPerfil, entregadorNome, entregadorPerfil sql.NullString
+
+		err := rows.Scan(
+			&h.ID, &h.PedidoID, &statusAnterior, &h.StatusNovo,
+			&usuarioID, &usuarioNome, &usuarioPerfil,
+			&entregadorID, &entregadorNome, &entregadorPerfil,
+			&motivo, &h.CriadoEm,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("erro ao ler histórico do pedido: %w", err)
+		}
+
+		if statusAnterior.Valid {
+			status := models.StatusPedido(statusAnterior.String)
+			h.StatusAnterior = &status
+		}
+		if usuarioID.Valid {
+			h.Usuario = &models.UsuarioBasico{ID: int(usuarioID.Int64), Nome: usuarioNome.String, Perfil: usuarioPerfil.String}
+		}
+		if entregadorID.Valid {
+			h.Entregador = &models.UsuarioBasico{ID: int(entregadorID.Int64), Nome: entregadorNome.String, Perfil: entregadorPerfil.String}
+		}
+		h.Motivo = motivo.String
+
+		historico = append(historico, h)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("erro ao ler histórico do pedido: %w", err)
+	}
+	return historico, nil
+}
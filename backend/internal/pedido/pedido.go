@@ -0,0 +1,454 @@
+// Package pedido concentra as regras do ciclo de vida de um pedido: as transições de status
+// permitidas, quem pode executá-las e os efeitos sobre estoque, botijas e contas a receber.
+// Todos os endpoints que alteram o status de um pedido devem passar por aqui, o que também
+// garante que cada transição fique registrada em pedidos_historico.
+package pedido
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/tassyosilva/GestGAS/internal/eventos"
+	"github.com/tassyosilva/GestGAS/internal/models"
+	"github.com/tassyosilva/GestGAS/internal/permissao"
+	"github.com/tassyosilva/GestGAS/internal/webhook"
+)
+
+// Categorias de erro retornadas pelo serviço, para que os handlers escolham o status HTTP
+var (
+	ErrPedidoNaoEncontrado = errors.New("pedido não encontrado")
+	ErrTransicaoInvalida   = errors.New("transição de status inválida")
+	ErrSemPermissao        = errors.New("sem permissão")
+	ErrDadosInvalidos      = errors.New("dados inválidos")
+)
+
+// Erro é um erro de regra de negócio com mensagem pronta para o usuário
+type Erro struct {
+	Tipo     error
+	Mensagem string
+}
+
+func (e *Erro) Error() string { return e.Mensagem }
+
+// Unwrap permite identificar a categoria do erro com errors.Is
+func (e *Erro) Unwrap() error { return e.Tipo }
+
+func novoErro(tipo error, formato string, args ...interface{}) error {
+	return &Erro{Tipo: tipo, Mensagem: fmt.Sprintf(formato, args...)}
+}
+
+// transicoes define, para cada status, os próximos status permitidos
+var transicoes = map[models.StatusPedido][]models.StatusPedido{
+	models.StatusNovo:      {models.StatusEmPreparo, models.StatusCancelado},
+	models.StatusEmPreparo: {models.StatusEmEntrega, models.StatusCancelado},
+	models.StatusEmEntrega: {models.StatusEntregue, models.StatusCancelado, models.StatusEmPreparo}, // em_preparo: falha na entrega
+	models.StatusEntregue:  {models.StatusFinalizado},
+	// cancelado e finalizado são estados finais
+}
+
+// permissaoStatus é a permissão exigida para levar qualquer pedido a cada status
+var permissaoStatus = map[models.StatusPedido]string{
+	models.StatusEmPreparo:  permissao.PedidoAlterarStatus,
+	models.StatusEmEntrega:  permissao.PedidoAlterarStatus,
+	models.StatusEntregue:   permissao.PedidoAlterarStatus,
+	models.StatusFinalizado: permissao.PedidoAlterarStatus,
+	models.StatusCancelado:  permissao.PedidoCancelar,
+}
+
+// statusDoEntregador são os status que quem tem permissão para realizar entregas pode aplicar
+// aos pedidos atribuídos a ele
+var statusDoEntregador = map[models.StatusPedido]bool{
+	models.StatusEmEntrega:  true,
+	models.StatusEmPreparo:  true,
+	models.StatusEntregue:   true,
+	models.StatusFinalizado: true,
+}
+
+// Alteracao descreve uma mudança de status solicitada para um pedido
+type Alteracao struct {
+	PedidoID     int
+	UsuarioID    int
+	Perfil       string
+	Status       models.StatusPedido
+	EntregadorID *int       // Ao iniciar a entrega; se vazio, usa o entregador já atribuído
+	DataEntrega  *time.Time // Se vazio, usa o momento da confirmação
+	Motivo       string     // Motivo do cancelamento ou da falha na entrega
+}
+
+// TransicaoPermitida indica se um pedido pode passar do status atual para o novo
+func TransicaoPermitida(atual, novo models.StatusPedido) bool {
+	for _, permitido := range transicoes[atual] {
+		if permitido == novo {
+			return true
+		}
+	}
+	return false
+}
+
+// AlterarStatus aplica uma mudança de status e seus efeitos numa única transação
+func AlterarStatus(db *sql.DB, a Alteracao) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("erro ao iniciar transação: %w", err)
+	}
+	defer tx.Rollback() // sem efeito após o commit
+
+	evento, err := alterarStatus(tx, a)
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("erro ao finalizar transação: %w", err)
+	}
+
+	// Avisar as telas conectadas só depois que a alteração está gravada
+	eventos.Publicar(eventos.PedidoStatusAlterado, evento)
+	if evento.StatusNovo == models.StatusEntregue {
+		eventos.Publicar(eventos.EntregaConfirmada, evento)
+	}
+	return nil
+}
+
+// AlterarStatusTx aplica uma mudança de status dentro de uma transação existente.
+// Cabe a quem controla a transação publicar o evento da alteração após o commit.
+func AlterarStatusTx(tx *sql.Tx, a Alteracao) error {
+	_, err := alterarStatus(tx, a)
+	return err
+}
+
+// alterarStatus aplica a mudança de status e retorna o evento a publicar após o commit
+func alterarStatus(tx *sql.Tx, a Alteracao) (models.EventoPedido, error) {
+	if a.Status == "" {
+		return models.EventoPedido{}, novoErro(ErrDadosInvalidos, "Status é obrigatório")
+	}
+
+	// Bloquear o pedido para que duas alterações simultâneas não partam do mesmo status
+	statusAtual, entregadorAtual, err := bloquearPedido(tx, a.PedidoID)
+	if err != nil {
+		return models.EventoPedido{}, err
+	}
+
+	if !TransicaoPermitida(statusAtual, a.Status) {
+		return models.EventoPedido{}, novoErro(ErrTransicaoInvalida, "Transição de status inválida: %s -> %s", statusAtual, a.Status)
+	}
+
+	if !podeAlterar(a, entregadorAtual) {
+		return models.EventoPedido{}, novoErro(ErrSemPermissao, "Sem permissão para alterar o pedido para %s", a.Status)
+	}
+
+	switch a.Status {
+	case models.StatusEmPreparo:
+		if statusAtual == models.StatusEmEntrega {
+			err = registrarFalhaEntrega(tx, a)
+		} else {
+			err = atualizarStatus(tx, a.PedidoID, a.Status)
+		}
+	case models.StatusEmEntrega:
+		if a.EntregadorID == nil {
+			a.EntregadorID = entregadorAtual
+		}
+		err = iniciarEntrega(tx, a)
+	case models.StatusEntregue:
+		err = confirmarEntrega(tx, a)
+	case models.StatusFinalizado:
+		a.Motivo, err = finalizar(tx, a)
+	case models.StatusCancelado:
+		err = cancelar(tx, a)
+	default:
+		err = novoErro(ErrDadosInvalidos, "Status desconhecido: %s", a.Status)
+	}
+	if err != nil {
+		return models.EventoPedido{}, err
+	}
+
+	// Toda transição aplicada entra na linha do tempo do pedido
+	entregador := entregadorAtual
+	if a.Status == models.StatusEmEntrega {
+		entregador = a.EntregadorID
+	}
+	if err := registrarHistorico(tx, a.PedidoID, statusAtual, a.Status, a.UsuarioID, entregador, a.Motivo); err != nil {
+		return models.EventoPedido{}, err
+	}
+
+	evento := models.EventoPedido{
+		PedidoID:       a.PedidoID,
+		StatusAnterior: statusAtual,
+		StatusNovo:     a.Status,
+		UsuarioID:      a.UsuarioID,
+		EntregadorID:   entregador,
+		Motivo:         a.Motivo,
+	}
+	if err := webhook.Enfileirar(tx, eventos.PedidoStatusAlterado, evento); err != nil {
+		return models.EventoPedido{}, err
+	}
+	return evento, nil
+}
+
+// RegistrarRetornoBotijas registra as botijas vazias de um pedido já entregue que ainda não foram registradas
+func RegistrarRetornoBotijas(db *sql.DB, pedidoID, usuarioID int, perfil string) ([]models.BotijaRetornada, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("erro ao iniciar transação: %w", err)
+	}
+	defer tx.Rollback() // sem efeito após o commit
+
+	statusAtual, entregadorAtual, err := bloquearPedido(tx, pedidoID)
+	if err != nil {
+		return nil, err
+	}
+	if statusAtual != models.StatusEntregue && statusAtual != models.StatusFinalizado {
+		return nil, novoErro(ErrTransicaoInvalida, "O pedido deve estar entregue ou finalizado para registrar botijas retornadas")
+	}
+
+	// Mesma regra da confirmação de entrega
+	if !podeAlterar(Alteracao{UsuarioID: usuarioID, Perfil: perfil, Status: models.StatusEntregue}, entregadorAtual) {
+		return nil, novoErro(ErrSemPermissao, "Sem permissão para registrar botijas deste pedido")
+	}
+
+	var jaRegistradas bool
+	err = tx.QueryRow("SELECT botijas_registradas FROM pedidos WHERE id = $1", pedidoID).Scan(&jaRegistradas)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao verificar botijas do pedido: %w", err)
+	}
+	if jaRegistradas {
+		return nil, novoErro(ErrDadosInvalidos, "As botijas deste pedido já foram registradas")
+	}
+
+	botijas, err := registrarBotijasRetornadas(tx, pedidoID, usuarioID)
+	if err != nil {
+		return nil, err
+	}
+	if len(botijas) == 0 {
+		return nil, novoErro(ErrDadosInvalidos, "Não há botijas para retornar neste pedido")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("erro ao finalizar transação: %w", err)
+	}
+	return botijas, nil
+}
+
+// AtribuirEntregadorTx reserva um pedido em preparo para um entregador, sem alterar o status.
+// A entrega é iniciada depois, com AlterarStatus para em_entrega.
+func AtribuirEntregadorTx(tx *sql.Tx, pedidoID, entregadorID, usuarioID int, perfil string) error {
+	statusAtual, entregadorAtual, err := bloquearPedido(tx, pedidoID)
+	if err != nil {
+		return err
+	}
+	if statusAtual != models.StatusEmPreparo {
+		return novoErro(ErrTransicaoInvalida, "Pedido %d não está em preparo", pedidoID)
+	}
+	if !permissao.Possui(perfil, permissao.PedidoAtribuirEntregador) {
+		return novoErro(ErrSemPermissao, "Sem permissão para atribuir entregadores")
+	}
+	if entregadorAtual != nil && *entregadorAtual == entregadorID {
+		return nil
+	}
+	if err := validarEntregador(tx, entregadorID); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("UPDATE pedidos SET entregador_id = $1, aceito_em = NULL, atualizado_em = NOW() WHERE id = $2", entregadorID, pedidoID)
+	if err != nil {
+		return fmt.Errorf("erro ao atribuir entregador: %w", err)
+	}
+	return registrarHistorico(tx, pedidoID, statusAtual, statusAtual, usuarioID, &entregadorID, "Pedido atribuído ao entregador")
+}
+
+// AceitarEntrega registra que o entregador aceitou um pedido em preparo atribuído a ele
+func AceitarEntrega(db *sql.DB, pedidoID, entregadorID int) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("erro ao iniciar transação: %w", err)
+	}
+	defer tx.Rollback() // sem efeito após o commit
+
+	statusAtual, entregadorAtual, err := bloquearPedido(tx, pedidoID)
+	if err != nil {
+		return err
+	}
+	if entregadorAtual == nil || *entregadorAtual != entregadorID {
+		return novoErro(ErrSemPermissao, "Este pedido não está atribuído a você")
+	}
+	if statusAtual != models.StatusEmPreparo {
+		return novoErro(ErrTransicaoInvalida, "Apenas pedidos em preparo podem ser aceitos")
+	}
+
+	res, err := tx.Exec("UPDATE pedidos SET aceito_em = NOW(), atualizado_em = NOW() WHERE id = $1 AND aceito_em IS NULL", pedidoID)
+	if err != nil {
+		return fmt.Errorf("erro ao aceitar entrega: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return novoErro(ErrDadosInvalidos, "A entrega deste pedido já foi aceita")
+	}
+
+	if err := registrarHistorico(tx, pedidoID, statusAtual, statusAtual, entregadorID, &entregadorID, "Entrega aceita pelo entregador"); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("erro ao finalizar transação: %w", err)
+	}
+	return nil
+}
+
+// bloquearPedido lê o status e o entregador do pedido, bloqueando a linha até o fim da transação
+func bloquearPedido(tx *sql.Tx, pedidoID int) (models.StatusPedido, *int, error) {
+	var status models.StatusPedido
+	var entregadorID sql.NullInt64
+	err := tx.QueryRow("SELECT status, entregador_id FROM pedidos WHERE id = $1 FOR UPDATE", pedidoID).Scan(&status, &entregadorID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", nil, novoErro(ErrPedidoNaoEncontrado, "Pedido não encontrado")
+		}
+		return "", nil, fmt.Errorf("erro ao buscar pedido: %w", err)
+	}
+	if !entregadorID.Valid {
+		return status, nil, nil
+	}
+	id := int(entregadorID.Int64)
+	return status, &id, nil
+}
+
+// podeAlterar verifica se as permissões do perfil do usuário permitem aplicar o novo status.
+// Sem permissão sobre qualquer pedido, quem realiza entregas só pode iniciar, devolver, confirmar
+// e finalizar os pedidos atribuídos a ele, e não pode passar a entrega para outro entregador.
+func podeAlterar(a Alteracao, entregadorAtual *int) bool {
+	if permissao.Possui(a.Perfil, permissaoStatus[a.Status]) {
+		return true
+	}
+	if a.EntregadorID != nil && *a.EntregadorID != a.UsuarioID {
+		return false
+	}
+	return permissao.Possui(a.Perfil, permissao.EntregaRealizar) && statusDoEntregador[a.Status] &&
+		entregadorAtual != nil && *entregadorAtual == a.UsuarioID
+}
+
+func atualizarStatus(tx *sql.Tx, pedidoID int, status models.StatusPedido) error {
+	_, err := tx.Exec("UPDATE pedidos SET status = $1, atualizado_em = NOW() WHERE id = $2", status, pedidoID)
+	if err != nil {
+		return fmt.Errorf("erro ao atualizar status do pedido: %w", err)
+	}
+	return nil
+}
+
+// iniciarEntrega atribui o entregador e coloca o pedido em entrega
+func iniciarEntrega(tx *sql.Tx, a Alteracao) error {
+	if a.EntregadorID == nil {
+		return novoErro(ErrDadosInvalidos, "É necessário definir um entregador para iniciar a entrega")
+	}
+	if err := validarEntregador(tx, *a.EntregadorID); err != nil {
+		return err
+	}
+
+	_, err := tx.Exec(`
+		UPDATE pedidos
+		SET status = $1, entregador_id = $2,
+			aceito_em = CASE WHEN entregador_id = $2 THEN COALESCE(aceito_em, NOW()) ELSE NOW() END,
+			atualizado_em = NOW()
+		WHERE id = $3
+	`, models.StatusEmEntrega, *a.EntregadorID, a.PedidoID)
+	if err != nil {
+		return fmt.Errorf("erro ao atualizar status do pedido: %w", err)
+	}
+	return nil
+}
+
+// validarEntregador verifica se o usuário existe, está ativo e tem perfil de entregador
+func validarEntregador(tx *sql.Tx, entregadorID int) error {
+	var perfilEntregador string
+	var ativo bool
+	err := tx.QueryRow("SELECT perfil, ativo FROM usuarios WHERE id = $1", entregadorID).Scan(&perfilEntregador, &ativo)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return novoErro(ErrDadosInvalidos, "Entregador não encontrado")
+		}
+		return fmt.Errorf("erro ao buscar entregador: %w", err)
+	}
+	if perfilEntregador != models.PerfilEntregador {
+		return novoErro(ErrDadosInvalidos, "O usuário informado não é um entregador")
+	}
+	if !ativo {
+		return novoErro(ErrDadosInvalidos, "O entregador informado está desativado")
+	}
+	return nil
+}
+
+// confirmarEntrega marca o pedido como entregue, baixa o estoque reservado e dá entrada nas botijas vazias
+func confirmarEntrega(tx *sql.Tx, a Alteracao) error {
+	dataEntrega := time.Now()
+	if a.DataEntrega != nil {
+		dataEntrega = *a.DataEntrega
+	}
+
+	_, err := tx.Exec(`
+		UPDATE pedidos
+		SET status = $1, data_entrega = $2, atualizado_em = NOW()
+		WHERE id = $3
+	`, models.StatusEntregue, dataEntrega, a.PedidoID)
+	if err != nil {
+		return fmt.Errorf("erro ao atualizar status do pedido: %w", err)
+	}
+
+	if err := converterReservas(tx, a.PedidoID, a.UsuarioID); err != nil {
+		return err
+	}
+
+	_, err = registrarBotijasRetornadas(tx, a.PedidoID, a.UsuarioID)
+	return err
+}
+
+// registrarFalhaEntrega devolve um pedido em entrega para preparo, liberando-o para ser
+// atribuído de novo. A reserva de estoque continua ativa.
+func registrarFalhaEntrega(tx *sql.Tx, a Alteracao) error {
+	if a.Motivo == "" {
+		return novoErro(ErrDadosInvalidos, "Informe o motivo da falha na entrega")
+	}
+
+	_, err := tx.Exec(`
+		UPDATE pedidos
+		SET status = $1, entregador_id = NULL, aceito_em = NULL, atualizado_em = NOW()
+		WHERE id = $2
+	`, models.StatusEmPreparo, a.PedidoID)
+	if err != nil {
+		return fmt.Errorf("erro ao atualizar status do pedido: %w", err)
+	}
+	return nil
+}
+
+// finalizar encerra o pedido entregue, vincula-o ao caixa e gera a conta a receber dos pedidos fiados.
+// Retorna o motivo a registrar no histórico: MotivoSemCaixa quando o pedido fica sem caixa.
+func finalizar(tx *sql.Tx, a Alteracao) (string, error) {
+	if err := atualizarStatus(tx, a.PedidoID, models.StatusFinalizado); err != nil {
+		return "", err
+	}
+	vinculado, err := vincularCaixa(tx, a.PedidoID, a.UsuarioID)
+	if err != nil {
+		return "", err
+	}
+	motivo := a.Motivo
+	if !vinculado {
+		motivo = MotivoSemCaixa
+	}
+	return motivo, registrarVendaFiada(tx, a.PedidoID)
+}
+
+// cancelar cancela o pedido, devolve o estoque comprometido e, se o pedido veio do resgate
+// de um vale-gás, devolve a unidade ao vale
+func cancelar(tx *sql.Tx, a Alteracao) error {
+	_, err := tx.Exec(`
+		UPDATE pedidos
+		SET status = $1, motivo_cancelamento = $2, atualizado_em = NOW()
+		WHERE id = $3
+	`, models.StatusCancelado, a.Motivo, a.PedidoID)
+	if err != nil {
+		return fmt.Errorf("erro ao atualizar status do pedido: %w", err)
+	}
+	if err := devolverEstoque(tx, a.PedidoID, a.UsuarioID); err != nil {
+		return err
+	}
+	return estornarResgate(tx, a.PedidoID)
+}
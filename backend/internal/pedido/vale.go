@@ -0,0 +1,136 @@
+package pedido
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+
+	"github.com/tassyosilva/GestGAS/internal/models"
+)
+
+// saldoVale é o saldo de unidades de um vale-gás (venda antecipada)
+type saldoVale struct {
+	Quantidade       int
+	Resgatadas       int
+	Status           models.StatusVendaAntecipada
+	ValorTotal       float64
+	ValorReembolsado float64
+}
+
+// resgatar consome uma unidade do vale, que passa a resgatado quando não sobram unidades
+func (s saldoVale) resgatar() (saldoVale, error) {
+	if s.Status != models.VendaAntecipadaPendente || s.Resgatadas >= s.Quantidade {
+		return s, novoErro(ErrDadosInvalidos, "Venda antecipada não possui unidades disponíveis para resgate")
+	}
+	s.Resgatadas++
+	if s.Resgatadas >= s.Quantidade {
+		s.Status = models.VendaAntecipadaResgatada
+	}
+	return s, nil
+}
+
+// estornar devolve ao vale a unidade de um resgate cancelado. Um vale resgatado volta a
+// ficar pendente. Um vale cancelado não aceita novos resgates, então a unidade devolvida entra
+// no reembolso, calculado como no cancelamento: proporcional às unidades não resgatadas.
+func (s saldoVale) estornar() saldoVale {
+	if s.Resgatadas == 0 {
+		return s
+	}
+	s.Resgatadas--
+	switch s.Status {
+	case models.VendaAntecipadaResgatada:
+		s.Status = models.VendaAntecipadaPendente
+	case models.VendaAntecipadaCancelada:
+		s.ValorReembolsado = math.Round(s.ValorTotal*float64(s.Quantidade-s.Resgatadas)/float64(s.Quantidade)*100) / 100
+	}
+	return s
+}
+
+// buscarSaldoVale lê o saldo de um vale-gás, bloqueando-o até o fim da transação
+func buscarSaldoVale(tx *sql.Tx, vendaID int) (saldoVale, error) {
+	var s saldoVale
+	err := tx.QueryRow(`
+		SELECT quantidade, quantidade_resgatada, status, valor_total, COALESCE(valor_reembolsado, 0)
+		FROM vendas_antecipadas
+		WHERE id = $1
+		FOR UPDATE
+	`, vendaID).Scan(&s.Quantidade, &s.Resgatadas, &s.Status, &s.ValorTotal, &s.ValorReembolsado)
+	if err != nil {
+		return s, fmt.Errorf("erro ao buscar venda antecipada: %w", err)
+	}
+	return s, nil
+}
+
+// RegistrarResgate consome uma unidade do vale-gás para o pedido criado no resgate
+func RegistrarResgate(tx *sql.Tx, vendaID, pedidoID, usuarioID int) error {
+	saldo, err := buscarSaldoVale(tx, vendaID)
+	if err != nil {
+		return err
+	}
+	saldo, err = saldo.resgatar()
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO resgates_vendas_antecipadas
+		(venda_antecipada_id, pedido_id, usuario_id, criado_em)
+		VALUES
+		($1, $2, $3, NOW())
+	`, vendaID, pedidoID, usuarioID)
+	if err != nil {
+		return fmt.Errorf("erro ao registrar resgate: %w", err)
+	}
+
+	_, err = tx.Exec(`
+		UPDATE vendas_antecipadas
+		SET quantidade_resgatada = $1, pedido_id = $2, status = $3, atualizado_em = NOW()
+		WHERE id = $4
+	`, saldo.Resgatadas, pedidoID, saldo.Status, vendaID)
+	if err != nil {
+		return fmt.Errorf("erro ao atualizar venda antecipada: %w", err)
+	}
+	return nil
+}
+
+// estornarResgate devolve ao vale-gás a unidade resgatada por um pedido cancelado.
+// Pedidos que não vieram de um resgate são ignorados.
+func estornarResgate(tx *sql.Tx, pedidoID int) error {
+	var vendaID int
+	err := tx.QueryRow(`
+		DELETE FROM resgates_vendas_antecipadas
+		WHERE pedido_id = $1
+		RETURNING venda_antecipada_id
+	`, pedidoID).Scan(&vendaID)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("erro ao estornar resgate do vale-gás: %w", err)
+	}
+
+	saldo, err := buscarSaldoVale(tx, vendaID)
+	if err != nil {
+		return err
+	}
+	saldo = saldo.estornar()
+
+	// pedido_id passa a apontar para o resgate anterior que continua válido, se houver.
+	// valor_reembolsado só muda (e só deixa de ser nulo) quando o vale já estava cancelado.
+	_, err = tx.Exec(`
+		UPDATE vendas_antecipadas
+		SET quantidade_resgatada = $1, status = $2, atualizado_em = NOW(),
+			valor_reembolsado = CASE WHEN status = $4 THEN $5 ELSE valor_reembolsado END,
+			pedido_id = (
+				SELECT pedido_id FROM resgates_vendas_antecipadas
+				WHERE venda_antecipada_id = $3
+				ORDER BY criado_em DESC, id DESC
+				LIMIT 1
+			)
+		WHERE id = $3
+	`, saldo.Resgatadas, saldo.Status, vendaID, models.VendaAntecipadaCancelada, saldo.ValorReembolsado)
+	if err != nil {
+		return fmt.Errorf("erro ao devolver unidade ao vale-gás: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package pedido
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/tassyosilva/GestGAS/internal/models"
+)
+
+func TestValeResgateCanceladoDevolveUnidade(t *testing.T) {
+	vale := saldoVale{Quantidade: 2, Status: models.VendaAntecipadaPendente}
+
+	vale, err := vale.resgatar()
+	if err != nil {
+		t.Fatalf("primeiro resgate falhou: %v", err)
+	}
+	vale, err = vale.resgatar()
+	if err != nil {
+		t.Fatalf("segundo resgate falhou: %v", err)
+	}
+	if vale.Resgatadas != 2 || vale.Status != models.VendaAntecipadaResgatada {
+		t.Fatalf("após resgatar tudo esperava 2 resgatadas e status resgatada, veio %+v", vale)
+	}
+
+	// O pedido do segundo resgate é cancelado
+	vale = vale.estornar()
+	if vale.Resgatadas != 1 || vale.Status != models.VendaAntecipadaPendente {
+		t.Fatalf("após o cancelamento esperava 1 resgatada e status pendente, veio %+v", vale)
+	}
+
+	// A unidade devolvida pode ser resgatada de novo
+	vale, err = vale.resgatar()
+	if err != nil {
+		t.Fatalf("resgate da unidade devolvida falhou: %v", err)
+	}
+	if vale.Resgatadas != 2 || vale.Status != models.VendaAntecipadaResgatada {
+		t.Fatalf("esperava o vale resgatado de novo, veio %+v", vale)
+	}
+}
+
+func TestValeSemSaldoRecusaResgate(t *testing.T) {
+	casos := []saldoVale{
+		{Quantidade: 1, Resgatadas: 1, Status: models.VendaAntecipadaResgatada},
+		{Quantidade: 3, Resgatadas: 1, Status: models.VendaAntecipadaCancelada},
+	}
+	for _, vale := range casos {
+		if _, err := vale.resgatar(); !errors.Is(err, ErrDadosInvalidos) {
+			t.Errorf("resgate de %+v deveria ser recusado, veio %v", vale, err)
+		}
+	}
+}
+
+func TestValeCanceladoReembolsaUnidadeEstornada(t *testing.T) {
+	// Vale de 3 unidades por R$ 300,00, cancelado com 2 resgates: reembolsou R$ 100,00
+	vale := saldoVale{Quantidade: 3, Resgatadas: 2, Status: models.VendaAntecipadaCancelada, ValorTotal: 300, ValorReembolsado: 100}
+
+	// O pedido de um dos resgates é cancelado: a unidade não pode mais ser resgatada, então é reembolsada
+	vale = vale.estornar()
+	if vale.Resgatadas != 1 || vale.Status != models.VendaAntecipadaCancelada {
+		t.Fatalf("esperava 1 resgatada e o vale ainda cancelado, veio %+v", vale)
+	}
+	if vale.ValorReembolsado != 200 {
+		t.Fatalf("esperava R$ 200,00 reembolsados, veio %.2f", vale.ValorReembolsado)
+	}
+
+	// Estorno do último resgate: o vale fica inteiramente reembolsado, sem perda de centavos
+	dividido := saldoVale{Quantidade: 3, Resgatadas: 1, Status: models.VendaAntecipadaCancelada, ValorTotal: 100, ValorReembolsado: 66.67}
+	if dividido = dividido.estornar(); dividido.ValorReembolsado != 100 {
+		t.Fatalf("esperava R$ 100,00 reembolsados, veio %.2f", dividido.ValorReembolsado)
+	}
+
+	// Num vale resgatado o estorno devolve a unidade ao saldo, sem reembolso
+	resgatado := saldoVale{Quantidade: 2, Resgatadas: 2, Status: models.VendaAntecipadaResgatada, ValorTotal: 200}
+	if resgatado = resgatado.estornar(); resgatado.ValorReembolsado != 0 {
+		t.Fatalf("vale resgatado não deveria ter reembolso, veio %.2f", resgatado.ValorReembolsado)
+	}
+
+	// Sem resgates não há o que estornar
+	vazio := saldoVale{Quantidade: 1, Status: models.VendaAntecipadaPendente, ValorTotal: 100}
+	if vazio.estornar() != vazio {
+		t.Fatal("estornar um vale sem resgates não deveria alterar o saldo")
+	}
+}
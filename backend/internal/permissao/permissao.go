@@ -0,0 +1,318 @@
+// Package permissao mantém a matriz de permissões: ações nomeadas (por exemplo estoque.ajustar)
+// concedidas a cada perfil. A matriz fica no banco e é lida para a memória na inicialização e
+// a cada alteração; o perfil admin sempre tem todas as permissões.
+package permissao
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/lib/pq"
+	"github.com/tassyosilva/GestGAS/internal/models"
+)
+
+// Permissões conhecidas pelo sistema
+const (
+	ClienteCriar    = "cliente.criar"
+	ClienteEditar   = "cliente.editar"
+	ClienteExcluir  = "cliente.excluir"
+	ClienteImportar = "cliente.importar"
+	ClienteMesclar  = "cliente.mesclar"
+
+	ProdutoCriar   = "produto.criar"
+	ProdutoEditar  = "produto.editar"
+	ProdutoExcluir = "produto.excluir"
+
+	EstoqueAjustar    = "estoque.ajustar"
+	EstoqueEmprestimo = "estoque.emprestimo"
+
+	PedidoConsultarTodos     = "pedido.consultar_todos"
+	PedidoCriar              = "pedido.criar"
+	PedidoAlterarStatus      = "pedido.alterar_status"
+	PedidoCancelar           = "pedido.cancelar"
+	PedidoAtribuirEntregador = "pedido.atribuir_entregador"
+	PedidoAcompanhar         = "pedido.acompanhar"
+
+	EntregaConsultar = "entrega.consultar"
+	EntregaRealizar  = "entrega.realizar"
+
+	VendaAntecipadaConsultar = "venda_antecipada.consultar"
+	VendaAntecipadaRegistrar = "venda_antecipada.registrar"
+	VendaAntecipadaResgatar  = "venda_antecipada.resgatar"
+	VendaAntecipadaCancelar  = "venda_antecipada.cancelar"
+	VendaAntecipadaPassivo   = "venda_antecipada.passivo"
+
+	FiadoConsultar = "fiado.consultar"
+	FiadoReceber   = "fiado.receber"
+
+	CaixaOperar        = "caixa.operar"
+	CaixaSupervisionar = "caixa.supervisionar"
+
+	ConciliacaoGerenciar = "conciliacao.gerenciar"
+	RelatorioVisualizar  = "relatorio.visualizar"
+	DadosExportar        = "dados.exportar"
+	BinaAtender          = "bina.atender"
+
+	UsuarioConsultar   = "usuario.consultar"
+	UsuarioGerenciar   = "usuario.gerenciar"
+	SegurancaGerenciar = "seguranca.gerenciar"
+	WebhookGerenciar   = "webhook.gerenciar"
+	PermissaoGerenciar = "permissao.gerenciar"
+)
+
+// Definicao descreve uma permissão e o perfil mínimo que a recebe quando ela é criada
+type Definicao struct {
+	Codigo       string
+	Descricao    string
+	PerfilMinimo string
+}
+
+// Catalogo lista todas as permissões. Os perfis padrão reproduzem a hierarquia
+// admin > gerente > atendente > entregador e só são aplicados a permissões novas;
+// depois disso vale o que estiver no banco.
+var Catalogo = []Definicao{
+	{ClienteCriar, "Cadastrar clientes", models.PerfilAtendente},
+	{ClienteEditar, "Atualizar clientes", models.PerfilAtendente},
+	{ClienteExcluir, "Excluir clientes", models.PerfilGerente},
+	{ClienteImportar, "Importar clientes de planilhas", models.PerfilGerente},
+	{ClienteMesclar, "Consultar e mesclar clientes duplicados", models.PerfilGerente},
+
+	{ProdutoCriar, "Cadastrar produtos", models.PerfilGerente},
+	{ProdutoEditar, "Atualizar produtos", models.PerfilGerente},
+	{ProdutoExcluir, "Excluir produtos", models.PerfilAdmin},
+
+	{EstoqueAjustar, "Ajustar o estoque e o alerta mínimo", models.PerfilGerente},
+	{EstoqueEmprestimo, "Registrar empréstimo e devolução de botijas", models.PerfilAtendente},
+
+	{PedidoConsultarTodos, "Consultar todos os pedidos (sem ela, apenas os atribuídos ao próprio usuário)", models.PerfilAtendente},
+	{PedidoCriar, "Registrar pedidos", models.PerfilAtendente},
+	{PedidoAlterarStatus, "Alterar o status de qualquer pedido", models.PerfilAtendente},
+	{PedidoCancelar, "Cancelar pedidos", models.PerfilAtendente},
+	{PedidoAtribuirEntregador, "Atribuir pedidos a entregadores", models.PerfilAtendente},
+	{PedidoAcompanhar, "Acompanhar os eventos dos pedidos em tempo real", models.PerfilAtendente},
+
+	{EntregaConsultar, "Consultar lotes e roteiros de todos os entregadores", models.PerfilAtendente},
+	{EntregaRealizar, "Aceitar, iniciar, confirmar e devolver as entregas atribuídas ao próprio usuário", models.PerfilEntregador},
+
+	{VendaAntecipadaConsultar, "Consultar vendas antecipadas", models.PerfilAtendente},
+	{VendaAntecipadaRegistrar, "Registrar vendas antecipadas", models.PerfilAtendente},
+	{VendaAntecipadaResgatar, "Resgatar vendas antecipadas", models.PerfilAtendente},
+	{VendaAntecipadaCancelar, "Cancelar vendas antecipadas", models.PerfilGerente},
+	{VendaAntecipadaPassivo, "Consultar o passivo de vendas antecipadas", models.PerfilGerente},
+
+	{FiadoConsultar, "Consultar vendas fiadas", models.PerfilAtendente},
+	{FiadoReceber, "Registrar pagamentos de vendas fiadas", models.PerfilAtendente},
+
+	{CaixaOperar, "Abrir, movimentar e fechar o próprio caixa", models.PerfilAtendente},
+	{CaixaSupervisionar, "Consultar e operar o caixa de outros usuários", models.PerfilGerente},
+
+	{ConciliacaoGerenciar, "Importar extratos e conciliar recebimentos", models.PerfilGerente},
+	{RelatorioVisualizar, "Acessar relatórios e o painel", models.PerfilGerente},
+	{DadosExportar, "Exportar dados", models.PerfilGerente},
+	{BinaAtender, "Receber e consultar chamadas do bina", models.PerfilAtendente},
+
+	{UsuarioConsultar, "Consultar outros usuários", models.PerfilGerente},
+	{UsuarioGerenciar, "Cadastrar, alterar, desativar e redefinir a senha de usuários", models.PerfilAdmin},
+	{SegurancaGerenciar, "Consultar tentativas de login e liberar bloqueios", models.PerfilAdmin},
+	{WebhookGerenciar, "Gerenciar webhooks", models.PerfilAdmin},
+	{PermissaoGerenciar, "Alterar as permissões dos perfis", models.PerfilAdmin},
+}
+
+// Perfis cujas permissões podem ser alteradas, do mais amplo ao mais restrito
+var Perfis = []string{models.PerfilGerente, models.PerfilAtendente, models.PerfilEntregador}
+
+// nivel ordena os perfis da hierarquia usada nas permissões padrão
+var nivel = map[string]int{
+	models.PerfilEntregador: 1,
+	models.PerfilAtendente:  2,
+	models.PerfilGerente:    3,
+	models.PerfilAdmin:      4,
+}
+
+// Erros retornados ao alterar a matriz
+var (
+	ErrPerfilInvalido    = errors.New("perfil inválido")
+	ErrPermissaoInvalida = errors.New("permissão desconhecida")
+)
+
+var (
+	mu     sync.RWMutex
+	matriz = map[string]map[string]bool{} // perfil -> permissões concedidas
+)
+
+// Possui indica se o perfil tem a permissão
+func Possui(perfil, codigo string) bool {
+	if perfil == models.PerfilAdmin {
+		return true
+	}
+	mu.RLock()
+	defer mu.RUnlock()
+	return matriz[perfil][codigo]
+}
+
+// Descricao retorna a descrição da permissão, ou o próprio código se ela não existir
+func Descricao(codigo string) string {
+	for _, d := range Catalogo {
+		if d.Codigo == codigo {
+			return d.Descricao
+		}
+	}
+	return codigo
+}
+
+// Sincronizar grava no banco as permissões do catálogo que ainda não existem, concedendo-as aos
+// perfis padrão, remove as que saíram do catálogo e carrega a matriz para a memória
+func Sincronizar(db *sql.DB) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("erro ao iniciar transação: %w", err)
+	}
+	defer tx.Rollback() // sem efeito após o commit
+
+	codigos := make([]string, 0, len(Catalogo))
+	for _, d := range Catalogo {
+		codigos = append(codigos, d.Codigo)
+
+		// Só uma permissão recém-criada recebe os perfis padrão; as existentes mantêm o que foi configurado
+		res, err := tx.Exec("INSERT INTO permissoes (codigo, descricao) VALUES ($1, $2) ON CONFLICT (codigo) DO NOTHING", d.Codigo, d.Descricao)
+		if err != nil {
+			return fmt.Errorf("erro ao gravar permissão %s: %w", d.Codigo, err)
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			if _, err := tx.Exec("UPDATE permissoes SET descricao = $2 WHERE codigo = $1 AND descricao <> $2", d.Codigo, d.Descricao); err != nil {
+				return fmt.Errorf("erro ao atualizar permissão %s: %w", d.Codigo, err)
+			}
+			continue
+		}
+		for _, perfil := range Perfis {
+			if nivel[perfil] < nivel[d.PerfilMinimo] {
+				continue
+			}
+			if _, err := tx.Exec("INSERT INTO perfil_permissoes (perfil, permissao) VALUES ($1, $2)", perfil, d.Codigo); err != nil {
+				return fmt.Errorf("erro ao conceder permissão %s: %w", d.Codigo, err)
+			}
+		}
+	}
+
+	if _, err := tx.Exec("DELETE FROM permissoes WHERE codigo <> ALL($1)", pq.Array(codigos)); err != nil {
+		return fmt.Errorf("erro ao remover permissões antigas: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("erro ao finalizar transação: %w", err)
+	}
+	return Carregar(db)
+}
+
+// Carregar lê a matriz de permissões do banco para a memória
+func Carregar(db *sql.DB) error {
+	rows, err := db.Query("SELECT perfil, permissao FROM perfil_permissoes")
+	if err != nil {
+		return fmt.Errorf("erro ao carregar permissões: %w", err)
+	}
+	defer rows.Close()
+
+	nova := map[string]map[string]bool{}
+	for rows.Next() {
+		var perfil, codigo string
+		if err := rows.Scan(&perfil, &codigo); err != nil {
+			return fmt.Errorf("erro ao ler permissão: %w", err)
+		}
+		if nova[perfil] == nil {
+			nova[perfil] = map[string]bool{}
+		}
+		nova[perfil][codigo] = true
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("erro ao carregar permissões: %w", err)
+	}
+
+	mu.Lock()
+	matriz = nova
+	mu.Unlock()
+	return nil
+}
+
+// Definir substitui as permissões de um perfil e recarrega a matriz
+func Definir(db *sql.DB, perfil string, codigos []string) error {
+	if !editavel(perfil) {
+		return ErrPerfilInvalido
+	}
+	for _, codigo := range codigos {
+		if !existe(codigo) {
+			return fmt.Errorf("%w: %s", ErrPermissaoInvalida, codigo)
+		}
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("erro ao iniciar transação: %w", err)
+	}
+	defer tx.Rollback() // sem efeito após o commit
+
+	if _, err := tx.Exec("DELETE FROM perfil_permissoes WHERE perfil = $1", perfil); err != nil {
+		return fmt.Errorf("erro ao remover permissões do perfil: %w", err)
+	}
+	for _, codigo := range codigos {
+		_, err := tx.Exec("INSERT INTO perfil_permissoes (perfil, permissao) VALUES ($1, $2) ON CONFLICT DO NOTHING", perfil, codigo)
+		if err != nil {
+			return fmt.Errorf("erro ao conceder permissão %s: %w", codigo, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("erro ao finalizar transação: %w", err)
+	}
+	return Carregar(db)
+}
+
+// Matriz retorna o catálogo com os perfis que têm cada permissão (admin incluído)
+func Matriz() []models.Permissao {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	permissoes := make([]models.Permissao, 0, len(Catalogo))
+	for _, d := range Catalogo {
+		p := models.Permissao{Codigo: d.Codigo, Descricao: d.Descricao, Perfis: []string{models.PerfilAdmin}}
+		for _, perfil := range Perfis {
+			if matriz[perfil][d.Codigo] {
+				p.Perfis = append(p.Perfis, perfil)
+			}
+		}
+		permissoes = append(permissoes, p)
+	}
+	return permissoes
+}
+
+// DoPerfil lista, em ordem alfabética, as permissões do perfil
+func DoPerfil(perfil string) []string {
+	codigos := []string{}
+	for _, d := range Catalogo {
+		if Possui(perfil, d.Codigo) {
+			codigos = append(codigos, d.Codigo)
+		}
+	}
+	sort.Strings(codigos)
+	return codigos
+}
+
+func editavel(perfil string) bool {
+	for _, p := range Perfis {
+		if p == perfil {
+			return true
+		}
+	}
+	return false
+}
+
+func existe(codigo string) bool {
+	for _, d := range Catalogo {
+		if d.Codigo == codigo {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,239 @@
+// Package pix gera o BR Code (padrão EMV de QR Code do Banco Central) das cobranças Pix dos
+// pedidos. A geração é feita localmente, sem consultar o banco: é um QR Code estático com o valor
+// e o txid do pedido, que o extrato bancário devolve na conciliação.
+package pix
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// gui identifica o arranjo Pix no campo de dados do recebedor
+	gui = "br.gov.bcb.pix"
+	// prefixoTxID identifica, na conciliação, os pagamentos gerados para pedidos
+	prefixoTxID = "PEDIDO"
+
+	tamanhoMaximoChave  = 77
+	tamanhoMaximoNome   = 25
+	tamanhoMaximoCidade = 15
+	tamanhoMaximoTxID   = 25
+)
+
+// Cobranca descreve um QR Code de cobrança
+type Cobranca struct {
+	Chave         string
+	NomeRecebedor string
+	Cidade        string
+	Valor         float64 // Zero deixa o valor para o pagador informar
+	TxID          string  // Até 25 letras e números; vazio é enviado como "***"
+}
+
+// TxIDPedido retorna o txid das cobranças de um pedido
+func TxIDPedido(pedidoID int) string {
+	return prefixoTxID + strconv.Itoa(pedidoID)
+}
+
+// PedidoDoTxID retorna o pedido de um txid gerado por TxIDPedido
+func PedidoDoTxID(txid string) (int, bool) {
+	txid = strings.ToUpper(strings.TrimSpace(txid))
+	if !strings.HasPrefix(txid, prefixoTxID) {
+		return 0, false
+	}
+	id, err := strconv.Atoi(txid[len(prefixoTxID):])
+	if err != nil || id <= 0 {
+		return 0, false
+	}
+	return id, true
+}
+
+// GerarPayload monta o texto do BR Code, que é o conteúdo do QR Code e também o "Pix copia e cola"
+func GerarPayload(c Cobranca) (string, error) {
+	chave, err := NormalizarChave(c.Chave)
+	if err != nil {
+		return "", err
+	}
+	nome := textoEMV(c.NomeRecebedor)
+	cidade := textoEMV(c.Cidade)
+	if err := validarNomeCidade(nome, cidade); err != nil {
+		return "", err
+	}
+	txid := c.TxID
+	if txid == "" {
+		txid = "***"
+	} else if !txidValido(txid) {
+		return "", fmt.Errorf("txid inválido: %q (até %d letras e números)", txid, tamanhoMaximoTxID)
+	}
+	if c.Valor < 0 {
+		return "", errors.New("valor da cobrança não pode ser negativo")
+	}
+
+	var b strings.Builder
+	b.WriteString(campo("00", "01")) // Versão do payload
+	b.WriteString(campo("26", campo("00", gui)+campo("01", chave)))
+	b.WriteString(campo("52", "0000")) // Categoria do estabelecimento (não informada)
+	b.WriteString(campo("53", "986"))  // Real
+	if c.Valor > 0 {
+		b.WriteString(campo("54", strconv.FormatFloat(c.Valor, 'f', 2, 64)))
+	}
+	b.WriteString(campo("58", "BR"))
+	b.WriteString(campo("59", nome))
+	b.WriteString(campo("60", cidade))
+	b.WriteString(campo("62", campo("05", txid)))
+
+	// O CRC cobre todo o payload, incluindo o identificador e o tamanho do próprio campo
+	b.WriteString("6304")
+	return b.String() + fmt.Sprintf("%04X", crc16(b.String())), nil
+}
+
+// ValidarRecebedor verifica se a chave, o nome e a cidade configurados podem ser usados no BR Code
+func ValidarRecebedor(chave, nome, cidade string) error {
+	if _, err := NormalizarChave(chave); err != nil {
+		return err
+	}
+	return validarNomeCidade(textoEMV(nome), textoEMV(cidade))
+}
+
+// NormalizarChave valida a chave Pix e a coloca no formato do diretório do Banco Central:
+// CPF ou CNPJ só com dígitos, telefone como +55DDNNNNNNNNN, e-mail ou chave aleatória em minúsculas.
+// Onze dígitos sem "+" são tratados como CPF.
+func NormalizarChave(chave string) (string, error) {
+	chave = strings.TrimSpace(chave)
+	switch {
+	case chave == "":
+		return "", errors.New("chave Pix não informada")
+	case strings.Contains(chave, "@"):
+		chave = strings.ToLower(chave)
+		if strings.ContainsAny(chave, " \t") || strings.HasPrefix(chave, "@") || strings.HasSuffix(chave, "@") {
+			return "", errors.New("chave Pix de e-mail inválida")
+		}
+	case chaveAleatoria(chave):
+		chave = strings.ToLower(chave)
+	case strings.HasPrefix(chave, "+"):
+		digitos := apenasDigitos(chave)
+		if len(digitos) < 12 || len(digitos) > 13 || !strings.HasPrefix(digitos, "55") {
+			return "", errors.New("chave Pix de telefone inválida (use +55, DDD e número)")
+		}
+		chave = "+" + digitos
+	default:
+		digitos := apenasDigitos(chave)
+		if len(digitos) != 11 && len(digitos) != 14 {
+			return "", errors.New("chave Pix inválida (use CPF, CNPJ, telefone com +55, e-mail ou chave aleatória)")
+		}
+		chave = digitos
+	}
+	if len(chave) > tamanhoMaximoChave {
+		return "", fmt.Errorf("chave Pix muito longa (máximo de %d caracteres)", tamanhoMaximoChave)
+	}
+	return chave, nil
+}
+
+func validarNomeCidade(nome, cidade string) error {
+	if nome == "" {
+		return errors.New("nome do recebedor não informado")
+	}
+	if len(nome) > tamanhoMaximoNome {
+		return fmt.Errorf("nome do recebedor muito longo (máximo de %d caracteres)", tamanhoMaximoNome)
+	}
+	if cidade == "" {
+		return errors.New("cidade do recebedor não informada")
+	}
+	if len(cidade) > tamanhoMaximoCidade {
+		return fmt.Errorf("cidade do recebedor muito longa (máximo de %d caracteres)", tamanhoMaximoCidade)
+	}
+	return nil
+}
+
+// campo codifica um campo EMV: identificador, tamanho com dois dígitos e valor
+func campo(id, valor string) string {
+	return fmt.Sprintf("%s%02d%s", id, len(valor), valor)
+}
+
+// crc16 calcula o CRC16-CCITT (polinômio 0x1021, valor inicial 0xFFFF) exigido pelo BR Code
+func crc16(dados string) uint16 {
+	crc := uint16(0xFFFF)
+	for i := 0; i < len(dados); i++ {
+		crc ^= uint16(dados[i]) << 8
+		for bit := 0; bit < 8; bit++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
+
+// acentos mapeia as letras acentuadas do português para a forma sem acento
+var acentos = strings.NewReplacer(
+	"á", "a", "à", "a", "â", "a", "ã", "a", "ä", "a",
+	"é", "e", "è", "e", "ê", "e", "ë", "e",
+	"í", "i", "ì", "i", "î", "i", "ï", "i",
+	"ó", "o", "ò", "o", "ô", "o", "õ", "o", "ö", "o",
+	"ú", "u", "ù", "u", "û", "u", "ü", "u",
+	"ç", "c", "ñ", "n",
+	"Á", "A", "À", "A", "Â", "A", "Ã", "A", "Ä", "A",
+	"É", "E", "È", "E", "Ê", "E", "Ë", "E",
+	"Í", "I", "Ì", "I", "Î", "I", "Ï", "I",
+	"Ó", "O", "Ò", "O", "Ô", "O", "Õ", "O", "Ö", "O",
+	"Ú", "U", "Ù", "U", "Û", "U", "Ü", "U",
+	"Ç", "C", "Ñ", "N",
+)
+
+// textoEMV remove acentos e caracteres fora do ASCII imprimível, que nem todo banco aceita
+func textoEMV(s string) string {
+	s = acentos.Replace(strings.TrimSpace(s))
+	var b strings.Builder
+	for _, r := range s {
+		if r >= 0x20 && r < 0x7F && r != utf8.RuneError {
+			b.WriteRune(r)
+		}
+	}
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+func txidValido(txid string) bool {
+	if len(txid) > tamanhoMaximoTxID {
+		return false
+	}
+	for _, r := range txid {
+		if !(r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z') {
+			return false
+		}
+	}
+	return true
+}
+
+// chaveAleatoria indica se a chave tem o formato de UUID das chaves aleatórias (EVP)
+func chaveAleatoria(chave string) bool {
+	if len(chave) != 36 {
+		return false
+	}
+	for i, r := range chave {
+		switch i {
+		case 8, 13, 18, 23:
+			if r != '-' {
+				return false
+			}
+		default:
+			if !(r >= '0' && r <= '9' || r >= 'a' && r <= 'f' || r >= 'A' && r <= 'F') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func apenasDigitos(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if r >= '0' && r <= '9' {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
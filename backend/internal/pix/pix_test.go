@@ -0,0 +1,50 @@
+package pix
+
+import "testing"
+
+// exemploBCB é o BR Code estático de exemplo do manual do Banco Central, com CRC 1D3D
+const exemploBCB = "00020126580014br.gov.bcb.pix0136123e4567-e12b-12d1-a456-4266554400005204000053039865802BR5913Fulano de Tal6008BRASILIA62070503***63041D3D"
+
+func TestGerarPayloadExemploBCB(t *testing.T) {
+	payload, err := GerarPayload(Cobranca{
+		Chave:         "123e4567-e12b-12d1-a456-426655440000",
+		NomeRecebedor: "Fulano de Tal",
+		Cidade:        "BRASILIA",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if payload != exemploBCB {
+		t.Fatalf("payload diferente do exemplo do Banco Central:\n%s\nesperado:\n%s", payload, exemploBCB)
+	}
+}
+
+func TestCRC16ExemploBCB(t *testing.T) {
+	semCRC := exemploBCB[:len(exemploBCB)-4]
+	if crc := crc16(semCRC); crc != 0x1D3D {
+		t.Fatalf("crc16 = %04X, esperado 1D3D", crc)
+	}
+}
+
+func TestTxIDPedidoIdaEVolta(t *testing.T) {
+	for _, pedidoID := range []int{1, 42, 123456, 2147483647} {
+		txid := TxIDPedido(pedidoID)
+		if !txidValido(txid) {
+			t.Errorf("TxIDPedido(%d) = %q não é um txid válido", pedidoID, txid)
+		}
+		id, ok := PedidoDoTxID(txid)
+		if !ok || id != pedidoID {
+			t.Errorf("PedidoDoTxID(TxIDPedido(%d)) = %d, %v", pedidoID, id, ok)
+		}
+	}
+
+	// O extrato pode devolver o txid em minúsculas ou com espaços
+	if id, ok := PedidoDoTxID(" pedido77 "); !ok || id != 77 {
+		t.Errorf("PedidoDoTxID com minúsculas e espaços = %d, %v", id, ok)
+	}
+	for _, txid := range []string{"", "***", "PEDIDO", "PEDIDO0", "PEDIDO-5", "PEDIDOX1", "VENDA12"} {
+		if id, ok := PedidoDoTxID(txid); ok {
+			t.Errorf("PedidoDoTxID(%q) deveria ser recusado, veio %d", txid, id)
+		}
+	}
+}
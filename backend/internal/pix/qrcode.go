@@ -0,0 +1,14 @@
+package pix
+
+import (
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+// tamanhoPadraoQRCode é o lado, em pixels, da imagem gerada para a tela do entregador
+const tamanhoPadraoQRCode = 512
+
+// GerarQRCodePNG desenha o payload como QR Code em PNG. A correção de erro média tolera
+// telas com reflexo e câmeras simples sem deixar o código denso demais.
+func GerarQRCodePNG(payload string) ([]byte, error) {
+	return qrcode.Encode(payload, qrcode.Medium, tamanhoPadraoQRCode)
+}
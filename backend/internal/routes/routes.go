@@ -5,14 +5,24 @@ import (
 	"net/http"
 	"strings"
 
+	"github.com/tassyosilva/GestGAS/internal/auth"
+	"github.com/tassyosilva/GestGAS/internal/bina"
+	"github.com/tassyosilva/GestGAS/internal/config"
+	"github.com/tassyosilva/GestGAS/internal/entrega"
 	"github.com/tassyosilva/GestGAS/internal/handlers"
 	"github.com/tassyosilva/GestGAS/internal/middleware"
 )
 
 // ConfigurarRotas configura todas as rotas da API
-func ConfigurarRotas(db *sql.DB) http.Handler {
+func ConfigurarRotas(db *sql.DB, cfg config.Config, central *bina.Central) http.Handler {
 	mux := http.NewServeMux()
 
+	// Limitador de tentativas de login (atraso progressivo e bloqueio temporário)
+	limitador := auth.NovoLimitador(auth.PoliticaPadrao(), nil)
+
+	// Planejador de roteiros de entrega (distância em linha reta a partir do depósito configurado)
+	planejador := entrega.NovoPlanejador(cfg.Entregas, nil)
+
 	// Definir rotas básicas
 	mux.HandleFunc("/api/health", func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
@@ -20,8 +30,31 @@ func ConfigurarRotas(db *sql.DB) http.Handler {
 		w.Write([]byte(`{"status": "ok"}`))
 	})
 
-	// Rota de login (pública)
-	mux.HandleFunc("/api/login", handlers.LoginHandler(db))
+	// Rotas de autenticação (login e renovação são públicas)
+	mux.HandleFunc("/api/login", handlers.LoginHandler(db, limitador))
+	mux.HandleFunc("/api/token/refresh", handlers.RenovarTokenHandler(db))
+	mux.Handle("/api/logout", middleware.AuthMiddleware(db)(http.HandlerFunc(handlers.LogoutHandler(db))))
+
+	// Rotas para auditoria e bloqueios de login (apenas admin)
+	mux.Handle("/api/seguranca/tentativas-login", middleware.AuthMiddleware(db)(http.HandlerFunc(handlers.ListarTentativasLoginHandler(db))))
+	mux.Handle("/api/seguranca/bloqueios-login", middleware.AuthMiddleware(db)(http.HandlerFunc(handlers.BloqueiosLoginHandler(limitador))))
+
+	// Rotas protegidas - Matriz de permissões dos perfis
+	mux.Handle("/api/permissoes", middleware.AuthMiddleware(db)(http.HandlerFunc(handlers.ListarPermissoesHandler(db))))
+	mux.Handle("/api/permissoes/", middleware.AuthMiddleware(db)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		segments := strings.Split(r.URL.Path, "/")
+		// Rota para as permissões do próprio usuário
+		if len(segments) == 4 && segments[3] == "me" {
+			handlers.MinhasPermissoesHandler()(w, r)
+			return
+		}
+		// Rota para alterar as permissões de um perfil
+		if len(segments) == 4 && segments[3] != "" {
+			handlers.DefinirPermissoesPerfilHandler(db)(w, r)
+			return
+		}
+		http.Error(w, "Rota não encontrada", http.StatusNotFound)
+	})))
 
 	// Rotas protegidas - Produtos
 	mux.Handle("/api/produtos", middleware.AuthMiddleware(db)(http.HandlerFunc(handlers.ListarProdutosHandler(db))))
@@ -64,12 +97,19 @@ func ConfigurarRotas(db *sql.DB) http.Handler {
 		// Se não for nenhum dos casos acima, método não permitido
 		http.Error(w, "Método não permitido", http.StatusMethodNotAllowed)
 	})))
+	mux.Handle("/api/clientes/importar", middleware.AuthMiddleware(db)(http.HandlerFunc(handlers.ImportarClientesHandler(db))))
+	mux.Handle("/api/clientes/duplicados", middleware.AuthMiddleware(db)(http.HandlerFunc(handlers.ListarClientesDuplicadosHandler(db))))
 	mux.Handle("/api/clientes/buscar", middleware.AuthMiddleware(db)(http.HandlerFunc(handlers.BuscarClientePorTelefoneHandler(db))))
 	mux.Handle("/api/clientes/", middleware.AuthMiddleware(db)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		path := r.URL.Path
 		segments := strings.Split(path, "/")
 		// Verificar se é uma requisição para um cliente específico
 		if len(segments) >= 4 && segments[3] != "" {
+			// Rota para mesclar clientes duplicados no cliente da URL
+			if len(segments) == 5 && segments[4] == "mesclar" {
+				handlers.MesclarClientesHandler(db)(w, r)
+				return
+			}
 			// Verificar se é uma atualização de endereço
 			if len(segments) >= 5 && segments[4] == "endereco" {
 				if r.Method == http.MethodPut || r.Method == http.MethodPatch {
@@ -111,6 +151,16 @@ func ConfigurarRotas(db *sql.DB) http.Handler {
 			handlers.AtualizarStatusPedidoHandler(db)(w, r)
 			return
 		}
+		// Rota para a linha do tempo de status do pedido
+		if len(segments) == 5 && segments[4] == "historico" && r.Method == http.MethodGet {
+			handlers.ObterHistoricoPedidoHandler(db)(w, r)
+			return
+		}
+		// Rota para o QR Code Pix do pedido
+		if len(segments) == 5 && segments[4] == "pix" && r.Method == http.MethodGet {
+			handlers.ObterPixPedidoHandler(db, cfg.Pix)(w, r)
+			return
+		}
 		// Rota para obter pedido específico
 		if len(segments) == 4 && segments[3] != "" && r.Method == http.MethodGet {
 			handlers.ObterPedidoHandler(db)(w, r)
@@ -131,6 +181,59 @@ func ConfigurarRotas(db *sql.DB) http.Handler {
 	// Adicionar nova rota para finalizar pedido
 	mux.Handle("/api/pedidos/finalizar", middleware.AuthMiddleware(db)(http.HandlerFunc(handlers.FinalizarPedidoHandler(db))))
 
+	// Rotas para vendas fiadas
+	mux.Handle("/api/fiados", middleware.AuthMiddleware(db)(http.HandlerFunc(handlers.ListarFiadosHandler(db))))
+	mux.Handle("/api/fiados/clientes", middleware.AuthMiddleware(db)(http.HandlerFunc(handlers.ListarSaldosFiadoHandler(db))))
+	mux.Handle("/api/fiados/", middleware.AuthMiddleware(db)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := r.URL.Path
+		segments := strings.Split(path, "/")
+		// Rota para registrar pagamento de uma venda fiada
+		if len(segments) == 5 && segments[4] == "pagamentos" && r.Method == http.MethodPost {
+			handlers.RegistrarPagamentoFiadoHandler(db)(w, r)
+			return
+		}
+		// Rota para obter venda fiada específica
+		if len(segments) == 4 && segments[3] != "" && r.Method == http.MethodGet {
+			handlers.ObterFiadoHandler(db)(w, r)
+			return
+		}
+		http.Error(w, "Rota não encontrada", http.StatusNotFound)
+	})))
+
+	// Rotas para vendas antecipadas (vale-gás)
+	mux.Handle("/api/vales-gas", middleware.AuthMiddleware(db)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Verificar se é método GET para listar ou POST para criar
+		if r.Method == http.MethodGet {
+			handlers.ListarVendasAntecipadasHandler(db)(w, r)
+			return
+		} else if r.Method == http.MethodPost {
+			handlers.CriarVendaAntecipadaHandler(db)(w, r)
+			return
+		}
+		http.Error(w, "Método não permitido", http.StatusMethodNotAllowed)
+	})))
+	mux.Handle("/api/vales-gas/passivo", middleware.AuthMiddleware(db)(http.HandlerFunc(handlers.PassivoVendasAntecipadasHandler(db))))
+	mux.Handle("/api/vales-gas/", middleware.AuthMiddleware(db)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := r.URL.Path
+		segments := strings.Split(path, "/")
+		// Rota para resgatar uma unidade do vale
+		if len(segments) == 5 && segments[4] == "resgatar" && r.Method == http.MethodPost {
+			handlers.ResgatarVendaAntecipadaHandler(db)(w, r)
+			return
+		}
+		// Rota para cancelar o vale com reembolso
+		if len(segments) == 5 && segments[4] == "cancelar" && r.Method == http.MethodPost {
+			handlers.CancelarVendaAntecipadaHandler(db)(w, r)
+			return
+		}
+		// Rota para obter vale específico
+		if len(segments) == 4 && segments[3] != "" && r.Method == http.MethodGet {
+			handlers.ObterVendaAntecipadaHandler(db)(w, r)
+			return
+		}
+		http.Error(w, "Rota não encontrada", http.StatusNotFound)
+	})))
+
 	// Rotas para estoque
 	mux.Handle("/api/estoque", middleware.AuthMiddleware(db)(http.HandlerFunc(handlers.ListarEstoqueHandler(db))))
 	mux.Handle("/api/estoque/alertas", middleware.AuthMiddleware(db)(http.HandlerFunc(handlers.ListarAlertasEstoqueHandler(db))))
@@ -173,6 +276,30 @@ func ConfigurarRotas(db *sql.DB) http.Handler {
 	mux.Handle("/api/usuarios/", middleware.AuthMiddleware(db)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		path := r.URL.Path
 		segments := strings.Split(path, "/")
+		// Rota para o usuário autenticado trocar a própria senha
+		if path == middleware.RotaTrocarSenha {
+			handlers.TrocarSenhaHandler(db)(w, r)
+			return
+		}
+		// Rota para o admin gerar uma senha temporária para o usuário
+		if len(segments) == 5 && segments[3] != "" && segments[4] == "redefinir-senha" {
+			handlers.RedefinirSenhaUsuarioHandler(db)(w, r)
+			return
+		}
+		// Rota para revogar todas as sessões do usuário
+		if len(segments) == 5 && segments[3] != "" && segments[4] == "revogar-sessoes" {
+			handlers.RevogarSessoesUsuarioHandler(db)(w, r)
+			return
+		}
+		// Rotas para desativar e reativar o usuário
+		if len(segments) == 5 && segments[3] != "" && segments[4] == "desativar" {
+			handlers.DesativarUsuarioHandler(db)(w, r)
+			return
+		}
+		if len(segments) == 5 && segments[3] != "" && segments[4] == "reativar" {
+			handlers.ReativarUsuarioHandler(db)(w, r)
+			return
+		}
 		// Verificar se é uma requisição para um usuário específico
 		if len(segments) >= 4 && segments[3] != "" {
 			switch r.Method {
@@ -193,7 +320,151 @@ func ConfigurarRotas(db *sql.DB) http.Handler {
 
 	// Rota específica para listar entregadores
 	mux.Handle("/api/entregadores", middleware.AuthMiddleware(db)(http.HandlerFunc(handlers.ListarEntregadoresHandler(db))))
+	mux.Handle("/api/entregadores/", middleware.AuthMiddleware(db)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		segments := strings.Split(r.URL.Path, "/")
+		// Rota para o roteiro de entregas do entregador
+		if len(segments) == 5 && segments[3] != "" && segments[4] == "rota" {
+			handlers.RotaEntregadorHandler(db, planejador)(w, r)
+			return
+		}
+		http.Error(w, "Rota não encontrada", http.StatusNotFound)
+	})))
+
+	// Rotas para o identificador de chamadas (bina)
+	mux.Handle("/api/bina/chamadas", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Dispositivos se autenticam pelo token do bina; usuários, pelo JWT
+		if r.Header.Get("X-Bina-Token") != "" {
+			handlers.ReceberChamadaDispositivoHandler(central, cfg.Bina.Token)(w, r)
+			return
+		}
+		middleware.AuthMiddleware(db)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodPost {
+				handlers.ReceberChamadaHandler(central)(w, r)
+				return
+			}
+			handlers.ListarChamadasHandler(db)(w, r)
+		})).ServeHTTP(w, r)
+	}))
+	mux.Handle("/api/bina/eventos", middleware.AuthStreamMiddleware(db)(http.HandlerFunc(handlers.EventosChamadasHandler(db, central))))
+
+	// Rota para eventos em tempo real do painel (Server-Sent Events). O EventSource do navegador
+	// se autentica com o token de stream de /api/eventos/token, que também vale para /api/bina/eventos
+	mux.Handle("/api/eventos", middleware.AuthStreamMiddleware(db)(http.HandlerFunc(handlers.EventosHandler(db))))
+	mux.Handle("/api/eventos/token", middleware.AuthMiddleware(db)(http.HandlerFunc(handlers.TokenStreamHandler())))
+
+	// Rotas para webhooks de saída (apenas admin)
+	mux.Handle("/api/webhooks", middleware.AuthMiddleware(db)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			handlers.ListarWebhooksHandler(db)(w, r)
+			return
+		} else if r.Method == http.MethodPost {
+			handlers.CriarWebhookHandler(db)(w, r)
+			return
+		}
+		http.Error(w, "Método não permitido", http.StatusMethodNotAllowed)
+	})))
+	mux.Handle("/api/webhooks/", middleware.AuthMiddleware(db)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		segments := strings.Split(r.URL.Path, "/")
+		// Entregas: /api/webhooks/entregas/{id} e /api/webhooks/entregas/{id}/reenviar
+		if len(segments) >= 5 && segments[3] == "entregas" && segments[4] != "" {
+			if len(segments) == 6 && segments[5] == "reenviar" {
+				handlers.ReenviarEntregaWebhookHandler(db)(w, r)
+				return
+			}
+			if len(segments) == 5 {
+				handlers.ObterEntregaWebhookHandler(db)(w, r)
+				return
+			}
+			http.Error(w, "Rota não encontrada", http.StatusNotFound)
+			return
+		}
+		if len(segments) >= 4 && segments[3] != "" {
+			// Registro de entregas do webhook
+			if len(segments) == 5 && segments[4] == "entregas" {
+				handlers.ListarEntregasWebhookHandler(db)(w, r)
+				return
+			}
+			if len(segments) > 4 {
+				http.Error(w, "Rota não encontrada", http.StatusNotFound)
+				return
+			}
+			switch r.Method {
+			case http.MethodGet:
+				handlers.ObterWebhookHandler(db)(w, r)
+			case http.MethodPut, http.MethodPatch:
+				handlers.AtualizarWebhookHandler(db)(w, r)
+			case http.MethodDelete:
+				handlers.ExcluirWebhookHandler(db)(w, r)
+			default:
+				http.Error(w, "Método não permitido", http.StatusMethodNotAllowed)
+			}
+			return
+		}
+		http.Error(w, "Método não permitido", http.StatusMethodNotAllowed)
+	})))
+
+	// Rotas para conciliação bancária (gerente ou admin)
+	mux.Handle("/api/conciliacao/extratos", middleware.AuthMiddleware(db)(http.HandlerFunc(handlers.ImportarExtratoHandler(db))))
+	mux.Handle("/api/conciliacao/pedidos-pendentes", middleware.AuthMiddleware(db)(http.HandlerFunc(handlers.ListarPedidosNaoConciliadosHandler(db))))
+	mux.Handle("/api/conciliacao/transacoes", middleware.AuthMiddleware(db)(http.HandlerFunc(handlers.ListarTransacoesBancariasHandler(db))))
+	mux.Handle("/api/conciliacao/transacoes/", middleware.AuthMiddleware(db)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		segments := strings.Split(r.URL.Path, "/")
+		// Rota para conciliar ou desfazer: /api/conciliacao/transacoes/{id}/conciliar
+		if len(segments) == 6 && segments[4] != "" && segments[5] == "conciliar" {
+			handlers.ConciliarTransacaoHandler(db)(w, r)
+			return
+		}
+		http.Error(w, "Rota não encontrada", http.StatusNotFound)
+	})))
+
+	// Rotas para relatórios
+	mux.Handle("/api/relatorios/dashboard", middleware.AuthMiddleware(db)(http.HandlerFunc(handlers.DashboardHandler(db))))
+
+	// Rotas para exportação de planilhas (CSV ou XLSX)
+	mux.Handle("/api/exportar/pedidos", middleware.AuthMiddleware(db)(http.HandlerFunc(handlers.ExportarPedidosHandler(db))))
+	mux.Handle("/api/exportar/clientes", middleware.AuthMiddleware(db)(http.HandlerFunc(handlers.ExportarClientesHandler(db))))
+	mux.Handle("/api/exportar/movimentacoes-estoque", middleware.AuthMiddleware(db)(http.HandlerFunc(handlers.ExportarMovimentacoesEstoqueHandler(db))))
+
+	// Rotas para sessões de caixa
+	mux.Handle("/api/caixas", middleware.AuthMiddleware(db)(http.HandlerFunc(handlers.ListarCaixasHandler(db))))
+	mux.Handle("/api/caixas/abrir", middleware.AuthMiddleware(db)(http.HandlerFunc(handlers.AbrirCaixaHandler(db))))
+	mux.Handle("/api/caixas/atual", middleware.AuthMiddleware(db)(http.HandlerFunc(handlers.CaixaAtualHandler(db))))
+	mux.Handle("/api/caixas/", middleware.AuthMiddleware(db)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		segments := strings.Split(r.URL.Path, "/")
+		// Rota para obter um caixa específico
+		if len(segments) == 4 && segments[3] != "" {
+			handlers.ObterCaixaHandler(db)(w, r)
+			return
+		}
+		if len(segments) == 5 && segments[3] != "" {
+			switch segments[4] {
+			case "sangria", "suprimento":
+				handlers.MovimentarCaixaHandler(db)(w, r)
+				return
+			case "fechar":
+				handlers.FecharCaixaHandler(db)(w, r)
+				return
+			}
+		}
+		http.Error(w, "Rota não encontrada", http.StatusNotFound)
+	})))
+
+	// Rotas do entregador para as próprias entregas
+	mux.Handle("/api/minhas-entregas", middleware.AuthMiddleware(db)(http.HandlerFunc(handlers.MinhasEntregasHandler(db))))
+	mux.Handle("/api/minhas-entregas/", middleware.AuthMiddleware(db)(http.HandlerFunc(handlers.AcaoMinhaEntregaHandler(db))))
+
+	// Rotas para despacho de entregas
+	mux.Handle("/api/entregas/lotes", middleware.AuthMiddleware(db)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			handlers.ListarLotesEntregaHandler(db, planejador)(w, r)
+		case http.MethodPost:
+			handlers.AtribuirLoteEntregaHandler(db)(w, r)
+		default:
+			http.Error(w, "Método não permitido", http.StatusMethodNotAllowed)
+		}
+	})))
 
 	// Aplicar o middleware CORS a todas as rotas
-	return middleware.CorsMiddleware(mux)
+	return middleware.CorsMiddleware(cfg.CORS.OrigensPermitidas)(mux)
 }
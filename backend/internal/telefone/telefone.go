@@ -0,0 +1,74 @@
+// Package telefone normaliza números de telefone para o formato E.164 (+55 DDD número),
+// usado na busca exata de clientes pelo número que está ligando.
+package telefone
+
+import "strings"
+
+// codigoBrasil é o código de país assumido quando o número não começa com '+'
+const codigoBrasil = "55"
+
+// NormalizarE164 converte o número digitado em qualquer formato para E.164. Números brasileiros
+// aceitam zeros de discagem e o código 55 opcionais e precisam do DDD; celulares antigos com
+// 8 dígitos ganham o nono dígito, de modo que as duas variantes resultam no mesmo valor.
+// Números com '+' e outro código de país são aceitos como estão (8 a 15 dígitos).
+func NormalizarE164(numero string) (string, bool) {
+	numero = strings.TrimSpace(numero)
+	digitos := apenasDigitos(numero)
+
+	if strings.HasPrefix(numero, "+") {
+		if !strings.HasPrefix(digitos, codigoBrasil) {
+			if len(digitos) < 8 || len(digitos) > 15 {
+				return "", false
+			}
+			return "+" + digitos, true
+		}
+		digitos = digitos[len(codigoBrasil):]
+	} else {
+		digitos = strings.TrimLeft(digitos, "0")
+		if (len(digitos) == 12 || len(digitos) == 13) && strings.HasPrefix(digitos, codigoBrasil) {
+			digitos = digitos[len(codigoBrasil):]
+		}
+	}
+
+	// DDD de dois dígitos, nenhum deles zero
+	if len(digitos) < 10 || digitos[0] == '0' || digitos[1] == '0' {
+		return "", false
+	}
+
+	switch {
+	case len(digitos) == 11 && digitos[2] == '9':
+		// Celular com nove dígitos
+	case len(digitos) == 10 && digitos[2] >= '6':
+		// Celular no formato antigo de oito dígitos
+		digitos = digitos[:2] + "9" + digitos[2:]
+	case len(digitos) == 10 && digitos[2] >= '2':
+		// Telefone fixo
+	default:
+		return "", false
+	}
+	return "+" + codigoBrasil + digitos, true
+}
+
+// Formatar retorna o número E.164 brasileiro no formato de exibição (DD) 99999-9999;
+// outros números são retornados sem alteração
+func Formatar(e164 string) string {
+	prefixo := "+" + codigoBrasil
+	if !strings.HasPrefix(e164, prefixo) {
+		return e164
+	}
+	d := e164[len(prefixo):]
+	if len(d) != 10 && len(d) != 11 {
+		return e164
+	}
+	return "(" + d[:2] + ") " + d[2:len(d)-4] + "-" + d[len(d)-4:]
+}
+
+func apenasDigitos(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if r >= '0' && r <= '9' {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,80 @@
+package telefone
+
+import "testing"
+
+func TestNormalizarE164(t *testing.T) {
+	casos := []struct {
+		numero   string
+		esperado string // vazio quando o número é recusado
+	}{
+		// Celulares com nove dígitos e no formato antigo, que ganham o nono dígito
+		{"86999887766", "+5586999887766"},
+		{"(86) 99988-7766", "+5586999887766"},
+		{"8699887766", "+5586999887766"},
+		{"(86) 9988-7766", "+5586999887766"},
+		{"86 8988-7766", "+5586989887766"},
+		{"8669887766", "+5586969887766"},
+		{"8679887766", "+5586979887766"},
+
+		// Fixos com prefixo de 2 a 5
+		{"(86) 3222-1100", "+558632221100"},
+		{"1122221100", "+551122221100"},
+		{"8642221100", "+558642221100"},
+		{"8652221100", "+558652221100"},
+
+		// +55, 55 e zeros de discagem
+		{"+55 86 99988-7766", "+5586999887766"},
+		{"+55 (86) 3222-1100", "+558632221100"},
+		{"5586999887766", "+5586999887766"},
+		{"558632221100", "+558632221100"},
+		{"0 86 99988-7766", "+5586999887766"},
+		{"086 3222-1100", "+558632221100"},
+		{"005586999887766", "+5586999887766"},
+		{" 86999887766 ", "+5586999887766"},
+
+		// DDD inválido
+		{"+55 06 99988-7766", ""},
+		{"+55 0632221100", ""},
+		{"8032221100", ""},
+		{"80 99988-7766", ""},
+
+		// Prefixo que não é de fixo nem de celular
+		{"8612221100", ""},
+		{"8602221100", ""},
+		{"86 8998-87766", ""},
+
+		// Curto ou longo demais
+		{"", ""},
+		{"3222-1100", ""},
+		{"99988-7766", ""},
+		{"869998877661", ""},
+		{"+55 86 999887766123", ""},
+		{"+55", ""},
+
+		// Números estrangeiros com '+' ficam como estão, de 8 a 15 dígitos
+		{"+1 (415) 555-2671", "+14155552671"},
+		{"+351 912 345 678", "+351912345678"},
+		{"+4412345", ""},
+		{"+1234567890123456", ""},
+	}
+	for _, c := range casos {
+		e164, ok := NormalizarE164(c.numero)
+		if ok != (c.esperado != "") || e164 != c.esperado {
+			t.Errorf("NormalizarE164(%q) = %q, %v; esperado %q", c.numero, e164, ok, c.esperado)
+		}
+	}
+}
+
+func TestFormatar(t *testing.T) {
+	casos := map[string]string{
+		"+5586999887766": "(86) 99988-7766",
+		"+558632221100":  "(86) 3222-1100",
+		"+14155552671":   "+14155552671",
+		"+55123":         "+55123",
+	}
+	for e164, esperado := range casos {
+		if formatado := Formatar(e164); formatado != esperado {
+			t.Errorf("Formatar(%q) = %q, esperado %q", e164, formatado, esperado)
+		}
+	}
+}
@@ -0,0 +1,327 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/tassyosilva/GestGAS/internal/models"
+)
+
+const (
+	// intervaloVarredura é de quanto em quanto tempo a outbox e as entregas pendentes são verificadas
+	intervaloVarredura = 5 * time.Second
+	// tamanhoLote limita os eventos distribuídos e as entregas feitas em cada varredura
+	tamanhoLote = 50
+	// tempoLimiteEnvio é quanto o destino tem para responder a uma entrega
+	tempoLimiteEnvio = 10 * time.Second
+	// reservaEntrega afasta a próxima tentativa enquanto o envio está em andamento, para que outra
+	// instância não envie a mesma entrega ao mesmo tempo
+	reservaEntrega = 2 * time.Minute
+
+	// MaxTentativas é quantas vezes uma entrega é tentada antes de ser marcada como falha
+	MaxTentativas = 10
+	// esperaInicial é o intervalo antes da segunda tentativa; dobra a cada falha, até esperaMaxima
+	esperaInicial = 30 * time.Second
+	esperaMaxima  = 6 * time.Hour
+)
+
+// Despachante distribui os eventos da outbox aos webhooks e faz as entregas pendentes
+type Despachante struct {
+	db      *sql.DB
+	cliente *http.Client
+}
+
+// NovoDespachante cria o despachante de webhooks
+func NovoDespachante(db *sql.DB) *Despachante {
+	return &Despachante{
+		db: db,
+		cliente: &http.Client{
+			Timeout:   tempoLimiteEnvio,
+			Transport: novoTransporte(),
+			// Redirecionamentos não são seguidos: a URL cadastrada deve ser a final
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+// Executar varre a outbox e as entregas pendentes até o contexto ser cancelado
+func (d *Despachante) Executar(ctx context.Context) {
+	ticker := time.NewTicker(intervaloVarredura)
+	defer ticker.Stop()
+	for {
+		if err := d.distribuir(); err != nil {
+			log.Printf("Erro ao distribuir eventos de webhook: %v", err)
+		}
+		if err := d.entregarPendentes(ctx); err != nil {
+			log.Printf("Erro ao entregar webhooks: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// distribuir cria uma entrega para cada webhook ativo que assina cada evento ainda não distribuído.
+// Os webhooks são consultados no momento da distribuição: um webhook cadastrado depois não recebe
+// eventos antigos.
+func (d *Despachante) distribuir() error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() // sem efeito após o commit
+
+	rows, err := tx.Query(`
+		SELECT id, tipo
+		FROM webhooks_outbox
+		WHERE distribuido_em IS NULL
+		ORDER BY id
+		LIMIT $1
+		FOR UPDATE SKIP LOCKED
+	`, tamanhoLote)
+	if err != nil {
+		return err
+	}
+	type eventoOutbox struct {
+		id   int64
+		tipo string
+	}
+	var pendentes []eventoOutbox
+	for rows.Next() {
+		var e eventoOutbox
+		if err := rows.Scan(&e.id, &e.tipo); err != nil {
+			rows.Close()
+			return err
+		}
+		pendentes = append(pendentes, e)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(pendentes) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, 0, len(pendentes))
+	for _, e := range pendentes {
+		_, err := tx.Exec(`
+			INSERT INTO webhooks_entregas (webhook_id, evento_id)
+			SELECT id, $1 FROM webhooks WHERE ativo AND $2 = ANY(eventos)
+			ON CONFLICT (webhook_id, evento_id) DO NOTHING
+		`, e.id, e.tipo)
+		if err != nil {
+			return fmt.Errorf("erro ao distribuir evento %d: %w", e.id, err)
+		}
+		ids = append(ids, e.id)
+	}
+
+	_, err = tx.Exec("UPDATE webhooks_outbox SET distribuido_em = NOW() WHERE id = ANY($1)", pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// entregaPendente reúne o que é preciso para enviar uma entrega
+type entregaPendente struct {
+	id         int64
+	tentativas int
+	url        string
+	segredo    string
+	eventoID   int64
+	tipo       string
+	dados      json.RawMessage
+	criadoEm   time.Time
+}
+
+// entregarPendentes reserva as entregas cuja próxima tentativa já venceu e as envia.
+// Entregas de webhooks desativados ficam pendentes até o webhook ser reativado.
+func (d *Despachante) entregarPendentes(ctx context.Context) error {
+	rows, err := d.db.Query(`
+		WITH reservadas AS (
+			UPDATE webhooks_entregas
+			SET proxima_tentativa = NOW() + make_interval(secs => $1), atualizado_em = NOW()
+			WHERE id IN (
+				SELECT id FROM webhooks_entregas
+				WHERE status = $2 AND proxima_tentativa <= NOW()
+				AND webhook_id IN (SELECT id FROM webhooks WHERE ativo)
+				ORDER BY proxima_tentativa
+				LIMIT $3
+				FOR UPDATE SKIP LOCKED
+			)
+			RETURNING id, webhook_id, evento_id, tentativas
+		)
+		SELECT r.id, r.tentativas, w.url, w.segredo, o.id, o.tipo, o.dados, o.criado_em
+		FROM reservadas r
+		JOIN webhooks w ON r.webhook_id = w.id
+		JOIN webhooks_outbox o ON r.evento_id = o.id
+		ORDER BY r.id
+	`, int(reservaEntrega.Seconds()), models.EntregaWebhookPendente, tamanhoLote)
+	if err != nil {
+		return err
+	}
+	var entregas []entregaPendente
+	for rows.Next() {
+		var e entregaPendente
+		if err := rows.Scan(&e.id, &e.tentativas, &e.url, &e.segredo, &e.eventoID, &e.tipo, &e.dados, &e.criadoEm); err != nil {
+			rows.Close()
+			return err
+		}
+		entregas = append(entregas, e)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, e := range entregas {
+		if ctx.Err() != nil {
+			// As entregas reservadas voltam a ser tentadas quando a reserva expirar
+			return nil
+		}
+		if err := d.entregar(ctx, e); err != nil {
+			log.Printf("Erro ao registrar entrega de webhook %d: %v", e.id, err)
+		}
+	}
+	return nil
+}
+
+// entregar envia o evento e registra a tentativa, reagendando a entrega em caso de falha
+func (d *Despachante) entregar(ctx context.Context, e entregaPendente) error {
+	corpo, err := json.Marshal(struct {
+		ID       int64           `json:"id"`
+		Tipo     string          `json:"tipo"`
+		CriadoEm time.Time       `json:"criado_em"`
+		Dados    json.RawMessage `json:"dados"`
+	}{e.eventoID, e.tipo, e.criadoEm, e.dados})
+	if err != nil {
+		return err
+	}
+
+	inicio := time.Now()
+	statusHTTP, erroEnvio := d.enviar(ctx, e, corpo, inicio.Unix())
+	duracao := time.Since(inicio)
+
+	var statusNulo sql.NullInt64
+	if statusHTTP > 0 {
+		statusNulo = sql.NullInt64{Int64: int64(statusHTTP), Valid: true}
+	}
+	var mensagemErro sql.NullString
+	if erroEnvio != nil {
+		mensagemErro = sql.NullString{String: erroEnvio.Error(), Valid: true}
+	}
+
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() // sem efeito após o commit
+
+	_, err = tx.Exec(`
+		INSERT INTO webhooks_tentativas (entrega_id, status_http, erro, duracao_ms)
+		VALUES ($1, $2, $3, $4)
+	`, e.id, statusNulo, mensagemErro, duracao.Milliseconds())
+	if err != nil {
+		return err
+	}
+
+	tentativas := e.tentativas + 1
+	switch {
+	case erroEnvio == nil:
+		_, err = tx.Exec(`
+			UPDATE webhooks_entregas
+			SET status = $1, tentativas = $2, ultimo_status_http = $3, ultimo_erro = NULL,
+				proxima_tentativa = NULL, entregue_em = NOW(), atualizado_em = NOW()
+			WHERE id = $4
+		`, models.EntregaWebhookEntregue, tentativas, statusNulo, e.id)
+	case tentativas >= MaxTentativas:
+		_, err = tx.Exec(`
+			UPDATE webhooks_entregas
+			SET status = $1, tentativas = $2, ultimo_status_http = $3, ultimo_erro = $4,
+				proxima_tentativa = NULL, atualizado_em = NOW()
+			WHERE id = $5
+		`, models.EntregaWebhookFalhou, tentativas, statusNulo, mensagemErro, e.id)
+	default:
+		_, err = tx.Exec(`
+			UPDATE webhooks_entregas
+			SET tentativas = $1, ultimo_status_http = $2, ultimo_erro = $3,
+				proxima_tentativa = NOW() + make_interval(secs => $4), atualizado_em = NOW()
+			WHERE id = $5
+		`, tentativas, statusNulo, mensagemErro, int(Espera(tentativas).Seconds()), e.id)
+	}
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// enviar faz o POST assinado. Retorna o status HTTP recebido (0 se não houve resposta) e um erro
+// quando a entrega não foi aceita.
+func (d *Despachante) enviar(ctx context.Context, e entregaPendente, corpo []byte, timestamp int64) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url, bytes.NewReader(corpo))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "GestGAS-Webhook/1.0")
+	req.Header.Set(CabecalhoEvento, e.tipo)
+	req.Header.Set(CabecalhoEventoID, fmt.Sprint(e.eventoID))
+	req.Header.Set(CabecalhoTimestamp, fmt.Sprint(timestamp))
+	req.Header.Set(CabecalhoAssinatura, Assinar(e.segredo, timestamp, corpo))
+
+	resp, err := d.cliente.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("destino respondeu %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// Espera é o intervalo até a próxima tentativa depois da falha de número tentativas:
+// 30s, 1min, 2min, 4min... limitado a 6 horas
+func Espera(tentativas int) time.Duration {
+	espera := esperaInicial
+	for i := 1; i < tentativas; i++ {
+		espera *= 2
+		if espera >= esperaMaxima {
+			return esperaMaxima
+		}
+	}
+	return espera
+}
+
+// Reenviar coloca a entrega de novo na fila para envio imediato, com as tentativas zeradas.
+// Retorna sql.ErrNoRows se a entrega não existir.
+func Reenviar(db *sql.DB, entregaID int64) error {
+	res, err := db.Exec(`
+		UPDATE webhooks_entregas
+		SET status = $1, tentativas = 0, proxima_tentativa = NOW(), atualizado_em = NOW()
+		WHERE id = $2
+	`, models.EntregaWebhookPendente, entregaID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+// ErrDestinoProibido indica uma URL que aponta para a própria máquina ou para a rede interna
+var ErrDestinoProibido = errors.New("destino não permitido: o webhook deve apontar para um endereço público")
+
+// tempoLimiteResolucao é quanto a validação do cadastro espera pela resolução do nome do destino
+const tempoLimiteResolucao = 5 * time.Second
+
+// redesProibidas são faixas que não aparecem nos métodos de net.IP: rede "este host" (0.0.0.0/8)
+// e o espaço compartilhado das operadoras (100.64.0.0/10, RFC 6598)
+var redesProibidas = []*net.IPNet{
+	{IP: net.IPv4(0, 0, 0, 0), Mask: net.CIDRMask(8, 32)},
+	{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)},
+}
+
+// enderecoProibido indica se o IP é de loopback, link-local (como 169.254.169.254, dos metadados
+// das nuvens), privado (RFC 1918 e fc00::/7), multicast ou não especificado
+func enderecoProibido(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() {
+		return true
+	}
+	for _, rede := range redesProibidas {
+		if rede.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidarDestino recusa URLs de webhook cujo host seja, ou resolva para, um endereço proibido.
+// A validação no cadastro dá retorno imediato ao administrador; a proteção efetiva é a do
+// discador do Despachante, que confere o IP de cada conexão e por isso resiste a DNS rebinding.
+func ValidarDestino(endereco string) error {
+	u, err := url.Parse(endereco)
+	if err != nil {
+		return fmt.Errorf("URL inválida: %w", err)
+	}
+	host := u.Hostname()
+
+	if ip := net.ParseIP(host); ip != nil {
+		if enderecoProibido(ip) {
+			return ErrDestinoProibido
+		}
+		return nil
+	}
+
+	ctx, cancelar := context.WithTimeout(context.Background(), tempoLimiteResolucao)
+	defer cancelar()
+	ips, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return fmt.Errorf("não foi possível resolver o host %s: %w", host, err)
+	}
+	for _, ip := range ips {
+		if enderecoProibido(ip.IP) {
+			return ErrDestinoProibido
+		}
+	}
+	return nil
+}
+
+// controlarConexao é chamado pelo discador com o IP já resolvido, imediatamente antes de conectar
+func controlarConexao(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return fmt.Errorf("endereço de destino inválido %s: %w", address, err)
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || enderecoProibido(ip) {
+		return fmt.Errorf("%w (%s)", ErrDestinoProibido, host)
+	}
+	return nil
+}
+
+// novoTransporte cria o transporte HTTP das entregas: sem proxy, que faria a conexão sair de
+// outro endereço, e com a verificação de destino em cada conexão aberta
+func novoTransporte() *http.Transport {
+	transporte := http.DefaultTransport.(*http.Transport).Clone()
+	transporte.Proxy = nil
+	transporte.DialContext = (&net.Dialer{
+		Timeout:   tempoLimiteEnvio,
+		KeepAlive: 30 * time.Second,
+		Control:   controlarConexao,
+	}).DialContext
+	return transporte
+}
@@ -0,0 +1,62 @@
+package webhook
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestEnderecoProibido(t *testing.T) {
+	casos := map[string]bool{
+		"127.0.0.1":            true,
+		"::1":                  true,
+		"169.254.169.254":      true,
+		"10.1.2.3":             true,
+		"172.16.0.1":           true,
+		"192.168.0.10":         true,
+		"100.64.0.1":           true,
+		"0.0.0.0":              true,
+		"fd00::1":              true,
+		"fe80::1":              true,
+		"::ffff:127.0.0.1":     true,
+		"8.8.8.8":              false,
+		"172.32.0.1":           false,
+		"2001:4860:4860::8888": false,
+	}
+	for endereco, proibido := range casos {
+		if got := enderecoProibido(net.ParseIP(endereco)); got != proibido {
+			t.Errorf("enderecoProibido(%s) = %v, esperado %v", endereco, got, proibido)
+		}
+	}
+}
+
+func TestValidarDestinoComIP(t *testing.T) {
+	for _, url := range []string{
+		"http://127.0.0.1:8080/hook",
+		"http://169.254.169.254/latest/meta-data/",
+		"https://[::1]/hook",
+		"http://192.168.1.20/erp",
+	} {
+		if err := ValidarDestino(url); !errors.Is(err, ErrDestinoProibido) {
+			t.Errorf("ValidarDestino(%s) = %v, esperado ErrDestinoProibido", url, err)
+		}
+	}
+	if err := ValidarDestino("https://203.0.113.10/hook"); err != nil {
+		t.Errorf("destino público recusado: %v", err)
+	}
+}
+
+func TestDespachanteNaoConectaNaRedeInterna(t *testing.T) {
+	servidor := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("a entrega não deveria chegar a um servidor em loopback")
+	}))
+	defer servidor.Close()
+
+	d := NovoDespachante(nil)
+	_, err := d.cliente.Post(servidor.URL, "application/json", nil)
+	if !errors.Is(err, ErrDestinoProibido) {
+		t.Fatalf("esperado ErrDestinoProibido ao conectar em %s, veio %v", servidor.URL, err)
+	}
+}
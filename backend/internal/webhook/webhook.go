@@ -0,0 +1,70 @@
+// Package webhook envia os eventos do sistema a integrações externas (bot de WhatsApp,
+// contabilidade) por HTTP POST assinado com HMAC-SHA256.
+//
+// Os eventos são gravados em webhooks_outbox pela mesma transação que altera o estado, com
+// Enfileirar, e só existem se ela for confirmada. O Despachante distribui cada evento aos
+// webhooks assinantes e faz as entregas, com novas tentativas em intervalos crescentes.
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/tassyosilva/GestGAS/internal/eventos"
+)
+
+// EventosSuportados são os tipos de evento que podem ser assinados
+var EventosSuportados = []string{
+	eventos.PedidoCriado,
+	eventos.PedidoStatusAlterado,
+	eventos.EstoqueAlerta,
+	eventos.BotijasRetornadas,
+}
+
+// Cabeçalhos enviados em cada entrega
+const (
+	CabecalhoEvento     = "X-GestGAS-Evento"     // Tipo do evento
+	CabecalhoEventoID   = "X-GestGAS-Evento-ID"  // Igual em todas as tentativas, para o destino ignorar repetições
+	CabecalhoTimestamp  = "X-GestGAS-Timestamp"  // Segundos desde 1970, incluídos na assinatura
+	CabecalhoAssinatura = "X-GestGAS-Assinatura" // "sha256=" + HMAC-SHA256 hexadecimal
+)
+
+// EventoSuportado indica se o tipo de evento pode ser assinado
+func EventoSuportado(tipo string) bool {
+	for _, suportado := range EventosSuportados {
+		if suportado == tipo {
+			return true
+		}
+	}
+	return false
+}
+
+// Enfileirar grava o evento na outbox dentro da transação da alteração que o originou.
+// Se a transação for desfeita, o evento também é.
+func Enfileirar(tx *sql.Tx, tipo string, dados interface{}) error {
+	conteudo, err := json.Marshal(dados)
+	if err != nil {
+		return fmt.Errorf("erro ao serializar evento %s: %w", tipo, err)
+	}
+	_, err = tx.Exec("INSERT INTO webhooks_outbox (tipo, dados) VALUES ($1, $2)", tipo, conteudo)
+	if err != nil {
+		return fmt.Errorf("erro ao registrar evento %s: %w", tipo, err)
+	}
+	return nil
+}
+
+// Assinar calcula a assinatura enviada no cabeçalho X-GestGAS-Assinatura: o HMAC-SHA256, com o
+// segredo do webhook, de "<timestamp>.<corpo>". O destino deve recalculá-la sobre o corpo recebido
+// e recusar timestamps antigos.
+func Assinar(segredo string, timestamp int64, corpo []byte) string {
+	mac := hmac.New(sha256.New, []byte(segredo))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(corpo)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
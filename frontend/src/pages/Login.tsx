@@ -1,5 +1,6 @@
 import { useState, useEffect } from 'react';
 import { useNavigate } from 'react-router-dom';
+import axios from 'axios';
 import {
   Box,
   Button,
@@ -16,7 +17,7 @@ import {
   styled,
 } from '@mui/material';
 import logo from '../assets/logo.png';
-import { authService } from '../services/authService';
+import { authService, LoginResponse } from '../services/authService';
 
 const StyledCard = styled(Card)(({ theme }) => ({
   display: 'flex',
@@ -74,6 +75,9 @@ export default function Login() {
     senha: '',
   });
   const [loading, setLoading] = useState(false);
+  // Login com senha provisória: a sessão só é guardada depois da troca de senha
+  const [loginPendente, setLoginPendente] = useState<LoginResponse | null>(null);
+  const [novaSenha, setNovaSenha] = useState({ senha: '', confirmacao: '' });
 
   // Verificar se há mensagem de erro de autenticação armazenada
   useEffect(() => {
@@ -92,6 +96,45 @@ export default function Login() {
     });
   };
 
+  // Armazenar os tokens no localStorage, agendar a renovação e ir para a página principal
+  const concluirLogin = (response: LoginResponse) => {
+    authService.salvarTokens(response);
+    localStorage.setItem('user', JSON.stringify({
+      id: response.id,
+      nome: response.nome,
+      login: response.login,
+      perfil: response.perfil,
+    }));
+    navigate('/dashboard');
+  };
+
+  const handleTrocarSenha = async (event: React.FormEvent<HTMLFormElement>) => {
+    event.preventDefault();
+    if (!loginPendente) {
+      return;
+    }
+    if (novaSenha.senha !== novaSenha.confirmacao) {
+      setLoginError('A confirmação não confere com a nova senha');
+      return;
+    }
+    setLoading(true);
+
+    try {
+      await authService.trocarSenha(loginPendente.token, formData.senha, novaSenha.senha);
+      concluirLogin(loginPendente);
+    } catch (error) {
+      console.error('Erro ao trocar senha:', error);
+      // A política de senhas devolve o motivo da recusa em texto
+      if (axios.isAxiosError(error) && typeof error.response?.data === 'string') {
+        setLoginError(error.response.data.trim());
+      } else {
+        setLoginError('Não foi possível trocar a senha');
+      }
+    } finally {
+      setLoading(false);
+    }
+  };
+
   const handleSubmit = async (event: React.FormEvent<HTMLFormElement>) => {
     event.preventDefault();
     setLoading(true);
@@ -99,20 +142,21 @@ export default function Login() {
     try {
       const response = await authService.login(formData.login, formData.senha);
 
-      // Armazenar o token no localStorage
-      localStorage.setItem('token', response.token);
-      localStorage.setItem('user', JSON.stringify({
-        id: response.id,
-        nome: response.nome,
-        login: response.login,
-        perfil: response.perfil,
-      }));
-
-      // Redirecionar para a página principal
-      navigate('/dashboard');
+      if (response.deve_trocar_senha) {
+        setLoginError('');
+        setLoginPendente(response);
+        return;
+      }
+      concluirLogin(response);
     } catch (error) {
       console.error('Erro de login:', error);
-      setLoginError('Usuário ou senha inválidos');
+      // Bloqueio por excesso de tentativas (429) ou usuário desativado (403): mostrar a mensagem do servidor
+      const status = axios.isAxiosError(error) ? error.response?.status : undefined;
+      if (axios.isAxiosError(error) && (status === 429 || status === 403) && typeof error.response?.data === 'string') {
+        setLoginError(error.response.data.trim());
+      } else {
+        setLoginError('Usuário ou senha inválidos');
+      }
     } finally {
       setLoading(false);
     }
@@ -144,6 +188,62 @@ export default function Login() {
             </Typography>
           )}
 
+          {loginPendente ? (
+          <Box
+            component="form"
+            onSubmit={handleTrocarSenha}
+            noValidate
+            sx={{
+              display: 'flex',
+              flexDirection: 'column',
+              width: '100%',
+              gap: 2,
+            }}
+          >
+            <Typography sx={{ textAlign: 'center' }}>
+              Sua senha é provisória. Defina uma nova senha para continuar.
+            </Typography>
+
+            <FormControl>
+              <FormLabel htmlFor="nova-senha">Nova senha</FormLabel>
+              <TextField
+                id="nova-senha"
+                value={novaSenha.senha}
+                onChange={(e) => setNovaSenha({ ...novaSenha, senha: e.target.value })}
+                type="password"
+                autoComplete="new-password"
+                autoFocus
+                required
+                fullWidth
+                variant="outlined"
+              />
+            </FormControl>
+
+            <FormControl>
+              <FormLabel htmlFor="confirmacao-senha">Confirme a nova senha</FormLabel>
+              <TextField
+                id="confirmacao-senha"
+                value={novaSenha.confirmacao}
+                onChange={(e) => setNovaSenha({ ...novaSenha, confirmacao: e.target.value })}
+                type="password"
+                autoComplete="new-password"
+                required
+                fullWidth
+                variant="outlined"
+              />
+            </FormControl>
+
+            <Button
+              type="submit"
+              fullWidth
+              variant="contained"
+              color="primary"
+              disabled={loading}
+            >
+              {loading ? 'Salvando...' : 'Trocar senha e entrar'}
+            </Button>
+          </Box>
+          ) : (
           <Box
             component="form"
             onSubmit={handleSubmit}
@@ -206,6 +306,7 @@ export default function Login() {
               Esqueceu a senha?
             </Link>
           </Box>
+          )}
         </StyledCard>
       </LoginContainer>
     </CssBaseline>
@@ -8,9 +8,26 @@ export interface LoginResponse {
     nome: string;
     login: string;
     perfil: string;
+    deve_trocar_senha: boolean;
     token: string;
+    expira: string;
+    refresh_token: string;
+    refresh_expira: string;
 }
 
+// Resposta da renovação de sessão
+export interface TokensResponse {
+    token: string;
+    expira: string;
+    refresh_token: string;
+    refresh_expira: string;
+}
+
+// Antecedência com que o token de acesso é renovado antes de expirar
+const ANTECEDENCIA_RENOVACAO_MS = 60 * 1000;
+
+let temporizadorRenovacao: ReturnType<typeof setTimeout> | undefined;
+
 // Configuração inicial do Axios
 const api = axios.create({
     baseURL: API_BASE_URL,
@@ -41,6 +58,48 @@ export const authService = {
         }
     },
 
+    // Trocar a senha provisória com o token recebido no login, antes de guardá-lo
+    async trocarSenha(token: string, senhaAtual: string, novaSenha: string): Promise<void> {
+        await api.post('/usuarios/me/senha', { senha_atual: senhaAtual, nova_senha: novaSenha }, {
+            headers: { Authorization: `Bearer ${token}` },
+        });
+    },
+
+    // Guardar os tokens recebidos no login ou na renovação e agendar a próxima renovação
+    salvarTokens(tokens: TokensResponse) {
+        localStorage.setItem('token', tokens.token);
+        localStorage.setItem('tokenExpira', tokens.expira);
+        localStorage.setItem('refreshToken', tokens.refresh_token);
+        this.agendarRenovacao();
+    },
+
+    // Trocar o refresh token por um novo par de tokens; sem sessão válida, volta ao login
+    async renovarToken(): Promise<void> {
+        const refreshToken = localStorage.getItem('refreshToken');
+        if (!refreshToken) {
+            return;
+        }
+        try {
+            const response = await axios.post<TokensResponse>(`${API_BASE_URL}/token/refresh`, { refresh_token: refreshToken });
+            this.salvarTokens(response.data);
+        } catch (error) {
+            console.error('Erro ao renovar sessão:', error);
+            this.limparSessao();
+            window.location.href = '/login';
+        }
+    },
+
+    // Renovar o token de acesso pouco antes de ele expirar
+    agendarRenovacao() {
+        clearTimeout(temporizadorRenovacao);
+        const expira = localStorage.getItem('tokenExpira');
+        if (!expira || !localStorage.getItem('refreshToken')) {
+            return;
+        }
+        const espera = Math.max(new Date(expira).getTime() - Date.now() - ANTECEDENCIA_RENOVACAO_MS, 0);
+        temporizadorRenovacao = setTimeout(() => this.renovarToken(), espera);
+    },
+
     // Verificar se o usuário está autenticado
     isAuthenticated(): boolean {
         return !!localStorage.getItem('token');
@@ -52,13 +111,29 @@ export const authService = {
         return user ? JSON.parse(user) : null;
     },
 
-    // Logout do usuário
-    logout() {
+    // Remover os dados da sessão do navegador
+    limparSessao() {
+        clearTimeout(temporizadorRenovacao);
         localStorage.removeItem('token');
+        localStorage.removeItem('tokenExpira');
+        localStorage.removeItem('refreshToken');
         localStorage.removeItem('user');
+    },
+
+    // Logout do usuário (encerra a sessão também no servidor)
+    async logout() {
+        try {
+            await api.post('/logout');
+        } catch (error) {
+            console.error('Erro ao encerrar sessão:', error);
+        }
+        this.limparSessao();
         // Redirecionar para a página de login
         window.location.href = '/login';
     }
 };
 
+// Retomar a renovação automática ao recarregar a página
+authService.agendarRenovacao();
+
 export default api;
\ No newline at end of file